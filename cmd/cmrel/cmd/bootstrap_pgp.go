@@ -56,6 +56,10 @@ type bootstrapPGPOptions struct {
 	// projects/<PROJECT_NAME>/locations/<LOCATION>/keyRings/<KEYRING_NAME>/cryptoKeys/<KEY_NAME>/cryptoKeyVersions/<KEY_VERSION>
 	Key string
 
+	// PGPProfile is the path to a YAML file of key profiles, which set the PGP
+	// identity of each key; see sign.KeyProfiles
+	PGPProfile string
+
 	// The path to the cloudbuild.yaml file to be invoked
 	CloudBuildFile string
 
@@ -64,7 +68,8 @@ type bootstrapPGPOptions struct {
 }
 
 func (o *bootstrapPGPOptions) AddFlags(fs *flag.FlagSet, markRequired func(string)) {
-	fs.StringVar(&o.Key, "key", "", "Full name of the GCP KMS key to use for bootstrapping")
+	fs.StringVar(&o.Key, "key", "", "Full name of the GCP KMS key to use for bootstrapping. Multiple comma-separated keys can be given to create a keyring holding each key.")
+	fs.StringVar(&o.PGPProfile, "pgp-profile", "", pgpProfileUsage)
 	fs.StringVar(&o.CloudBuildFile, "cloudbuild", "./gcb/bootstrap-pgp/cloudbuild.yaml", "The path to the cloudbuild.yaml file to be invoked.")
 	fs.StringVar(&o.Project, "project", release.DefaultReleaseProject, "GCP project in which to run the GCB build job.")
 	markRequired("key")
@@ -73,6 +78,7 @@ func (o *bootstrapPGPOptions) AddFlags(fs *flag.FlagSet, markRequired func(strin
 func (o *bootstrapPGPOptions) print() {
 	log.Printf("bootstrap-pgp options:")
 	log.Printf("             Key: %q", o.Key)
+	log.Printf("      PGPProfile: %q", o.PGPProfile)
 	log.Printf("         Project: %q", o.Project)
 	log.Printf("  CloudBuildFile: %q", o.CloudBuildFile)
 }
@@ -109,7 +115,13 @@ func runBootstrapPGP(rootOpts *rootOptions, o *bootstrapPGPOptions) error {
 		return fmt.Errorf("error loading %q: %w", o.CloudBuildFile, err)
 	}
 
+	pgpProfile, err := encodePGPProfile(o.PGPProfile)
+	if err != nil {
+		return err
+	}
+
 	build.Substitutions["_KMS_KEY"] = o.Key
+	build.Substitutions["_PGP_PROFILE"] = pgpProfile
 
 	log.Printf("DEBUG: building google cloud build API client")
 
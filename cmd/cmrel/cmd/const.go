@@ -21,3 +21,12 @@ package cmd
 // WARNING: cosign requires a different format for the key; this is the format required by the GCP API but not cosign (which needs "versions" instead of "cryptoKeyVersions")
 // WARNING: This key is *manually* copied to hack/push_and_sign_chart.sh - if you update this key, you must also update that script.
 const defaultKMSKey = "projects/cert-manager-release/locations/europe-west1/keyRings/cert-manager-release/cryptoKeys/cert-manager-release-signing-key/cryptoKeyVersions/1"
+
+// signingKeyFormats describes the accepted formats for signing keys in flag usage; see sign.SigningKey
+const signingKeyFormats = "the full name of a GCP KMS key, or a gcpkms://, file:// or ephemeral:// URI"
+
+// multipleSigningKeys describes how to sign with multiple keys in flag usage; see sign.ParseSigningKeys
+const multipleSigningKeys = "Multiple comma-separated keys can be given while rotating keys, in which case everything is signed with every key."
+
+// pgpProfileUsage describes the --pgp-profile flag in flag usage; see sign.KeyProfiles
+const pgpProfileUsage = "Optional path to a YAML file of key profiles, setting the name, email, comment, creation time and expiry time of the PGP key derived from each signing key. Keys without a profile use the cert-manager identity."
@@ -0,0 +1,234 @@
+/*
+Copyright 2021 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	flag "github.com/spf13/pflag"
+
+	"github.com/cert-manager/release/pkg/release"
+	"github.com/cert-manager/release/pkg/release/validation"
+)
+
+const (
+	dryRunCommand         = "dry-run"
+	dryRunDescription     = "Rehearse publishing a release from locally built artifacts"
+	dryRunLongDescription = `The dry-run command takes a directory of locally built release tarballs and
+the metadata.json file describing them, unpacks and validates them exactly as
+'gcb publish' would, and prints everything that would be pushed, tagged, signed
+and uploaded.
+
+It never contacts GCS, a Docker daemon, GitHub or KMS, and so can be run on a
+laptop or an air-gapped machine before a release is staged.`
+)
+
+var dryRunExample = fmt.Sprintf(`To rehearse publishing the artifacts in ./_bin/release:
+
+	%s %s --artifacts-dir ./_bin/release`, rootCommand, dryRunCommand)
+
+type dryRunOptions struct {
+	// ArtifactsDir is the path to a directory holding a metadata.json file
+	// and the release artifacts it references.
+	ArtifactsDir string
+
+	// PublishedImageRepository is the image repository that images as part of
+	// releases would be pushed to.
+	PublishedImageRepository string
+
+	// ImageMirrors is the path to a YAML file listing image repositories which
+	// images and image indexes would also be pushed to; see registry.Mirrors
+	ImageMirrors string
+
+	// PublishedHelmChartGitHubOwner is the name of the owner of the GitHub repo
+	// for Helm charts.
+	PublishedHelmChartGitHubOwner string
+
+	// PublishedHelmChartGitHubRepo is the name of the GitHub repository for
+	// Helm charts.
+	PublishedHelmChartGitHubRepo string
+
+	// PublishedHelmChartGitHubBranch is the name of the main branch in the
+	// GitHub repository for Helm Charts.
+	PublishedHelmChartGitHubBranch string
+
+	// PublishedHelmChartOCIRepository is the OCI repository which Helm charts
+	// would be pushed to, e.g. oci://quay.io/jetstack/charts
+	PublishedHelmChartOCIRepository string
+
+	// helmChartRepoOptions selects the repository Helm charts are published
+	// to by the helmchartpr action
+	helmChartRepoOptions
+
+	// PublishedGitHubOrg is the org of the repository where the release would
+	// be published to.
+	PublishedGitHubOrg string
+
+	// PublishedGitHubRepo is the repo name in the provided org where the
+	// release would be published to.
+	PublishedGitHubRepo string
+
+	// SkipSigning, if true, will plan to skip signing images
+	SkipSigning bool
+
+	// SigningKMSKey is the full name of the GCP KMS key that would be used for
+	// signing. It is only parsed, never used.
+	SigningKMSKey string
+
+	// PushLatestImageTag, if true, plans to move the latest tag of each image
+	// with the pushfloatingimagetags action
+	PushLatestImageTag bool
+
+	// PublishActions list of publishing actions to plan
+	PublishActions []string
+}
+
+func (o *dryRunOptions) AddFlags(fs *flag.FlagSet, markRequired func(string)) {
+	fs.StringVar(&o.ArtifactsDir, "artifacts-dir", "", "Path to a directory containing release tarballs and the metadata.json file describing them.")
+	fs.StringVar(&o.PublishedImageRepository, "published-image-repo", release.DefaultImageRepository, "The docker image repository the release images & image indexes would be pushed to.")
+	fs.StringVar(&o.ImageMirrors, "image-mirrors", "", imageMirrorsUsage)
+	fs.StringVar(&o.PublishedHelmChartGitHubOwner, "published-helm-chart-github-owner", release.DefaultHelmChartGitHubOwner, "The name of the owner of the GitHub repo for Helm charts.")
+	fs.StringVar(&o.PublishedHelmChartGitHubRepo, "published-helm-chart-github-repo", release.DefaultHelmChartGitHubRepo, "The name of the GitHub repo for Helm charts.")
+	fs.StringVar(&o.PublishedHelmChartGitHubBranch, "published-helm-chart-github-branch", release.DefaultHelmChartGitHubBranch, "The name of the main branch in the GitHub repository for Helm charts.")
+	fs.StringVar(&o.PublishedHelmChartOCIRepository, "published-helm-chart-oci-repo", release.DefaultHelmChartOCIRepository, "The OCI repository Helm charts would be pushed to.")
+	o.helmChartRepoOptions.addFlags(fs)
+	fs.StringVar(&o.PublishedGitHubOrg, "published-github-org", release.DefaultGitHubOrg, "The org of the repository where the release would be published to.")
+	fs.StringVar(&o.PublishedGitHubRepo, "published-github-repo", release.DefaultGitHubRepo, "The repo name in the provided org where the release would be published to.")
+	fs.StringVar(&o.SigningKMSKey, "signing-kms-key", defaultKMSKey, "Key which would be used for signing; "+signingKeyFormats+". "+multipleSigningKeys)
+	fs.BoolVar(&o.SkipSigning, "skip-signing", false, "Plan to skip signing container images.")
+	fs.BoolVar(&o.PushLatestImageTag, "push-latest-image-tag", false, "Plan to move the 'latest' tag of each image with the pushfloatingimagetags action.")
+	fs.StringSliceVar(&o.PublishActions, "publish-actions", []string{"*"}, fmt.Sprintf("Comma-separated list of actions to plan, or '*' for everything. Actions can be removed with a prefix of '-'. %s", publishActionOptions()))
+	markRequired("artifacts-dir")
+}
+
+func (o *dryRunOptions) print() {
+	log.Printf("Dry run options:")
+	log.Printf("  ArtifactsDir: %q", o.ArtifactsDir)
+	log.Printf("  PublishedImageRepo: %q", o.PublishedImageRepository)
+	log.Printf("  ImageMirrors: %q", o.ImageMirrors)
+	log.Printf("  PublishedHelmChartGitHubRepo: %q", o.PublishedHelmChartGitHubRepo)
+	log.Printf("  PublishedHelmChartGitHubOwner: %q", o.PublishedHelmChartGitHubOwner)
+	log.Printf("  PublishedHelmChartGitHubBranch: %q", o.PublishedHelmChartGitHubBranch)
+	log.Printf("  PublishedHelmChartOCIRepo: %q", o.PublishedHelmChartOCIRepository)
+	o.helmChartRepoOptions.print()
+	log.Printf("  PublishedGitHubOrg: %q", o.PublishedGitHubOrg)
+	log.Printf("  PublishedGitHubRepo: %q", o.PublishedGitHubRepo)
+	log.Printf("  SkipSigning: %v", o.SkipSigning)
+	log.Printf("  SigningKMSKey: %q", o.SigningKMSKey)
+	log.Printf("  PushLatestImageTag: %t", o.PushLatestImageTag)
+	log.Printf("  PublishActions: %q", strings.Join(o.PublishActions, ","))
+}
+
+// publishOptions converts the dry run options into the equivalent options
+// for 'gcb publish', so that the same planning functions can be used.
+func (o *dryRunOptions) publishOptions() *gcbPublishOptions {
+	p := NewGCBPublishOptions()
+	p.PublishedImageRepository = o.PublishedImageRepository
+	p.ImageMirrors = o.ImageMirrors
+	p.PublishedHelmChartGitHubOwner = o.PublishedHelmChartGitHubOwner
+	p.PublishedHelmChartGitHubRepo = o.PublishedHelmChartGitHubRepo
+	p.PublishedHelmChartGitHubBranch = o.PublishedHelmChartGitHubBranch
+	p.PublishedHelmChartOCIRepository = o.PublishedHelmChartOCIRepository
+	p.helmChartRepoOptions = o.helmChartRepoOptions
+	p.PublishedGitHubOrg = o.PublishedGitHubOrg
+	p.PublishedGitHubRepo = o.PublishedGitHubRepo
+	p.SkipSigning = o.SkipSigning
+	p.SigningKMSKey = o.SigningKMSKey
+	p.PushLatestImageTag = o.PushLatestImageTag
+	p.PublishActions = o.PublishActions
+	return p
+}
+
+func dryRunCmd(rootOpts *rootOptions) *cobra.Command {
+	o := &dryRunOptions{}
+	cmd := &cobra.Command{
+		Use:          dryRunCommand,
+		Short:        dryRunDescription,
+		Long:         dryRunLongDescription,
+		Example:      dryRunExample,
+		SilenceUsage: true,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			o.print()
+			log.Printf("---")
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runDryRun(rootOpts, o)
+		},
+	}
+	o.AddFlags(cmd.Flags(), mustMarkRequired(cmd.MarkFlagRequired))
+	return cmd
+}
+
+func runDryRun(rootOpts *rootOptions, o *dryRunOptions) error {
+	ctx := context.Background()
+
+	plan, err := dryRun(ctx, o)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Dry run complete; the following would be published:")
+	return plan.print(os.Stdout)
+}
+
+// dryRun unpacks and validates the release in o.ArtifactsDir and returns
+// the plan for publishing it.
+func dryRun(ctx context.Context, o *dryRunOptions) (*publishPlan, error) {
+	staged, err := release.LoadStagedDirectory(ctx, o.ArtifactsDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load release from %q: %w", o.ArtifactsDir, err)
+	}
+
+	rel, err := release.Unpack(ctx, staged)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unpack release: %w", err)
+	}
+
+	validationOpts := validation.Options{
+		ReleaseVersion:  staged.Metadata().ReleaseVersion,
+		ImageRepository: o.PublishedImageRepository,
+	}
+	violations, err := validation.ValidateUnpackedRelease(validationOpts, rel)
+	if err != nil {
+		return nil, fmt.Errorf("failed to validate unpacked release: %w", err)
+	}
+	if len(violations) > 0 {
+		log.Printf("Release validation failed:")
+		for _, v := range violations {
+			log.Printf("  - %s", v)
+		}
+		return nil, fmt.Errorf("release failed validation - it would not be published")
+	}
+	log.Printf("Release validation succeeded!")
+
+	publishOpts := o.publishOptions()
+	mirrors, err := loadImageMirrors(publishOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkMirrorSigning(mirrors, o.SkipSigning); err != nil {
+		return nil, err
+	}
+
+	return buildPublishPlan(publishOpts, rel)
+}
@@ -0,0 +1,304 @@
+/*
+Copyright 2021 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+
+	"github.com/cert-manager/release/pkg/release"
+)
+
+func writeTarGz(t *testing.T, dest string, files map[string][]byte, gz bool) {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	seenDirs := map[string]bool{}
+	for name, content := range files {
+		var dirs []string
+		for dir := path.Dir(name); dir != "." && !seenDirs[dir]; dir = path.Dir(dir) {
+			seenDirs[dir] = true
+			dirs = append([]string{dir}, dirs...)
+		}
+		for _, dir := range dirs {
+			if err := tw.WriteHeader(&tar.Header{Name: dir + "/", Typeflag: tar.TypeDir, Mode: 0o755}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0o644, Size: int64(len(content))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	out := buf.Bytes()
+	if gz {
+		gzBuf := &bytes.Buffer{}
+		gw := gzip.NewWriter(gzBuf)
+		if _, err := gw.Write(out); err != nil {
+			t.Fatal(err)
+		}
+		if err := gw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		out = gzBuf.Bytes()
+	}
+
+	if err := os.WriteFile(dest, out, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// buildImageTar returns a 'docker save' tarball holding a random image whose
+// config declares the given platform.
+func buildImageTar(t *testing.T, rawImageName, osName, arch string) []byte {
+	t.Helper()
+	img, err := random.Image(256, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := img.ConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg = cfg.DeepCopy()
+	cfg.OS = osName
+	cfg.Architecture = arch
+	if arch == "arm" {
+		cfg.Variant = "v7"
+	}
+	img, err = mutate.ConfigFile(img, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tag, err := name.NewTag(rawImageName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := tarball.Write(tag, img, buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// writeTestReleaseDir writes a minimal set of locally built release
+// artifacts and their metadata.json to a new directory, returning its path.
+func writeTestReleaseDir(t *testing.T, version string, arches ...string) string {
+	t.Helper()
+	scratch := t.TempDir()
+	dir := filepath.Join(t.TempDir(), "release")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	chartPath := filepath.Join(scratch, "chart.tgz")
+	writeTarGz(t, chartPath, map[string][]byte{
+		"cert-manager/Chart.yaml": fmt.Appendf(nil, "name: cert-manager\nversion: %s\nappVersion: %s\n", version, version),
+	}, true)
+	chart, err := os.ReadFile(chartPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var artifacts []release.ArtifactMetadata
+	addArtifact := func(name, osName, arch string) {
+		sum, err := sha256SumFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		artifacts = append(artifacts, release.ArtifactMetadata{Name: name, SHA256: sum, OS: osName, Architecture: arch})
+	}
+
+	writeTarGz(t, filepath.Join(dir, "cert-manager-manifests.tar.gz"), map[string][]byte{
+		"deploy/chart/cert-manager-" + version + ".tgz": chart,
+		"deploy/manifests/cert-manager.yaml":            []byte("kind: List\n"),
+	}, true)
+	addArtifact("cert-manager-manifests.tar.gz", "", "")
+
+	for _, arch := range arches {
+		imageTar := buildImageTar(t, fmt.Sprintf("%s/cert-manager-controller-%s:%s", release.DefaultImageRepository, arch, version), "linux", arch)
+
+		name := fmt.Sprintf("cert-manager-server-linux-%s.tar.gz", arch)
+		writeTarGz(t, filepath.Join(dir, name), map[string][]byte{
+			"server/images/controller.tar": imageTar,
+		}, true)
+		addArtifact(name, "linux", arch)
+	}
+
+	meta, err := json.Marshal(release.Metadata{
+		ReleaseVersion: version,
+		GitCommitRef:   "0123456789abcdef0123456789abcdef01234567",
+		Artifacts:      artifacts,
+		BuildSource:    release.BuildSourceMake,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, release.MetadataFileName), meta, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	return dir
+}
+
+func TestDryRun(t *testing.T) {
+	dir := writeTestReleaseDir(t, "v1.15.0", "amd64", "arm")
+
+	o := &dryRunOptions{
+		ArtifactsDir:                    dir,
+		PublishedImageRepository:        release.DefaultImageRepository,
+		PublishedHelmChartGitHubOwner:   "jetstack",
+		PublishedHelmChartGitHubRepo:    "jetstack-charts",
+		PublishedHelmChartGitHubBranch:  "main",
+		PublishedHelmChartOCIRepository: release.DefaultHelmChartOCIRepository,
+		PublishedGitHubOrg:              "cert-manager",
+		PublishedGitHubRepo:             "cert-manager",
+		SigningKMSKey:                   defaultKMSKey,
+		PublishActions:                  []string{"*", "helmchartoci"},
+	}
+
+	plan, err := dryRun(context.TODO(), o)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(plan.Images) != 2 {
+		t.Errorf("expected 2 images to be planned, got %d", len(plan.Images))
+	}
+
+	if len(plan.ImageIndexes) != 1 || plan.ImageIndexes[0].Name != "quay.io/jetstack/cert-manager-controller:v1.15.0" {
+		t.Fatalf("unexpected image indexes: %#v", plan.ImageIndexes)
+	}
+
+	for _, e := range plan.ImageIndexes[0].Entries {
+		if e.Arch == "arm" && e.Variant != "v7" {
+			t.Errorf("expected arm image to have variant v7 from its config, got %q", e.Variant)
+		}
+	}
+
+	// 2 images + 1 image index
+	if len(plan.Signatures) != 3 {
+		t.Errorf("expected 3 signatures to be planned, got %v", plan.Signatures)
+	}
+
+	// the static manifest, an SPDX and CycloneDX SBOM for each image, and the checksums file
+	var assetNames []string
+	if plan.GitHubRelease != nil {
+		for _, asset := range plan.GitHubRelease.Assets {
+			assetNames = append(assetNames, asset.Name)
+		}
+	}
+	expectedAssetNames := []string{
+		"cert-manager.yaml",
+		"cert-manager-controller-linux-amd64.spdx.json",
+		"cert-manager-controller-linux-amd64.cdx.json",
+		"cert-manager-controller-linux-arm.spdx.json",
+		"cert-manager-controller-linux-arm.cdx.json",
+		checksumsFileName,
+	}
+	if !reflect.DeepEqual(assetNames, expectedAssetNames) {
+		t.Errorf("expected GitHub release assets %v, got %v", expectedAssetNames, assetNames)
+	}
+
+	if plan.GitHubRelease != nil && !reflect.DeepEqual(plan.GitHubRelease.SigningKeys, []string{defaultKMSKey}) {
+		t.Errorf("expected checksums and provenance to be signed with %q, got %q", defaultKMSKey, plan.GitHubRelease.SigningKeys)
+	}
+
+	if plan.GitHubRelease != nil && plan.GitHubRelease.Prerelease {
+		t.Errorf("expected %s not to be marked as a pre-release", plan.ReleaseVersion)
+	}
+
+	if plan.HelmChartPR == nil || !reflect.DeepEqual(plan.HelmChartPR.Files, []string{"charts/cert-manager-v1.15.0.tgz", "index.yaml"}) {
+		t.Errorf("unexpected Helm chart PR plan: %#v", plan.HelmChartPR)
+	}
+
+	if plan.HelmChartOCI == nil || len(plan.HelmChartOCI.Charts) != 1 || plan.HelmChartOCI.Charts[0].Reference != "quay.io/jetstack/charts/cert-manager:v1.15.0" {
+		t.Errorf("unexpected Helm chart OCI plan: %#v", plan.HelmChartOCI)
+	}
+
+	out := &strings.Builder{}
+	if err := plan.print(out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "quay.io/jetstack/cert-manager-controller-arm:v1.15.0") {
+		t.Errorf("expected printed plan to include arch-specific image tag, got:\n%s", out)
+	}
+}
+
+func TestDryRunRespectsPublishActions(t *testing.T) {
+	dir := writeTestReleaseDir(t, "v1.15.0", "amd64")
+
+	plan, err := dryRun(context.TODO(), &dryRunOptions{
+		ArtifactsDir:             dir,
+		PublishedImageRepository: release.DefaultImageRepository,
+		SkipSigning:              true,
+		PublishActions:           []string{"pushcontainerimages"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if plan.GitHubRelease != nil || plan.HelmChartPR != nil {
+		t.Errorf("expected only container images to be planned")
+	}
+
+	if len(plan.SigningKeys) != 0 || len(plan.Signatures) != 0 {
+		t.Errorf("expected signing to be skipped")
+	}
+}
+
+func TestDryRunFailsValidation(t *testing.T) {
+	dir := writeTestReleaseDir(t, "v1.15.0", "amd64")
+
+	// rewrite metadata.json with a version that doesn't match the artifacts
+	metaPath := filepath.Join(dir, release.MetadataFileName)
+	raw, err := os.ReadFile(metaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(metaPath, bytes.ReplaceAll(raw, []byte(`"releaseVersion":"v1.15.0"`), []byte(`"releaseVersion":"v1.15.1"`)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := dryRun(context.TODO(), &dryRunOptions{ArtifactsDir: dir, PublishActions: []string{"*"}, SkipSigning: true}); err == nil {
+		t.Errorf("expected dry run to fail validation")
+	}
+}
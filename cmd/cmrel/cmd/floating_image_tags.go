@@ -0,0 +1,312 @@
+/*
+Copyright 2021 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/blang/semver"
+
+	"github.com/cert-manager/release/pkg/release"
+	"github.com/cert-manager/release/pkg/release/publish/registry"
+)
+
+// latestImageTag is the floating tag of the highest GA release.
+const latestImageTag = "latest"
+
+// minorImageTag returns the floating tag of the highest GA patch release of
+// the minor version of v, e.g. v1.15.
+func minorImageTag(v semver.Version) string {
+	return fmt.Sprintf("v%d.%d", v.Major, v.Minor)
+}
+
+// floatingImageTagTarget returns the version in tags which the given floating
+// tag should point at: the highest GA release of its minor version, or the
+// highest GA release for the latest tag. It returns an empty string if no
+// version in tags matches.
+func floatingImageTagTarget(floatingTag string, tags []string) string {
+	var target string
+	var targetVersion semver.Version
+	for _, tag := range tags {
+		v, err := parseReleaseVersion(tag)
+		if err != nil || len(v.Pre) > 0 {
+			continue
+		}
+
+		if floatingTag != latestImageTag && minorImageTag(v) != floatingTag {
+			continue
+		}
+
+		if target == "" || v.GT(targetVersion) {
+			target, targetVersion = tag, v
+		}
+	}
+
+	return target
+}
+
+// floatingImageTags returns the floating tags which should point at the given
+// release version, given the tags already published in the registry: its
+// minor version tag if it's the highest patch release of its minor version,
+// and latest if includeLatest is set and it's the highest GA release.
+// Pre-releases never get floating tags.
+func floatingImageTags(version string, publishedTags []string, includeLatest bool) ([]string, error) {
+	v, err := parseReleaseVersion(version)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(v.Pre) > 0 {
+		return nil, nil
+	}
+
+	candidates := []string{minorImageTag(v)}
+	if includeLatest {
+		candidates = append(candidates, latestImageTag)
+	}
+
+	tags := append(slices.Clone(publishedTags), version)
+
+	var floating []string
+	for _, candidate := range candidates {
+		if floatingImageTagTarget(candidate, tags) == version {
+			floating = append(floating, candidate)
+		}
+	}
+
+	return floating, nil
+}
+
+// pushFloatingImageTags moves the floating tags of each component to the
+// image index pushed by pushContainerImages, if the release is the highest
+// patch release of its minor version, and signs them. The tags are moved in
+// the published image repository and in each of its mirrors, so that the
+// floating tags in every registry point at the same release.
+func pushFloatingImageTags(ctx context.Context, o *gcbPublishOptions, rel *release.Unpacked) error {
+	prerelease, err := isPrerelease(rel.ReleaseVersion)
+	if err != nil {
+		return err
+	}
+
+	if prerelease {
+		log.Printf("Not moving floating image tags to pre-release %q", rel.ReleaseVersion)
+		return nil
+	}
+
+	if o.SigningKMSKey == "" && !o.SkipSigning {
+		return fmt.Errorf("must set signing-kms-key or skip-signing in order to sign floating image tags")
+	}
+
+	dockerConfigDir, err := os.MkdirTemp("", "cmrel-image-mirrors-")
+	if err != nil {
+		return fmt.Errorf("failed to create directory for image mirror credentials: %w", err)
+	}
+	defer os.RemoveAll(dockerConfigDir)
+
+	registries, err := o.imageRegistries(dockerConfigDir)
+	if err != nil {
+		return err
+	}
+
+	components := make([]string, 0, len(rel.ComponentImageBundles))
+	for name := range rel.ComponentImageBundles {
+		components = append(components, name)
+	}
+	sort.Strings(components)
+
+	// which tags are moved is decided by the releases in the published image
+	// repository, since a mirror might not hold every earlier release
+	published := registries[0]
+	floating := map[string][]string{}
+	for _, name := range components {
+		indexName := buildImageIndexName(published.repository, name, rel.ReleaseVersion)
+		if _, err := publishedImageIndexDigest(ctx, o, published.publisher, indexName); err != nil {
+			return err
+		}
+
+		publishedTags, err := published.publisher.Tags(ctx, indexName)
+		if err != nil {
+			return err
+		}
+
+		tags, err := floatingImageTags(rel.ReleaseVersion, publishedTags, o.PushLatestImageTag)
+		if err != nil {
+			return err
+		}
+
+		if len(tags) == 0 {
+			log.Printf("Not moving floating tags to %q as a higher patch release has been published", indexName)
+			continue
+		}
+
+		floating[name] = tags
+	}
+
+	for _, r := range registries {
+		if err := pushFloatingImageTagsToRegistry(ctx, o, rel, r, components, floating); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// pushFloatingImageTagsToRegistry moves the given floating tags of each
+// component to the release's image index in a single image repository, and
+// signs them.
+func pushFloatingImageTagsToRegistry(ctx context.Context, o *gcbPublishOptions, rel *release.Unpacked, r imageRegistry, components []string, floating map[string][]string) error {
+	var tagged []registryContent
+	for _, name := range components {
+		if len(floating[name]) == 0 {
+			continue
+		}
+
+		indexName := buildImageIndexName(r.repository, name, rel.ReleaseVersion)
+		digest, err := publishedImageIndexDigest(ctx, o, r.publisher, indexName)
+		if err != nil {
+			return err
+		}
+
+		for _, tag := range floating[name] {
+			floatingName := buildImageIndexName(r.repository, name, tag)
+
+			published, err := alreadyPublished(ctx, o, r.publisher, release.LedgerEntryFloatingImageTag, floatingName, digest)
+			if err != nil {
+				return err
+			}
+
+			tagged = append(tagged, registryContent{ref: floatingName, digest: digest})
+
+			if published {
+				log.Printf("Skipping floating image tag %q which was moved by a previous run", floatingName)
+				continue
+			}
+
+			log.Printf("Moving floating image tag %q to %s", floatingName, digest)
+			if err := retry(ctx, func() error { return r.publisher.Tag(ctx, indexName, digest, tag) }); err != nil {
+				return err
+			}
+
+			if err := o.ledger.Record(ctx, release.LedgerEntry{Kind: release.LedgerEntryFloatingImageTag, Name: floatingName, Digest: digest}); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := signRegistryContent(ctx, o, r.signer, tagged); err != nil {
+		return fmt.Errorf("failed to sign floating image tags in %q: %w", r.repository, err)
+	}
+
+	return nil
+}
+
+// publishedImageIndexDigest returns the digest of the image index which the
+// publish ledger records as pushed to indexName, checking that the registry
+// still holds it.
+func publishedImageIndexDigest(ctx context.Context, o *gcbPublishOptions, publisher *registry.Publisher, indexName string) (string, error) {
+	entry, ok := o.ledger.Get(release.LedgerEntryImageIndex, indexName)
+	if !ok {
+		return "", fmt.Errorf("image index %q must be pushed by the pushcontainerimages action before floating tags can point at it", indexName)
+	}
+
+	remoteDigest, err := publisher.RemoteDigest(ctx, indexName)
+	if err != nil {
+		return "", err
+	}
+	if remoteDigest != entry.Digest {
+		return "", fmt.Errorf("image index %q has digest %q in the registry but %q was published - refusing to move floating tags to it", indexName, remoteDigest, entry.Digest)
+	}
+
+	return entry.Digest, nil
+}
+
+// restoreFloatingImageTags moves each floating image tag which the publish
+// ledger records as having been moved to the release back to the highest
+// remaining release it should point at, or deletes it if there isn't one.
+// Floating tags which have since been moved to another release are left alone.
+// Each tag is restored using the publisher of the registry it was moved in.
+func restoreFloatingImageTags(ctx context.Context, registries []imageRegistry, plan *publishPlan, ledger *release.Ledger) error {
+	repositories := make([]string, 0, len(registries))
+	for _, r := range registries {
+		repositories = append(repositories, r.repository)
+	}
+
+	for _, e := range ledger.Entries() {
+		if e.Kind != release.LedgerEntryFloatingImageTag {
+			continue
+		}
+
+		repository, ok := imageRepositoryOf(repositories, e.Name)
+		if !ok {
+			return fmt.Errorf("no credentials for the image repository of floating image tag %q", e.Name)
+		}
+		publisher := registries[slices.Index(repositories, repository)].publisher
+
+		remoteDigest, err := publisher.RemoteDigest(ctx, e.Name)
+		if err != nil {
+			return err
+		}
+
+		if remoteDigest == e.Digest {
+			if err := restoreFloatingImageTag(ctx, publisher, plan.ReleaseVersion, e.Name); err != nil {
+				return err
+			}
+		} else {
+			log.Printf("%q no longer points at the release, skipping", e.Name)
+		}
+
+		for _, kind := range []release.LedgerEntryKind{release.LedgerEntrySignature, release.LedgerEntryFloatingImageTag} {
+			if err := ledger.Remove(ctx, kind, e.Name); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func restoreFloatingImageTag(ctx context.Context, publisher *registry.Publisher, version, floatingName string) error {
+	i := strings.LastIndex(floatingName, ":")
+	repo, floatingTag := floatingName[:i], floatingName[i+1:]
+
+	tags, err := publisher.Tags(ctx, floatingName)
+	if err != nil {
+		return err
+	}
+
+	tags = slices.DeleteFunc(tags, func(tag string) bool { return tag == version })
+
+	target := floatingImageTagTarget(floatingTag, tags)
+	if target == "" {
+		log.Printf("Deleting %q as no other release matches it", floatingName)
+		return publisher.Delete(ctx, floatingName)
+	}
+
+	digest, err := publisher.RemoteDigest(ctx, repo+":"+target)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Moving %q back to %s (%s)", floatingName, target, digest)
+	return publisher.Tag(ctx, floatingName, digest, floatingTag)
+}
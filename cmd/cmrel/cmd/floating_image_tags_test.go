@@ -0,0 +1,239 @@
+/*
+Copyright 2021 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/cert-manager/release/internal/testregistry"
+	"github.com/cert-manager/release/pkg/release"
+	"github.com/cert-manager/release/pkg/release/publish/registry"
+)
+
+func TestFloatingImageTags(t *testing.T) {
+	published := []string{"v1.14.5", "v1.14", "v1.15.0", "v1.15.1", "v1.15", "v1.16.0-rc.1", "latest", "sha256-1234.sig"}
+
+	tests := map[string]struct {
+		version       string
+		tags          []string
+		includeLatest bool
+		expected      []string
+	}{
+		"highest patch release": {
+			version:       "v1.15.2",
+			tags:          published,
+			includeLatest: true,
+			expected:      []string{"v1.15", "latest"},
+		},
+		"latest is only moved if requested": {
+			version:  "v1.15.2",
+			tags:     published,
+			expected: []string{"v1.15"},
+		},
+		"already published": {
+			version:       "v1.15.1",
+			tags:          published,
+			includeLatest: true,
+			expected:      []string{"v1.15", "latest"},
+		},
+		"patch release of an older minor version": {
+			version:       "v1.14.6",
+			tags:          published,
+			includeLatest: true,
+			expected:      []string{"v1.14"},
+		},
+		"not the highest patch release": {
+			version:       "v1.15.0",
+			tags:          published,
+			includeLatest: true,
+		},
+		"higher than a pre-release": {
+			version:       "v1.16.0",
+			tags:          published,
+			includeLatest: true,
+			expected:      []string{"v1.16", "latest"},
+		},
+		"pre-release": {
+			version:       "v1.17.0-alpha.0",
+			tags:          published,
+			includeLatest: true,
+		},
+		"first release": {
+			version:       "v0.1.0",
+			includeLatest: true,
+			expected:      []string{"v0.1", "latest"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			floating, err := floatingImageTags(test.version, test.tags, test.includeLatest)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(floating, test.expected) {
+				t.Errorf("expected floating tags %v, got %v", test.expected, floating)
+			}
+		})
+	}
+}
+
+func TestPushFloatingImageTags(t *testing.T) {
+	ctx := context.TODO()
+	host := testregistry.New(t)
+	publisher := registry.NewPublisher()
+
+	o := NewGCBPublishOptions()
+	o.PublishedImageRepository = host + "/jetstack"
+	o.SkipSigning = true
+	o.PushLatestImageTag = true
+
+	// publish returns the image index digest of the given release and its ledger
+	publish := func(version string) (string, *release.Ledger) {
+		t.Helper()
+		rel, ledger := loadTestRelease(t, writeTestReleaseDir(t, version, "amd64"))
+		o.ledger = ledger
+		if err := pushContainerImages(ctx, o, rel); err != nil {
+			t.Fatal(err)
+		}
+		if err := pushFloatingImageTags(ctx, o, rel); err != nil {
+			t.Fatal(err)
+		}
+
+		entry, _ := ledger.Get(release.LedgerEntryImageIndex, buildImageIndexName(o.PublishedImageRepository, "controller", version))
+		return entry.Digest, ledger
+	}
+
+	expectTags := func(expected map[string]string) {
+		t.Helper()
+		for tag, digest := range expected {
+			remoteDigest, err := publisher.RemoteDigest(ctx, buildImageIndexName(o.PublishedImageRepository, "controller", tag))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if remoteDigest != digest {
+				t.Errorf("expected %s to point at %q, got %q", tag, digest, remoteDigest)
+			}
+		}
+	}
+
+	v1150, _ := publish("v1.15.0")
+	expectTags(map[string]string{"v1.15": v1150, "latest": v1150})
+
+	v1151, ledger := publish("v1.15.1")
+	expectTags(map[string]string{"v1.15": v1151, "latest": v1151})
+	if n := len(ledger.Entries()); n < 2 || ledger.Entries()[n-1].Kind != release.LedgerEntryFloatingImageTag {
+		t.Errorf("expected floating tags to be recorded in the ledger, got %#v", ledger.Entries())
+	}
+
+	// older and pre-releases don't take the floating tags
+	v1146, _ := publish("v1.14.6")
+	publish("v1.16.0-rc.0")
+	expectTags(map[string]string{"v1.14": v1146, "v1.15": v1151, "latest": v1151, "v1.16": ""})
+
+	// unpublishing v1.15.1 moves its floating tags back to v1.15.0
+	plan := &publishPlan{ReleaseVersion: "v1.15.1"}
+	if err := restoreFloatingImageTags(ctx, []imageRegistry{{repository: o.PublishedImageRepository, publisher: publisher}}, plan, ledger); err != nil {
+		t.Fatal(err)
+	}
+	if err := publisher.Delete(ctx, buildImageIndexName(o.PublishedImageRepository, "controller", "v1.15.1")); err != nil {
+		t.Fatal(err)
+	}
+	expectTags(map[string]string{"v1.15": v1150, "latest": v1150})
+
+	for _, e := range ledger.Entries() {
+		if e.Kind == release.LedgerEntryFloatingImageTag {
+			t.Errorf("expected floating tags to be removed from the ledger, got %#v", e)
+		}
+	}
+}
+
+func TestPushFloatingImageTagsToMirrors(t *testing.T) {
+	ctx := context.TODO()
+	published := testregistry.New(t) + "/jetstack"
+	mirror := testregistry.New(t) + "/cert-manager"
+	publisher := registry.NewPublisher()
+
+	mirrorsPath := filepath.Join(t.TempDir(), "mirrors.yaml")
+	if err := os.WriteFile(mirrorsPath, []byte("mirrors:\n- repository: "+mirror+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	o := NewGCBPublishOptions()
+	o.PublishedImageRepository = published
+	o.ImageMirrors = mirrorsPath
+	o.SkipSigning = true
+
+	publish := func(version string) (string, *release.Ledger) {
+		t.Helper()
+		rel, ledger := loadTestRelease(t, writeTestReleaseDir(t, version, "amd64"))
+		o.ledger = ledger
+		if err := pushContainerImages(ctx, o, rel); err != nil {
+			t.Fatal(err)
+		}
+		if err := pushFloatingImageTags(ctx, o, rel); err != nil {
+			t.Fatal(err)
+		}
+
+		entry, _ := ledger.Get(release.LedgerEntryImageIndex, buildImageIndexName(published, "controller", version))
+		return entry.Digest, ledger
+	}
+
+	expectTag := func(repository, digest string) {
+		t.Helper()
+		ref := buildImageIndexName(repository, "controller", "v1.15")
+		remoteDigest, err := publisher.RemoteDigest(ctx, ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if remoteDigest != digest {
+			t.Errorf("expected %q to point at %q, got %q", ref, digest, remoteDigest)
+		}
+	}
+
+	v1150, _ := publish("v1.15.0")
+	v1151, ledger := publish("v1.15.1")
+	expectTag(published, v1151)
+	expectTag(mirror, v1151)
+
+	if _, ok := ledger.Get(release.LedgerEntryFloatingImageTag, buildImageIndexName(mirror, "controller", "v1.15")); !ok {
+		t.Errorf("expected the mirrored floating tag to be recorded in the ledger")
+	}
+
+	// unpublishing v1.15.1 moves its floating tags back to v1.15.0 in every
+	// registry
+	dockerConfigDir := t.TempDir()
+	registries, err := o.imageRegistries(dockerConfigDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := restoreFloatingImageTags(ctx, registries, &publishPlan{ReleaseVersion: "v1.15.1"}, ledger); err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range registries {
+		if err := r.publisher.Delete(ctx, buildImageIndexName(r.repository, "controller", "v1.15.1")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expectTag(published, v1150)
+	expectTag(mirror, v1150)
+}
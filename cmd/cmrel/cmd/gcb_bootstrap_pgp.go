@@ -18,14 +18,13 @@ package cmd
 
 import (
 	"context"
+	"crypto/x509"
+	"encoding/pem"
 	"fmt"
 	"log"
 
 	"github.com/spf13/cobra"
 	flag "github.com/spf13/pflag"
-	"golang.org/x/oauth2/google"
-	"google.golang.org/api/cloudkms/v1"
-	"google.golang.org/api/option"
 
 	"github.com/cert-manager/release/pkg/sign"
 )
@@ -43,6 +42,10 @@ The public key is written to stdout in "armored" format.
 
 The raw PEM-encoded public key is also written to stdout.
 
+If multiple comma-separated keys are given, such as while rotating keys, a
+keyring holding the PGP public key for every key is written instead, followed
+by the PEM-encoded public key of each key.
+
 This is the internal version of the 'bootstrap-pgp' target. It is intended to be run by
 a Google Cloud Build started via the 'bootstrap-pgp' sub-command.`
 )
@@ -54,17 +57,25 @@ var gcbBootstrapPGPExample = fmt.Sprintf(`To create a public PGP identity for a
 type gcbBootstrapPGPOptions struct {
 	// Key is the full name of the GCP KMS key to be used, e.g.
 	// projects/<PROJECT_NAME>/locations/<LOCATION>/keyRings/<KEYRING_NAME>/cryptoKeys/<KEY_NAME>/cryptoKeyVersions/<KEY_VERSION>
+	// Other keys, such as local files, can be given as URIs; see sign.SigningKey.
+	// Multiple comma-separated keys can be given to create a keyring.
 	Key string
+
+	// PGPProfile is the path to a YAML file of key profiles, which set the PGP
+	// identity of each key; see sign.KeyProfiles
+	PGPProfile string
 }
 
 func (o *gcbBootstrapPGPOptions) AddFlags(fs *flag.FlagSet, markRequired func(string)) {
-	fs.StringVar(&o.Key, "key", "", "Full name of the GCP KMS key to use for bootstrapping")
+	fs.StringVar(&o.Key, "key", "", "Key to use for bootstrapping; "+signingKeyFormats+". Multiple comma-separated keys can be given to create a keyring holding each key.")
+	fs.StringVar(&o.PGPProfile, "pgp-profile", "", pgpProfileUsage)
 	markRequired("key")
 }
 
 func (o *gcbBootstrapPGPOptions) print() {
 	log.Printf("bootstrap-pgp options:")
 	log.Printf("         Key: %q", o.Key)
+	log.Printf("  PGPProfile: %q", o.PGPProfile)
 }
 
 func gcbBootstrapPGPCmd(rootOpts *rootOptions) *cobra.Command {
@@ -89,46 +100,44 @@ func gcbBootstrapPGPCmd(rootOpts *rootOptions) *cobra.Command {
 	return cmd
 }
 
-func getPEMPubkey(ctx context.Context, key sign.GCPKMSKey) (string, error) {
-	oauthClient, err := google.DefaultClient(ctx, cloudkms.CloudPlatformScope)
+// getPEMPubkey returns the public half of the given signer in PEM-encoded PKIX format
+func getPEMPubkey(signer sign.Signer) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(signer.Public())
 	if err != nil {
-		return "", fmt.Errorf("could not create GCP OAuth2 client: %w", err)
+		return "", fmt.Errorf("could not marshal public key: %w", err)
 	}
 
-	svc, err := cloudkms.NewService(ctx, option.WithHTTPClient(oauthClient))
-	if err != nil {
-		return "", fmt.Errorf("could not create GCP KMS client: %w", err)
-	}
-
-	res, err := svc.Projects.Locations.KeyRings.CryptoKeys.CryptoKeyVersions.GetPublicKey(key.GCPFormat()).Do()
-	if err != nil {
-		return "", fmt.Errorf("could not get public key from Google Cloud KMS API: %w", err)
-	}
-
-	return res.Pem, nil
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})), nil
 }
 
 func runGCBBootstrapPGP(rootOpts *rootOptions, o *gcbBootstrapPGPOptions) error {
 	ctx := context.Background()
 
-	parsedKey, err := sign.NewGCPKMSKey(o.Key)
+	parsedKeys, err := loadSigningKeys(o.Key, o.PGPProfile)
 	if err != nil {
 		return err
 	}
 
-	armoredKey, err := sign.BootstrapPGPFromGCP(ctx, parsedKey)
+	signers, err := sign.NewSigners(ctx, parsedKeys)
 	if err != nil {
-		return fmt.Errorf("failed to bootstrap PGP identity using %q: %w", o.Key, err)
+		return err
 	}
 
-	pemPubkey, err := getPEMPubkey(ctx, parsedKey)
+	armoredKey, err := sign.Keyring(signers)
 	if err != nil {
-		return fmt.Errorf("failed to get pubkey for %q: %w", o.Key, err)
+		return fmt.Errorf("failed to bootstrap PGP identity using %q: %w", o.Key, err)
 	}
 
 	fmt.Printf("armored signed PGP public identity:\n%s\n", armoredKey)
 
-	fmt.Printf("PEM formatted raw public key:\n%s\n", pemPubkey)
+	for _, signer := range signers {
+		pemPubkey, err := getPEMPubkey(signer)
+		if err != nil {
+			return fmt.Errorf("failed to get pubkey for %q: %w", signer, err)
+		}
+
+		fmt.Printf("PEM formatted raw public key for %s:\n%s\n", signer, pemPubkey)
+	}
 
 	return nil
 }
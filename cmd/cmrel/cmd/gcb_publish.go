@@ -23,12 +23,14 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
 	"time"
 
-	"cloud.google.com/go/storage"
 	"github.com/cenkalti/backoff/v5"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/google/go-github/v35/github"
 	"github.com/spf13/cobra"
 	flag "github.com/spf13/pflag"
@@ -37,7 +39,6 @@ import (
 	"k8s.io/utils/ptr"
 
 	"github.com/cert-manager/release/pkg/release"
-	"github.com/cert-manager/release/pkg/release/docker"
 	"github.com/cert-manager/release/pkg/release/helm"
 	"github.com/cert-manager/release/pkg/release/publish/registry"
 	"github.com/cert-manager/release/pkg/release/validation"
@@ -53,7 +54,15 @@ The 'gcb publish' subcommand will fetch a staged release from GCS, verify its
 integrity and publish artifacts to public-facing artifact repositories (e.g.
 Quay.io, GitHub releases and the Helm chart repostory).
 
-It requires Docker to be installed and available.
+Images are pushed directly to the registry, using credentials from the Docker
+config file if present. A Docker daemon is not required. Images can also be
+mirrored to other registries, each with its own credentials and signing keys,
+using --image-mirrors.
+
+Each completed step is recorded in a publish ledger stored alongside the
+staged release's metadata.json. If publishing fails part way through, running
+the command again resumes from where it stopped, after verifying that content
+which was already published is unchanged.
 
 The GitHub token to use to create the draft release should be set using the
 GITHUB_TOKEN environment variable.
@@ -75,9 +84,13 @@ type gcbPublishOptions struct {
 
 	// PublishedImageRepository is the image repository that images as part of
 	// releases should be pushed to.
-	// It is used as the repository for manifest lists created for artifacts.
+	// It is used as the repository for image indexes created for artifacts.
 	PublishedImageRepository string
 
+	// ImageMirrors is the path to a YAML file listing image repositories which
+	// images and image indexes are also pushed to; see registry.Mirrors
+	ImageMirrors string
+
 	// PublishedHelmChartGitHubOwner is the name of the owner of the GitHub repo
 	// for Helm charts.
 	PublishedHelmChartGitHubOwner string
@@ -90,6 +103,14 @@ type gcbPublishOptions struct {
 	// GitHub repository for Helm Charts.
 	PublishedHelmChartGitHubBranch string
 
+	// PublishedHelmChartOCIRepository is the OCI repository which Helm charts
+	// are pushed to, e.g. oci://quay.io/jetstack/charts
+	PublishedHelmChartOCIRepository string
+
+	// helmChartRepoOptions selects the repository Helm charts are published
+	// to by the helmchartpr action
+	helmChartRepoOptions
+
 	// PublishedGitHubOrg is the org of the repository where the release will
 	// be published to.
 	PublishedGitHubOrg string
@@ -103,21 +124,61 @@ type gcbPublishOptions struct {
 
 	// SigningKMSKey is the full name of the GCP KMS key to be used for signing, e.g.
 	// projects/<PROJECT_NAME>/locations/<LOCATION>/keyRings/<KEYRING_NAME>/cryptoKeys/<KEY_NAME>/versions/<KEY_VERSION>
+	// Other keys, such as local files, can be given as URIs; see sign.SigningKey.
 	// This must be set if SkipSigning is not set to true
 	SigningKMSKey string
 
+	// PGPProfile is the path to a YAML file of key profiles, which set the PGP
+	// identity of each signing key; see sign.KeyProfiles
+	PGPProfile string
+
 	// PublishActions list of publishing actions to take
 	PublishActions []string
 
 	// CosignPath points to the location of the cosign binary
 	CosignPath string
 
+	// RegistryRateLimit is the maximum number of requests per second made to
+	// each image registry host, or 0 for no limit
+	RegistryRateLimit float64
+
+	// RegistryHostRateLimits are <host>=<requests per second> limits which
+	// override RegistryRateLimit for specific registry hosts
+	RegistryHostRateLimits []string
+
+	// PushLatestImageTag, if true, makes the pushfloatingimagetags action
+	// also move the latest tag of each image to the release, if it's the
+	// highest GA release.
+	PushLatestImageTag bool
+
+	// UndraftGitHubRelease, if true, publishes the draft GitHub release once
+	// every publish action has succeeded, instead of leaving it to be
+	// published by hand.
+	UndraftGitHubRelease bool
+
+	// ReportFile is the path to write a JSON report of everything which was
+	// published to. If empty, no report file is written.
+	ReportFile string
+
+	// ReportBucket is the name of a GCS bucket (or a local directory prefixed
+	// with 'file://') to upload the JSON report to. If empty, the report is
+	// not uploaded.
+	ReportBucket string
+
 	// manualActionLogger logs to a buffer and is used by publish actions to log any manual
 	// actions that must be taken by the user even after a successful publish is completed.
 	// Get the log contents with ManualActionText()
 	manualActionLogger *log.Logger
 
 	manualActionBuffer bytes.Buffer
+
+	// ledger records each publishing step as it completes, so that a failed
+	// publish can be resumed. It is loaded from the staged release before
+	// any publish actions are run.
+	ledger *release.Ledger
+
+	// rateLimiter limits requests to image registries; nil means no limit
+	rateLimiter *registry.RateLimiter
 }
 
 // NewGCBPublishOptions creates options and initializes loggers correctly
@@ -157,6 +218,12 @@ func (o *gcbPublishOptions) PublishActionList() ([]publishAction, error) {
 }
 
 func (o *gcbPublishOptions) GitHubClient(ctx context.Context) (*github.Client, error) {
+	return newGitHubClient(ctx)
+}
+
+// newGitHubClient constructs a GitHub API client using the token in the
+// GITHUB_TOKEN environment variable.
+func newGitHubClient(ctx context.Context) (*github.Client, error) {
 	// construct the GitHub API client
 	// The GITHUB_TOKEN must be a GitHub personal access token with at least
 	// `repo` privileges and the associated user must have permission to create
@@ -176,19 +243,29 @@ func (o *gcbPublishOptions) GitHubClient(ctx context.Context) (*github.Client, e
 }
 
 func (o *gcbPublishOptions) AddFlags(fs *flag.FlagSet, markRequired func(string)) {
-	fs.StringVar(&o.Bucket, "bucket", release.DefaultBucketName, "The name of the GCS bucket to stage the release to.")
+	fs.StringVar(&o.Bucket, "bucket", release.DefaultBucketName, "The name of the GCS bucket to stage the release to, or a local directory prefixed with 'file://'.")
 	fs.StringVar(&o.ReleaseName, "release-name", "", "Name of the staged release to publish.")
 	fs.BoolVar(&o.NoMock, "nomock", false, "Whether to actually publish the release. If false, the command will exit after preparing the release for pushing.")
-	fs.StringVar(&o.PublishedImageRepository, "published-image-repo", release.DefaultImageRepository, "The docker image repository to push the release images & manifest lists to.")
+	fs.StringVar(&o.PublishedImageRepository, "published-image-repo", release.DefaultImageRepository, "The docker image repository to push the release images & image indexes to.")
+	fs.StringVar(&o.ImageMirrors, "image-mirrors", "", imageMirrorsUsage)
 	fs.StringVar(&o.PublishedHelmChartGitHubOwner, "published-helm-chart-github-owner", release.DefaultHelmChartGitHubOwner, "The name of the owner of the GitHub repo for Helm charts.")
 	fs.StringVar(&o.PublishedHelmChartGitHubRepo, "published-helm-chart-github-repo", release.DefaultHelmChartGitHubRepo, "The name of the GitHub repo for Helm charts.")
 	fs.StringVar(&o.PublishedHelmChartGitHubBranch, "published-helm-chart-github-branch", release.DefaultHelmChartGitHubBranch, "The name of the main branch in the GitHub repository for Helm charts.")
+	fs.StringVar(&o.PublishedHelmChartOCIRepository, "published-helm-chart-oci-repo", release.DefaultHelmChartOCIRepository, "The OCI repository to push Helm charts to. Each chart is pushed to a repository named after the chart within it.")
+	o.helmChartRepoOptions.addFlags(fs)
 	fs.StringVar(&o.PublishedGitHubOrg, "published-github-org", release.DefaultGitHubOrg, "The org of the repository where the release wil be published to.")
 	fs.StringVar(&o.PublishedGitHubRepo, "published-github-repo", release.DefaultGitHubRepo, "The repo name in the provided org where the release will be published to.")
 	fs.StringVar(&o.CosignPath, "cosign-path", "cosign", "Full path to the cosign binary. Defaults to searching in $PATH for a binary called 'cosign'")
-	fs.StringVar(&o.SigningKMSKey, "signing-kms-key", defaultKMSKey, "Full name of the GCP KMS key to use for signing.")
-	fs.BoolVar(&o.SkipSigning, "skip-signing", false, "Skip signing container images.")
-	fs.StringSliceVar(&o.PublishActions, "publish-actions", []string{"*"}, fmt.Sprintf("Comma-separated list of actions to take, or '*' to do everything. Only meaningful if nomock is set. Operations are done in alphabetical order. Actions can be removed with a prefix of '-'. Options: %s", strings.Join(allPublishActionNames(), ", ")))
+	fs.Float64Var(&o.RegistryRateLimit, "registry-rate-limit", defaultRegistryRateLimit, registryRateLimitUsage)
+	fs.StringSliceVar(&o.RegistryHostRateLimits, "registry-host-rate-limits", nil, registryHostRateLimitsUsage)
+	fs.StringVar(&o.SigningKMSKey, "signing-kms-key", defaultKMSKey, "Key to use for signing; "+signingKeyFormats+". Container images can only be signed with GCP KMS keys. "+multipleSigningKeys)
+	fs.StringVar(&o.PGPProfile, "pgp-profile", "", pgpProfileUsage)
+	fs.BoolVar(&o.SkipSigning, "skip-signing", false, "Skip signing container images, the GitHub release checksums file and provenance.")
+	fs.StringSliceVar(&o.PublishActions, "publish-actions", []string{"*"}, fmt.Sprintf("Comma-separated list of actions to take, or '*' to do everything. Only meaningful if nomock is set. Operations are done in alphabetical order. Actions can be removed with a prefix of '-'. %s", publishActionOptions()))
+	fs.BoolVar(&o.PushLatestImageTag, "push-latest-image-tag", false, "Make the pushfloatingimagetags action also move the 'latest' tag of each image to the release, if it's the highest GA release.")
+	fs.BoolVar(&o.UndraftGitHubRelease, "undraft-github-release", false, "Publish the draft GitHub release once all publish actions have succeeded, instead of leaving it to be reviewed and published by hand.")
+	fs.StringVar(&o.ReportFile, "report-file", "", "Path to write a JSON report of everything which was published to.")
+	fs.StringVar(&o.ReportBucket, "report-bucket", "", fmt.Sprintf("The name of a GCS bucket, or a local directory prefixed with 'file://', to upload the JSON report of everything which was published to. The report is stored at %q.", publishReportObjectName("<release-name>")))
 }
 
 func (o *gcbPublishOptions) print() {
@@ -197,15 +274,25 @@ func (o *gcbPublishOptions) print() {
 	log.Printf("  ReleaseName: %q", o.ReleaseName)
 	log.Printf("  NoMock: %t", o.NoMock)
 	log.Printf("  PublishedImageRepo: %q", o.PublishedImageRepository)
+	log.Printf("  ImageMirrors: %q", o.ImageMirrors)
 	log.Printf("  PublishedHelmChartGitHubRepo: %q", o.PublishedHelmChartGitHubRepo)
 	log.Printf("  PublishedHelmChartGitHubOwner: %q", o.PublishedHelmChartGitHubOwner)
 	log.Printf("  PublishedHelmChartGitHubBranch: %q", o.PublishedHelmChartGitHubBranch)
+	log.Printf("  PublishedHelmChartOCIRepo: %q", o.PublishedHelmChartOCIRepository)
+	o.helmChartRepoOptions.print()
 	log.Printf("  PublishedGitHubOrg: %q", o.PublishedGitHubOrg)
 	log.Printf("  PublishedGitHubRepo: %q", o.PublishedGitHubRepo)
 	log.Printf("  CosignPath: %q", o.CosignPath)
+	log.Printf("  RegistryRateLimit: %v", o.RegistryRateLimit)
+	log.Printf("  RegistryHostRateLimits: %q", strings.Join(o.RegistryHostRateLimits, ","))
 	log.Printf("  SkipSigning: %v", o.SkipSigning)
 	log.Printf("  SigningKMSKey: %q", o.SigningKMSKey)
+	log.Printf("  PGPProfile: %q", o.PGPProfile)
 	log.Printf("  PublishActions: %q", strings.Join(o.PublishActions, ","))
+	log.Printf("  PushLatestImageTag: %t", o.PushLatestImageTag)
+	log.Printf("  UndraftGitHubRelease: %t", o.UndraftGitHubRelease)
+	log.Printf("  ReportFile: %q", o.ReportFile)
+	log.Printf("  ReportBucket: %q", o.ReportBucket)
 }
 
 func allPublishActionNames() []string {
@@ -220,9 +307,15 @@ func allPublishActionNames() []string {
 	return names
 }
 
+// publishActionOptions describes the publish actions in flag usage.
+func publishActionOptions() string {
+	return fmt.Sprintf("Options: %s. Opt-in actions, which aren't included in '*': %s", strings.Join(allPublishActionNames(), ", "), strings.Join(optInPublishActions.List(), ", "))
+}
+
 // canonicalizeAndVerifyPublishActions converts a list of raw actions into
 // a slice of canonical action names (whitespace removed, lowercased), returning an error
 // if any of the actions don't correspond to known actions. Supports removing actions via a prefix of "-"
+// "*" selects every action except opt-in actions.
 // Actions are returned in alphabetical order
 func canonicalizeAndVerifyPublishActions(rawActions []string) ([]string, error) {
 	actions := sets.NewString()
@@ -235,7 +328,7 @@ func canonicalizeAndVerifyPublishActions(rawActions []string) ([]string, error)
 		}
 
 		if action == "*" {
-			actions = actions.Insert(allPublishActionNames()...)
+			actions = actions.Insert(sets.NewString(allPublishActionNames()...).Difference(optInPublishActions).List()...)
 			continue
 		}
 
@@ -255,11 +348,16 @@ func canonicalizeAndVerifyPublishActions(rawActions []string) ([]string, error)
 }
 
 var publishActionMap map[string]publishAction = map[string]publishAction{
-	"helmchartpr":         pushHelmChartPR,
-	"githubrelease":       pushGitHubRelease,
-	"pushcontainerimages": pushContainerImages,
+	"helmchartpr":           pushHelmChartPR,
+	"helmchartoci":          pushHelmChartOCI,
+	"githubrelease":         pushGitHubRelease,
+	"pushcontainerimages":   pushContainerImages,
+	"pushfloatingimagetags": pushFloatingImageTags,
 }
 
+// optInPublishActions are only taken if they're listed explicitly.
+var optInPublishActions = sets.NewString("helmchartoci", "pushfloatingimagetags")
+
 func gcbPublishCmd(rootOpts *rootOptions) *cobra.Command {
 	o := NewGCBPublishOptions()
 
@@ -284,7 +382,7 @@ func runGCBPublish(rootOpts *rootOptions, o *gcbPublishOptions) error {
 	ctx := context.Background()
 
 	if o.SigningKMSKey != "" {
-		if _, err := sign.NewGCPKMSKey(o.SigningKMSKey); err != nil {
+		if _, err := loadSigningKeys(o.SigningKMSKey, o.PGPProfile); err != nil {
 			return err
 		}
 
@@ -294,13 +392,27 @@ func runGCBPublish(rootOpts *rootOptions, o *gcbPublishOptions) error {
 		}
 	}
 
-	// fetch the staged release from GCS
-	gcs, err := storage.NewClient(ctx)
+	mirrors, err := loadImageMirrors(o)
 	if err != nil {
-		return fmt.Errorf("failed to create GCS client: %w", err)
+		return err
 	}
 
-	bucket := release.NewBucket(gcs.Bucket(o.Bucket), release.DefaultBucketPathPrefix, release.BuildTypeRelease)
+	if err := checkMirrorSigning(mirrors, o.SkipSigning); err != nil {
+		return err
+	}
+
+	o.rateLimiter, err = newRegistryRateLimiter(o.RegistryRateLimit, o.RegistryHostRateLimits)
+	if err != nil {
+		return err
+	}
+
+	// fetch the staged release from GCS (or a local artifact store)
+	store, err := release.OpenArtifactStore(ctx, o.Bucket)
+	if err != nil {
+		return err
+	}
+
+	bucket := release.NewBucket(store, release.DefaultBucketPathPrefix, release.BuildTypeRelease)
 
 	staged, err := bucket.GetRelease(ctx, o.ReleaseName)
 	if err != nil {
@@ -332,22 +444,28 @@ func runGCBPublish(rootOpts *rootOptions, o *gcbPublishOptions) error {
 	}
 	log.Printf("Release validation succeeded!")
 
-	for name, tars := range rel.ComponentImageBundles {
-		log.Printf("Loading release images for component %q into local docker daemon...", name)
-		for _, t := range tars {
-			if err := docker.Load(ctx, t.Filepath()); err != nil {
-				return err
-			}
-		}
-	}
-
 	if !o.NoMock {
 		log.Printf("--nomock flag set to false, skipping actually publishing the release")
-		return nil
+
+		plan, err := buildPublishPlan(o, rel)
+		if err != nil {
+			return fmt.Errorf("failed to plan publishing release: %w", err)
+		}
+
+		log.Printf("The following would be published:")
+		return plan.print(log.Writer())
 	}
 
 	log.Printf("!!! Publishing release artifacts to public repositories !!!")
 
+	o.ledger, err = staged.Ledger(ctx)
+	if err != nil {
+		return err
+	}
+	if n := len(o.ledger.Entries()); n > 0 {
+		log.Printf("Resuming publish: %d step(s) were completed by a previous run according to %q", n, o.ledger.ObjectName())
+	}
+
 	// TODO: perform check to ensure we have permission to create releases
 
 	publishFuncs, err := o.PublishActionList()
@@ -355,14 +473,36 @@ func runGCBPublish(rootOpts *rootOptions, o *gcbPublishOptions) error {
 		return fmt.Errorf("failed to parse published artifacts list: %w", err)
 	}
 
+	var publishErr error
 	for _, publishFunc := range publishFuncs {
-		err = publishFunc(ctx, o, rel)
+		publishErr = publishFunc(ctx, o, rel)
 
-		if err != nil {
-			return errorDuringPublish(err)
+		if publishErr != nil {
+			break
+		}
+	}
+
+	if publishErr == nil && o.UndraftGitHubRelease {
+		publishErr = undraftGitHubRelease(ctx, o, rel)
+	}
+
+	// always write a report, so that a partially published release can be
+	// inspected
+	report, err := buildPublishReport(rel, o.ledger, o.PublishedImageRepository, mirrors.Repositories(), publishErr == nil)
+	if err == nil {
+		err = writePublishReport(ctx, o, report)
+	}
+	if err != nil {
+		log.Printf("Failed to write publish report: %v", err)
+		if publishErr == nil {
+			return err
 		}
 	}
 
+	if publishErr != nil {
+		return errorDuringPublish(publishErr)
+	}
+
 	log.Println()
 	log.Printf("+++++++++ Publishing release completed successfully! +++++++++")
 	log.Printf("You MUST now perform the following manual tasks:\n%s", o.ManualActionText())
@@ -371,24 +511,19 @@ func runGCBPublish(rootOpts *rootOptions, o *gcbPublishOptions) error {
 }
 
 func pushHelmChartPR(ctx context.Context, o *gcbPublishOptions, rel *release.Unpacked) error {
-	githubClient, err := o.GitHubClient(ctx)
+	helmRepo, err := newHelmChartRepositoryManager(ctx, o)
 	if err != nil {
-		return fmt.Errorf("failed to create github client for pushing helm chart PR: %w", err)
+		return err
 	}
 
-	helmRepo := helm.NewGitHubRepositoryManager(
-		&helm.GitHubClient{
-			GitClient:          githubClient.Git,
-			PullRequestClient:  githubClient.PullRequests,
-			RepositoriesClient: githubClient.Repositories,
-			UsersClient:        githubClient.Users,
-		},
-		o.PublishedHelmChartGitHubOwner,
-		o.PublishedHelmChartGitHubRepo,
-		o.PublishedHelmChartGitHubBranch,
-	)
 	if err := helmRepo.Check(ctx); err != nil {
-		return fmt.Errorf("error in preflight checks for Helm GitHub repository: %v", err)
+		return fmt.Errorf("error in preflight checks for Helm chart repository: %v", err)
+	}
+
+	if entry, ok := o.ledger.Get(release.LedgerEntryHelmChartPR, rel.ReleaseName); ok {
+		log.Printf("Skipping pushing Helm chart(s); a PR was created by a previous run: %s", entry.URL)
+		o.manualActionLogger.Printf("Review and merge the PR containing the Helm charts: %s", entry.URL)
+		return nil
 	}
 
 	log.Printf("Pushing Helm chart(s)")
@@ -398,7 +533,67 @@ func pushHelmChartPR(ctx context.Context, o *gcbPublishOptions, rel *release.Unp
 		return err
 	}
 
-	o.manualActionLogger.Printf("Review and merge the GitHub PR containing the Helm charts: %s", prURLForHelmCharts)
+	if err := o.ledger.Record(ctx, release.LedgerEntry{Kind: release.LedgerEntryHelmChartPR, Name: rel.ReleaseName, URL: prURLForHelmCharts}); err != nil {
+		return err
+	}
+
+	o.manualActionLogger.Printf("Review and merge the PR containing the Helm charts: %s", prURLForHelmCharts)
+
+	return nil
+}
+
+// pushHelmChartOCI pushes each Helm chart to the OCI chart repository, where
+// it's signed with cosign in the same way as container images.
+func pushHelmChartOCI(ctx context.Context, o *gcbPublishOptions, rel *release.Unpacked) error {
+	if o.SigningKMSKey == "" && !o.SkipSigning {
+		return fmt.Errorf("must set signing-kms-key or skip-signing in order to sign Helm charts")
+	}
+
+	helmRepo, err := helm.NewOCIRepositoryManager(o.PublishedHelmChartOCIRepository)
+	if err != nil {
+		return err
+	}
+	helmRepo.WithTransport(o.rateLimiter.Transport(remote.DefaultTransport))
+
+	if err := helmRepo.Check(ctx); err != nil {
+		return fmt.Errorf("error in preflight checks for Helm OCI repository: %v", err)
+	}
+
+	publisher := o.newPublisher()
+
+	var pushedContent []registryContent
+	for _, chart := range rel.Charts {
+		ociChart, err := helmRepo.Build(chart)
+		if err != nil {
+			return err
+		}
+
+		published, err := alreadyPublished(ctx, o, publisher, release.LedgerEntryHelmChartOCI, ociChart.Reference, ociChart.Digest)
+		if err != nil {
+			return err
+		}
+
+		pushedContent = append(pushedContent, registryContent{ref: ociChart.Reference, digest: ociChart.Digest})
+
+		if published {
+			log.Printf("Skipping Helm chart %q (%s) which was pushed by a previous run", ociChart.Reference, ociChart.Digest)
+			continue
+		}
+
+		if err := retry(ctx, func() error { return helmRepo.Push(ctx, ociChart) }); err != nil {
+			return err
+		}
+
+		if err := o.ledger.Record(ctx, release.LedgerEntry{Kind: release.LedgerEntryHelmChartOCI, Name: ociChart.Reference, Digest: ociChart.Digest, URL: ociChart.URL()}); err != nil {
+			return err
+		}
+
+		log.Printf("Pushed Helm chart %q (%s)", ociChart.URL(), ociChart.Digest)
+	}
+
+	if err := signRegistryContent(ctx, o, o.signer(), pushedContent); err != nil {
+		return fmt.Errorf("failed to sign Helm charts: %w", err)
+	}
 
 	return nil
 }
@@ -409,200 +604,574 @@ func pushGitHubRelease(ctx context.Context, o *gcbPublishOptions, rel *release.U
 		return fmt.Errorf("failed to create github client for creating github release: %w", err)
 	}
 
-	// open manifest files ahead of time to ensure they are available on disk
-	manifestsByName := map[string]*os.File{}
-	for _, manifest := range rel.YAMLs {
-		f, err := os.Open(manifest.Path())
+	generatedAssetsDir, err := os.MkdirTemp("", "cmrel-release-assets-")
+	if err != nil {
+		return fmt.Errorf("failed to create directory for release checksums and provenance: %w", err)
+	}
+	defer os.RemoveAll(generatedAssetsDir)
+
+	assets := githubReleaseAssets(rel)
+	provenance, err := writeProvenanceAsset(ctx, o, rel, assets, generatedAssetsDir)
+	if err != nil {
+		return err
+	}
+
+	sboms, err := writeSBOMAssets(o, rel, generatedAssetsDir)
+	if err != nil {
+		return err
+	}
+	assets = append(assets, sboms...)
+
+	checksums, err := writeChecksumAssets(ctx, o, assets, generatedAssetsDir)
+	if err != nil {
+		return err
+	}
+	assets = append(append(assets, checksums...), provenance...)
+
+	// open all assets ahead of time to ensure they are available on disk
+	assetFiles := make([]*os.File, len(assets))
+	for i, asset := range assets {
+		f, err := os.Open(asset.Path)
 		if err != nil {
-			return fmt.Errorf("failed to open manifest file to be uploaded: %v", err)
+			return fmt.Errorf("failed to open release asset to be uploaded: %v", err)
 		}
 		defer f.Close()
-		manifestsByName[filepath.Base(manifest.Path())] = f
+		assetFiles[i] = f
 	}
 
-	log.Printf("Creating a draft GitHub release %q in repository %s/%s", rel.ReleaseVersion, o.PublishedGitHubOrg, o.PublishedGitHubRepo)
+	prerelease, err := isPrerelease(rel.ReleaseVersion)
+	if err != nil {
+		return err
+	}
 
-	defaultReleaseBody := "!!! Update this release note body before publishing this draft release!"
-	githubRelease, resp, err := githubClient.Repositories.CreateRelease(ctx, o.PublishedGitHubOrg, o.PublishedGitHubRepo, &github.RepositoryRelease{
-		TagName:         &rel.ReleaseVersion,
-		TargetCommitish: &rel.GitCommitRef,
-		Name:            &rel.ReleaseVersion,
-		Body:            &defaultReleaseBody,
-		Draft:           ptr.To(true),
-		// TODO: determine whether this ReleaseVersion is a 'prerelease'
-		Prerelease: nil,
-	})
+	latest, err := isLatestGitHubRelease(ctx, o, githubClient, rel.ReleaseVersion)
 	if err != nil {
-		return fmt.Errorf("failed to create GitHub release: %v", err)
+		return err
 	}
 
-	if resp.StatusCode < 200 || resp.StatusCode > 299 {
-		return fmt.Errorf("unexpected response code when creating GitHub release %d", resp.StatusCode)
+	var releaseID int64
+	if entry, ok := o.ledger.Get(release.LedgerEntryGitHubRelease, rel.ReleaseVersion); ok {
+		log.Printf("Resuming draft GitHub release %q created by a previous run: %s", rel.ReleaseVersion, entry.URL)
+		releaseID = entry.ID
+	} else {
+		releaseBody, err := generateReleaseNotes(ctx, o, rel, githubClient)
+		if err != nil {
+			log.Printf("Creating the draft GitHub release without release notes: %v", err)
+			releaseBody = placeholderReleaseBody
+		}
+
+		log.Printf("Creating a draft GitHub release %q in repository %s/%s (pre-release: %t, latest: %t)", rel.ReleaseVersion, o.PublishedGitHubOrg, o.PublishedGitHubRepo, prerelease, latest)
+
+		githubRelease, resp, err := createGitHubRelease(ctx, githubClient, o.PublishedGitHubOrg, o.PublishedGitHubRepo, &githubReleaseRequest{
+			RepositoryRelease: &github.RepositoryRelease{
+				TagName:         &rel.ReleaseVersion,
+				TargetCommitish: &rel.GitCommitRef,
+				Name:            &rel.ReleaseVersion,
+				Body:            &releaseBody,
+				Draft:           ptr.To(true),
+				Prerelease:      &prerelease,
+			},
+			MakeLatest: makeLatest(latest),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create GitHub release: %v", err)
+		}
+
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return fmt.Errorf("unexpected response code when creating GitHub release %d", resp.StatusCode)
+		}
+
+		releaseID = githubRelease.GetID()
+		if err := o.ledger.Record(ctx, release.LedgerEntry{Kind: release.LedgerEntryGitHubRelease, Name: rel.ReleaseVersion, ID: releaseID, URL: githubRelease.GetHTMLURL()}); err != nil {
+			return err
+		}
 	}
 
-	log.Printf("Uploading %d release manifests to GitHub release", len(manifestsByName))
-	for name, f := range manifestsByName {
-		asset, resp, err := githubClient.Repositories.UploadReleaseAsset(ctx, o.PublishedGitHubOrg, o.PublishedGitHubRepo, *githubRelease.ID, &github.UploadOptions{
-			Name: name,
-		}, f)
+	log.Printf("Uploading %d release assets to GitHub release", len(assets))
+	for i, asset := range assets {
+		sum, err := sha256SumFile(asset.Path)
+		if err != nil {
+			return fmt.Errorf("failed to compute sha256sum of release asset %q: %w", asset.Name, err)
+		}
+		digest := "sha256:" + sum
+
+		if entry, ok := o.ledger.Get(release.LedgerEntryGitHubAsset, asset.Name); ok {
+			if entry.Digest != digest {
+				return fmt.Errorf("release asset %q was previously uploaded with digest %q but the staged release has digest %q - refusing to overwrite it", asset.Name, entry.Digest, digest)
+			}
+			log.Printf("Skipping asset %q which was uploaded by a previous run", asset.Name)
+			continue
+		}
+
+		uploaded, resp, err := githubClient.Repositories.UploadReleaseAsset(ctx, o.PublishedGitHubOrg, o.PublishedGitHubRepo, releaseID, &github.UploadOptions{
+			Name: asset.Name,
+		}, assetFiles[i])
 		if err != nil {
 			return fmt.Errorf("failed to upload github release asset: %v", err)
 		}
 		if resp.StatusCode < 200 || resp.StatusCode > 299 {
 			return fmt.Errorf("unexpected response code when uploading github release asset %d", resp.StatusCode)
 		}
-		log.Printf("Uploaded asset %q to GitHub release %q", *asset.Name, *githubRelease.Name)
+
+		if err := o.ledger.Record(ctx, release.LedgerEntry{Kind: release.LedgerEntryGitHubAsset, Name: asset.Name, Digest: digest, ID: uploaded.GetID(), URL: uploaded.GetBrowserDownloadURL()}); err != nil {
+			return err
+		}
+
+		log.Printf("Uploaded asset %q to GitHub release %q", uploaded.GetName(), rel.ReleaseVersion)
+	}
+
+	if o.UndraftGitHubRelease {
+		log.Printf("The draft GitHub release will be published once all other publish actions have succeeded")
+		return nil
+	}
+
+	if latest {
+		o.manualActionLogger.Printf("Review the release notes of the draft GitHub release, updating them if they weren't generated, and hit PUBLISH! It should be set as the latest release.")
+	} else {
+		o.manualActionLogger.Printf("Review the release notes of the draft GitHub release, updating them if they weren't generated, and hit PUBLISH! It should NOT be set as the latest release.")
+	}
+	return nil
+}
+
+// githubReleaseAsset is a single file which is uploaded to the GitHub release.
+type githubReleaseAsset struct {
+	// Name is the name of the asset in the GitHub release
+	Name string
+
+	// Path is the location of the asset on disk
+	Path string
+}
+
+// githubReleaseAssets returns every file which pushGitHubRelease uploads to
+// the GitHub release: the static YAML manifests and, for older releases, the
+// ctl binary archives.
+func githubReleaseAssets(rel *release.Unpacked) []githubReleaseAsset {
+	var assets []githubReleaseAsset
+	for _, manifest := range rel.YAMLs {
+		assets = append(assets, githubReleaseAsset{
+			Name: filepath.Base(manifest.Path()),
+			Path: manifest.Path(),
+		})
 	}
 
 	if release.CmctlIsShipped(rel.ReleaseVersion) {
-		// Open ctl binary tar files ahead of time to ensure they are available
-		// on disk.
-		ctlBinariesByName := map[string]*os.File{}
 		for _, ctlBinary := range rel.CtlBinaryBundles {
-			f, err := os.Open(ctlBinary.Filepath())
-			if err != nil {
-				return fmt.Errorf("failed to open manifest file to be uploaded: %v", err)
-			}
+			assets = append(assets, githubReleaseAsset{
+				Name: ctlBinary.ArtifactFilename(),
+				Path: ctlBinary.Filepath(),
+			})
+		}
+	}
 
-			defer f.Close()
+	return assets
+}
 
-			ctlBinariesByName[ctlBinary.ArtifactFilename()] = f
+const (
+	// checksumsFileName is the name of the GitHub release asset listing the
+	// SHA256 sum of every other asset, in the format used by sha256sum.
+	checksumsFileName = "SHA256SUMS"
+
+	// checksumsSignatureFileName is the name of the GitHub release asset
+	// holding a detached, armored PGP signature of checksumsFileName.
+	checksumsSignatureFileName = checksumsFileName + ".asc"
+
+	// keyringFileName is the name of the GitHub release asset holding the
+	// armored PGP public key of every key the release was signed with. While
+	// keys are being rotated, it holds both the old and the new key.
+	keyringFileName = "cert-manager-keyring.asc"
+)
+
+// buildChecksums returns the contents of a SHA256SUMS file for the given
+// assets, which can be checked using "sha256sum --check".
+func buildChecksums(assets []githubReleaseAsset) ([]byte, error) {
+	sorted := append([]githubReleaseAsset(nil), assets...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	out := &bytes.Buffer{}
+	for _, asset := range sorted {
+		sum, err := sha256SumFile(asset.Path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compute sha256sum of release asset %q: %w", asset.Name, err)
 		}
+		fmt.Fprintf(out, "%s  %s\n", sum, asset.Name)
+	}
 
-		log.Printf("Uploading %d release binary tars to GitHub release", len(ctlBinariesByName))
-		for name, f := range ctlBinariesByName {
-			asset, resp, err := githubClient.Repositories.UploadReleaseAsset(ctx, o.PublishedGitHubOrg, o.PublishedGitHubRepo, *githubRelease.ID, &github.UploadOptions{
-				Name: name,
-			}, f)
-			if err != nil {
-				return fmt.Errorf("failed to upload github release asset: %v", err)
-			}
-			if resp.StatusCode < 200 || resp.StatusCode > 299 {
-				return fmt.Errorf("unexpected response code when uploading github release asset %d", resp.StatusCode)
-			}
-			log.Printf("Uploaded asset %q to GitHub release %q", *asset.Name, *githubRelease.Name)
+	return out.Bytes(), nil
+}
+
+// writeChecksumAssets writes a SHA256SUMS file for the given assets to dir,
+// along with a detached PGP signature of it and a keyring holding the PGP
+// public key of every signing key unless signing is skipped, and returns them
+// as GitHub release assets.
+// Signatures aren't reproducible, so if a previous run already uploaded the
+// signature or keyring it isn't created again.
+func writeChecksumAssets(ctx context.Context, o *gcbPublishOptions, assets []githubReleaseAsset, dir string) ([]githubReleaseAsset, error) {
+	checksums, err := buildChecksums(assets)
+	if err != nil {
+		return nil, err
+	}
+
+	checksumsPath := filepath.Join(dir, checksumsFileName)
+	if err := os.WriteFile(checksumsPath, checksums, 0o644); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", checksumsFileName, err)
+	}
+
+	written := []githubReleaseAsset{{Name: checksumsFileName, Path: checksumsPath}}
+
+	if o.SkipSigning {
+		log.Printf("Skipping signing %s as skip-signing is set", checksumsFileName)
+		return written, nil
+	}
+
+	_, signed := o.ledger.Get(release.LedgerEntryGitHubAsset, checksumsSignatureFileName)
+	_, keyringUploaded := o.ledger.Get(release.LedgerEntryGitHubAsset, keyringFileName)
+	if signed && keyringUploaded {
+		log.Printf("Skipping signing %s which was signed and uploaded by a previous run", checksumsFileName)
+		return written, nil
+	}
+
+	parsedKeys, err := loadSigningKeys(o.SigningKMSKey, o.PGPProfile)
+	if err != nil {
+		return nil, err
+	}
+
+	signers, err := sign.NewSigners(ctx, parsedKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	if !signed {
+		log.Printf("Signing %s using %s", checksumsFileName, o.SigningKMSKey)
+		signature, err := sign.DetachedSignature(signers, checksums)
+		if err != nil {
+			return nil, fmt.Errorf("failed to sign %s: %w", checksumsFileName, err)
+		}
+
+		signaturePath := filepath.Join(dir, checksumsSignatureFileName)
+		if err := os.WriteFile(signaturePath, signature, 0o644); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", checksumsSignatureFileName, err)
 		}
 
+		written = append(written, githubReleaseAsset{Name: checksumsSignatureFileName, Path: signaturePath})
 	}
 
-	o.manualActionLogger.Printf("Update the GitHub release with release notes and hit PUBLISH!")
-	return nil
+	if !keyringUploaded {
+		keyring, err := sign.Keyring(signers)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create %s: %w", keyringFileName, err)
+		}
+
+		keyringPath := filepath.Join(dir, keyringFileName)
+		if err := os.WriteFile(keyringPath, []byte(keyring), 0o644); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", keyringFileName, err)
+		}
+
+		written = append(written, githubReleaseAsset{Name: keyringFileName, Path: keyringPath})
+	}
+
+	return written, nil
 }
 
-const registryWaitTime = time.Second * 2
+const (
+	// registryRetryInitialInterval is how long retry waits after the first
+	// failure; each following wait is twice as long, up to
+	// registryRetryMaxInterval
+	registryRetryInitialInterval = time.Second
+	registryRetryMaxInterval     = time.Minute
+
+	// registryRetryMaxTries is the number of times retry tries an operation
+	registryRetryMaxTries = 8
+)
 
+// retry calls f until it succeeds, backing off exponentially between
+// attempts. Errors which retrying won't fix, such as authentication failures,
+// are returned immediately; see registry.IsRetryable. Requests to registries
+// which ask to be retried later are held back by the rate limiter.
 func retry(ctx context.Context, f func() error) error {
 	operation := func() (struct{}, error) {
 		err := f()
+		if err != nil && !registry.IsRetryable(err) {
+			return struct{}{}, backoff.Permanent(err)
+		}
 		return struct{}{}, err
 	}
 
-	_, err := backoff.Retry(ctx, operation, backoff.WithBackOff(backoff.NewConstantBackOff(registryWaitTime)), backoff.WithMaxTries(5))
+	b := backoff.NewExponentialBackOff()
+	b.InitialInterval = registryRetryInitialInterval
+	b.Multiplier = 2
+	b.MaxInterval = registryRetryMaxInterval
+
+	notify := func(err error, wait time.Duration) {
+		log.Printf("Retrying in %s: %v", wait.Round(time.Millisecond), err)
+	}
+
+	_, err := backoff.Retry(ctx, operation, backoff.WithBackOff(b), backoff.WithMaxTries(registryRetryMaxTries), backoff.WithMaxElapsedTime(0), backoff.WithNotify(notify))
 
 	return err
 }
 
-func pushContainerImages(ctx context.Context, o *gcbPublishOptions, rel *release.Unpacked) error {
-	log.Printf("Pushing arch-specific docker images")
+// newPublisher returns a registry.Publisher whose requests are rate limited
+// by o.rateLimiter.
+func (o *gcbPublishOptions) newPublisher(options ...remote.Option) *registry.Publisher {
+	return registry.NewPublisher(append(options, remote.WithTransport(o.rateLimiter.Transport(remote.DefaultTransport)))...)
+}
 
+func pushContainerImages(ctx context.Context, o *gcbPublishOptions, rel *release.Unpacked) error {
 	if o.SigningKMSKey == "" && !o.SkipSigning {
 		return fmt.Errorf("must set signing-kms-key or skip-signing in order to sign images")
 	}
 
-	var pushedContent []string
+	dockerConfigDir, err := os.MkdirTemp("", "cmrel-image-mirrors-")
+	if err != nil {
+		return fmt.Errorf("failed to create directory for image mirror credentials: %w", err)
+	}
+	defer os.RemoveAll(dockerConfigDir)
+
+	registries, err := o.imageRegistries(dockerConfigDir)
+	if err != nil {
+		return err
+	}
+
+	// the same content is pushed to each mirror once it has been pushed to
+	// the published image repository
+	for _, r := range registries {
+		if err := pushContainerImagesToRegistry(ctx, o, rel, r); err != nil {
+			return err
+		}
+	}
+
+	if len(registries) > 1 {
+		if err := checkMirroredDigests(o, rel, registries); err != nil {
+			return err
+		}
+		log.Printf("Images and image indexes were pushed to %d mirror(s) with matching digests", len(registries)-1)
+	}
+
+	return nil
+}
+
+// pushContainerImagesToRegistry pushes, signs and attests every image and
+// image index in the release to a single image repository.
+func pushContainerImagesToRegistry(ctx context.Context, o *gcbPublishOptions, rel *release.Unpacked, r imageRegistry) error {
+	log.Printf("Pushing arch-specific docker images to %q", r.repository)
+
+	publisher := r.publisher
+
+	var pushedContent []registryContent
 
 	for name, tars := range rel.ComponentImageBundles {
 		log.Printf("Pushing release images for component %q", name)
 		for _, t := range tars {
-			imageTag := buildImageTag(o.PublishedImageRepository, name, t.Architecture(), rel.ReleaseVersion)
+			imageTag := buildImageTag(r.repository, name, t.Architecture(), rel.ReleaseVersion)
 
-			log.Printf("Tagging %q with new name %q", t.RawImageName(), imageTag)
-
-			if err := docker.Tag(ctx, t.RawImageName(), imageTag); err != nil {
+			digest, err := registry.ImageDigest(t)
+			if err != nil {
 				return err
 			}
 
-			if err := retry(ctx, func() error { return docker.Push(ctx, imageTag) }); err != nil {
+			published, err := alreadyPublished(ctx, o, publisher, release.LedgerEntryImage, imageTag, digest)
+			if err != nil {
 				return err
 			}
 
-			// PublishedTag will be used later to refer to the image under the tag we
-			// actually pushed it under
-			t.PublishedTag = imageTag
+			if published {
+				log.Printf("Skipping release image %q (%s) which was pushed by a previous run", imageTag, digest)
+				t.PublishedTag = imageTag
+				t.PublishedDigest = digest
+				pushedContent = append(pushedContent, registryContent{ref: imageTag, digest: digest})
+				continue
+			}
 
-			log.Printf("Pushed release image %q", imageTag)
-			pushedContent = append(pushedContent, imageTag)
+			// PushImage sets PublishedTag, which will be used later to refer
+			// to the image under the tag we actually pushed it under
+			if err := retry(ctx, func() error { return publisher.PushImage(ctx, t, imageTag) }); err != nil {
+				return err
+			}
+
+			if err := o.ledger.Record(ctx, release.LedgerEntry{Kind: release.LedgerEntryImage, Name: imageTag, Digest: t.PublishedDigest}); err != nil {
+				return err
+			}
 
-			// Wait to avoid being rate limited by the registry
-			time.Sleep(registryWaitTime)
+			log.Printf("Pushed release image %q (%s)", imageTag, t.PublishedDigest)
+			pushedContent = append(pushedContent, registryContent{ref: imageTag, digest: t.PublishedDigest})
 		}
 	}
 
-	// manifest lists can only be created using the docker CLI after the child
-	// images have been pushed to the registry.
-	// Build them all at once, and push them afterwards to avoid releasing an
-	// incomplete set of manifest lists.
-	var builtManifestLists []string
-	log.Printf("Creating multi-arch manifest lists for image components")
+	// Build all image indexes at once, and push them afterwards to avoid
+	// releasing an incomplete set of image indexes.
+	type imageIndex struct {
+		name   string
+		index  v1.ImageIndex
+		digest string
+	}
+	var builtIndexes []imageIndex
+	log.Printf("Creating multi-arch image indexes for image components")
 	for name, tars := range rel.ComponentImageBundles {
-		manifestListName := buildManifestListName(o.PublishedImageRepository, name, rel.ReleaseVersion)
-		if err := registry.CreateManifestList(ctx, manifestListName, tars); err != nil {
+		indexName := buildImageIndexName(r.repository, name, rel.ReleaseVersion)
+		idx, err := registry.CreateImageIndex(indexName, tars)
+		if err != nil {
 			return err
 		}
 
-		builtManifestLists = append(builtManifestLists, manifestListName)
+		digest, err := idx.Digest()
+		if err != nil {
+			return fmt.Errorf("failed to compute digest for image index %q: %w", indexName, err)
+		}
+
+		builtIndexes = append(builtIndexes, imageIndex{name: indexName, index: idx, digest: digest.String()})
 	}
 
-	log.Printf("Pushing all multi-arch manifest lists")
-	for _, manifestListName := range builtManifestLists {
-		log.Printf("Pushing manifest list %q", manifestListName)
-		if err := retry(ctx, func() error { return docker.PushManifestList(ctx, manifestListName) }); err != nil {
+	log.Printf("Pushing all multi-arch image indexes")
+	for _, imageIndex := range builtIndexes {
+		published, err := alreadyPublished(ctx, o, publisher, release.LedgerEntryImageIndex, imageIndex.name, imageIndex.digest)
+		if err != nil {
+			return err
+		}
+
+		pushedContent = append(pushedContent, registryContent{ref: imageIndex.name, digest: imageIndex.digest})
+
+		if published {
+			log.Printf("Skipping image index %q (%s) which was pushed by a previous run", imageIndex.name, imageIndex.digest)
+			continue
+		}
+
+		log.Printf("Pushing image index %q", imageIndex.name)
+		if err := retry(ctx, func() error { return publisher.PushImageIndex(ctx, imageIndex.name, imageIndex.index) }); err != nil {
 			return err
 		}
 
-		pushedContent = append(pushedContent, manifestListName)
-		log.Printf("Pushed multi-arch manifest list %q", manifestListName)
+		if err := o.ledger.Record(ctx, release.LedgerEntry{Kind: release.LedgerEntryImageIndex, Name: imageIndex.name, Digest: imageIndex.digest}); err != nil {
+			return err
+		}
 
-		// Wait to avoid being rate limited by the registry
-		time.Sleep(registryWaitTime)
+		log.Printf("Pushed multi-arch image index %q (%s)", imageIndex.name, imageIndex.digest)
 	}
 
-	if err := signRegistryContent(ctx, o, pushedContent); err != nil {
+	if err := signRegistryContent(ctx, o, r.signer, pushedContent); err != nil {
 		return fmt.Errorf("failed to sign images: %w", err)
 	}
 
+	if err := attestRegistryContent(ctx, o, r.signer, rel, pushedContent); err != nil {
+		return fmt.Errorf("failed to attest provenance of images: %w", err)
+	}
+
+	if err := attachImageSBOMs(ctx, o, r, rel); err != nil {
+		return fmt.Errorf("failed to attach SBOMs to images: %w", err)
+	}
+
 	return nil
 }
 
-func signRegistryContent(ctx context.Context, o *gcbPublishOptions, allContentToSign []string) error {
-	if o.SkipSigning {
-		log.Println("Skipping signing container images / manifest lists as skip-signing is set")
+// registryContent is an image or image index which has been pushed to a
+// registry.
+type registryContent struct {
+	ref    string
+	digest string
+}
+
+// alreadyPublished returns true if the publish ledger records that content
+// with the given digest was pushed to ref by a previous run, and the registry
+// still holds exactly that content. It returns an error if the staged content
+// or the content in the registry differs from what was recorded, since
+// pushing again would overwrite a published artifact.
+func alreadyPublished(ctx context.Context, o *gcbPublishOptions, publisher *registry.Publisher, kind release.LedgerEntryKind, ref, digest string) (bool, error) {
+	entry, ok := o.ledger.Get(kind, ref)
+	if !ok {
+		return false, nil
+	}
+
+	if entry.Digest != digest {
+		return false, fmt.Errorf("%q was previously published with digest %q but the staged release has digest %q - refusing to overwrite it", ref, entry.Digest, digest)
+	}
+
+	remoteDigest, err := publisher.RemoteDigest(ctx, ref)
+	if err != nil {
+		return false, err
+	}
+
+	switch remoteDigest {
+	case digest:
+		return true, nil
+
+	case "":
+		log.Printf("%q was previously published but is missing from the registry; it will be pushed again", ref)
+		return false, nil
+
+	default:
+		return false, fmt.Errorf("%q has digest %q in the registry but %q was published - refusing to overwrite it", ref, remoteDigest, digest)
+	}
+}
+
+func signRegistryContent(ctx context.Context, o *gcbPublishOptions, signer registrySigner, allContentToSign []registryContent) error {
+	if signer.keys == "" {
+		log.Println("Skipping signing container images / image indexes as skip-signing is set")
 		return nil
 	}
 
 	log.Println("Signing container images")
 
-	parsedKey, err := sign.NewGCPKMSKey(o.SigningKMSKey)
+	parsedKeys, err := sign.ParseSigningKeys(signer.keys)
 	if err != nil {
 		return err
 	}
 
+	var signed []string
 	for _, toSign := range allContentToSign {
-		log.Printf("Signing %q", toSign)
-		if err := retry(ctx, func() error { return cosign.Sign(ctx, o.CosignPath, []string{toSign}, parsedKey) }); err != nil {
-			return fmt.Errorf("failed to sign container image / manifest list %q: %w", toSign, err)
+		signedKeys, unsignedKeys := keysToSign(o, release.LedgerEntrySignature, toSign, parsedKeys)
+		if len(unsignedKeys) == 0 {
+			log.Printf("Skipping signing %q which was signed by a previous run", toSign.ref)
+			continue
+		}
+
+		for _, key := range unsignedKeys {
+			log.Printf("Signing %q using %s", toSign.ref, key)
+			// cosign failures aren't retried, since there's no telling
+			// whether retrying would fix them
+			if err := o.rateLimiter.WaitForReference(ctx, toSign.ref); err != nil {
+				return err
+			}
+			if err := cosign.Sign(ctx, o.CosignPath, signer.env, []string{toSign.ref}, key); err != nil {
+				return fmt.Errorf("failed to sign container image / image index %q: %w", toSign.ref, err)
+			}
+
+			signedKeys = append(signedKeys, key.String())
+			if err := o.ledger.Record(ctx, release.LedgerEntry{Kind: release.LedgerEntrySignature, Name: toSign.ref, Digest: toSign.digest, Keys: signedKeys}); err != nil {
+				return err
+			}
 		}
 
-		// Wait to avoid being rate limited by the registry
-		time.Sleep(registryWaitTime)
+		signed = append(signed, toSign.ref)
 	}
 
-	log.Printf("Finished signing: %s", strings.Join(allContentToSign, ", "))
+	log.Printf("Finished signing: %s", strings.Join(signed, ", "))
 
 	return nil
 }
 
-func buildManifestListName(repo, componentName, tag string) string {
+// keysToSign returns the keys which have already signed or attested the
+// given content according to the ledger, and those which still need to.
+// Entries recorded before signing keys were tracked don't say which key
+// signed them, which needn't be any of the current keys after a rotation, so
+// the content is signed again with every key.
+func keysToSign(o *gcbPublishOptions, kind release.LedgerEntryKind, content registryContent, keys []sign.SigningKey) ([]string, []sign.SigningKey) {
+	var signedKeys []string
+	if entry, ok := o.ledger.Get(kind, content.ref); ok && entry.Digest == content.digest {
+		signedKeys = slices.Clone(entry.Keys)
+	}
+
+	var unsignedKeys []sign.SigningKey
+	for _, key := range keys {
+		if !slices.Contains(signedKeys, key.String()) {
+			unsignedKeys = append(unsignedKeys, key)
+		}
+	}
+
+	return signedKeys, unsignedKeys
+}
+
+func buildImageIndexName(repo, componentName, tag string) string {
 	return fmt.Sprintf("%s/cert-manager-%s:%s", repo, componentName, tag)
 }
 
@@ -613,6 +1182,7 @@ func buildImageTag(repo, componentName, arch, tag string) string {
 func errorDuringPublish(err error) error {
 	if err != nil {
 		log.Printf("ERROR OCCURRED DURING PUBLISHING - INCOMPLETE RELEASE MAY BE PUBLISHED: %v", err)
+		log.Printf("Completed steps are recorded in the publish ledger; re-run this command with the same arguments to resume publishing")
 	}
 	return err
 }
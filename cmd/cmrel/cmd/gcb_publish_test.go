@@ -17,10 +17,21 @@ limitations under the License.
 package cmd
 
 import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
 	"reflect"
 	"sort"
 	"strings"
 	"testing"
+
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+
+	"github.com/cert-manager/release/internal/testregistry"
+	"github.com/cert-manager/release/pkg/release"
+	"github.com/cert-manager/release/pkg/sign"
 )
 
 func sortedSlice(s []string) []string {
@@ -39,6 +50,11 @@ func TestCanonicalizeAndVerifyPublishActions(t *testing.T) {
 	}{
 		"basic case with '*'": {
 			inputActions:   []string{"*"},
+			expectedOutput: sortedSlice([]string{"githubrelease", "helmchartpr", "pushcontainerimages"}),
+			expectErr:      false,
+		},
+		"opt-in actions can be added to '*'": {
+			inputActions:   []string{"*", "helmchartoci", "pushfloatingimagetags"},
 			expectedOutput: sortedSlice(allPublishActionNames()),
 			expectErr:      false,
 		},
@@ -102,3 +118,314 @@ func TestCanonicalizeAndVerifyPublishActions(t *testing.T) {
 		})
 	}
 }
+
+// loadTestRelease unpacks the release in dir and loads its publish ledger.
+func loadTestRelease(t *testing.T, dir string) (*release.Unpacked, *release.Ledger) {
+	t.Helper()
+	ctx := context.TODO()
+
+	staged, err := release.LoadStagedDirectory(ctx, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rel, err := release.Unpack(ctx, staged)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ledger, err := staged.Ledger(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return rel, ledger
+}
+
+func TestPushContainerImagesResumesFromLedger(t *testing.T) {
+	ctx := context.TODO()
+	host := testregistry.New(t)
+	dir := writeTestReleaseDir(t, "v1.15.0", "amd64")
+
+	o := NewGCBPublishOptions()
+	o.PublishedImageRepository = host + "/jetstack"
+	o.SkipSigning = true
+
+	rel, ledger := loadTestRelease(t, dir)
+	o.ledger = ledger
+	if err := pushContainerImages(ctx, o, rel); err != nil {
+		t.Fatal(err)
+	}
+
+	first := ledger.Entries()
+	if len(first) != 4 || first[0].Kind != release.LedgerEntryImage || first[1].Kind != release.LedgerEntryImageIndex || first[2].Kind != release.LedgerEntrySBOM || first[3].Kind != release.LedgerEntrySBOM {
+		t.Fatalf("expected an image, an image index and 2 SBOMs to be recorded, got %#v", first)
+	}
+
+	// a second run must skip everything, but still know what was published
+	rel, ledger = loadTestRelease(t, dir)
+	o.ledger = ledger
+	if err := pushContainerImages(ctx, o, rel); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(ledger.Entries(), first) {
+		t.Errorf("expected ledger to be unchanged by a resumed run, got %#v", ledger.Entries())
+	}
+
+	tar := rel.ComponentImageBundles["controller"][0]
+	if tar.PublishedDigest != first[0].Digest {
+		t.Errorf("expected skipped image to have digest %q, got %q", first[0].Digest, tar.PublishedDigest)
+	}
+
+	// content which differs from what was published must never be overwritten
+	rel, ledger = loadTestRelease(t, dir)
+	if err := ledger.Record(ctx, release.LedgerEntry{Kind: release.LedgerEntryImage, Name: first[0].Name, Digest: "sha256:0000"}); err != nil {
+		t.Fatal(err)
+	}
+	o.ledger = ledger
+	if err := pushContainerImages(ctx, o, rel); err == nil {
+		t.Errorf("expected an error when the staged image differs from the published image")
+	}
+}
+
+func TestWriteChecksumAssets(t *testing.T) {
+	ctx := context.TODO()
+	dir := t.TempDir()
+
+	var assets []githubReleaseAsset
+	for _, name := range []string{"cmctl-linux-amd64.tar.gz", "cert-manager.yaml"} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		assets = append(assets, githubReleaseAsset{Name: name, Path: path})
+	}
+
+	o := NewGCBPublishOptions()
+	o.SkipSigning = true
+
+	written, err := writeChecksumAssets(ctx, o, assets, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(written) != 1 || written[0].Name != checksumsFileName {
+		t.Fatalf("expected only %s to be written when signing is skipped, got %#v", checksumsFileName, written)
+	}
+
+	checksums, err := os.ReadFile(written[0].Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(checksums), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines in %s, got:\n%s", checksumsFileName, checksums)
+	}
+
+	for i, name := range []string{"cert-manager.yaml", "cmctl-linux-amd64.tar.gz"} {
+		sum, err := sha256SumFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := sum + "  " + name; lines[i] != want {
+			t.Errorf("line %d: expected %q, got %q", i, want, lines[i])
+		}
+	}
+}
+
+// newTestLedger returns an empty ledger stored in a temporary directory
+func newTestLedger(t *testing.T) *release.Ledger {
+	t.Helper()
+	ctx := context.TODO()
+
+	store, err := release.NewLocalArtifactStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ledger, err := release.LoadLedger(ctx, store, release.LedgerFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return ledger
+}
+
+func TestWriteChecksumAssetsWithMultipleKeys(t *testing.T) {
+	ctx := context.TODO()
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "cert-manager.yaml")
+	if err := os.WriteFile(path, []byte("cert-manager.yaml"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	assets := []githubReleaseAsset{{Name: "cert-manager.yaml", Path: path}}
+
+	o := NewGCBPublishOptions()
+	o.SigningKMSKey = "ephemeral://checksums-old,ephemeral://checksums-new"
+	o.ledger = newTestLedger(t)
+
+	written, err := writeChecksumAssets(ctx, o, assets, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	contents := map[string][]byte{}
+	for _, asset := range written {
+		names = append(names, asset.Name)
+		contents[asset.Name], err = os.ReadFile(asset.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if expected := []string{checksumsFileName, checksumsSignatureFileName, keyringFileName}; !reflect.DeepEqual(names, expected) {
+		t.Fatalf("expected assets %v to be written, got %v", expected, names)
+	}
+
+	keyring := sign.PGPArmoredBlock(contents[keyringFileName])
+	if err := sign.VerifyDetachedSignature(keyring, contents[checksumsFileName], contents[checksumsSignatureFileName]); err != nil {
+		t.Errorf("expected %s to verify with %s: %v", checksumsSignatureFileName, keyringFileName, err)
+	}
+
+	// users with only one of the keys must also be able to verify the checksums
+	parsedKeys, err := sign.ParseSigningKeys(o.SigningKMSKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range parsedKeys {
+		signer, err := sign.NewSigner(ctx, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		publicKey, err := sign.BootstrapPGP(signer)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := sign.VerifyDetachedSignature(publicKey, contents[checksumsFileName], contents[checksumsSignatureFileName]); err != nil {
+			t.Errorf("expected %s to verify with %s: %v", checksumsSignatureFileName, key, err)
+		}
+	}
+}
+
+func TestKeysToSign(t *testing.T) {
+	ctx := context.TODO()
+	content := registryContent{ref: "quay.io/jetstack/cert-manager-controller:v1.15.0", digest: "sha256:1111"}
+
+	parsedKeys, err := sign.ParseSigningKeys("ephemeral://old,ephemeral://new")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		entry *release.LedgerEntry
+
+		expectedSigned   []string
+		expectedUnsigned []string
+	}{
+		"unsigned content is signed with every key": {
+			expectedUnsigned: []string{"ephemeral://old", "ephemeral://new"},
+		},
+		"content signed by a previous run with every key is skipped": {
+			entry:          &release.LedgerEntry{Digest: content.digest, Keys: []string{"ephemeral://old", "ephemeral://new"}},
+			expectedSigned: []string{"ephemeral://old", "ephemeral://new"},
+		},
+		"content signed by a previous run with some keys is signed with the rest": {
+			entry:            &release.LedgerEntry{Digest: content.digest, Keys: []string{"ephemeral://old"}},
+			expectedSigned:   []string{"ephemeral://old"},
+			expectedUnsigned: []string{"ephemeral://new"},
+		},
+		"content signed before keys were recorded is signed again with every key": {
+			entry:            &release.LedgerEntry{Digest: content.digest},
+			expectedUnsigned: []string{"ephemeral://old", "ephemeral://new"},
+		},
+		"content with a different digest is signed with every key": {
+			entry:            &release.LedgerEntry{Digest: "sha256:0000", Keys: []string{"ephemeral://old", "ephemeral://new"}},
+			expectedUnsigned: []string{"ephemeral://old", "ephemeral://new"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			o := NewGCBPublishOptions()
+			o.ledger = newTestLedger(t)
+
+			if test.entry != nil {
+				entry := *test.entry
+				entry.Kind = release.LedgerEntrySignature
+				entry.Name = content.ref
+				if err := o.ledger.Record(ctx, entry); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			signed, unsigned := keysToSign(o, release.LedgerEntrySignature, content, parsedKeys)
+
+			var unsignedNames []string
+			for _, key := range unsigned {
+				unsignedNames = append(unsignedNames, key.String())
+			}
+
+			if !reflect.DeepEqual(signed, test.expectedSigned) {
+				t.Errorf("expected signed keys %v, got %v", test.expectedSigned, signed)
+			}
+
+			if !reflect.DeepEqual(unsignedNames, test.expectedUnsigned) {
+				t.Errorf("expected unsigned keys %v, got %v", test.expectedUnsigned, unsignedNames)
+			}
+		})
+	}
+}
+
+func TestRetry(t *testing.T) {
+	tests := map[string]struct {
+		errs          []error
+		expectedCalls int
+		expectErr     bool
+	}{
+		"succeeds first time": {
+			expectedCalls: 1,
+		},
+		"succeeds after a transient error": {
+			errs:          []error{&transport.Error{StatusCode: http.StatusTooManyRequests}},
+			expectedCalls: 2,
+		},
+		"fails fast on authentication failure": {
+			errs:          []error{&transport.Error{StatusCode: http.StatusUnauthorized}},
+			expectedCalls: 1,
+			expectErr:     true,
+		},
+		"fails fast on an error not from a registry": {
+			errs:          []error{errors.New("cosign exited with status 1")},
+			expectedCalls: 1,
+			expectErr:     true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			calls := 0
+			err := retry(context.TODO(), func() error {
+				calls++
+				if calls <= len(test.errs) {
+					return test.errs[calls-1]
+				}
+				return nil
+			})
+
+			if test.expectErr != (err != nil) {
+				t.Errorf("expected error: %t, got: %v", test.expectErr, err)
+			}
+
+			if calls != test.expectedCalls {
+				t.Errorf("expected %d calls, got %d", test.expectedCalls, calls)
+			}
+		})
+	}
+}
@@ -28,8 +28,8 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"time"
 
-	"cloud.google.com/go/storage"
 	"github.com/spf13/cobra"
 	flag "github.com/spf13/pflag"
 
@@ -79,22 +79,41 @@ type gcbStageOptions struct {
 
 	// SigningKMSKey is the full name of the GCP KMS key to be used for signing, e.g.
 	// projects/<PROJECT_NAME>/locations/<LOCATION>/keyRings/<KEYRING_NAME>/cryptoKeys/<KEY_NAME>/cryptoKeyVersions/<KEY_VERSION>
+	// Other keys, such as local files, can be given as URIs; see sign.SigningKey.
+	// Multiple comma-separated keys can be given while rotating keys.
 	// This must be set if SkipSigning is not set to true
 	SigningKMSKey string
 
+	// PGPProfile is the path to a YAML file of key profiles, which set the PGP
+	// identity of each signing key; see sign.KeyProfiles
+	PGPProfile string
+
 	// TargetOSes is a comma-separated list of OSes which should be built for in this invocation
 	TargetOSes string
 
 	// TargetArches is a comma-separated list of architectures which should be built for in this invocation
 	TargetArches string
+
+	// BuildID is the ID of the Cloud Build running this command, recorded
+	// as a build input in the staged metadata.
+	BuildID string
+
+	// BuilderImage is the container image running this command, recorded
+	// as a build input in the staged metadata.
+	BuilderImage string
+
+	// BuildSubstitutions is a list of KEY=VALUE Cloud Build substitutions,
+	// recorded as build inputs in the staged metadata.
+	BuildSubstitutions []string
 }
 
 func (o *gcbStageOptions) AddFlags(fs *flag.FlagSet, markRequired func(string)) {
-	fs.StringVar(&o.Bucket, "bucket", release.DefaultBucketName, "The name of the GCS bucket to stage the release to.")
+	fs.StringVar(&o.Bucket, "bucket", release.DefaultBucketName, "The name of the GCS bucket to stage the release to, or a local directory prefixed with 'file://'.")
 	fs.StringVar(&o.RepoPath, "repo-path", "", "Path to the cert-manager repository stored in disk to be built and published. This must already be checked out at the appropriate revision.")
 	fs.StringVar(&o.ReleaseVersion, "release-version", "", "Optional release version override used to force the version strings used during the release to a specific value.")
 	fs.StringVar(&o.PublishedImageRepository, "published-image-repo", release.DefaultImageRepository, "The docker image repository set when building the release.")
-	fs.StringVar(&o.SigningKMSKey, "signing-kms-key", defaultKMSKey, "Full name of the GCP KMS key to use for signing")
+	fs.StringVar(&o.SigningKMSKey, "signing-kms-key", defaultKMSKey, "Key to use for signing; "+signingKeyFormats+". "+multipleSigningKeys)
+	fs.StringVar(&o.PGPProfile, "pgp-profile", "", pgpProfileUsage)
 	fs.BoolVar(&o.SkipPush, "skip-push", false, "Skip pushing the staged release to a GCS bucket.")
 	fs.BoolVar(&o.SkipSigning, "skip-signing", false, "Skip signing release artifacts.")
 
@@ -105,6 +124,10 @@ func (o *gcbStageOptions) AddFlags(fs *flag.FlagSet, markRequired func(string))
 
 	fs.StringVar(&o.TargetOSes, "target-os", "*", fmt.Sprintf("Comma-separated list of OSes to target, or '*' for all. Options: %s", allOSes))
 	fs.StringVar(&o.TargetArches, "target-arch", "*", fmt.Sprintf("Comma-separated list of arches to target, or '*' for all. Options: %s", allArches))
+
+	fs.StringVar(&o.BuildID, "build-id", "", "ID of the Cloud Build running the stage, recorded in the release metadata for provenance.")
+	fs.StringVar(&o.BuilderImage, "builder-image", "", "Container image the stage is running in, recorded in the release metadata for provenance.")
+	fs.StringArrayVar(&o.BuildSubstitutions, "build-substitution", nil, "A KEY=VALUE Cloud Build substitution, recorded in the release metadata for provenance. May be repeated.")
 }
 
 func (o *gcbStageOptions) print() {
@@ -114,9 +137,13 @@ func (o *gcbStageOptions) print() {
 	log.Printf("  SkipPush: %v", o.SkipPush)
 	log.Printf("  SkipSigning: %v", o.SkipSigning)
 	log.Printf("  SigningKMSKey: %q", o.SigningKMSKey)
+	log.Printf("  PGPProfile: %q", o.PGPProfile)
 	log.Printf("  ReleaseVersion: %q", o.ReleaseVersion)
 	log.Printf("  TargetOSes: %q", o.TargetOSes)
 	log.Printf("  TargetArches: %q", o.TargetArches)
+	log.Printf("  BuildID: %q", o.BuildID)
+	log.Printf("  BuilderImage: %q", o.BuilderImage)
+	log.Printf("  BuildSubstitutions: %q", o.BuildSubstitutions)
 }
 
 func gcbStageCmd(rootOpts *rootOptions) *cobra.Command {
@@ -141,13 +168,18 @@ func gcbStageCmd(rootOpts *rootOptions) *cobra.Command {
 func runGCBStage(rootOpts *rootOptions, o *gcbStageOptions) error {
 	ctx := context.Background()
 
+	buildInputs, err := o.buildInputs()
+	if err != nil {
+		return err
+	}
+
 	gitRef, err := readGitRef(o.RepoPath)
 	if err != nil {
 		return fmt.Errorf("failed to read git ref from repository: %v", err)
 	}
 
 	if o.SigningKMSKey != "" {
-		if _, err := sign.NewGCPKMSKey(o.SigningKMSKey); err != nil {
+		if _, err := loadSigningKeys(o.SigningKMSKey, o.PGPProfile); err != nil {
 			return err
 		}
 	}
@@ -202,6 +234,7 @@ func runGCBStage(rootOpts *rootOptions, o *gcbStageOptions) error {
 			}
 
 			log.Printf("Building %q target for %q OS for %q architecture", release.TarsBazelTarget, osVariant, arch)
+			buildInputs.TargetPlatforms = append(buildInputs.TargetPlatforms, osVariant+"/"+arch)
 
 			if err := runBazel(o.RepoPath, bazelBuildEnv(o), "build", "--stamp", platformFlagForOSArch(osVariant, arch), release.TarsBazelTarget); err != nil {
 				return fmt.Errorf("failed building release artifacts for architecture %q: %w", arch, err)
@@ -229,18 +262,32 @@ func runGCBStage(rootOpts *rootOptions, o *gcbStageOptions) error {
 		}
 	}
 
+	var signingKeys []string
 	manifestPostProcessor := func(path string) error {
 		if o.SkipSigning {
 			log.Println("skipping signing cert-manager-manifests.tar.gz because skip-signing is true")
 			return nil
 		}
 
-		parsedKey, err := sign.NewGCPKMSKey(o.SigningKMSKey)
+		parsedKeys, err := loadSigningKeys(o.SigningKMSKey, o.PGPProfile)
 		if err != nil {
 			return err
 		}
 
-		return sign.CertManagerManifests(ctx, parsedKey, path, o.ReleaseVersion)
+		signers, err := sign.NewSigners(ctx, parsedKeys)
+		if err != nil {
+			return err
+		}
+
+		if err := sign.CertManagerManifests(signers, path, o.ReleaseVersion); err != nil {
+			return err
+		}
+
+		for _, key := range parsedKeys {
+			signingKeys = append(signingKeys, key.String())
+		}
+
+		return nil
 	}
 
 	// add 'manifests' (helm chart, k8s YAML manifests)
@@ -248,10 +295,14 @@ func runGCBStage(rootOpts *rootOptions, o *gcbStageOptions) error {
 		return err
 	}
 
+	buildInputs.FinishedOn = time.Now().UTC()
+
 	meta, err := json.MarshalIndent(release.Metadata{
 		ReleaseVersion: o.ReleaseVersion,
 		GitCommitRef:   gitRef,
 		Artifacts:      artifacts,
+		BuildInputs:    buildInputs,
+		SigningKeys:    signingKeys,
 	}, "", " ")
 	if err != nil {
 		return fmt.Errorf("failed to encode metadata output: %w", err)
@@ -264,43 +315,51 @@ func runGCBStage(rootOpts *rootOptions, o *gcbStageOptions) error {
 		return nil
 	}
 
-	// Build Google Cloud Storage API client for uploading artifacts
-	gcs, err := storage.NewClient(ctx)
+	// Build the artifact store client for uploading artifacts
+	store, err := release.OpenArtifactStore(ctx, o.Bucket)
 	if err != nil {
-		return fmt.Errorf("failed to create GCS client: %w", err)
+		return err
 	}
 
 	// Upload all built release artifacts
 	for _, artifact := range artifacts {
 		filePath := buildArtifactPath(o.RepoPath, "build", "release-tars", artifact.Name)
-		gcsPath := buildObjectName(outputDir, artifact.Name)
-		log.Printf("Uploading artifact %q to GCS at path: %s", artifact, gcsPath)
-		if err := func(filePath, gcsPath string) error {
+		objectName := buildObjectName(outputDir, artifact.Name)
+		log.Printf("Uploading artifact %q to artifact store at path: %s", artifact, objectName)
+		if err := func(filePath, objectName string) error {
 			r, err := os.Open(filePath)
 			if err != nil {
 				return err
 			}
 			defer r.Close()
 
-			w := gcs.Bucket(o.Bucket).Object(gcsPath).NewWriter(ctx)
+			w, err := store.NewWriter(ctx, objectName)
+			if err != nil {
+				return err
+			}
 			if _, err := io.Copy(w, r); err != nil {
+				w.Abort()
 				return err
 			}
 			if err := w.Close(); err != nil {
 				return err
 			}
-			log.Printf("Uploaded artifact %q to GCS", artifact)
+			log.Printf("Uploaded artifact %q to artifact store", artifact)
 
 			return nil
-		}(filePath, gcsPath); err != nil {
-			return fmt.Errorf("failed to copy output artifact to GCS staging location: %w", err)
+		}(filePath, objectName); err != nil {
+			return fmt.Errorf("failed to copy output artifact to staging location: %w", err)
 		}
 	}
 
 	log.Printf("Uploading release metadata")
-	w := gcs.Bucket(o.Bucket).Object(buildObjectName(outputDir, release.MetadataFileName)).NewWriter(ctx)
+	w, err := store.NewWriter(ctx, buildObjectName(outputDir, release.MetadataFileName))
+	if err != nil {
+		return fmt.Errorf("failed to open release metadata in staging location: %w", err)
+	}
 	if _, err := w.Write(meta); err != nil {
-		return fmt.Errorf("failed to write release metadata to GCS staging location: %w", err)
+		w.Abort()
+		return fmt.Errorf("failed to write release metadata to staging location: %w", err)
 	}
 	if err := w.Close(); err != nil {
 		return err
@@ -311,6 +370,40 @@ func runGCBStage(rootOpts *rootOptions, o *gcbStageOptions) error {
 	return nil
 }
 
+// buildInputs returns the inputs to this build which are known before it
+// starts. TargetPlatforms and FinishedOn are filled in as the build runs.
+func (o *gcbStageOptions) buildInputs() (*release.BuildInputs, error) {
+	substitutions, err := parseBuildSubstitutions(o.BuildSubstitutions)
+	if err != nil {
+		return nil, err
+	}
+
+	return &release.BuildInputs{
+		BuildID:       o.BuildID,
+		BuilderImage:  o.BuilderImage,
+		Substitutions: substitutions,
+		StartedOn:     time.Now().UTC(),
+	}, nil
+}
+
+// parseBuildSubstitutions parses a list of KEY=VALUE pairs into a map
+func parseBuildSubstitutions(raw []string) (map[string]string, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+
+	substitutions := make(map[string]string, len(raw))
+	for _, r := range raw {
+		key, value, ok := strings.Cut(r, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid build substitution %q; must be of the form KEY=VALUE", r)
+		}
+		substitutions[key] = value
+	}
+
+	return substitutions, nil
+}
+
 func bazelBuildEnv(opts *gcbStageOptions) []string {
 	return append(os.Environ(), "DOCKER_REGISTRY="+opts.PublishedImageRepository)
 }
@@ -0,0 +1,190 @@
+/*
+Copyright 2021 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/blang/semver"
+	"github.com/google/go-github/v35/github"
+	"k8s.io/utils/ptr"
+
+	"github.com/cert-manager/release/pkg/release"
+)
+
+// githubReleaseRequest is the body of a request to create or edit a GitHub
+// release. It adds the make_latest field, which go-github doesn't support, to
+// github.RepositoryRelease.
+type githubReleaseRequest struct {
+	*github.RepositoryRelease
+
+	// MakeLatest is "true" if the release should be marked as the latest
+	// release of the repository once it's published, or "false" otherwise.
+	MakeLatest *string `json:"make_latest,omitempty"`
+}
+
+// createGitHubRelease creates a GitHub release.
+// GitHub API docs: https://docs.github.com/en/rest/releases/releases#create-a-release
+func createGitHubRelease(ctx context.Context, githubClient *github.Client, owner, repo string, ghRelease *githubReleaseRequest) (*github.RepositoryRelease, *github.Response, error) {
+	return doGitHubReleaseRequest(ctx, githubClient, http.MethodPost, fmt.Sprintf("repos/%s/%s/releases", owner, repo), ghRelease)
+}
+
+// editGitHubRelease edits the GitHub release with the given ID.
+// GitHub API docs: https://docs.github.com/en/rest/releases/releases#update-a-release
+func editGitHubRelease(ctx context.Context, githubClient *github.Client, owner, repo string, id int64, ghRelease *githubReleaseRequest) (*github.RepositoryRelease, *github.Response, error) {
+	return doGitHubReleaseRequest(ctx, githubClient, http.MethodPatch, fmt.Sprintf("repos/%s/%s/releases/%d", owner, repo, id), ghRelease)
+}
+
+func doGitHubReleaseRequest(ctx context.Context, githubClient *github.Client, method, u string, ghRelease *githubReleaseRequest) (*github.RepositoryRelease, *github.Response, error) {
+	req, err := githubClient.NewRequest(method, u, ghRelease)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	r := new(github.RepositoryRelease)
+	resp, err := githubClient.Do(ctx, req, r)
+	if err != nil {
+		return nil, resp, err
+	}
+
+	return r, resp, nil
+}
+
+// isPrerelease returns true if the given release version has a semver
+// pre-release component, e.g. v1.15.0-alpha.0.
+func isPrerelease(version string) (bool, error) {
+	v, err := parseReleaseVersion(version)
+	if err != nil {
+		return false, err
+	}
+
+	return len(v.Pre) > 0, nil
+}
+
+// isLatestVersion returns true if the given release version is a GA release
+// and no GA release in tags is higher than it. Tags which aren't versions are
+// ignored.
+func isLatestVersion(version string, tags []string) (bool, error) {
+	v, err := parseReleaseVersion(version)
+	if err != nil {
+		return false, err
+	}
+
+	if len(v.Pre) > 0 {
+		return false, nil
+	}
+
+	for _, tag := range tags {
+		t, err := parseReleaseVersion(tag)
+		if err != nil || len(t.Pre) > 0 {
+			continue
+		}
+		if t.GT(v) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+func parseReleaseVersion(version string) (semver.Version, error) {
+	v, ok := strings.CutPrefix(version, "v")
+	if !ok {
+		return semver.Version{}, fmt.Errorf("invalid release version %q: version number must have a leading 'v' character", version)
+	}
+
+	parsed, err := semver.Parse(v)
+	if err != nil {
+		return semver.Version{}, fmt.Errorf("invalid release version %q: %w", version, err)
+	}
+
+	return parsed, nil
+}
+
+// isLatestGitHubRelease returns true if the given release version should be
+// marked as the latest release of the GitHub repository, because it's higher
+// than every GA release already tagged in it.
+func isLatestGitHubRelease(ctx context.Context, o *gcbPublishOptions, githubClient *github.Client, version string) (bool, error) {
+	var tags []string
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		page, resp, err := githubClient.Repositories.ListTags(ctx, o.PublishedGitHubOrg, o.PublishedGitHubRepo, opts)
+		if err != nil {
+			return false, fmt.Errorf("failed to list tags in %s/%s: %w", o.PublishedGitHubOrg, o.PublishedGitHubRepo, err)
+		}
+		for _, tag := range page {
+			tags = append(tags, tag.GetName())
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	return isLatestVersion(version, tags)
+}
+
+// makeLatest returns the value of the make_latest field of a GitHub release.
+func makeLatest(latest bool) *string {
+	return ptr.To(fmt.Sprintf("%t", latest))
+}
+
+// undraftGitHubRelease publishes the draft GitHub release created by
+// pushGitHubRelease, marking it as the latest release if no higher GA release
+// has been tagged since it was created.
+func undraftGitHubRelease(ctx context.Context, o *gcbPublishOptions, rel *release.Unpacked) error {
+	entry, ok := o.ledger.Get(release.LedgerEntryGitHubRelease, rel.ReleaseVersion)
+	if !ok {
+		return fmt.Errorf("can't publish the GitHub release %q as no draft release was created for it; run the githubrelease publish action first", rel.ReleaseVersion)
+	}
+
+	githubClient, err := o.GitHubClient(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create github client for publishing github release: %w", err)
+	}
+
+	latest, err := isLatestGitHubRelease(ctx, o, githubClient, rel.ReleaseVersion)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Publishing draft GitHub release %s (latest: %t)", entry.URL, latest)
+	githubRelease, resp, err := editGitHubRelease(ctx, githubClient, o.PublishedGitHubOrg, o.PublishedGitHubRepo, entry.ID, &githubReleaseRequest{
+		RepositoryRelease: &github.RepositoryRelease{Draft: ptr.To(false)},
+		MakeLatest:        makeLatest(latest),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to publish GitHub release: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected response code when publishing GitHub release %d", resp.StatusCode)
+	}
+
+	// the URL of a draft release changes once it's published
+	entry.URL = githubRelease.GetHTMLURL()
+	if err := o.ledger.Record(ctx, entry); err != nil {
+		return err
+	}
+
+	log.Printf("Published GitHub release %s", githubRelease.GetHTMLURL())
+	return nil
+}
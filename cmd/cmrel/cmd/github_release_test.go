@@ -0,0 +1,244 @@
+/*
+Copyright 2021 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/v35/github"
+	"k8s.io/utils/ptr"
+)
+
+func TestIsPrerelease(t *testing.T) {
+	tests := map[string]struct {
+		version   string
+		expected  bool
+		expectErr string
+	}{
+		"GA release":        {version: "v1.15.0"},
+		"alpha release":     {version: "v1.15.0-alpha.0", expected: true},
+		"release candidate": {version: "v1.15.0-rc.1", expected: true},
+		"build metadata":    {version: "v1.15.0+abcdef"},
+		"no leading v":      {version: "1.15.0", expectErr: "leading 'v'"},
+		"not a version":     {version: "vnext", expectErr: `invalid release version "vnext"`},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			prerelease, err := isPrerelease(test.version)
+			if test.expectErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.expectErr) {
+					t.Errorf("expected error containing %q, got: %v", test.expectErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if prerelease != test.expected {
+				t.Errorf("expected pre-release %t, got %t", test.expected, prerelease)
+			}
+		})
+	}
+}
+
+func TestIsLatestVersion(t *testing.T) {
+	tags := []string{"v1.14.5", "v1.15.0", "v1.16.0-rc.1", "cmd/ctl/v2.0.0", "not-a-version"}
+
+	tests := map[string]struct {
+		version  string
+		tags     []string
+		expected bool
+	}{
+		"highest GA release": {
+			version:  "v1.15.1",
+			tags:     tags,
+			expected: true,
+		},
+		"higher than a pre-release": {
+			version:  "v1.16.0",
+			tags:     tags,
+			expected: true,
+		},
+		"already tagged": {
+			version:  "v1.15.0",
+			tags:     tags,
+			expected: true,
+		},
+		"patch of an older minor version": {
+			version: "v1.14.6",
+			tags:    tags,
+		},
+		"pre-release": {
+			version: "v1.17.0-alpha.0",
+			tags:    tags,
+		},
+		"first release": {
+			version:  "v0.1.0",
+			expected: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			latest, err := isLatestVersion(test.version, test.tags)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if latest != test.expected {
+				t.Errorf("expected latest %t, got %t", test.expected, latest)
+			}
+		})
+	}
+}
+
+func TestCreateGitHubRelease(t *testing.T) {
+	var body map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/repos/cert-manager/cert-manager/releases" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Error(err)
+		}
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"id": 1, "html_url": "https://github.com/cert-manager/cert-manager/releases/tag/untagged-1"}`))
+	}))
+	defer server.Close()
+
+	githubClient := github.NewClient(nil)
+	githubClient.BaseURL, _ = url.Parse(server.URL + "/")
+
+	ghRelease, _, err := createGitHubRelease(context.TODO(), githubClient, "cert-manager", "cert-manager", &githubReleaseRequest{
+		RepositoryRelease: &github.RepositoryRelease{
+			TagName:    ptr.To("v1.15.0-rc.1"),
+			Draft:      ptr.To(true),
+			Prerelease: ptr.To(true),
+		},
+		MakeLatest: makeLatest(false),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ghRelease.GetID() != 1 {
+		t.Errorf("expected release ID 1, got %d", ghRelease.GetID())
+	}
+
+	expected := map[string]any{"tag_name": "v1.15.0-rc.1", "draft": true, "prerelease": true, "make_latest": "false"}
+	for k, v := range expected {
+		if body[k] != v {
+			t.Errorf("expected %s to be %v in the request, got %v", k, v, body[k])
+		}
+	}
+	if len(body) != len(expected) {
+		t.Errorf("unexpected fields in request: %v", body)
+	}
+}
+
+func TestReleaseNotesVerification(t *testing.T) {
+	const kmsKey = "projects/cert-manager-release/locations/europe-west1/keyRings/cert-manager-release/cryptoKeys/cert-manager-release-signing-key/cryptoKeyVersions/1"
+
+	tests := map[string]struct {
+		signingKeys        string
+		skipSigning        bool
+		expectedCosignKeys []string
+		expectSignature    bool
+	}{
+		"KMS key": {
+			signingKeys:        kmsKey,
+			expectedCosignKeys: []string{"gcpkms://projects/cert-manager-release/locations/europe-west1/keyRings/cert-manager-release/cryptoKeys/cert-manager-release-signing-key/versions/1"},
+			expectSignature:    true,
+		},
+		"keys which cosign can't use are left out": {
+			signingKeys:        "ephemeral://test," + kmsKey,
+			expectedCosignKeys: []string{"gcpkms://projects/cert-manager-release/locations/europe-west1/keyRings/cert-manager-release/cryptoKeys/cert-manager-release-signing-key/versions/1"},
+			expectSignature:    true,
+		},
+		"only keys which cosign can't use": {
+			signingKeys:     "ephemeral://test",
+			expectSignature: true,
+		},
+		"signing skipped": {
+			signingKeys: kmsKey,
+			skipSigning: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			o := NewGCBPublishOptions()
+			o.SigningKMSKey = test.signingKeys
+			o.SkipSigning = test.skipSigning
+
+			verification, err := releaseNotesVerification(o)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !slices.Equal(verification.CosignKeys, test.expectedCosignKeys) {
+				t.Errorf("expected cosign keys %v, got %v", test.expectedCosignKeys, verification.CosignKeys)
+			}
+
+			if verification.ChecksumsFile != checksumsFileName {
+				t.Errorf("expected checksums file %q, got %q", checksumsFileName, verification.ChecksumsFile)
+			}
+
+			if hasSignature := verification.ChecksumsSignatureFile != ""; hasSignature != test.expectSignature {
+				t.Errorf("expected checksums signature: %t, got %q", test.expectSignature, verification.ChecksumsSignatureFile)
+			}
+		})
+	}
+}
+
+func TestReleaseImageIndexes(t *testing.T) {
+	rel, _ := loadTestRelease(t, writeTestReleaseDir(t, "v1.15.0", "amd64", "arm64"))
+
+	o := NewGCBPublishOptions()
+	o.PublishedImageRepository = "quay.io/jetstack"
+
+	indexes, err := releaseImageIndexes(o, rel)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := expectedRegistryContent(o.PublishedImageRepository, rel)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(indexes) != len(rel.ComponentImageBundles) {
+		t.Fatalf("expected an image index for each of the %d components, got %v", len(rel.ComponentImageBundles), indexes)
+	}
+	for _, index := range indexes {
+		if !slices.Contains(content, registryContent{ref: index.Name, digest: index.Digest}) {
+			t.Errorf("expected %q (%s) to be pushed when publishing", index.Name, index.Digest)
+		}
+		if !strings.HasSuffix(index.Name, ":v1.15.0") {
+			t.Errorf("expected %q to be an image index, not an image", index.Name)
+		}
+	}
+}
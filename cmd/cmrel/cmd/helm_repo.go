@@ -0,0 +1,201 @@
+/*
+Copyright 2021 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	flag "github.com/spf13/pflag"
+
+	"github.com/cert-manager/release/pkg/release"
+	"github.com/cert-manager/release/pkg/release/helm"
+)
+
+const (
+	helmChartRepoTypeGitHub = "github"
+	helmChartRepoTypeGitLab = "gitlab"
+	helmChartRepoTypeGit    = "git"
+)
+
+// helmChartRepoOptions selects where the helmchartpr action publishes Helm
+// charts to. GitHub repositories are configured with the
+// --published-helm-chart-github-* flags.
+type helmChartRepoOptions struct {
+	// PublishedHelmChartRepoType is the kind of repository Helm charts are
+	// published to: "github", "gitlab" or "git".
+	PublishedHelmChartRepoType string
+
+	// PublishedHelmChartGitLabURL is the URL of the GitLab instance hosting
+	// the Helm chart project.
+	PublishedHelmChartGitLabURL string
+
+	// PublishedHelmChartGitLabProject is the full path of the GitLab project
+	// for Helm charts, such as "jetstack/jetstack-charts".
+	PublishedHelmChartGitLabProject string
+
+	// PublishedHelmChartGitLabBranch is the name of the main branch in the
+	// GitLab project for Helm charts.
+	PublishedHelmChartGitLabBranch string
+
+	// PublishedHelmChartGitURL is the SSH or HTTPS URL of the plain git
+	// repository for Helm charts.
+	PublishedHelmChartGitURL string
+
+	// PublishedHelmChartGitBranch is the name of the main branch in the plain
+	// git repository for Helm charts.
+	PublishedHelmChartGitBranch string
+}
+
+func (o *helmChartRepoOptions) addFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.PublishedHelmChartRepoType, "published-helm-chart-repo-type", helmChartRepoTypeGitHub, "The kind of repository Helm charts are published to by the helmchartpr action: 'github', 'gitlab' (a merge request is created with the token in GITLAB_TOKEN) or 'git' (a branch is pushed using the SSH key in GIT_SSH_KEY_FILE or an SSH agent, or the HTTPS credentials in GIT_USERNAME and GIT_TOKEN).")
+	fs.StringVar(&o.PublishedHelmChartGitLabURL, "published-helm-chart-gitlab-url", release.DefaultHelmChartGitLabURL, "The URL of the GitLab instance hosting the Helm chart project.")
+	fs.StringVar(&o.PublishedHelmChartGitLabProject, "published-helm-chart-gitlab-project", "", "The full path of the GitLab project for Helm charts, such as 'jetstack/jetstack-charts'.")
+	fs.StringVar(&o.PublishedHelmChartGitLabBranch, "published-helm-chart-gitlab-branch", release.DefaultHelmChartGitBranch, "The name of the main branch in the GitLab project for Helm charts.")
+	fs.StringVar(&o.PublishedHelmChartGitURL, "published-helm-chart-git-url", "", "The SSH or HTTPS URL of the git repository for Helm charts.")
+	fs.StringVar(&o.PublishedHelmChartGitBranch, "published-helm-chart-git-branch", release.DefaultHelmChartGitBranch, "The name of the main branch in the git repository for Helm charts.")
+}
+
+func (o *helmChartRepoOptions) print() {
+	log.Printf("  PublishedHelmChartRepoType: %q", o.PublishedHelmChartRepoType)
+	log.Printf("  PublishedHelmChartGitLabURL: %q", o.PublishedHelmChartGitLabURL)
+	log.Printf("  PublishedHelmChartGitLabProject: %q", o.PublishedHelmChartGitLabProject)
+	log.Printf("  PublishedHelmChartGitLabBranch: %q", o.PublishedHelmChartGitLabBranch)
+	log.Printf("  PublishedHelmChartGitURL: %q", o.PublishedHelmChartGitURL)
+	log.Printf("  PublishedHelmChartGitBranch: %q", o.PublishedHelmChartGitBranch)
+}
+
+// helmChartPRDestination returns a description of the repository which Helm
+// charts are published to by the helmchartpr action, and its main branch.
+func helmChartPRDestination(o *gcbPublishOptions) (string, string, error) {
+	switch o.PublishedHelmChartRepoType {
+	case "", helmChartRepoTypeGitHub:
+		return fmt.Sprintf("github.com/%s/%s", o.PublishedHelmChartGitHubOwner, o.PublishedHelmChartGitHubRepo), o.PublishedHelmChartGitHubBranch, nil
+
+	case helmChartRepoTypeGitLab:
+		if o.PublishedHelmChartGitLabProject == "" {
+			return "", "", fmt.Errorf("must set published-helm-chart-gitlab-project to publish Helm charts to GitLab")
+		}
+		return fmt.Sprintf("%s/%s", o.PublishedHelmChartGitLabURL, o.PublishedHelmChartGitLabProject), o.PublishedHelmChartGitLabBranch, nil
+
+	case helmChartRepoTypeGit:
+		if o.PublishedHelmChartGitURL == "" {
+			return "", "", fmt.Errorf("must set published-helm-chart-git-url to publish Helm charts to a git repository")
+		}
+		return o.PublishedHelmChartGitURL, o.PublishedHelmChartGitBranch, nil
+
+	default:
+		return "", "", fmt.Errorf("unknown Helm chart repository type %q; must be one of %q, %q or %q", o.PublishedHelmChartRepoType, helmChartRepoTypeGitHub, helmChartRepoTypeGitLab, helmChartRepoTypeGit)
+	}
+}
+
+// newHelmChartRepositoryManager returns the helm.RepositoryManager for the
+// repository which Helm charts are published to by the helmchartpr action,
+// using credentials from the environment.
+func newHelmChartRepositoryManager(ctx context.Context, o *gcbPublishOptions) (helm.RepositoryManager, error) {
+	if _, _, err := helmChartPRDestination(o); err != nil {
+		return nil, err
+	}
+
+	switch o.PublishedHelmChartRepoType {
+	case helmChartRepoTypeGitLab:
+		token := os.Getenv("GITLAB_TOKEN")
+		if token == "" {
+			return nil, fmt.Errorf("GITLAB_TOKEN environment variable not set - a token is required to publish Helm charts to GitLab")
+		}
+
+		client, err := helm.NewGitLabClient(o.PublishedHelmChartGitLabURL, token)
+		if err != nil {
+			return nil, err
+		}
+
+		return helm.NewGitLabRepositoryManager(client, o.PublishedHelmChartGitLabProject, o.PublishedHelmChartGitLabBranch), nil
+
+	case helmChartRepoTypeGit:
+		auth, err := gitAuth(o.PublishedHelmChartGitURL)
+		if err != nil {
+			return nil, err
+		}
+
+		return helm.NewGitRepositoryManager(o.PublishedHelmChartGitURL, o.PublishedHelmChartGitBranch, auth), nil
+
+	default:
+		githubClient, err := o.GitHubClient(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create github client for pushing helm chart PR: %w", err)
+		}
+
+		return helm.NewGitHubRepositoryManager(
+			&helm.GitHubClient{
+				GitClient:          githubClient.Git,
+				PullRequestClient:  githubClient.PullRequests,
+				RepositoriesClient: githubClient.Repositories,
+				UsersClient:        githubClient.Users,
+			},
+			o.PublishedHelmChartGitHubOwner,
+			o.PublishedHelmChartGitHubRepo,
+			o.PublishedHelmChartGitHubBranch,
+		), nil
+	}
+}
+
+// gitAuth returns credentials for the git repository at url from the
+// environment. SSH remotes use the private key in the file named by
+// GIT_SSH_KEY_FILE, or an SSH agent if that's not set. HTTPS remotes use
+// GIT_USERNAME and GIT_TOKEN if they're set.
+func gitAuth(url string) (transport.AuthMethod, error) {
+	endpoint, err := transport.NewEndpoint(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid git URL %q: %w", url, err)
+	}
+
+	switch endpoint.Protocol {
+	case "ssh":
+		user := endpoint.User
+		if user == "" {
+			user = "git"
+		}
+
+		if keyFile := os.Getenv("GIT_SSH_KEY_FILE"); keyFile != "" {
+			return gitssh.NewPublicKeysFromFile(user, keyFile, "")
+		}
+
+		return gitssh.NewSSHAgentAuth(user)
+
+	case "http", "https":
+		token := os.Getenv("GIT_TOKEN")
+		if token == "" {
+			return nil, nil
+		}
+
+		username := os.Getenv("GIT_USERNAME")
+		if username == "" {
+			// most git hosts accept any username with a token
+			username = "cmrel"
+		}
+
+		return &githttp.BasicAuth{Username: username, Password: token}, nil
+
+	default:
+		return nil, nil
+	}
+}
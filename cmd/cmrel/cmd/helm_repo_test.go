@@ -0,0 +1,99 @@
+/*
+Copyright 2021 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHelmChartPRDestination(t *testing.T) {
+	tests := map[string]struct {
+		opts helmChartRepoOptions
+
+		expectedRepository string
+		expectedBranch     string
+		expectedErr        string
+	}{
+		"GitHub by default": {
+			expectedRepository: "github.com/jetstack/jetstack-charts",
+			expectedBranch:     "main",
+		},
+		"GitLab": {
+			opts: helmChartRepoOptions{
+				PublishedHelmChartRepoType:      helmChartRepoTypeGitLab,
+				PublishedHelmChartGitLabURL:     "https://gitlab.example.com",
+				PublishedHelmChartGitLabProject: "platform/charts",
+				PublishedHelmChartGitLabBranch:  "master",
+			},
+			expectedRepository: "https://gitlab.example.com/platform/charts",
+			expectedBranch:     "master",
+		},
+		"GitLab without a project": {
+			opts: helmChartRepoOptions{
+				PublishedHelmChartRepoType: helmChartRepoTypeGitLab,
+			},
+			expectedErr: "must set published-helm-chart-gitlab-project",
+		},
+		"git": {
+			opts: helmChartRepoOptions{
+				PublishedHelmChartRepoType:  helmChartRepoTypeGit,
+				PublishedHelmChartGitURL:    "git@git.example.com:platform/charts.git",
+				PublishedHelmChartGitBranch: "main",
+			},
+			expectedRepository: "git@git.example.com:platform/charts.git",
+			expectedBranch:     "main",
+		},
+		"git without a URL": {
+			opts: helmChartRepoOptions{
+				PublishedHelmChartRepoType: helmChartRepoTypeGit,
+			},
+			expectedErr: "must set published-helm-chart-git-url",
+		},
+		"unknown type": {
+			opts: helmChartRepoOptions{
+				PublishedHelmChartRepoType: "bitbucket",
+			},
+			expectedErr: `unknown Helm chart repository type "bitbucket"`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			o := NewGCBPublishOptions()
+			o.PublishedHelmChartGitHubOwner = "jetstack"
+			o.PublishedHelmChartGitHubRepo = "jetstack-charts"
+			o.PublishedHelmChartGitHubBranch = "main"
+			o.helmChartRepoOptions = test.opts
+
+			repository, branch, err := helmChartPRDestination(o)
+			if test.expectedErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.expectedErr) {
+					t.Fatalf("expected error containing %q, got: %v", test.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if repository != test.expectedRepository || branch != test.expectedBranch {
+				t.Errorf("expected %s@%s, got %s@%s", test.expectedRepository, test.expectedBranch, repository, branch)
+			}
+		})
+	}
+}
@@ -0,0 +1,243 @@
+/*
+Copyright 2021 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+
+	"github.com/cert-manager/release/pkg/release"
+	"github.com/cert-manager/release/pkg/release/publish/registry"
+)
+
+// imageMirrorsUsage describes the --image-mirrors flag in flag usage; see registry.Mirrors
+const imageMirrorsUsage = "Optional path to a YAML file listing image repositories which the release images & image indexes are mirrored to, with the same digests, along with the credentials and signing keys to use for each. Floating image tags moved by the pushfloatingimagetags action are moved in every mirror too."
+
+// registrySigner signs and attests content in a registry using cosign.
+type registrySigner struct {
+	// keys are the keys to sign with, in the format of --signing-kms-key, or
+	// empty if signing is skipped
+	keys string
+
+	// env holds extra environment variables for cosign, setting the
+	// credentials it uses for the registry
+	env []string
+}
+
+// signer returns the signer for content in the published image repository
+// and the Helm chart OCI repository.
+func (o *gcbPublishOptions) signer() registrySigner {
+	if o.SkipSigning {
+		return registrySigner{}
+	}
+
+	return registrySigner{keys: o.SigningKMSKey}
+}
+
+// imageRegistry is an image repository which release images and image
+// indexes are pushed to: either the published image repository or one of
+// its mirrors.
+type imageRegistry struct {
+	repository string
+	publisher  *registry.Publisher
+	signer     registrySigner
+}
+
+// imageRegistries returns the published image repository followed by each of
+// its mirrors. The Docker config files of mirrors which have them are written
+// to dir.
+func (o *gcbPublishOptions) imageRegistries(dir string) ([]imageRegistry, error) {
+	mirrors, err := loadImageMirrors(o)
+	if err != nil {
+		return nil, err
+	}
+
+	registries := []imageRegistry{{
+		repository: o.PublishedImageRepository,
+		publisher:  o.newPublisher(),
+		signer:     o.signer(),
+	}}
+
+	for i, m := range mirrors.Mirrors {
+		signer := o.signer()
+		if m.SkipSigning || o.SkipSigning {
+			signer.keys = ""
+		} else if m.SigningKeys != "" {
+			signer.keys = m.SigningKeys
+		}
+
+		var dockerConfigDir string
+		if m.DockerConfigEnv != "" {
+			dockerConfigDir = filepath.Join(dir, strconv.Itoa(i))
+			if err := os.MkdirAll(dockerConfigDir, 0o700); err != nil {
+				return nil, err
+			}
+
+			if err := m.WriteDockerConfig(dockerConfigDir); err != nil {
+				return nil, err
+			}
+
+			signer.env = []string{"DOCKER_CONFIG=" + dockerConfigDir}
+		}
+
+		registries = append(registries, imageRegistry{
+			repository: m.Repository,
+			publisher:  o.newPublisher(remote.WithAuthFromKeychain(m.Keychain(dockerConfigDir))),
+			signer:     signer,
+		})
+	}
+
+	return registries, nil
+}
+
+// loadImageMirrors reads the mirrors of the published image repository from
+// o.ImageMirrors.
+func loadImageMirrors(o *gcbPublishOptions) (*registry.Mirrors, error) {
+	mirrors, err := registry.LoadMirrors(o.ImageMirrors)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, m := range mirrors.Mirrors {
+		if strings.TrimSuffix(m.Repository, "/") == strings.TrimSuffix(o.PublishedImageRepository, "/") {
+			return nil, fmt.Errorf("image mirror %q is the published image repository", m.Repository)
+		}
+	}
+
+	return mirrors, nil
+}
+
+// checkMirrorSigning returns an error if signing is skipped but a mirror sets
+// its own signing keys, which would otherwise be silently ignored. It isn't
+// part of loadImageMirrors since unpublishing never signs anything.
+func checkMirrorSigning(mirrors *registry.Mirrors, skipSigning bool) error {
+	if !skipSigning {
+		return nil
+	}
+
+	for _, m := range mirrors.Mirrors {
+		if m.SigningKeys != "" {
+			return fmt.Errorf("image mirror %q sets signingKeys, but signing is skipped", m.Repository)
+		}
+	}
+
+	return nil
+}
+
+// encodeImageMirrors validates the image mirrors at the given path and returns them
+// base64 encoded, to be passed to a GCB build in the _IMAGE_MIRRORS substitution, in
+// the same way as encodePGPProfile. An empty path gives an empty string, which the
+// build writes out as an empty file meaning there are no mirrors.
+func encodeImageMirrors(path string, skipSigning bool) (string, error) {
+	if path == "" {
+		return "", nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read image mirrors: %w", err)
+	}
+
+	mirrors, err := registry.ParseMirrors(data)
+	if err != nil {
+		return "", fmt.Errorf("invalid image mirrors in %q: %w", path, err)
+	}
+
+	if err := checkMirrorSigning(mirrors, skipSigning); err != nil {
+		return "", fmt.Errorf("invalid image mirrors in %q: %w", path, err)
+	}
+
+	return base64.StdEncoding.EncodeToString(data), nil
+}
+
+// mirroredRef returns the reference in the published image repository which
+// ref, in one of the given mirrors, is a mirror of.
+func mirroredRef(publishedImageRepository string, mirrors []string, ref string) (string, bool) {
+	mirror, ok := imageRepositoryOf(mirrors, ref)
+	if !ok {
+		return "", false
+	}
+
+	rest := strings.TrimPrefix(ref, strings.TrimSuffix(mirror, "/")+"/")
+	return strings.TrimSuffix(publishedImageRepository, "/") + "/" + rest, true
+}
+
+// imageRepositoryOf returns the one of the given image repositories which
+// holds ref.
+func imageRepositoryOf(repositories []string, ref string) (string, bool) {
+	// the longest matching repository wins, in case one repository is
+	// nested within another
+	sorted := append([]string(nil), repositories...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return len(sorted[i]) > len(sorted[j])
+	})
+
+	for _, repository := range sorted {
+		if strings.HasPrefix(ref, strings.TrimSuffix(repository, "/")+"/") {
+			return repository, true
+		}
+	}
+
+	return "", false
+}
+
+// checkMirroredDigests returns an error unless the publish ledger records
+// that every image and image index in the release was pushed to every
+// mirror with the same digest as in the published image repository.
+func checkMirroredDigests(o *gcbPublishOptions, rel *release.Unpacked, registries []imageRegistry) error {
+	published := registries[0].repository
+	for _, mirror := range registries[1:] {
+		for name, tars := range rel.ComponentImageBundles {
+			for _, t := range tars {
+				if err := checkMirroredDigest(o.ledger, release.LedgerEntryImage, buildImageTag(published, name, t.Architecture(), rel.ReleaseVersion), buildImageTag(mirror.repository, name, t.Architecture(), rel.ReleaseVersion)); err != nil {
+					return err
+				}
+			}
+
+			if err := checkMirroredDigest(o.ledger, release.LedgerEntryImageIndex, buildImageIndexName(published, name, rel.ReleaseVersion), buildImageIndexName(mirror.repository, name, rel.ReleaseVersion)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func checkMirroredDigest(ledger *release.Ledger, kind release.LedgerEntryKind, published, mirrored string) error {
+	entry, ok := ledger.Get(kind, published)
+	if !ok {
+		return fmt.Errorf("%q isn't recorded in the publish ledger", published)
+	}
+
+	mirroredEntry, ok := ledger.Get(kind, mirrored)
+	if !ok {
+		return fmt.Errorf("mirror %q of %q isn't recorded in the publish ledger", mirrored, published)
+	}
+
+	if mirroredEntry.Digest != entry.Digest {
+		return fmt.Errorf("mirror %q has digest %q but %q has digest %q", mirrored, mirroredEntry.Digest, published, entry.Digest)
+	}
+
+	return nil
+}
@@ -0,0 +1,199 @@
+/*
+Copyright 2021 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cert-manager/release/internal/testregistry"
+	"github.com/cert-manager/release/pkg/release"
+	"github.com/cert-manager/release/pkg/release/publish/registry"
+)
+
+func TestMirroredRef(t *testing.T) {
+	mirrors := []string{"ghcr.io/cert-manager", "ghcr.io/cert-manager/mirror"}
+
+	tests := map[string]struct {
+		ref      string
+		expected string
+	}{
+		"mirrored image": {
+			ref:      "ghcr.io/cert-manager/cert-manager-controller:v1.15.0",
+			expected: "quay.io/jetstack/cert-manager-controller:v1.15.0",
+		},
+		"nested mirror": {
+			ref:      "ghcr.io/cert-manager/mirror/cert-manager-controller:v1.15.0",
+			expected: "quay.io/jetstack/cert-manager-controller:v1.15.0",
+		},
+		"published image": {
+			ref: "quay.io/jetstack/cert-manager-controller:v1.15.0",
+		},
+		"repository with a mirror as a prefix": {
+			ref: "ghcr.io/cert-manager-other/cert-manager-controller:v1.15.0",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ref, ok := mirroredRef("quay.io/jetstack", mirrors, test.ref)
+			if ok != (test.expected != "") || ref != test.expected {
+				t.Errorf("expected %q, got %q (%t)", test.expected, ref, ok)
+			}
+		})
+	}
+}
+
+func TestPushContainerImagesToMirrors(t *testing.T) {
+	ctx := context.TODO()
+	published := testregistry.New(t) + "/jetstack"
+	mirror := testregistry.New(t) + "/cert-manager"
+
+	// the test registry doesn't require credentials, but they must be
+	// readable
+	t.Setenv("TEST_MIRROR_DOCKER_CONFIG", `{"auths":{"`+strings.Split(mirror, "/")[0]+`":{"auth":"dXNlcjpwYXNz"}}}`)
+
+	mirrorsPath := filepath.Join(t.TempDir(), "mirrors.yaml")
+	if err := os.WriteFile(mirrorsPath, []byte("mirrors:\n- repository: "+mirror+"\n  dockerConfigEnv: TEST_MIRROR_DOCKER_CONFIG\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	rel, ledger := loadTestRelease(t, writeTestReleaseDir(t, "v1.15.0", "amd64"))
+
+	o := NewGCBPublishOptions()
+	o.PublishedImageRepository = published
+	o.ImageMirrors = mirrorsPath
+	o.SkipSigning = true
+	o.ledger = ledger
+
+	if err := pushContainerImages(ctx, o, rel); err != nil {
+		t.Fatal(err)
+	}
+
+	publisher := registry.NewPublisher()
+	for _, ref := range []func(repo string) string{
+		func(repo string) string { return buildImageTag(repo, "controller", "amd64", "v1.15.0") },
+		func(repo string) string { return buildImageIndexName(repo, "controller", "v1.15.0") },
+	} {
+		publishedDigest, err := publisher.RemoteDigest(ctx, ref(published))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		mirroredDigest, err := publisher.RemoteDigest(ctx, ref(mirror))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if publishedDigest == "" || mirroredDigest != publishedDigest {
+			t.Errorf("expected %q to be mirrored with digest %q, got %q", ref(mirror), publishedDigest, mirroredDigest)
+		}
+	}
+
+	report, err := buildPublishReport(rel, ledger, published, []string{mirror}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(report.ImageIndexes) != 1 || len(report.ImageIndexes[0].Mirrors) != 1 || report.ImageIndexes[0].Mirrors[0].Digest != report.ImageIndexes[0].Digest {
+		t.Errorf("expected the mirrored image index to be reported with the published image index, got %#v", report.ImageIndexes)
+	}
+
+	// publishing again resumes without pushing anything
+	if err := pushContainerImages(ctx, o, rel); err != nil {
+		t.Fatal(err)
+	}
+
+	// a mirror with a different digest is refused
+	mirroredIndex := buildImageIndexName(mirror, "controller", "v1.15.0")
+	mirroredIndexEntry, ok := ledger.Get(release.LedgerEntryImageIndex, mirroredIndex)
+	if !ok {
+		t.Fatalf("expected %q to be recorded in the ledger", mirroredIndex)
+	}
+	if err := ledger.Record(ctx, release.LedgerEntry{Kind: release.LedgerEntryImageIndex, Name: mirroredIndex, Digest: "sha256:0000"}); err != nil {
+		t.Fatal(err)
+	}
+	registries, err := o.imageRegistries(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := checkMirroredDigests(o, rel, registries); err == nil || !strings.Contains(err.Error(), mirroredIndex) {
+		t.Errorf("expected an error for a mirror with a different digest, got: %v", err)
+	}
+
+	// unpublishing removes the mirrored images
+	if err := ledger.Record(ctx, mirroredIndexEntry); err != nil {
+		t.Fatal(err)
+	}
+	uo := &unpublishOptions{PublishedImageRepository: published, ImageMirrors: mirrorsPath, UnpublishActions: []string{"pushcontainerimages"}}
+	plan, err := buildPublishPlan(uo.publishOptions(), rel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Mirrors) != 1 || plan.Mirrors[0].Repository != mirror {
+		t.Fatalf("expected the mirror to be planned, got %#v", plan.Mirrors)
+	}
+
+	if err := unpublishImageMirrors(ctx, uo, plan, ledger); err != nil {
+		t.Fatal(err)
+	}
+
+	digest, err := publisher.RemoteDigest(ctx, mirroredIndex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if digest != "" {
+		t.Errorf("expected %q to be deleted", mirroredIndex)
+	}
+	if _, ok := ledger.Get(release.LedgerEntryImageIndex, mirroredIndex); ok {
+		t.Errorf("expected %q to be removed from the ledger", mirroredIndex)
+	}
+}
+
+func TestCheckMirrorSigning(t *testing.T) {
+	mirrorsPath := filepath.Join(t.TempDir(), "mirrors.yaml")
+	if err := os.WriteFile(mirrorsPath, []byte("mirrors:\n- repository: ghcr.io/cert-manager\n  signingKeys: file://cosign.key\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	o := NewGCBPublishOptions()
+	o.PublishedImageRepository = "quay.io/jetstack"
+	o.ImageMirrors = mirrorsPath
+
+	// unpublishing skips signing, but must still be able to load mirrors
+	// which set signing keys
+	o.SkipSigning = true
+	mirrors, err := loadImageMirrors(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := checkMirrorSigning(mirrors, false); err != nil {
+		t.Errorf("expected mirror signing keys to be accepted when signing, got: %v", err)
+	}
+
+	if err := checkMirrorSigning(mirrors, true); err == nil {
+		t.Error("expected mirror signing keys to be rejected when signing is skipped")
+	}
+
+	if _, err := encodeImageMirrors(mirrorsPath, true); err == nil {
+		t.Error("expected encoding mirror signing keys to fail when signing is skipped")
+	}
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2021 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/cert-manager/release/pkg/sign"
+)
+
+const (
+	keysCommand         = "keys"
+	keysDescription     = "Subcommands for managing the keys used to sign releases"
+	keysDescriptionLong = `keys contains commands for managing the keys which cert-manager releases are
+signed with.
+
+Keys are rotated by signing releases with both the old and the new key for a
+transition period, passing both keys as a comma-separated list to
+--signing-kms-key. During that period the published keyring holds both public
+keys, so users can verify releases with either key. Once no supported release
+relies on the old key alone, it can be dropped.`
+)
+
+func keysCmd(o *rootOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   keysCommand,
+		Short: keysDescription,
+		Long:  keysDescriptionLong,
+	}
+
+	cmd.AddCommand(keysStatusCmd(o))
+
+	return cmd
+}
+
+// loadSigningKeys parses the given comma-separated signing keys, setting the PGP identity of
+// each from the key profiles at profilePath if it's set; see sign.KeyProfiles
+func loadSigningKeys(rawKeys string, profilePath string) ([]sign.SigningKey, error) {
+	keys, err := sign.ParseSigningKeys(rawKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	profiles, err := sign.LoadKeyProfiles(profilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return profiles.Apply(keys), nil
+}
+
+// encodePGPProfile validates the key profiles at the given path and returns them base64
+// encoded, to be passed to a GCB build in the _PGP_PROFILE substitution. Profiles are
+// encoded since substitutions can't hold newlines. An empty path gives an empty string,
+// which the build writes out as an empty file meaning every key uses the default identity.
+func encodePGPProfile(path string) (string, error) {
+	if path == "" {
+		return "", nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read key profiles: %w", err)
+	}
+
+	if _, err := sign.ParseKeyProfiles(data); err != nil {
+		return "", fmt.Errorf("invalid key profiles in %q: %w", path, err)
+	}
+
+	return base64.StdEncoding.EncodeToString(data), nil
+}
@@ -0,0 +1,187 @@
+/*
+Copyright 2021 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	flag "github.com/spf13/pflag"
+
+	"github.com/cert-manager/release/pkg/release"
+	"github.com/cert-manager/release/pkg/sign"
+)
+
+const (
+	keysStatusCommand         = "status"
+	keysStatusDescription     = "List which keys each staged release was signed with"
+	keysStatusLongDescription = `The status command lists the staged releases in the GCS bucket along with the
+keys which signed them, to show which releases are signed by which key while
+keys are being rotated.
+
+The keys which signed the Helm chart are recorded when a release is staged, and
+the keys which signed container images and image indexes are recorded in the
+publish ledger when it's published. Images signed before keys were recorded
+show "unrecorded" instead. "-" means that nothing was signed, or for Helm
+charts that the release was staged before keys were recorded.`
+)
+
+var keysStatusExample = fmt.Sprintf(`To list the signing keys of every release:
+
+%s %s %s
+
+To list only the releases signed by a given key:
+
+%s %s %s --key "projects/<PROJECT_NAME>/locations/<LOCATION>/keyRings/<KEYRING_NAME>/cryptoKeys/<KEY_NAME>/cryptoKeyVersions/<KEY_VERSION>"`, rootCommand, keysCommand, keysStatusCommand, rootCommand, keysCommand, keysStatusCommand)
+
+// unrecordedKeys is shown in place of signing keys for content which was
+// signed before signing keys were recorded
+const unrecordedKeys = "unrecorded"
+
+type keysStatusOptions struct {
+	// The name of the GCS bucket containing the staged releases
+	Bucket string
+
+	// ReleaseVersion, if set, limits the releases listed to those with the
+	// given version.
+	ReleaseVersion string
+
+	// The type of release to list - usually one of 'release' or 'devel'
+	ReleaseType string
+
+	// Key, if set, limits the releases listed to those signed by the given key
+	Key string
+}
+
+func (o *keysStatusOptions) AddFlags(fs *flag.FlagSet, markRequired func(string)) {
+	fs.StringVar(&o.Bucket, "bucket", release.DefaultBucketName, "The name of the GCS bucket containing the staged releases, or a local directory prefixed with 'file://'.")
+	fs.StringVar(&o.ReleaseVersion, "release-version", "", "Optional release version to list the signing keys of.")
+	fs.StringVar(&o.ReleaseType, "release-type", "release", "The type of release to list, usually one of 'release' or 'devel'")
+	fs.StringVar(&o.Key, "key", "", "Optional key to list the releases signed by; "+signingKeyFormats+".")
+}
+
+func (o *keysStatusOptions) print() {
+	log.Printf("keys status options:")
+	log.Printf("  Bucket: %q", o.Bucket)
+	log.Printf("  ReleaseVersion: %q", o.ReleaseVersion)
+	log.Printf("  ReleaseType: %q", o.ReleaseType)
+	log.Printf("  Key: %q", o.Key)
+}
+
+func keysStatusCmd(rootOpts *rootOptions) *cobra.Command {
+	o := &keysStatusOptions{}
+	cmd := &cobra.Command{
+		Use:          keysStatusCommand,
+		Short:        keysStatusDescription,
+		Long:         keysStatusLongDescription,
+		Example:      keysStatusExample,
+		SilenceUsage: true,
+		PreRun: func(_ *cobra.Command, _ []string) {
+			o.print()
+			log.Printf("---")
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runKeysStatus(rootOpts, o)
+		},
+	}
+	o.AddFlags(cmd.Flags(), mustMarkRequired(cmd.MarkFlagRequired))
+	return cmd
+}
+
+func runKeysStatus(_ *rootOptions, o *keysStatusOptions) error {
+	ctx := context.Background()
+
+	var filterKey string
+	if o.Key != "" {
+		parsedKey, err := sign.ParseSigningKey(o.Key)
+		if err != nil {
+			return err
+		}
+		filterKey = parsedKey.String()
+	}
+
+	store, err := release.OpenArtifactStore(ctx, o.Bucket)
+	if err != nil {
+		return err
+	}
+
+	bucket := release.NewBucket(store, release.DefaultBucketPathPrefix, o.ReleaseType)
+	stagedReleases, err := bucket.ListReleases(ctx, o.ReleaseVersion, "")
+	if err != nil {
+		return fmt.Errorf("failed listing staged releases: %w", err)
+	}
+
+	lines := []string{"NAME\tVERSION\tHELM CHART\tIMAGES"}
+	sort.Sort(ByVersion(stagedReleases))
+	for _, rel := range stagedReleases {
+		ledger, err := rel.Ledger(ctx)
+		if err != nil {
+			return err
+		}
+
+		chartKeys, imageKeys := releaseSigningKeys(rel.Metadata(), ledger)
+		if filterKey != "" && !slices.Contains(chartKeys, filterKey) && !slices.Contains(imageKeys, filterKey) {
+			continue
+		}
+
+		lines = append(lines, fmt.Sprintf("%s\t%s\t%s\t%s", rel.Name(), rel.Metadata().ReleaseVersion, formatSigningKeys(chartKeys), formatSigningKeys(imageKeys)))
+	}
+
+	logTable(lines...)
+
+	return nil
+}
+
+// releaseSigningKeys returns the keys which signed the Helm chart of a staged
+// release, as recorded when it was staged, and the keys which signed its
+// container images and image indexes, as recorded in its publish ledger.
+func releaseSigningKeys(meta release.Metadata, ledger *release.Ledger) ([]string, []string) {
+	chartKeys := meta.SigningKeys
+
+	var imageKeys []string
+	for _, e := range ledger.Entries() {
+		if e.Kind != release.LedgerEntrySignature {
+			continue
+		}
+
+		keys := e.Keys
+		if len(keys) == 0 {
+			keys = []string{unrecordedKeys}
+		}
+
+		for _, key := range keys {
+			if !slices.Contains(imageKeys, key) {
+				imageKeys = append(imageKeys, key)
+			}
+		}
+	}
+
+	return chartKeys, imageKeys
+}
+
+func formatSigningKeys(keys []string) string {
+	if len(keys) == 0 {
+		return "-"
+	}
+
+	return strings.Join(keys, ", ")
+}
@@ -0,0 +1,84 @@
+/*
+Copyright 2021 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/cert-manager/release/pkg/release"
+)
+
+func TestReleaseSigningKeys(t *testing.T) {
+	const (
+		oldKey = "projects/cert-manager-release/locations/europe-west1/keyRings/cert-manager-release/cryptoKeys/cert-manager-release-signing-key/cryptoKeyVersions/1"
+		newKey = "projects/cert-manager-release/locations/europe-west1/keyRings/cert-manager-release/cryptoKeys/cert-manager-release-signing-key/cryptoKeyVersions/2"
+	)
+
+	tests := map[string]struct {
+		meta    release.Metadata
+		entries []release.LedgerEntry
+
+		expectedChartKeys []string
+		expectedImageKeys []string
+	}{
+		"unsigned release": {
+			entries: []release.LedgerEntry{
+				{Kind: release.LedgerEntryImage, Name: "quay.io/jetstack/cert-manager-controller-amd64:v1.15.0"},
+			},
+		},
+		"release signed with both keys": {
+			meta: release.Metadata{SigningKeys: []string{oldKey, newKey}},
+			entries: []release.LedgerEntry{
+				{Kind: release.LedgerEntrySignature, Name: "quay.io/jetstack/cert-manager-controller-amd64:v1.15.0", Keys: []string{oldKey, newKey}},
+				{Kind: release.LedgerEntryAttestation, Name: "quay.io/jetstack/cert-manager-controller-amd64:v1.15.0", Keys: []string{oldKey, newKey}},
+				{Kind: release.LedgerEntrySignature, Name: "quay.io/jetstack/cert-manager-controller:v1.15.0", Keys: []string{oldKey, newKey}},
+			},
+			expectedChartKeys: []string{oldKey, newKey},
+			expectedImageKeys: []string{oldKey, newKey},
+		},
+		"images signed before keys were recorded": {
+			entries: []release.LedgerEntry{
+				{Kind: release.LedgerEntrySignature, Name: "quay.io/jetstack/cert-manager-controller-amd64:v1.15.0"},
+				{Kind: release.LedgerEntrySignature, Name: "quay.io/jetstack/cert-manager-controller:v1.15.0", Keys: []string{newKey}},
+			},
+			expectedImageKeys: []string{unrecordedKeys, newKey},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ledger := newTestLedger(t)
+			for _, entry := range test.entries {
+				if err := ledger.Record(context.TODO(), entry); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			chartKeys, imageKeys := releaseSigningKeys(test.meta, ledger)
+
+			if !reflect.DeepEqual(chartKeys, test.expectedChartKeys) {
+				t.Errorf("expected Helm chart keys %v, got %v", test.expectedChartKeys, chartKeys)
+			}
+
+			if !reflect.DeepEqual(imageKeys, test.expectedImageKeys) {
+				t.Errorf("expected image keys %v, got %v", test.expectedImageKeys, imageKeys)
+			}
+		})
+	}
+}
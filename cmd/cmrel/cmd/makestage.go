@@ -87,7 +87,7 @@ func (o *makeStageOptions) AddFlags(fs *flag.FlagSet, markRequired func(string))
 		"The default value assumes that this tool is run from the root of the release repository.")
 	fs.StringVar(&o.Project, "project", release.DefaultReleaseProject, "The GCP project to run the GCB build jobs in.")
 	fs.StringVar(&o.PublishedImageRepository, "published-image-repo", release.DefaultImageRepository, "The docker image repository set when building the release.")
-	fs.StringVar(&o.SigningKMSKey, "signing-kms-key", defaultKMSKey, "Full name of the GCP KMS key to use for signing")
+	fs.StringVar(&o.SigningKMSKey, "signing-kms-key", defaultKMSKey, "Full name of the GCP KMS key to use for signing. "+multipleSigningKeys)
 
 	markRequired("ref")
 }
@@ -130,7 +130,7 @@ func runMakeStage(rootOpts *rootOptions, o *makeStageOptions) error {
 	ctx := context.Background()
 
 	if o.SigningKMSKey != "" {
-		if _, err := sign.NewGCPKMSKey(o.SigningKMSKey); err != nil {
+		if _, err := sign.ParseSigningKeys(o.SigningKMSKey); err != nil {
 			return err
 		}
 	}
@@ -0,0 +1,178 @@
+/*
+Copyright 2021 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/cert-manager/release/pkg/release"
+	"github.com/cert-manager/release/pkg/release/provenance"
+	"github.com/cert-manager/release/pkg/sign"
+	"github.com/cert-manager/release/pkg/sign/cosign"
+)
+
+// provenanceFileName is the name of the GitHub release asset holding a signed
+// in-toto provenance statement for each other asset, one DSSE envelope per line.
+const provenanceFileName = "cert-manager.intoto.jsonl"
+
+// buildProvenanceStatements returns a provenance statement for each of the
+// given assets.
+func buildProvenanceStatements(rel *release.Unpacked, assets []githubReleaseAsset) ([]*provenance.Statement, error) {
+	var statements []*provenance.Statement
+	for _, asset := range assets {
+		sum, err := sha256SumFile(asset.Path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compute sha256sum of release asset %q: %w", asset.Name, err)
+		}
+
+		subject, err := provenance.NewSubject(asset.Name, "sha256:"+sum)
+		if err != nil {
+			return nil, err
+		}
+
+		statements = append(statements, provenance.NewStatement(rel, subject))
+	}
+
+	return statements, nil
+}
+
+// writeProvenanceAsset writes a signed provenance statement for each of the
+// given assets to dir, and returns the file as a GitHub release asset. Nothing
+// is written if signing is skipped, since unsigned provenance can't be trusted.
+// Signatures aren't necessarily reproducible, so if a previous run already
+// uploaded the provenance it isn't created again.
+func writeProvenanceAsset(ctx context.Context, o *gcbPublishOptions, rel *release.Unpacked, assets []githubReleaseAsset, dir string) ([]githubReleaseAsset, error) {
+	if o.SkipSigning {
+		log.Printf("Skipping creating %s as skip-signing is set", provenanceFileName)
+		return nil, nil
+	}
+
+	if _, ok := o.ledger.Get(release.LedgerEntryGitHubAsset, provenanceFileName); ok {
+		log.Printf("Skipping creating %s which was uploaded by a previous run", provenanceFileName)
+		return nil, nil
+	}
+
+	parsedKeys, err := loadSigningKeys(o.SigningKMSKey, o.PGPProfile)
+	if err != nil {
+		return nil, err
+	}
+
+	signers, err := sign.NewSigners(ctx, parsedKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	statements, err := buildProvenanceStatements(rel, assets)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &bytes.Buffer{}
+	for _, statement := range statements {
+		payload, err := json.Marshal(statement)
+		if err != nil {
+			return nil, err
+		}
+
+		log.Printf("Signing provenance for %q using %s", statement.Subject[0].Name, o.SigningKMSKey)
+		envelope, err := sign.DSSE(signers, provenance.PayloadType, payload)
+		if err != nil {
+			return nil, fmt.Errorf("failed to sign provenance for %q: %w", statement.Subject[0].Name, err)
+		}
+
+		line, err := json.Marshal(envelope)
+		if err != nil {
+			return nil, err
+		}
+
+		out.Write(line)
+		out.WriteString("\n")
+	}
+
+	path := filepath.Join(dir, provenanceFileName)
+	if err := os.WriteFile(path, out.Bytes(), 0o644); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", provenanceFileName, err)
+	}
+
+	return []githubReleaseAsset{{Name: provenanceFileName, Path: path}}, nil
+}
+
+// attestRegistryContent attaches a signed provenance attestation to each of
+// the given images and image indexes using cosign.
+func attestRegistryContent(ctx context.Context, o *gcbPublishOptions, signer registrySigner, rel *release.Unpacked, allContentToAttest []registryContent) error {
+	if signer.keys == "" {
+		log.Println("Skipping attesting provenance of container images / image indexes as skip-signing is set")
+		return nil
+	}
+
+	parsedKeys, err := sign.ParseSigningKeys(signer.keys)
+	if err != nil {
+		return err
+	}
+
+	predicate, err := json.Marshal(provenance.NewPredicate(rel))
+	if err != nil {
+		return err
+	}
+
+	predicateFile, err := os.CreateTemp("", "cmrel-provenance-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to create provenance predicate file: %w", err)
+	}
+	defer os.Remove(predicateFile.Name())
+
+	if _, err := predicateFile.Write(predicate); err != nil {
+		predicateFile.Close()
+		return fmt.Errorf("failed to write provenance predicate file: %w", err)
+	}
+	if err := predicateFile.Close(); err != nil {
+		return fmt.Errorf("failed to write provenance predicate file: %w", err)
+	}
+
+	for _, toAttest := range allContentToAttest {
+		attestedKeys, unattestedKeys := keysToSign(o, release.LedgerEntryAttestation, toAttest, parsedKeys)
+		if len(unattestedKeys) == 0 {
+			log.Printf("Skipping attesting provenance of %q which was attested by a previous run", toAttest.ref)
+			continue
+		}
+
+		for _, key := range unattestedKeys {
+			log.Printf("Attesting provenance of %q using %s", toAttest.ref, key)
+			// cosign failures aren't retried, since there's no telling
+			// whether retrying would fix them
+			if err := o.rateLimiter.WaitForReference(ctx, toAttest.ref); err != nil {
+				return err
+			}
+			if err := cosign.Attest(ctx, o.CosignPath, signer.env, toAttest.ref, predicateFile.Name(), provenance.PredicateType, key); err != nil {
+				return fmt.Errorf("failed to attest provenance of container image / image index %q: %w", toAttest.ref, err)
+			}
+
+			attestedKeys = append(attestedKeys, key.String())
+			if err := o.ledger.Record(ctx, release.LedgerEntry{Kind: release.LedgerEntryAttestation, Name: toAttest.ref, Digest: toAttest.digest, Keys: attestedKeys}); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+/*
+Copyright 2021 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cert-manager/release/pkg/release"
+	"github.com/cert-manager/release/pkg/release/provenance"
+)
+
+func TestBuildProvenanceStatements(t *testing.T) {
+	dir := writeTestReleaseDir(t, "v1.15.0", "amd64")
+	rel, _ := loadTestRelease(t, dir)
+	rel.BuildInputs = &release.BuildInputs{
+		BuildID:       "1234",
+		Substitutions: map[string]string{"_CM_REPO": "https://github.com/cert-manager/cert-manager.git"},
+		StartedOn:     time.Now(),
+	}
+
+	assets := githubReleaseAssets(rel)
+	statements, err := buildProvenanceStatements(rel, assets)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(statements) != len(assets) {
+		t.Fatalf("expected a statement per asset, got %d statements for %d assets", len(statements), len(assets))
+	}
+
+	for i, statement := range statements {
+		sum, err := sha256SumFile(assets[i].Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if statement.Type != provenance.StatementType || statement.PredicateType != provenance.PredicateType {
+			t.Errorf("unexpected statement types: %#v", statement)
+		}
+
+		if len(statement.Subject) != 1 || statement.Subject[0].Name != assets[i].Name || statement.Subject[0].Digest["sha256"] != sum {
+			t.Errorf("expected subject %q with sha256 %q, got %#v", assets[i].Name, sum, statement.Subject)
+		}
+
+		if statement.Predicate.RunDetails.Metadata == nil || statement.Predicate.RunDetails.Metadata.InvocationID != "1234" {
+			t.Errorf("expected provenance to include the recorded build ID, got %#v", statement.Predicate.RunDetails)
+		}
+	}
+}
+
+func TestWriteProvenanceAssetSkippedWithoutSigning(t *testing.T) {
+	dir := writeTestReleaseDir(t, "v1.15.0", "amd64")
+	rel, ledger := loadTestRelease(t, dir)
+
+	o := NewGCBPublishOptions()
+	o.SkipSigning = true
+	o.ledger = ledger
+
+	written, err := writeProvenanceAsset(context.TODO(), o, rel, githubReleaseAssets(rel), t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(written) != 0 {
+		t.Errorf("expected no provenance to be written when signing is skipped, got %#v", written)
+	}
+}
@@ -20,9 +20,9 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"strconv"
 	"strings"
 
-	"cloud.google.com/go/storage"
 	"github.com/spf13/cobra"
 	flag "github.com/spf13/pflag"
 	"google.golang.org/api/cloudbuild/v1"
@@ -70,9 +70,21 @@ type publishOptions struct {
 
 	// PublishedImageRepository is the image repository that images as part of
 	// releases should be pushed to.
-	// It is used as the repository for manifest lists created for artifacts.
+	// It is used as the repository for image indexes created for artifacts.
 	PublishedImageRepository string
 
+	// ImageMirrors is the path to a local YAML file listing image repositories
+	// which images and image indexes are also pushed to; see registry.Mirrors
+	ImageMirrors string
+
+	// RegistryRateLimit is the maximum number of requests per second the
+	// publish job makes to each image registry host, or 0 for no limit
+	RegistryRateLimit float64
+
+	// RegistryHostRateLimits are <host>=<requests per second> limits which
+	// override RegistryRateLimit for specific registry hosts
+	RegistryHostRateLimits []string
+
 	// PublishedHelmChartGitHubOwner is the name of the owner of the GitHub repo
 	// for Helm charts.
 	PublishedHelmChartGitHubOwner string
@@ -85,6 +97,14 @@ type publishOptions struct {
 	// GitHub repository for Helm Charts.
 	PublishedHelmChartGitHubBranch string
 
+	// PublishedHelmChartOCIRepository is the OCI repository which Helm charts
+	// are pushed to, e.g. oci://quay.io/jetstack/charts
+	PublishedHelmChartOCIRepository string
+
+	// helmChartRepoOptions selects the repository Helm charts are published
+	// to by the helmchartpr action
+	helmChartRepoOptions
+
 	// PublishedGitHubOrg is the org of the repository where the release will
 	// be published to.
 	PublishedGitHubOrg string
@@ -104,6 +124,26 @@ type publishOptions struct {
 	// projects/<PROJECT_NAME>/locations/<LOCATION>/keyRings/<KEYRING_NAME>/cryptoKeys/<KEY_NAME>/versions/<KEY_VERSION>
 	// This must be set if SkipSigning is not set to true
 	SigningKMSKey string
+
+	// PGPProfile is the path to a local YAML file of key profiles, which set the
+	// PGP identity of each signing key; see sign.KeyProfiles
+	PGPProfile string
+
+	// PushLatestImageTag, if true, makes the pushfloatingimagetags action
+	// also move the latest tag of each image to the release.
+	PushLatestImageTag bool
+
+	// UndraftGitHubRelease, if true, publishes the draft GitHub release once
+	// every publish action has succeeded.
+	UndraftGitHubRelease bool
+
+	// ReportBucket is the name of a GCS bucket which the publish job uploads
+	// a JSON report of everything which was published to.
+	ReportBucket string
+
+	// ReportFile is the path to download the JSON report to once the publish
+	// job completes. Requires ReportBucket to be set.
+	ReportFile string
 }
 
 func (o *publishOptions) AddFlags(fs *flag.FlagSet, markRequired func(string)) {
@@ -113,15 +153,25 @@ func (o *publishOptions) AddFlags(fs *flag.FlagSet, markRequired func(string)) {
 		"The default value assumes that this tool is run from the root of the release repository.")
 	fs.StringVar(&o.Project, "project", release.DefaultReleaseProject, "The GCP project to run the GCB build jobs in.")
 	fs.BoolVar(&o.NoMock, "nomock", false, "Whether to actually publish the release. If false, the command will exit after preparing the release for pushing.")
-	fs.StringVar(&o.PublishedImageRepository, "published-image-repo", release.DefaultImageRepository, "The docker image repository to push the release images & manifest lists to.")
+	fs.StringVar(&o.PublishedImageRepository, "published-image-repo", release.DefaultImageRepository, "The docker image repository to push the release images & image indexes to.")
+	fs.StringVar(&o.ImageMirrors, "image-mirrors", "", imageMirrorsUsage+" Credentials in environment variables must be available to the GCB job.")
+	fs.Float64Var(&o.RegistryRateLimit, "registry-rate-limit", defaultRegistryRateLimit, registryRateLimitUsage)
+	fs.StringSliceVar(&o.RegistryHostRateLimits, "registry-host-rate-limits", nil, registryHostRateLimitsUsage)
 	fs.StringVar(&o.PublishedHelmChartGitHubOwner, "published-helm-chart-github-owner", release.DefaultHelmChartGitHubOwner, "The name of the owner of the GitHub repo for Helm charts.")
 	fs.StringVar(&o.PublishedHelmChartGitHubRepo, "published-helm-chart-github-repo", release.DefaultHelmChartGitHubRepo, "The name of the GitHub repo for Helm charts.")
 	fs.StringVar(&o.PublishedHelmChartGitHubBranch, "published-helm-chart-github-branch", release.DefaultHelmChartGitHubBranch, "The name of the main branch in the GitHub repository for Helm charts.")
+	fs.StringVar(&o.PublishedHelmChartOCIRepository, "published-helm-chart-oci-repo", release.DefaultHelmChartOCIRepository, "The OCI repository Helm charts are pushed to.")
+	o.helmChartRepoOptions.addFlags(fs)
 	fs.StringVar(&o.PublishedGitHubOrg, "published-github-org", release.DefaultGitHubOrg, "The org of the repository where the release wil be published to.")
 	fs.StringVar(&o.PublishedGitHubRepo, "published-github-repo", release.DefaultGitHubRepo, "The repo name in the provided org where the release will be published to.")
-	fs.StringVar(&o.SigningKMSKey, "signing-kms-key", defaultKMSKey, "Full name of the GCP KMS key to use for signing.")
+	fs.StringVar(&o.SigningKMSKey, "signing-kms-key", defaultKMSKey, "Full name of the GCP KMS key to use for signing. "+multipleSigningKeys)
+	fs.StringVar(&o.PGPProfile, "pgp-profile", "", pgpProfileUsage)
 	fs.BoolVar(&o.SkipSigning, "skip-signing", false, "Skip signing container images.")
-	fs.StringSliceVar(&o.PublishActions, "publish-actions", []string{"*"}, fmt.Sprintf("Comma-separated list of actions to take, or '*' to do everything. Only meaningful if nomock is set. Order of operations is preserved if given, or is alphabetical by default. Actions can be removed with a prefix of '-'. Options: %s", strings.Join(allPublishActionNames(), ", ")))
+	fs.StringSliceVar(&o.PublishActions, "publish-actions", []string{"*"}, fmt.Sprintf("Comma-separated list of actions to take, or '*' to do everything. Only meaningful if nomock is set. Order of operations is preserved if given, or is alphabetical by default. Actions can be removed with a prefix of '-'. %s", publishActionOptions()))
+	fs.BoolVar(&o.PushLatestImageTag, "push-latest-image-tag", false, "Make the pushfloatingimagetags action also move the 'latest' tag of each image to the release, if it's the highest GA release.")
+	fs.BoolVar(&o.UndraftGitHubRelease, "undraft-github-release", false, "Publish the draft GitHub release once all publish actions have succeeded, instead of leaving it to be reviewed and published by hand.")
+	fs.StringVar(&o.ReportBucket, "report-bucket", "", fmt.Sprintf("The name of a GCS bucket for the publish job to upload a JSON report of everything which was published to. The report is stored at %q.", publishReportObjectName("<release-name>")))
+	fs.StringVar(&o.ReportFile, "report-file", "", "Path to download the JSON report to once the publish job completes. Requires --report-bucket.")
 }
 
 func (o *publishOptions) print() {
@@ -132,12 +182,22 @@ func (o *publishOptions) print() {
 	log.Printf("  Project: %q", o.Project)
 	log.Printf("  NoMock: %t", o.NoMock)
 	log.Printf("  PublishedImageRepo: %q", o.PublishedImageRepository)
+	log.Printf("  ImageMirrors: %q", o.ImageMirrors)
+	log.Printf("  RegistryRateLimit: %v", o.RegistryRateLimit)
+	log.Printf("  RegistryHostRateLimits: %q", strings.Join(o.RegistryHostRateLimits, ","))
 	log.Printf("  PublishedHelmChartGitHubRepo: %q", o.PublishedHelmChartGitHubRepo)
 	log.Printf("  PublishedHelmChartGitHubOwner: %q", o.PublishedHelmChartGitHubOwner)
 	log.Printf("  PublishedHelmChartGitHubBranch: %q", o.PublishedHelmChartGitHubBranch)
+	log.Printf("  PublishedHelmChartOCIRepo: %q", o.PublishedHelmChartOCIRepository)
+	o.helmChartRepoOptions.print()
 	log.Printf("  PublishedGitHubOrg: %q", o.PublishedGitHubOrg)
 	log.Printf("  PublishedGitHubRepo: %q", o.PublishedGitHubRepo)
+	log.Printf("  PGPProfile: %q", o.PGPProfile)
 	log.Printf("  PublishActions: %q", strings.Join(o.PublishActions, ","))
+	log.Printf("  PushLatestImageTag: %t", o.PushLatestImageTag)
+	log.Printf("  UndraftGitHubRelease: %t", o.UndraftGitHubRelease)
+	log.Printf("  ReportBucket: %q", o.ReportBucket)
+	log.Printf("  ReportFile: %q", o.ReportFile)
 }
 
 func publishCmd(rootOpts *rootOptions) *cobra.Command {
@@ -163,18 +223,32 @@ func publishCmd(rootOpts *rootOptions) *cobra.Command {
 func runPublish(rootOpts *rootOptions, o *publishOptions) error {
 	ctx := context.Background()
 
-	gcs, err := storage.NewClient(ctx)
+	store, err := release.OpenArtifactStore(ctx, o.Bucket)
 	if err != nil {
-		return fmt.Errorf("failed to create GCS client: %w", err)
+		return err
 	}
 
 	if o.SigningKMSKey != "" {
-		if _, err := sign.NewGCPKMSKey(o.SigningKMSKey); err != nil {
+		if _, err := sign.ParseSigningKeys(o.SigningKMSKey); err != nil {
 			return err
 		}
 	}
 
-	bucket := release.NewBucket(gcs.Bucket(o.Bucket), release.DefaultBucketPathPrefix, release.BuildTypeRelease)
+	pgpProfile, err := encodePGPProfile(o.PGPProfile)
+	if err != nil {
+		return err
+	}
+
+	imageMirrors, err := encodeImageMirrors(o.ImageMirrors, o.SkipSigning)
+	if err != nil {
+		return err
+	}
+
+	if _, err := newRegistryRateLimiter(o.RegistryRateLimit, o.RegistryHostRateLimits); err != nil {
+		return err
+	}
+
+	bucket := release.NewBucket(store, release.DefaultBucketPathPrefix, release.BuildTypeRelease)
 	rel, err := bucket.GetRelease(ctx, o.ReleaseName)
 	if err != nil {
 		return fmt.Errorf("failed to fetch release: %w", err)
@@ -195,6 +269,10 @@ func runPublish(rootOpts *rootOptions, o *publishOptions) error {
 		build.Options.MachineType = "E2_HIGHCPU_32"
 	}
 
+	if o.ReportFile != "" && o.ReportBucket == "" {
+		return fmt.Errorf("--report-file requires --report-bucket to be set")
+	}
+
 	// make sure that publish-actions is valid
 	_, err = canonicalizeAndVerifyPublishActions(o.PublishActions)
 	if err != nil {
@@ -209,10 +287,24 @@ func runPublish(rootOpts *rootOptions, o *publishOptions) error {
 	build.Substitutions["_PUBLISHED_HELM_CHART_GITHUB_OWNER"] = o.PublishedHelmChartGitHubOwner
 	build.Substitutions["_PUBLISHED_HELM_CHART_GITHUB_REPO"] = o.PublishedHelmChartGitHubRepo
 	build.Substitutions["_PUBLISHED_HELM_CHART_GITHUB_BRANCH"] = o.PublishedHelmChartGitHubBranch
+	build.Substitutions["_PUBLISHED_HELM_CHART_OCI_REPO"] = o.PublishedHelmChartOCIRepository
+	build.Substitutions["_PUBLISHED_HELM_CHART_REPO_TYPE"] = o.PublishedHelmChartRepoType
+	build.Substitutions["_PUBLISHED_HELM_CHART_GITLAB_URL"] = o.PublishedHelmChartGitLabURL
+	build.Substitutions["_PUBLISHED_HELM_CHART_GITLAB_PROJECT"] = o.PublishedHelmChartGitLabProject
+	build.Substitutions["_PUBLISHED_HELM_CHART_GITLAB_BRANCH"] = o.PublishedHelmChartGitLabBranch
+	build.Substitutions["_PUBLISHED_HELM_CHART_GIT_URL"] = o.PublishedHelmChartGitURL
+	build.Substitutions["_PUBLISHED_HELM_CHART_GIT_BRANCH"] = o.PublishedHelmChartGitBranch
 	build.Substitutions["_PUBLISHED_IMAGE_REPO"] = o.PublishedImageRepository
+	build.Substitutions["_IMAGE_MIRRORS"] = imageMirrors
+	build.Substitutions["_REGISTRY_RATE_LIMIT"] = strconv.FormatFloat(o.RegistryRateLimit, 'g', -1, 64)
+	build.Substitutions["_REGISTRY_HOST_RATE_LIMITS"] = strings.Join(o.RegistryHostRateLimits, ",")
 	build.Substitutions["_PUBLISH_ACTIONS"] = strings.Join(o.PublishActions, ",")
 	build.Substitutions["_SKIP_SIGNING"] = fmt.Sprintf("%v", o.SkipSigning)
 	build.Substitutions["_KMS_KEY"] = o.SigningKMSKey
+	build.Substitutions["_PGP_PROFILE"] = pgpProfile
+	build.Substitutions["_PUSH_LATEST_IMAGE_TAG"] = fmt.Sprintf("%t", o.PushLatestImageTag)
+	build.Substitutions["_UNDRAFT_GITHUB_RELEASE"] = fmt.Sprintf("%t", o.UndraftGitHubRelease)
+	build.Substitutions["_REPORT_BUCKET"] = o.ReportBucket
 
 	log.Printf("DEBUG: building google cloud build API client")
 	svc, err := cloudbuild.NewService(ctx)
@@ -237,6 +329,15 @@ func runPublish(rootOpts *rootOptions, o *publishOptions) error {
 		return fmt.Errorf("error waiting for cloud build to complete: %w", err)
 	}
 
+	if o.ReportFile != "" && o.NoMock {
+		// a partial report is uploaded even if publishing failed
+		if err := downloadPublishReport(ctx, o.ReportBucket, o.ReleaseName, o.ReportFile); err != nil {
+			log.Printf("Failed to download publish report: %v", err)
+		} else {
+			log.Printf("Wrote publish report to %q", o.ReportFile)
+		}
+	}
+
 	if build.Status == gcb.Success {
 		log.Printf("Release %q published!", rel.Metadata().ReleaseVersion)
 	} else {
@@ -0,0 +1,543 @@
+/*
+Copyright 2021 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/cert-manager/release/pkg/release"
+	"github.com/cert-manager/release/pkg/release/helm"
+	"github.com/cert-manager/release/pkg/release/images"
+	"github.com/cert-manager/release/pkg/release/publish/registry"
+	"github.com/cert-manager/release/pkg/release/sbom"
+	"github.com/cert-manager/release/pkg/sign"
+)
+
+// publishPlan describes everything that 'gcb publish' would do for a given
+// unpacked release, without doing any of it.
+type publishPlan struct {
+	ReleaseName    string
+	ReleaseVersion string
+	GitCommitRef   string
+
+	// Actions is the list of canonical publish actions which are enabled
+	Actions []string
+
+	Images       []plannedImage
+	ImageIndexes []plannedImageIndex
+
+	// Mirrors are the image repositories which the images and image indexes
+	// would also be pushed to, with the same digests.
+	Mirrors []plannedMirror
+
+	// FloatingImageTags are the floating tags which would be moved to the
+	// image indexes in the published image repository and each mirror, if
+	// the release is the highest patch release of its minor version once
+	// published.
+	FloatingImageTags []string
+
+	// SigningKeys are the keys which would be used to sign images and image
+	// indexes, or empty if signing is skipped.
+	SigningKeys []string
+	Signatures  []string
+
+	GitHubRelease *plannedGitHubRelease
+	HelmChartPR   *plannedHelmChartPR
+	HelmChartOCI  *plannedHelmChartOCI
+}
+
+type plannedImage struct {
+	Component string
+	Source    string
+	Target    string
+	OS        string
+	Arch      string
+}
+
+type plannedImageIndex struct {
+	Name    string
+	Entries []registry.IndexEntry
+}
+
+type plannedMirror struct {
+	Repository   string
+	Images       []string
+	ImageIndexes []string
+
+	// SigningKeys are the keys which would be used to sign the mirrored
+	// images and image indexes, or empty if signing is skipped.
+	SigningKeys []string
+}
+
+type plannedGitHubRelease struct {
+	Repository      string
+	Tag             string
+	TargetCommitish string
+	Assets          []plannedGitHubReleaseAsset
+
+	// Prerelease is true if the release would be marked as a pre-release.
+	Prerelease bool
+
+	// Undraft is true if the draft release would be published once all
+	// other publish actions have succeeded.
+	Undraft bool
+
+	// SigningKeys are the keys which would be used to sign the checksums file
+	// and provenance, or empty if signing is skipped.
+	SigningKeys []string
+}
+
+type plannedGitHubReleaseAsset struct {
+	githubReleaseAsset
+	SHA256 string
+}
+
+type plannedHelmChartPR struct {
+	Repository string
+	BaseBranch string
+	HeadBranch string
+	Files      []string
+}
+
+type plannedHelmChartOCI struct {
+	Repository string
+	Charts     []plannedHelmChart
+
+	// SigningKeys are the keys which would be used to sign the charts, or
+	// empty if signing is skipped.
+	SigningKeys []string
+}
+
+type plannedHelmChart struct {
+	Reference string
+	Digest    string
+}
+
+// buildPublishPlan computes what each enabled publish action would push for
+// the given release. It uses the same naming and planning functions as the
+// publish actions themselves.
+func buildPublishPlan(o *gcbPublishOptions, rel *release.Unpacked) (*publishPlan, error) {
+	actions, err := canonicalizeAndVerifyPublishActions(o.PublishActions)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &publishPlan{
+		ReleaseName:    rel.ReleaseName,
+		ReleaseVersion: rel.ReleaseVersion,
+		GitCommitRef:   rel.GitCommitRef,
+		Actions:        actions,
+	}
+
+	enabled := sets.NewString(actions...)
+
+	if enabled.Has("pushcontainerimages") {
+		if err := planContainerImages(o, rel, plan); err != nil {
+			return nil, err
+		}
+	}
+
+	if enabled.Has("pushfloatingimagetags") {
+		if err := planFloatingImageTags(o, rel, plan); err != nil {
+			return nil, err
+		}
+	}
+
+	if enabled.Has("githubrelease") {
+		prerelease, err := isPrerelease(rel.ReleaseVersion)
+		if err != nil {
+			return nil, err
+		}
+
+		ghRelease := &plannedGitHubRelease{
+			Repository:      fmt.Sprintf("github.com/%s/%s", o.PublishedGitHubOrg, o.PublishedGitHubRepo),
+			Tag:             rel.ReleaseVersion,
+			TargetCommitish: rel.GitCommitRef,
+			Prerelease:      prerelease,
+			Undraft:         o.UndraftGitHubRelease,
+		}
+		assets := githubReleaseAssets(rel)
+		for _, asset := range assets {
+			sum, err := sha256SumFile(asset.Path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to compute sha256sum of release asset %q: %w", asset.Name, err)
+			}
+			ghRelease.Assets = append(ghRelease.Assets, plannedGitHubReleaseAsset{githubReleaseAsset: asset, SHA256: sum})
+		}
+
+		sbomDir, err := os.MkdirTemp("", "cmrel-sboms-")
+		if err != nil {
+			return nil, fmt.Errorf("failed to create directory for SBOMs: %w", err)
+		}
+		defer os.RemoveAll(sbomDir)
+
+		sboms, err := writeSBOMAssets(o, rel, sbomDir)
+		if err != nil {
+			return nil, err
+		}
+		for _, asset := range sboms {
+			sum, err := sha256SumFile(asset.Path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to compute sha256sum of release asset %q: %w", asset.Name, err)
+			}
+			// the SBOM is deleted once planning is complete
+			ghRelease.Assets = append(ghRelease.Assets, plannedGitHubReleaseAsset{githubReleaseAsset: githubReleaseAsset{Name: asset.Name}, SHA256: sum})
+		}
+
+		checksums, err := buildChecksums(append(assets, sboms...))
+		if err != nil {
+			return nil, err
+		}
+		checksumsSum := sha256.Sum256(checksums)
+		ghRelease.Assets = append(ghRelease.Assets, plannedGitHubReleaseAsset{
+			githubReleaseAsset: githubReleaseAsset{Name: checksumsFileName},
+			SHA256:             hex.EncodeToString(checksumsSum[:]),
+		})
+
+		if !o.SkipSigning {
+			parsedKeys, err := sign.ParseSigningKeys(o.SigningKMSKey)
+			if err != nil {
+				return nil, err
+			}
+			for _, key := range parsedKeys {
+				ghRelease.SigningKeys = append(ghRelease.SigningKeys, key.String())
+			}
+		}
+		plan.GitHubRelease = ghRelease
+	}
+
+	if enabled.Has("helmchartpr") {
+		repository, baseBranch, err := helmChartPRDestination(o)
+		if err != nil {
+			return nil, err
+		}
+
+		pr := &plannedHelmChartPR{
+			Repository: repository,
+			BaseBranch: baseBranch,
+			HeadBranch: rel.ReleaseName,
+		}
+		for _, chart := range rel.Charts {
+			pr.Files = append(pr.Files, "charts/"+chart.PackageFileName())
+			if chart.ProvPath() != nil {
+				pr.Files = append(pr.Files, "charts/"+chart.PackageFileName()+".prov")
+			}
+		}
+		pr.Files = append(pr.Files, "index.yaml")
+		plan.HelmChartPR = pr
+	}
+
+	if enabled.Has("helmchartoci") {
+		if err := planHelmChartOCI(o, rel, plan); err != nil {
+			return nil, err
+		}
+	}
+
+	return plan, nil
+}
+
+func planHelmChartOCI(o *gcbPublishOptions, rel *release.Unpacked, plan *publishPlan) error {
+	helmRepo, err := helm.NewOCIRepositoryManager(o.PublishedHelmChartOCIRepository)
+	if err != nil {
+		return err
+	}
+
+	plannedOCI := &plannedHelmChartOCI{Repository: o.PublishedHelmChartOCIRepository}
+	for _, chart := range rel.Charts {
+		ociChart, err := helmRepo.Build(chart)
+		if err != nil {
+			return err
+		}
+
+		plannedOCI.Charts = append(plannedOCI.Charts, plannedHelmChart{Reference: ociChart.Reference, Digest: ociChart.Digest})
+	}
+
+	if !o.SkipSigning {
+		plannedOCI.SigningKeys, err = cosignKeys(o.SigningKMSKey)
+		if err != nil {
+			return err
+		}
+	}
+
+	plan.HelmChartOCI = plannedOCI
+	return nil
+}
+
+func planFloatingImageTags(o *gcbPublishOptions, rel *release.Unpacked, plan *publishPlan) error {
+	v, err := parseReleaseVersion(rel.ReleaseVersion)
+	if err != nil {
+		return err
+	}
+
+	// pre-releases never get floating tags
+	if len(v.Pre) > 0 {
+		return nil
+	}
+
+	tags := []string{minorImageTag(v)}
+	if o.PushLatestImageTag {
+		tags = append(tags, latestImageTag)
+	}
+
+	components := make([]string, 0, len(rel.ComponentImageBundles))
+	for name := range rel.ComponentImageBundles {
+		components = append(components, name)
+	}
+	sort.Strings(components)
+
+	mirrors, err := loadImageMirrors(o)
+	if err != nil {
+		return err
+	}
+
+	repositories := append([]string{o.PublishedImageRepository}, mirrors.Repositories()...)
+	for _, repository := range repositories {
+		for _, name := range components {
+			for _, tag := range tags {
+				plan.FloatingImageTags = append(plan.FloatingImageTags, buildImageIndexName(repository, name, tag))
+			}
+		}
+	}
+
+	return nil
+}
+
+func planContainerImages(o *gcbPublishOptions, rel *release.Unpacked, plan *publishPlan) error {
+	components := make([]string, 0, len(rel.ComponentImageBundles))
+	for name := range rel.ComponentImageBundles {
+		components = append(components, name)
+	}
+	sort.Strings(components)
+
+	for _, name := range components {
+		// work on copies so that planning never sets PublishedTag on the
+		// release being planned
+		var planned []*images.Tar
+		for _, t := range rel.ComponentImageBundles[name] {
+			imageTag := buildImageTag(o.PublishedImageRepository, name, t.Architecture(), rel.ReleaseVersion)
+			plan.Images = append(plan.Images, plannedImage{
+				Component: name,
+				Source:    t.RawImageName(),
+				Target:    imageTag,
+				OS:        t.OS(),
+				Arch:      t.Architecture(),
+			})
+
+			copied := *t
+			copied.PublishedTag = imageTag
+			planned = append(planned, &copied)
+		}
+
+		entries, err := registry.IndexEntries(planned)
+		if err != nil {
+			return err
+		}
+
+		plan.ImageIndexes = append(plan.ImageIndexes, plannedImageIndex{
+			Name:    buildImageIndexName(o.PublishedImageRepository, name, rel.ReleaseVersion),
+			Entries: entries,
+		})
+	}
+
+	if err := planImageMirrors(o, rel, plan); err != nil {
+		return err
+	}
+
+	if o.SkipSigning {
+		return nil
+	}
+
+	signingKeys, err := cosignKeys(o.SigningKMSKey)
+	if err != nil {
+		return err
+	}
+	plan.SigningKeys = signingKeys
+
+	for _, image := range plan.Images {
+		plan.Signatures = append(plan.Signatures, image.Target)
+	}
+	for _, index := range plan.ImageIndexes {
+		plan.Signatures = append(plan.Signatures, index.Name)
+	}
+
+	return nil
+}
+
+func planImageMirrors(o *gcbPublishOptions, rel *release.Unpacked, plan *publishPlan) error {
+	mirrors, err := loadImageMirrors(o)
+	if err != nil {
+		return err
+	}
+
+	components := make([]string, 0, len(rel.ComponentImageBundles))
+	for name := range rel.ComponentImageBundles {
+		components = append(components, name)
+	}
+	sort.Strings(components)
+
+	for _, m := range mirrors.Mirrors {
+		planned := plannedMirror{Repository: m.Repository}
+		for _, name := range components {
+			for _, t := range rel.ComponentImageBundles[name] {
+				planned.Images = append(planned.Images, buildImageTag(m.Repository, name, t.Architecture(), rel.ReleaseVersion))
+			}
+			planned.ImageIndexes = append(planned.ImageIndexes, buildImageIndexName(m.Repository, name, rel.ReleaseVersion))
+		}
+
+		if !o.SkipSigning && !m.SkipSigning {
+			keys := o.SigningKMSKey
+			if m.SigningKeys != "" {
+				keys = m.SigningKeys
+			}
+
+			planned.SigningKeys, err = cosignKeys(keys)
+			if err != nil {
+				return err
+			}
+		}
+
+		plan.Mirrors = append(plan.Mirrors, planned)
+	}
+
+	return nil
+}
+
+// cosignKeys returns the given signing keys in the format used by cosign.
+func cosignKeys(keys string) ([]string, error) {
+	parsedKeys, err := sign.ParseSigningKeys(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	var cosignKeys []string
+	for _, key := range parsedKeys {
+		cosignKey, err := key.CosignFormat()
+		if err != nil {
+			return nil, err
+		}
+		cosignKeys = append(cosignKeys, cosignKey)
+	}
+
+	return cosignKeys, nil
+}
+
+// print writes a human readable summary of the plan to w.
+func (p *publishPlan) print(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "Release %s (%s) built from %s\n", p.ReleaseVersion, p.ReleaseName, p.GitCommitRef)
+	fmt.Fprintf(tw, "Publish actions: %v\n", p.Actions)
+
+	if len(p.Images) > 0 {
+		fmt.Fprintf(tw, "\nImages to be tagged and pushed:\n")
+		for _, image := range p.Images {
+			fmt.Fprintf(tw, "  %s\t-> %s\t(%s/%s)\n", image.Source, image.Target, image.OS, image.Arch)
+		}
+	}
+
+	if len(p.Images) > 0 {
+		fmt.Fprintf(tw, "\nSBOMs to be attached to each image: %v\n", sbom.Formats)
+	}
+
+	if len(p.ImageIndexes) > 0 {
+		fmt.Fprintf(tw, "\nImage indexes to be created and pushed:\n")
+		for _, index := range p.ImageIndexes {
+			fmt.Fprintf(tw, "  %s\n", index.Name)
+			for _, e := range index.Entries {
+				fmt.Fprintf(tw, "    %s\tos=%s arch=%s variant=%s os.version=%s\n", e.Image, e.OS, e.Arch, e.Variant, e.OSVersion)
+			}
+		}
+	}
+
+	for _, mirror := range p.Mirrors {
+		fmt.Fprintf(tw, "\nImages and image indexes to be mirrored to %s with the same digests:\n", mirror.Repository)
+		for _, index := range mirror.ImageIndexes {
+			fmt.Fprintf(tw, "  %s\n", index)
+		}
+		for _, image := range mirror.Images {
+			fmt.Fprintf(tw, "  %s\n", image)
+		}
+		if len(mirror.SigningKeys) > 0 {
+			fmt.Fprintf(tw, "  signed and with provenance attested with %s\n", strings.Join(mirror.SigningKeys, " and "))
+		} else {
+			fmt.Fprintf(tw, "  signing will be skipped\n")
+		}
+	}
+
+	if len(p.FloatingImageTags) > 0 {
+		fmt.Fprintf(tw, "\nFloating image tags to be moved to the image indexes and signed, if the release is the highest published patch release of its minor version:\n")
+		for _, tag := range p.FloatingImageTags {
+			fmt.Fprintf(tw, "  %s\n", tag)
+		}
+	}
+
+	if len(p.SigningKeys) > 0 {
+		fmt.Fprintf(tw, "\nContent to be signed and have its provenance attested with %s:\n", strings.Join(p.SigningKeys, " and "))
+		for _, s := range p.Signatures {
+			fmt.Fprintf(tw, "  %s\n", s)
+		}
+	} else if len(p.Images) > 0 {
+		fmt.Fprintf(tw, "\nSigning of images and image indexes will be skipped\n")
+	}
+
+	if p.GitHubRelease != nil {
+		fmt.Fprintf(tw, "\nDraft GitHub release %s to be created in %s at %s with assets:\n", p.GitHubRelease.Tag, p.GitHubRelease.Repository, p.GitHubRelease.TargetCommitish)
+		for _, asset := range p.GitHubRelease.Assets {
+			fmt.Fprintf(tw, "  %s\tsha256:%s\n", asset.Name, asset.SHA256)
+		}
+		if len(p.GitHubRelease.SigningKeys) > 0 {
+			signingKeys := strings.Join(p.GitHubRelease.SigningKeys, " and ")
+			fmt.Fprintf(tw, "  %s\tsigned with %s\n", checksumsSignatureFileName, signingKeys)
+			fmt.Fprintf(tw, "  %s\tsigned with %s\n", provenanceFileName, signingKeys)
+			fmt.Fprintf(tw, "  %s\tPGP public keys of %s\n", keyringFileName, signingKeys)
+		}
+		if p.GitHubRelease.Prerelease {
+			fmt.Fprintf(tw, "The release would be marked as a pre-release\n")
+		}
+		if p.GitHubRelease.Undraft {
+			fmt.Fprintf(tw, "The release would be published once all other publish actions have succeeded\n")
+		}
+	}
+
+	if p.HelmChartPR != nil {
+		fmt.Fprintf(tw, "\nHelm chart PR to be opened against %s@%s from branch %q with files:\n", p.HelmChartPR.Repository, p.HelmChartPR.BaseBranch, p.HelmChartPR.HeadBranch)
+		for _, f := range p.HelmChartPR.Files {
+			fmt.Fprintf(tw, "  %s\n", f)
+		}
+	}
+
+	if p.HelmChartOCI != nil {
+		fmt.Fprintf(tw, "\nHelm charts to be pushed to %s:\n", p.HelmChartOCI.Repository)
+		for _, chart := range p.HelmChartOCI.Charts {
+			fmt.Fprintf(tw, "  %s\t%s\n", chart.Reference, chart.Digest)
+		}
+		if len(p.HelmChartOCI.SigningKeys) > 0 {
+			fmt.Fprintf(tw, "  signed with %s\n", strings.Join(p.HelmChartOCI.SigningKeys, " and "))
+		}
+	}
+
+	return tw.Flush()
+}
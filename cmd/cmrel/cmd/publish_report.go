@@ -0,0 +1,276 @@
+/*
+Copyright 2021 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/cert-manager/release/pkg/release"
+	"github.com/cert-manager/release/pkg/release/publish/registry"
+)
+
+// publishReport is a machine readable summary of everything which has been
+// published for a release, written by 'gcb publish' for use by downstream
+// automation.
+type publishReport struct {
+	ReleaseName    string `json:"releaseName"`
+	ReleaseVersion string `json:"releaseVersion"`
+	GitCommitRef   string `json:"gitCommitRef"`
+
+	// Complete is true if every publish action succeeded. A partial report
+	// is written if publishing fails.
+	Complete bool `json:"complete"`
+
+	Images            []reportedImage        `json:"images"`
+	ImageIndexes      []reportedImage        `json:"imageIndexes"`
+	FloatingImageTags []reportedImage        `json:"floatingImageTags,omitempty"`
+	Signatures        []reportedSignature    `json:"signatures"`
+	Attestations      []reportedSignature    `json:"attestations"`
+	SBOMs             []reportedSBOM         `json:"sboms"`
+	GitHubRelease     *reportedGitHubRelease `json:"githubRelease,omitempty"`
+	HelmChartPR       string                 `json:"helmChartPR,omitempty"`
+	HelmCharts        []reportedImage        `json:"helmCharts,omitempty"`
+}
+
+type reportedImage struct {
+	Reference string `json:"reference"`
+	Digest    string `json:"digest"`
+
+	// Mirrors are the copies of the image or image index pushed to each
+	// mirror of the published image repository
+	Mirrors []reportedImage `json:"mirrors,omitempty"`
+}
+
+type reportedSignature struct {
+	// Subject is the image or image index which was signed or attested
+	Subject       string `json:"subject"`
+	SubjectDigest string `json:"subjectDigest"`
+
+	// Reference is where cosign stored the signature or attestation
+	Reference string `json:"reference"`
+
+	// Keys are the signing keys which made the signature or attestation
+	Keys []string `json:"keys,omitempty"`
+}
+
+type reportedSBOM struct {
+	// Subject is the image the SBOM was attached to
+	Subject string `json:"subject"`
+	Format  string `json:"format"`
+
+	// Digest is the digest of the OCI artifact holding the SBOM
+	Digest string `json:"digest"`
+}
+
+type reportedGitHubRelease struct {
+	URL    string                `json:"url"`
+	Assets []reportedGitHubAsset `json:"assets"`
+}
+
+type reportedGitHubAsset struct {
+	Name        string `json:"name"`
+	SHA256      string `json:"sha256"`
+	DownloadURL string `json:"downloadURL"`
+}
+
+// publishReportObjectName is the name of the object a report for the named
+// release is uploaded to.
+func publishReportObjectName(releaseName string) string {
+	return fmt.Sprintf("%s/reports/%s.json", release.DefaultBucketPathPrefix, releaseName)
+}
+
+// buildPublishReport summarises what the publish ledger records as having
+// been published for the given release. Images and image indexes pushed to
+// the given mirrors are reported alongside the image or image index in the
+// published image repository which they mirror.
+func buildPublishReport(rel *release.Unpacked, ledger *release.Ledger, publishedImageRepository string, mirrors []string, complete bool) (*publishReport, error) {
+	report := &publishReport{
+		ReleaseName:    rel.ReleaseName,
+		ReleaseVersion: rel.ReleaseVersion,
+		GitCommitRef:   rel.GitCommitRef,
+		Complete:       complete,
+		Images:         []reportedImage{},
+		ImageIndexes:   []reportedImage{},
+		Signatures:     []reportedSignature{},
+		Attestations:   []reportedSignature{},
+		SBOMs:          []reportedSBOM{},
+	}
+
+	var assets []reportedGitHubAsset
+	mirrored := map[release.LedgerEntryKind]map[string][]reportedImage{}
+	for _, e := range ledger.Entries() {
+		if e.Kind == release.LedgerEntryImage || e.Kind == release.LedgerEntryImageIndex {
+			if ref, ok := mirroredRef(publishedImageRepository, mirrors, e.Name); ok {
+				if mirrored[e.Kind] == nil {
+					mirrored[e.Kind] = map[string][]reportedImage{}
+				}
+				mirrored[e.Kind][ref] = append(mirrored[e.Kind][ref], reportedImage{Reference: e.Name, Digest: e.Digest})
+				continue
+			}
+		}
+
+		switch e.Kind {
+		case release.LedgerEntryImage:
+			report.Images = append(report.Images, reportedImage{Reference: e.Name, Digest: e.Digest})
+
+		case release.LedgerEntryImageIndex:
+			report.ImageIndexes = append(report.ImageIndexes, reportedImage{Reference: e.Name, Digest: e.Digest})
+
+		case release.LedgerEntryFloatingImageTag:
+			report.FloatingImageTags = append(report.FloatingImageTags, reportedImage{Reference: e.Name, Digest: e.Digest})
+
+		case release.LedgerEntrySignature:
+			sigTag, err := registry.SignatureTag(e.Name, e.Digest)
+			if err != nil {
+				return nil, err
+			}
+			report.Signatures = append(report.Signatures, reportedSignature{Subject: e.Name, SubjectDigest: e.Digest, Reference: sigTag, Keys: e.Keys})
+
+		case release.LedgerEntryAttestation:
+			attTag, err := registry.AttestationTag(e.Name, e.Digest)
+			if err != nil {
+				return nil, err
+			}
+			report.Attestations = append(report.Attestations, reportedSignature{Subject: e.Name, SubjectDigest: e.Digest, Reference: attTag, Keys: e.Keys})
+
+		case release.LedgerEntrySBOM:
+			subject, format, _ := strings.Cut(e.Name, "#")
+			report.SBOMs = append(report.SBOMs, reportedSBOM{Subject: subject, Format: format, Digest: e.Digest})
+
+		case release.LedgerEntryGitHubRelease:
+			report.GitHubRelease = &reportedGitHubRelease{URL: e.URL}
+
+		case release.LedgerEntryGitHubAsset:
+			assets = append(assets, reportedGitHubAsset{Name: e.Name, SHA256: strings.TrimPrefix(e.Digest, "sha256:"), DownloadURL: e.URL})
+
+		case release.LedgerEntryHelmChartPR:
+			report.HelmChartPR = e.URL
+
+		case release.LedgerEntryHelmChartOCI:
+			report.HelmCharts = append(report.HelmCharts, reportedImage{Reference: e.Name, Digest: e.Digest})
+		}
+	}
+
+	report.Images = addMirroredImages(report.Images, mirrored[release.LedgerEntryImage])
+	report.ImageIndexes = addMirroredImages(report.ImageIndexes, mirrored[release.LedgerEntryImageIndex])
+
+	if report.GitHubRelease != nil {
+		report.GitHubRelease.Assets = append([]reportedGitHubAsset{}, assets...)
+	}
+
+	return report, nil
+}
+
+// addMirroredImages adds the mirrors of each image, keyed by the reference
+// of the image they mirror. Mirrors of images which aren't in images, which
+// can only happen if publishing failed part way through, are reported as
+// images in their own right.
+func addMirroredImages(images []reportedImage, mirrors map[string][]reportedImage) []reportedImage {
+	for i, image := range images {
+		images[i].Mirrors = mirrors[image.Reference]
+		delete(mirrors, image.Reference)
+	}
+
+	refs := make([]string, 0, len(mirrors))
+	for ref := range mirrors {
+		refs = append(refs, ref)
+	}
+	sort.Strings(refs)
+
+	for _, ref := range refs {
+		images = append(images, mirrors[ref]...)
+	}
+
+	return images
+}
+
+// writePublishReport writes the report to o.ReportFile and uploads it to
+// o.ReportBucket, if either is set.
+func writePublishReport(ctx context.Context, o *gcbPublishOptions, report *publishReport) error {
+	if o.ReportFile == "" && o.ReportBucket == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if o.ReportFile != "" {
+		if err := os.WriteFile(o.ReportFile, data, 0o644); err != nil {
+			return fmt.Errorf("failed to write publish report: %w", err)
+		}
+		log.Printf("Wrote publish report to %q", o.ReportFile)
+	}
+
+	if o.ReportBucket != "" {
+		store, err := release.OpenArtifactStore(ctx, o.ReportBucket)
+		if err != nil {
+			return err
+		}
+
+		objectName := publishReportObjectName(report.ReleaseName)
+		w, err := store.NewWriter(ctx, objectName)
+		if err != nil {
+			return fmt.Errorf("failed to upload publish report: %w", err)
+		}
+		if _, err := w.Write(data); err != nil {
+			w.Abort()
+			return fmt.Errorf("failed to upload publish report: %w", err)
+		}
+		if err := w.Close(); err != nil {
+			return fmt.Errorf("failed to upload publish report: %w", err)
+		}
+		log.Printf("Uploaded publish report to %q in %q", objectName, o.ReportBucket)
+	}
+
+	return nil
+}
+
+// downloadPublishReport copies the report for the named release from the
+// given bucket to a file on disk.
+func downloadPublishReport(ctx context.Context, bucket, releaseName, path string) error {
+	store, err := release.OpenArtifactStore(ctx, bucket)
+	if err != nil {
+		return err
+	}
+
+	r, err := store.NewReader(ctx, publishReportObjectName(releaseName))
+	if err != nil {
+		return fmt.Errorf("failed to download publish report: %w", err)
+	}
+	defer r.Close()
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(f, r); err != nil {
+		return fmt.Errorf("failed to download publish report: %w", err)
+	}
+
+	return f.Close()
+}
@@ -0,0 +1,107 @@
+/*
+Copyright 2021 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cert-manager/release/pkg/release"
+	"github.com/cert-manager/release/pkg/release/sbom"
+)
+
+func TestPublishReport(t *testing.T) {
+	ctx := context.TODO()
+	dir := writeTestReleaseDir(t, "v1.15.0", "amd64")
+	rel, ledger := loadTestRelease(t, dir)
+
+	const digest = "sha256:4f53cda18c2baa0c0354bb5f9a3ecbe5ed12ab4d8e11ba873c2f11161202b945"
+	for _, e := range []release.LedgerEntry{
+		{Kind: release.LedgerEntryImage, Name: "quay.io/jetstack/cert-manager-controller-amd64:v1.15.0", Digest: digest},
+		{Kind: release.LedgerEntryImage, Name: "ghcr.io/cert-manager/cert-manager-controller-amd64:v1.15.0", Digest: digest},
+		{Kind: release.LedgerEntryImageIndex, Name: "quay.io/jetstack/cert-manager-controller:v1.15.0", Digest: digest},
+		{Kind: release.LedgerEntrySignature, Name: "quay.io/jetstack/cert-manager-controller:v1.15.0", Digest: digest},
+		{Kind: release.LedgerEntryAttestation, Name: "quay.io/jetstack/cert-manager-controller:v1.15.0", Digest: digest},
+		{Kind: release.LedgerEntrySBOM, Name: sbomLedgerName("quay.io/jetstack/cert-manager-controller-amd64:v1.15.0", sbom.FormatSPDX), Digest: digest},
+		{Kind: release.LedgerEntryGitHubRelease, Name: "v1.15.0", ID: 1, URL: "https://github.com/cert-manager/cert-manager/releases/tag/untagged-1"},
+		{Kind: release.LedgerEntryGitHubAsset, Name: "cert-manager.yaml", Digest: "sha256:abcd", URL: "https://example.com/cert-manager.yaml"},
+		{Kind: release.LedgerEntryHelmChartPR, Name: rel.ReleaseName, URL: "https://github.com/jetstack/jetstack-charts/pull/1"},
+	} {
+		if err := ledger.Record(ctx, e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report, err := buildPublishReport(rel, ledger, "quay.io/jetstack", []string{"ghcr.io/cert-manager"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	o := NewGCBPublishOptions()
+	o.ReportFile = filepath.Join(t.TempDir(), "report.json")
+	o.ReportBucket = "file://" + t.TempDir()
+	if err := writePublishReport(ctx, o, report); err != nil {
+		t.Fatal(err)
+	}
+
+	downloaded := filepath.Join(t.TempDir(), "downloaded.json")
+	if err := downloadPublishReport(ctx, o.ReportBucket, rel.ReleaseName, downloaded); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{o.ReportFile, downloaded} {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var got publishReport
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatal(err)
+		}
+
+		if !got.Complete || got.ReleaseVersion != "v1.15.0" {
+			t.Errorf("unexpected report header: %#v", got)
+		}
+		if len(got.Images) != 1 || got.Images[0].Digest != digest {
+			t.Errorf("unexpected images: %#v", got.Images)
+		} else if len(got.Images[0].Mirrors) != 1 || got.Images[0].Mirrors[0].Reference != "ghcr.io/cert-manager/cert-manager-controller-amd64:v1.15.0" || got.Images[0].Mirrors[0].Digest != digest {
+			t.Errorf("unexpected mirrored images: %#v", got.Images[0].Mirrors)
+		}
+		if len(got.ImageIndexes) != 1 {
+			t.Errorf("unexpected image indexes: %#v", got.ImageIndexes)
+		}
+		if len(got.Signatures) != 1 || got.Signatures[0].Reference != "quay.io/jetstack/cert-manager-controller:sha256-4f53cda18c2baa0c0354bb5f9a3ecbe5ed12ab4d8e11ba873c2f11161202b945.sig" {
+			t.Errorf("unexpected signatures: %#v", got.Signatures)
+		}
+		if len(got.Attestations) != 1 || got.Attestations[0].Reference != "quay.io/jetstack/cert-manager-controller:sha256-4f53cda18c2baa0c0354bb5f9a3ecbe5ed12ab4d8e11ba873c2f11161202b945.att" {
+			t.Errorf("unexpected attestations: %#v", got.Attestations)
+		}
+		if len(got.SBOMs) != 1 || got.SBOMs[0].Subject != "quay.io/jetstack/cert-manager-controller-amd64:v1.15.0" || got.SBOMs[0].Format != "spdx" {
+			t.Errorf("unexpected SBOMs: %#v", got.SBOMs)
+		}
+		if got.GitHubRelease == nil || len(got.GitHubRelease.Assets) != 1 || got.GitHubRelease.Assets[0].SHA256 != "abcd" || got.GitHubRelease.Assets[0].DownloadURL != "https://example.com/cert-manager.yaml" {
+			t.Errorf("unexpected GitHub release: %#v", got.GitHubRelease)
+		}
+		if got.HelmChartPR != "https://github.com/jetstack/jetstack-charts/pull/1" {
+			t.Errorf("unexpected Helm chart PR: %q", got.HelmChartPR)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2021 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+
+	"github.com/cert-manager/release/pkg/release/publish/registry"
+)
+
+const (
+	// defaultRegistryRateLimit is the default for --registry-rate-limit
+	defaultRegistryRateLimit = 10
+
+	// registryRateLimitUsage describes the --registry-rate-limit flag in flag usage
+	registryRateLimitUsage = "Maximum number of requests per second made to each image registry host when publishing, or 0 for no limit. Requests are retried with exponential backoff, waiting as long as the registry asks when it responds with 429 Too Many Requests."
+
+	// registryHostRateLimitsUsage describes the --registry-host-rate-limits flag in flag usage
+	registryHostRateLimitsUsage = "Comma-separated <host>=<requests per second> limits which override --registry-rate-limit for specific registry hosts, e.g. quay.io=5"
+)
+
+// newRegistryRateLimiter returns a rate limiter allowing limit requests per
+// second to each registry host, overridden by hostLimits given as
+// <host>=<requests per second>.
+func newRegistryRateLimiter(limit float64, hostLimits []string) (*registry.RateLimiter, error) {
+	if limit < 0 {
+		return nil, fmt.Errorf("invalid registry rate limit %v: must not be negative", limit)
+	}
+
+	limits := map[string]float64{}
+	for _, hostLimit := range hostLimits {
+		rawHost, value, ok := strings.Cut(hostLimit, "=")
+		if !ok || rawHost == "" {
+			return nil, fmt.Errorf("invalid registry host rate limit %q: expected <host>=<requests per second>", hostLimit)
+		}
+
+		// requests are made to the canonical host, e.g. index.docker.io
+		// rather than docker.io, so limits must be keyed by it
+		reg, err := name.NewRegistry(rawHost)
+		if err != nil {
+			return nil, fmt.Errorf("invalid registry host rate limit %q: %w", hostLimit, err)
+		}
+		host := reg.RegistryStr()
+
+		if _, ok := limits[host]; ok {
+			return nil, fmt.Errorf("invalid registry host rate limits: %q is given more than once", host)
+		}
+
+		hostRate, err := strconv.ParseFloat(value, 64)
+		if err != nil || hostRate < 0 {
+			return nil, fmt.Errorf("invalid registry host rate limit %q: expected a non-negative number of requests per second", hostLimit)
+		}
+
+		limits[host] = hostRate
+	}
+
+	return registry.NewRateLimiter(limit, limits), nil
+}
@@ -0,0 +1,145 @@
+/*
+Copyright 2021 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewRegistryRateLimiter(t *testing.T) {
+	tests := map[string]struct {
+		limit      float64
+		hostLimits []string
+		expectErr  string
+	}{
+		"default limit only": {
+			limit: 10,
+		},
+		"no limit": {
+			limit: 0,
+		},
+		"host limits": {
+			limit:      10,
+			hostLimits: []string{"quay.io=5", "europe-west1-docker.pkg.dev=0.5"},
+		},
+		"negative limit": {
+			limit:     -1,
+			expectErr: "must not be negative",
+		},
+		"host limit without a value": {
+			limit:      10,
+			hostLimits: []string{"quay.io"},
+			expectErr:  "expected <host>=<requests per second>",
+		},
+		"host limit without a host": {
+			limit:      10,
+			hostLimits: []string{"=5"},
+			expectErr:  "expected <host>=<requests per second>",
+		},
+		"host limit which isn't a number": {
+			limit:      10,
+			hostLimits: []string{"quay.io=fast"},
+			expectErr:  "expected a non-negative number",
+		},
+		"negative host limit": {
+			limit:      10,
+			hostLimits: []string{"quay.io=-5"},
+			expectErr:  "expected a non-negative number",
+		},
+		"duplicate host": {
+			limit:      10,
+			hostLimits: []string{"quay.io=5", "quay.io=1"},
+			expectErr:  "given more than once",
+		},
+		"duplicate host given by an alias": {
+			limit:      10,
+			hostLimits: []string{"docker.io=5", "index.docker.io=1"},
+			expectErr:  "given more than once",
+		},
+		"invalid host": {
+			limit:      10,
+			hostLimits: []string{"quay.io/jetstack=5"},
+			expectErr:  "invalid registry host rate limit",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			limiter, err := newRegistryRateLimiter(test.limit, test.hostLimits)
+			if test.expectErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.expectErr) {
+					t.Errorf("expected error containing %q, got: %v", test.expectErr, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if limiter == nil {
+				t.Errorf("expected a rate limiter")
+			}
+		})
+	}
+}
+
+func TestNewRegistryRateLimiterOverridesHosts(t *testing.T) {
+	limiter, err := newRegistryRateLimiter(0, []string{"quay.io=1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	for range 10 {
+		if err := limiter.WaitForReference(ctx, "ghcr.io/cert-manager/cert-manager-controller:v1.15.0"); err != nil {
+			t.Fatalf("expected hosts without a limit not to wait: %v", err)
+		}
+	}
+
+	if err := limiter.WaitForReference(ctx, "quay.io/jetstack/cert-manager-controller:v1.15.0"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := limiter.WaitForReference(ctx, "quay.io/jetstack/cert-manager-webhook:v1.15.0"); err == nil {
+		t.Errorf("expected a second request within a second to quay.io to wait")
+	}
+}
+
+func TestNewRegistryRateLimiterNormalizesHosts(t *testing.T) {
+	limiter, err := newRegistryRateLimiter(0, []string{"docker.io=1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	// requests to Docker Hub are made to index.docker.io
+	if err := limiter.Wait(ctx, "index.docker.io"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := limiter.Wait(ctx, "index.docker.io"); err == nil {
+		t.Errorf("expected the docker.io limit to apply to requests to index.docker.io")
+	}
+}
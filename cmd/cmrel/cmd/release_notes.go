@@ -0,0 +1,111 @@
+/*
+Copyright 2021 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/google/go-github/v35/github"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/cert-manager/release/pkg/release"
+	"github.com/cert-manager/release/pkg/release/notes"
+	"github.com/cert-manager/release/pkg/sign"
+)
+
+// placeholderReleaseBody is the body of the draft GitHub release if its
+// release notes couldn't be generated.
+const placeholderReleaseBody = "!!! Update this release note body before publishing this draft release!"
+
+// generateReleaseNotes returns the body of the GitHub release: the release
+// notes of every PR merged since the previous release, followed by the
+// digest of each image and instructions for verifying the release.
+func generateReleaseNotes(ctx context.Context, o *gcbPublishOptions, rel *release.Unpacked, githubClient *github.Client) (string, error) {
+	releaseNotes, err := notes.Generate(ctx, notes.NewGitHubClient(githubClient), o.PublishedGitHubOrg, o.PublishedGitHubRepo, rel.ReleaseVersion, rel.GitCommitRef)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate release notes: %w", err)
+	}
+
+	releaseNotes.Images, err = releaseImageIndexes(o, rel)
+	if err != nil {
+		return "", err
+	}
+
+	releaseNotes.Verification, err = releaseNotesVerification(o)
+	if err != nil {
+		return "", err
+	}
+
+	return releaseNotes.Markdown(), nil
+}
+
+// releaseNotesVerification describes how the release can be verified. Keys
+// which cosign can't use, such as the file:// and ephemeral:// keys used for
+// testing, aren't listed for verifying images, since images aren't signed
+// with them.
+func releaseNotesVerification(o *gcbPublishOptions) (notes.Verification, error) {
+	verification := notes.Verification{ChecksumsFile: checksumsFileName}
+	if o.SkipSigning {
+		return verification, nil
+	}
+
+	parsedKeys, err := sign.ParseSigningKeys(o.SigningKMSKey)
+	if err != nil {
+		return notes.Verification{}, err
+	}
+
+	for _, key := range parsedKeys {
+		cosignKey, err := key.CosignFormat()
+		if err != nil {
+			log.Printf("Not listing %q in the release notes for verifying images: %v", key, err)
+			continue
+		}
+		verification.CosignKeys = append(verification.CosignKeys, cosignKey)
+	}
+
+	verification.ChecksumsSignatureFile = checksumsSignatureFileName
+	verification.KeyringFile = keyringFileName
+
+	return verification, nil
+}
+
+// releaseImageIndexes returns the name and digest of the multi-arch image
+// index of each component, as pushed by pushContainerImages. The digests are
+// computed from the staged images, so they're known before the images are
+// pushed.
+func releaseImageIndexes(o *gcbPublishOptions, rel *release.Unpacked) ([]notes.Image, error) {
+	content, err := expectedRegistryContent(o.PublishedImageRepository, rel)
+	if err != nil {
+		return nil, err
+	}
+
+	indexNames := sets.NewString()
+	for name := range rel.ComponentImageBundles {
+		indexNames.Insert(buildImageIndexName(o.PublishedImageRepository, name, rel.ReleaseVersion))
+	}
+
+	var indexes []notes.Image
+	for _, c := range content {
+		if indexNames.Has(c.ref) {
+			indexes = append(indexes, notes.Image{Name: c.ref, Digest: c.digest})
+		}
+	}
+
+	return indexes, nil
+}
@@ -82,8 +82,12 @@ func Execute() {
 	cmd.AddCommand(makeStageCmd(o))
 	cmd.AddCommand(gcbCmd(o))
 	cmd.AddCommand(publishCmd(o))
+	cmd.AddCommand(dryRunCmd(o))
+	cmd.AddCommand(unpublishCmd(o))
+	cmd.AddCommand(verifyCmd(o))
 	cmd.AddCommand(bootstrapPGPCmd(o))
 	cmd.AddCommand(signCmd(o))
+	cmd.AddCommand(keysCmd(o))
 	cmd.AddCommand(validateGoModCmd(o))
 
 	if err := cmd.Execute(); err != nil {
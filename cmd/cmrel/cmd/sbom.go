@@ -0,0 +1,216 @@
+/*
+Copyright 2021 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+
+	"github.com/cert-manager/release/pkg/release"
+	"github.com/cert-manager/release/pkg/release/publish/registry"
+	"github.com/cert-manager/release/pkg/release/sbom"
+)
+
+// releaseSBOM is an SBOM, in a single format, for one artifact in a release.
+type releaseSBOM struct {
+	// AssetName is the name of the SBOM when uploaded to the GitHub release
+	AssetName string
+
+	Format sbom.Format
+	Data   []byte
+
+	// ImageTag and ImageDigest identify the image the SBOM describes, and
+	// are empty for SBOMs of ctl binaries.
+	ImageTag    string
+	ImageDigest string
+}
+
+// sbomLedgerName is the name under which an SBOM attached to an image is
+// recorded in the publish ledger.
+func sbomLedgerName(imageTag string, format sbom.Format) string {
+	return fmt.Sprintf("%s#%s", imageTag, format)
+}
+
+// sbomCreationTime is the creation time recorded in every SBOM for a release.
+// It's fixed so that SBOMs are reproducible, which allows a publish to be
+// resumed without changing content which was already published.
+func sbomCreationTime(rel *release.Unpacked) time.Time {
+	if rel.BuildInputs != nil && !rel.BuildInputs.FinishedOn.IsZero() {
+		return rel.BuildInputs.FinishedOn
+	}
+	return time.Unix(0, 0)
+}
+
+// imageSBOMs generates SBOMs for every component image in the release, as
+// pushed to the given image repository, in every supported format.
+func imageSBOMs(repository string, rel *release.Unpacked) ([]releaseSBOM, error) {
+	components := make([]string, 0, len(rel.ComponentImageBundles))
+	for component := range rel.ComponentImageBundles {
+		components = append(components, component)
+	}
+	sort.Strings(components)
+
+	var sboms []releaseSBOM
+	for _, component := range components {
+		for _, t := range rel.ComponentImageBundles[component] {
+			imageTag := buildImageTag(repository, component, t.Architecture(), rel.ReleaseVersion)
+
+			tag, err := name.NewTag(imageTag)
+			if err != nil {
+				return nil, fmt.Errorf("invalid image tag %q: %w", imageTag, err)
+			}
+
+			digest, err := registry.ImageDigest(t)
+			if err != nil {
+				return nil, err
+			}
+
+			img, err := t.Image()
+			if err != nil {
+				return nil, err
+			}
+
+			doc, err := sbom.FromImage(img, sbom.Subject{Name: tag.Context().Name(), Version: rel.ReleaseVersion, Digest: digest}, sbomCreationTime(rel))
+			if err != nil {
+				return nil, err
+			}
+
+			for _, format := range sbom.Formats {
+				data, err := doc.Encode(format)
+				if err != nil {
+					return nil, err
+				}
+
+				sboms = append(sboms, releaseSBOM{
+					AssetName:   fmt.Sprintf("cert-manager-%s-%s-%s%s", component, t.OS(), t.Architecture(), format.FileExtension()),
+					Format:      format,
+					Data:        data,
+					ImageTag:    imageTag,
+					ImageDigest: digest,
+				})
+			}
+		}
+	}
+
+	return sboms, nil
+}
+
+// binarySBOMs generates SBOMs for every ctl binary in the release, in every
+// supported format.
+func binarySBOMs(rel *release.Unpacked) ([]releaseSBOM, error) {
+	var sboms []releaseSBOM
+	for _, archive := range rel.CtlBinaryBundles {
+		binary, err := archive.Binary()
+		if err != nil {
+			return nil, err
+		}
+
+		sum := sha256.Sum256(binary)
+		subject := sbom.Subject{Name: archive.Name(), Version: rel.ReleaseVersion, Digest: "sha256:" + hex.EncodeToString(sum[:])}
+
+		doc, err := sbom.FromGoBinary(binary, subject, sbomCreationTime(rel))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, format := range sbom.Formats {
+			data, err := doc.Encode(format)
+			if err != nil {
+				return nil, err
+			}
+
+			sboms = append(sboms, releaseSBOM{
+				AssetName: strings.TrimSuffix(archive.ArtifactFilename(), archive.Extension()) + format.FileExtension(),
+				Format:    format,
+				Data:      data,
+			})
+		}
+	}
+
+	return sboms, nil
+}
+
+// writeSBOMAssets writes SBOMs for every image and ctl binary in the release
+// to dir, and returns them as GitHub release assets.
+func writeSBOMAssets(o *gcbPublishOptions, rel *release.Unpacked, dir string) ([]githubReleaseAsset, error) {
+	images, err := imageSBOMs(o.PublishedImageRepository, rel)
+	if err != nil {
+		return nil, err
+	}
+
+	binaries, err := binarySBOMs(rel)
+	if err != nil {
+		return nil, err
+	}
+
+	var assets []githubReleaseAsset
+	for _, s := range append(images, binaries...) {
+		path := filepath.Join(dir, s.AssetName)
+		if err := os.WriteFile(path, s.Data, 0o644); err != nil {
+			return nil, fmt.Errorf("failed to write SBOM %q: %w", s.AssetName, err)
+		}
+
+		assets = append(assets, githubReleaseAsset{Name: s.AssetName, Path: path})
+	}
+
+	log.Printf("Generated %d SBOMs for release images and binaries", len(assets))
+
+	return assets, nil
+}
+
+// attachImageSBOMs attaches SBOMs to every image pushed to the given image
+// repository as OCI artifacts.
+func attachImageSBOMs(ctx context.Context, o *gcbPublishOptions, r imageRegistry, rel *release.Unpacked) error {
+	sboms, err := imageSBOMs(r.repository, rel)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range sboms {
+		ledgerName := sbomLedgerName(s.ImageTag, s.Format)
+		if _, ok := o.ledger.Get(release.LedgerEntrySBOM, ledgerName); ok {
+			log.Printf("Skipping attaching %s SBOM to %q which was attached by a previous run", s.Format, s.ImageTag)
+			continue
+		}
+
+		log.Printf("Attaching %s SBOM to %q", s.Format, s.ImageTag)
+		var digest string
+		if err := retry(ctx, func() error {
+			var err error
+			digest, err = r.publisher.AttachArtifact(ctx, s.ImageTag, s.ImageDigest, s.Format.MediaType(), s.Data)
+			return err
+		}); err != nil {
+			return fmt.Errorf("failed to attach %s SBOM to %q: %w", s.Format, s.ImageTag, err)
+		}
+
+		if err := o.ledger.Record(ctx, release.LedgerEntry{Kind: release.LedgerEntrySBOM, Name: ledgerName, Digest: digest}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -55,6 +55,7 @@ func signCmd(o *rootOptions) *cobra.Command {
 
 	cmd.AddCommand(signHelmCmd(o))
 	cmd.AddCommand(signManifestsCmd(o))
+	cmd.AddCommand(signVerifyHelmCmd(o))
 
 	return cmd
 }
@@ -51,14 +51,22 @@ var signHelmExample = fmt.Sprintf(`To sign a chart called "mychart.tgz":
 type signHelmOptions struct {
 	// Key is the full name of the GCP KMS key to be used, e.g.
 	// projects/<PROJECT_NAME>/locations/<LOCATION>/keyRings/<KEYRING_NAME>/cryptoKeys/<KEY_NAME>/cryptoKeyVersions/<KEY_VERSION>
+	// Other keys, such as local files, can be given as URIs; see sign.SigningKey.
+	// Multiple comma-separated keys can be given, in which case the chart is
+	// signed with each of them.
 	Key string
 
+	// PGPProfile is the path to a YAML file of key profiles, which set the PGP
+	// identity of each key; see sign.KeyProfiles
+	PGPProfile string
+
 	// ChartPath is the path to the directory for the chart to sign
 	ChartPath string
 }
 
 func (o *signHelmOptions) AddFlags(fs *flag.FlagSet, markRequired func(string)) {
-	fs.StringVar(&o.Key, "key", "", "Full name of the GCP KMS key to use for signing")
+	fs.StringVar(&o.Key, "key", "", "Key to use for signing; "+signingKeyFormats+". "+multipleSigningKeys)
+	fs.StringVar(&o.PGPProfile, "pgp-profile", "", pgpProfileUsage)
 	fs.StringVar(&o.ChartPath, "chart-path", "", "Path to the directory of the helm chart to sign, similar to what would be passed into 'helm package'")
 	markRequired("key")
 	markRequired("chart-path")
@@ -66,8 +74,9 @@ func (o *signHelmOptions) AddFlags(fs *flag.FlagSet, markRequired func(string))
 
 func (o *signHelmOptions) print() {
 	log.Printf("sign helm options:")
-	log.Printf("        Key: %q", o.Key)
-	log.Printf("  ChartPath: %q", o.ChartPath)
+	log.Printf("         Key: %q", o.Key)
+	log.Printf("  PGPProfile: %q", o.PGPProfile)
+	log.Printf("   ChartPath: %q", o.ChartPath)
 }
 
 func signHelmCmd(rootOpts *rootOptions) *cobra.Command {
@@ -95,12 +104,17 @@ func signHelmCmd(rootOpts *rootOptions) *cobra.Command {
 func runSignHelm(rootOpts *rootOptions, o *signHelmOptions) error {
 	ctx := context.Background()
 
-	parsedKey, err := sign.NewGCPKMSKey(o.Key)
+	parsedKeys, err := loadSigningKeys(o.Key, o.PGPProfile)
+	if err != nil {
+		return err
+	}
+
+	signers, err := sign.NewSigners(ctx, parsedKeys)
 	if err != nil {
 		return err
 	}
 
-	signatureBytes, err := sign.HelmChart(ctx, parsedKey, o.ChartPath)
+	signatureBytes, err := sign.HelmChart(signers, o.ChartPath)
 	if err != nil {
 		return fmt.Errorf("failed to sign: %w", err)
 	}
@@ -51,8 +51,15 @@ var signManifestsExample = fmt.Sprintf(`To sign a manifests bundle at "/tmp/cert
 type signManifestsOptions struct {
 	// Key is the full name of the GCP KMS key to be used for signing, e.g.
 	// projects/<PROJECT_NAME>/locations/<LOCATION>/keyRings/<KEYRING_NAME>/cryptoKeys/<KEY_NAME>/cryptoKeyVersions/<KEY_VERSION>
+	// Other keys, such as local files, can be given as URIs; see sign.SigningKey.
+	// Multiple comma-separated keys can be given, in which case the chart is
+	// signed with each of them.
 	Key string
 
+	// PGPProfile is the path to a YAML file of key profiles, which set the PGP
+	// identity of each key; see sign.KeyProfiles
+	PGPProfile string
+
 	// Path is the path to the cert-manager-manifests.tar.gz file
 	Path string
 
@@ -64,7 +71,8 @@ type signManifestsOptions struct {
 }
 
 func (o *signManifestsOptions) AddFlags(fs *flag.FlagSet, markRequired func(string)) {
-	fs.StringVar(&o.Key, "key", "", "Full name of the GCP KMS key to use for signing")
+	fs.StringVar(&o.Key, "key", "", "Key to use for signing; "+signingKeyFormats+". "+multipleSigningKeys)
+	fs.StringVar(&o.PGPProfile, "pgp-profile", "", pgpProfileUsage)
 	fs.StringVar(&o.Path, "path", "", "Path to cert-manager-manifests.tar.gz")
 	fs.StringVar(&o.ReleaseVersion, "release-version", "", "Release version to add to the chart path when signing. The filename must match the filename the chart will eventually be distributed with. E.g. for version 'v1.0.0', the chart will be signed as `cert-manager-v.1.0.0.tgz`.")
 	markRequired("key")
@@ -75,6 +83,7 @@ func (o *signManifestsOptions) AddFlags(fs *flag.FlagSet, markRequired func(stri
 func (o *signManifestsOptions) print() {
 	log.Printf("sign manifests options:")
 	log.Printf("   Key: %q", o.Key)
+	log.Printf("  PGPProfile: %q", o.PGPProfile)
 	log.Printf("  Path: %q", o.Path)
 
 	log.Printf("  ReleaseVersion: %q", o.ReleaseVersion)
@@ -105,12 +114,17 @@ func signManifestsCmd(rootOpts *rootOptions) *cobra.Command {
 func runSignManifests(rootOpts *rootOptions, o *signManifestsOptions) error {
 	ctx := context.Background()
 
-	parsedKey, err := sign.NewGCPKMSKey(o.Key)
+	parsedKeys, err := loadSigningKeys(o.Key, o.PGPProfile)
+	if err != nil {
+		return err
+	}
+
+	signers, err := sign.NewSigners(ctx, parsedKeys)
 	if err != nil {
 		return err
 	}
 
-	err = sign.CertManagerManifests(ctx, parsedKey, o.Path, o.ReleaseVersion)
+	err = sign.CertManagerManifests(signers, o.Path, o.ReleaseVersion)
 	if err != nil {
 		return fmt.Errorf("failed to complete signing of %q: %w", o.Path, err)
 	}
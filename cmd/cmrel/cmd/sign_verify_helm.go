@@ -0,0 +1,180 @@
+/*
+Copyright 2021 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	flag "github.com/spf13/pflag"
+
+	"github.com/cert-manager/release/pkg/sign"
+)
+
+const (
+	signVerifyHelmCommand         = "verify-helm"
+	signVerifyHelmDescription     = "Verify the provenance file of a signed helm chart"
+	signVerifyHelmLongDescription = `The verify-helm command checks a helm chart provenance file, such as one created
+by 'cmrel sign helm', without needing the helm CLI or a local keyring.
+
+The provenance file must be clearsigned by the PGP key for the KMS key, or by
+the given public key, and both the SHA256 of the chart archive and the chart
+metadata it holds must match the chart. The ID and creation time of the key
+which made the signature are printed.
+
+The name of the chart file must be the name it was signed under, which is the
+name it is published as in the helm repository.`
+)
+
+var signVerifyHelmExample = fmt.Sprintf(`To verify a chart called "cert-manager-v1.15.0.tgz" against "cert-manager-v1.15.0.tgz.prov":
+
+%s %s %s --chart-path cert-manager-v1.15.0.tgz
+
+To verify it using a public key created by 'cmrel bootstrap-pgp', without accessing KMS:
+
+%s %s %s --chart-path cert-manager-v1.15.0.tgz --public-key cert-manager.asc`, rootCommand, signCommand, signVerifyHelmCommand, rootCommand, signCommand, signVerifyHelmCommand)
+
+type signVerifyHelmOptions struct {
+	// Key is the full name of the GCP KMS key or the URI of the key which the
+	// chart should have been signed with, used to derive the PGP public key if
+	// PublicKey isn't set.
+	Key string
+
+	// PGPProfile is the path to a YAML file of key profiles, which set the PGP
+	// identity of the key; see sign.KeyProfiles. Only used if PublicKey isn't set.
+	PGPProfile string
+
+	// PublicKey, if set, is the path to an armored PGP public key to verify
+	// the provenance file with.
+	PublicKey string
+
+	// ChartPath is the path to the packaged chart to verify
+	ChartPath string
+
+	// ProvPath is the path to the provenance file for the chart. Defaults to
+	// ChartPath with a ".prov" suffix.
+	ProvPath string
+}
+
+func (o *signVerifyHelmOptions) AddFlags(fs *flag.FlagSet, markRequired func(string)) {
+	fs.StringVar(&o.Key, "key", defaultKMSKey, "Key the chart should have been signed with; "+signingKeyFormats+". Only used if --public-key is not set.")
+	fs.StringVar(&o.PGPProfile, "pgp-profile", "", pgpProfileUsage+" Only used if --public-key is not set.")
+	fs.StringVar(&o.PublicKey, "public-key", "", "Path to an armored PGP public key to verify the provenance file with, instead of deriving it from the KMS key.")
+	fs.StringVar(&o.ChartPath, "chart-path", "", "Path to the packaged helm chart to verify.")
+	fs.StringVar(&o.ProvPath, "prov-path", "", "Path to the provenance file for the chart. Defaults to the chart path with a '.prov' suffix.")
+	markRequired("chart-path")
+}
+
+func (o *signVerifyHelmOptions) print() {
+	log.Printf("sign verify-helm options:")
+	log.Printf("         Key: %q", o.Key)
+	log.Printf("  PGPProfile: %q", o.PGPProfile)
+	log.Printf("   PublicKey: %q", o.PublicKey)
+	log.Printf("   ChartPath: %q", o.ChartPath)
+	log.Printf("    ProvPath: %q", o.ProvPath)
+}
+
+func signVerifyHelmCmd(rootOpts *rootOptions) *cobra.Command {
+	o := &signVerifyHelmOptions{}
+	cmd := &cobra.Command{
+		Use:          signVerifyHelmCommand,
+		Short:        signVerifyHelmDescription,
+		Long:         signVerifyHelmLongDescription,
+		Example:      signVerifyHelmExample,
+		SilenceUsage: true,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			o.print()
+			log.Printf("---")
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runSignVerifyHelm(rootOpts, o)
+		},
+	}
+
+	o.AddFlags(cmd.Flags(), mustMarkRequired(cmd.MarkFlagRequired))
+
+	return cmd
+}
+
+func runSignVerifyHelm(rootOpts *rootOptions, o *signVerifyHelmOptions) error {
+	ctx := context.Background()
+
+	publicKey, err := o.publicKey(ctx)
+	if err != nil {
+		return err
+	}
+
+	provPath := o.ProvPath
+	if provPath == "" {
+		provPath = o.ChartPath + ".prov"
+	}
+
+	prov, err := os.ReadFile(provPath)
+	if err != nil {
+		return fmt.Errorf("failed to read provenance file: %w", err)
+	}
+
+	verification, err := sign.VerifyHelmChart(o.ChartPath, prov, publicKey)
+	if err != nil {
+		return fmt.Errorf("failed to verify %q: %w", o.ChartPath, err)
+	}
+
+	log.Printf("verified %q (%s) against %q", verification.FileName, verification.SHA256, provPath)
+	log.Printf("  signed by key %s, created %s", verification.KeyID, verification.KeyCreationTime.UTC().Format(time.RFC3339))
+	log.Printf("  signature created %s", verification.SignatureCreationTime.UTC().Format(time.RFC3339))
+	log.Printf("  key identities: %s", strings.Join(verification.Identities, ", "))
+
+	return nil
+}
+
+// publicKey reads the PGP public key from PublicKey if set, and otherwise
+// derives it from the KMS key.
+func (o *signVerifyHelmOptions) publicKey(ctx context.Context) (sign.PGPArmoredBlock, error) {
+	if o.PublicKey != "" {
+		data, err := os.ReadFile(o.PublicKey)
+		if err != nil {
+			return "", fmt.Errorf("failed to read PGP public key: %w", err)
+		}
+		return sign.PGPArmoredBlock(data), nil
+	}
+
+	parsedKeys, err := loadSigningKeys(o.Key, o.PGPProfile)
+	if err != nil {
+		return "", err
+	}
+
+	if len(parsedKeys) != 1 {
+		return "", fmt.Errorf("expected a single key to verify the chart with, but got %d", len(parsedKeys))
+	}
+
+	signer, err := sign.NewSigner(ctx, parsedKeys[0])
+	if err != nil {
+		return "", err
+	}
+
+	publicKey, err := sign.BootstrapPGP(signer)
+	if err != nil {
+		return "", fmt.Errorf("failed to derive PGP public key: %w", err)
+	}
+
+	return publicKey, nil
+}
@@ -95,6 +95,10 @@ type stageOptions struct {
 	// This must be set if SkipSigning is not set to true
 	SigningKMSKey string
 
+	// PGPProfile is the path to a local YAML file of key profiles, which set the
+	// PGP identity of each signing key; see sign.KeyProfiles
+	PGPProfile string
+
 	// TargetOSes is a comma-separated list of OSes which should be built for in this invocation
 	TargetOSes string
 
@@ -113,7 +117,8 @@ func (o *stageOptions) AddFlags(fs *flag.FlagSet, markRequired func(string)) {
 	fs.StringVar(&o.Project, "project", release.DefaultReleaseProject, "The GCP project to run the GCB build jobs in.")
 	fs.StringVar(&o.ReleaseVersion, "release-version", "", "Optional release version override used to force the version strings used during the release to a specific value. If not set, build is treated as development build and artifacts staged to 'devel' path.")
 	fs.StringVar(&o.PublishedImageRepository, "published-image-repo", release.DefaultImageRepository, "The docker image repository set when building the release.")
-	fs.StringVar(&o.SigningKMSKey, "signing-kms-key", defaultKMSKey, "Full name of the GCP KMS key to use for signing")
+	fs.StringVar(&o.SigningKMSKey, "signing-kms-key", defaultKMSKey, "Full name of the GCP KMS key to use for signing. "+multipleSigningKeys)
+	fs.StringVar(&o.PGPProfile, "pgp-profile", "", pgpProfileUsage)
 	fs.BoolVar(&o.SkipSigning, "skip-signing", false, "Skip signing release artifacts.")
 
 	allOSList := release.AllOSes()
@@ -138,6 +143,7 @@ func (o *stageOptions) print() {
 	log.Printf("  SkipSigning: %v", o.SkipSigning)
 	log.Printf("  Project: %q", o.Project)
 	log.Printf("  SigningKMSKey: %q", o.SigningKMSKey)
+	log.Printf("  PGPProfile: %q", o.PGPProfile)
 	log.Printf("  ReleaseVersion: %q", o.ReleaseVersion)
 	log.Printf("  PublishedImageRepo: %q", o.PublishedImageRepository)
 	log.Printf("  TargetOSes: %q", o.TargetOSes)
@@ -175,11 +181,16 @@ func runStage(rootOpts *rootOptions, o *stageOptions) error {
 	}
 
 	if o.SigningKMSKey != "" {
-		if _, err := sign.NewGCPKMSKey(o.SigningKMSKey); err != nil {
+		if _, err := sign.ParseSigningKeys(o.SigningKMSKey); err != nil {
 			return err
 		}
 	}
 
+	pgpProfile, err := encodePGPProfile(o.PGPProfile)
+	if err != nil {
+		return err
+	}
+
 	log.Printf("Staging build for %s/%s@%s", o.Org, o.Repo, o.GitRef)
 
 	log.Printf("DEBUG: Loading cloudbuild.yaml file from %q", o.CloudBuildFile)
@@ -214,6 +225,7 @@ func runStage(rootOpts *rootOptions, o *stageOptions) error {
 	build.Substitutions["_PUBLISHED_IMAGE_REPO"] = o.PublishedImageRepository
 	build.Substitutions["_KMS_KEY"] = o.SigningKMSKey
 	build.Substitutions["_SKIP_SIGNING"] = fmt.Sprintf("%v", o.SkipSigning)
+	build.Substitutions["_PGP_PROFILE"] = pgpProfile
 	build.Substitutions["_TARGET_OSES"] = strings.Join(targetOSes.List(), ",")
 	build.Substitutions["_TARGET_ARCHES"] = strings.Join(targetArches.List(), ",")
 
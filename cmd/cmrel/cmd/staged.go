@@ -23,7 +23,6 @@ import (
 	"sort"
 	"text/tabwriter"
 
-	"cloud.google.com/go/storage"
 	"github.com/spf13/cobra"
 	flag "github.com/spf13/pflag"
 	"golang.org/x/mod/semver"
@@ -120,7 +119,7 @@ type stagedOptions struct {
 }
 
 func (o *stagedOptions) AddFlags(fs *flag.FlagSet, markRequired func(string)) {
-	fs.StringVar(&o.Bucket, "bucket", release.DefaultBucketName, "The name of the GCS bucket containing the staged releases.")
+	fs.StringVar(&o.Bucket, "bucket", release.DefaultBucketName, "The name of the GCS bucket containing the staged releases, or a local directory prefixed with 'file://'.")
 	fs.StringVar(&o.GitRef, "git-ref", "", "Optional specific git reference to list staged releases for - if specified, --release-version must also be specified.")
 	fs.StringVar(&o.ReleaseVersion, "release-version", "", "Optional release version override used to force the version strings used during the release to a specific value.")
 	fs.StringVar(&o.ReleaseType, "release-type", "release", "The type of release to list, usually one of 'release' or 'devel'")
@@ -158,12 +157,12 @@ func runStaged(_ *rootOptions, o *stagedOptions) error {
 		return fmt.Errorf("cannot specify --git-ref without --release-version")
 	}
 	ctx := context.Background()
-	gcs, err := storage.NewClient(ctx)
+	store, err := release.OpenArtifactStore(ctx, o.Bucket)
 	if err != nil {
-		return fmt.Errorf("failed to create GCS client: %w", err)
+		return err
 	}
 
-	bucket := release.NewBucket(gcs.Bucket(o.Bucket), release.DefaultBucketPathPrefix, o.ReleaseType)
+	bucket := release.NewBucket(store, release.DefaultBucketPathPrefix, o.ReleaseType)
 	stagedReleases, err := bucket.ListReleases(ctx, o.ReleaseVersion, o.GitRef)
 	if err != nil {
 		return fmt.Errorf("failed listing staged releases: %w", err)
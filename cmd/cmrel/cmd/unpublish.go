@@ -0,0 +1,612 @@
+/*
+Copyright 2021 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/google/go-github/v35/github"
+	"github.com/spf13/cobra"
+	flag "github.com/spf13/pflag"
+
+	"github.com/cert-manager/release/pkg/release"
+	"github.com/cert-manager/release/pkg/release/publish/registry"
+	"github.com/cert-manager/release/pkg/release/sbom"
+)
+
+const (
+	unpublishCommand         = "unpublish"
+	unpublishDescription     = "Remove a published release from the public-facing artifact repositories"
+	unpublishLongDescription = `The unpublish command is the inverse of 'gcb publish'. Given the name of a
+staged release, it deletes the arch-specific image tags, the multi-arch image
+indexes and their signatures, attestations and SBOMs from the image registry
+and any mirrors given with --image-mirrors, deletes the draft GitHub release
+and closes the Helm chart PR and deletes its branch.
+Floating image tags which were moved to the release are moved back to the
+previous release.
+
+A summary of everything which would be removed is always printed first, and
+nothing is removed unless --nomock is set.
+
+If the GitHub release is no longer a draft, the release has been published
+and nothing is removed; it must be unpublished by hand. Image tags which no
+longer point at the content recorded in the publish ledger are left alone.
+
+The GitHub token to use should be set using the GITHUB_TOKEN environment
+variable.`
+)
+
+var unpublishExample = fmt.Sprintf(`To see what would be removed for a release:
+
+	%s %s --release-name v1.15.0-0123456789abcdef0123456789abcdef01234567
+
+To remove it:
+
+	%s %s --release-name v1.15.0-0123456789abcdef0123456789abcdef01234567 --nomock`, rootCommand, unpublishCommand, rootCommand, unpublishCommand)
+
+type unpublishOptions struct {
+	// The name of the GCS bucket the release was staged to.
+	Bucket string
+
+	// Name of the staged release to unpublish
+	ReleaseName string
+
+	// NoMock controls whether anything is actually removed. If false, the
+	// command will exit after printing what would be removed.
+	NoMock bool
+
+	// PublishedImageRepository is the image repository that images were
+	// pushed to.
+	PublishedImageRepository string
+
+	// ImageMirrors is the path to a YAML file listing image repositories which
+	// images and image indexes were also pushed to; see registry.Mirrors
+	ImageMirrors string
+
+	// PublishedHelmChartGitHubOwner is the name of the owner of the GitHub repo
+	// for Helm charts.
+	PublishedHelmChartGitHubOwner string
+
+	// PublishedHelmChartGitHubRepo is the name of the GitHub repository for
+	// Helm charts.
+	PublishedHelmChartGitHubRepo string
+
+	// PublishedHelmChartGitHubBranch is the name of the main branch in the
+	// GitHub repository for Helm Charts.
+	PublishedHelmChartGitHubBranch string
+
+	// PublishedHelmChartOCIRepository is the OCI repository which Helm charts
+	// were pushed to, e.g. oci://quay.io/jetstack/charts
+	PublishedHelmChartOCIRepository string
+
+	// helmChartRepoOptions selects the repository Helm charts are published
+	// to by the helmchartpr action
+	helmChartRepoOptions
+
+	// PublishedGitHubOrg is the org of the repository where the release was
+	// published to.
+	PublishedGitHubOrg string
+
+	// PublishedGitHubRepo is the repo name in the provided org where the
+	// release was published to.
+	PublishedGitHubRepo string
+
+	// UnpublishActions is the list of publishing actions to undo
+	UnpublishActions []string
+}
+
+func (o *unpublishOptions) AddFlags(fs *flag.FlagSet, markRequired func(string)) {
+	fs.StringVar(&o.Bucket, "bucket", release.DefaultBucketName, "The name of the GCS bucket the release was staged to, or a local directory prefixed with 'file://'.")
+	fs.StringVar(&o.ReleaseName, "release-name", "", "Name of the staged release to unpublish.")
+	fs.BoolVar(&o.NoMock, "nomock", false, "Whether to actually remove the release. If false, the command will exit after printing what would be removed.")
+	fs.StringVar(&o.PublishedImageRepository, "published-image-repo", release.DefaultImageRepository, "The docker image repository the release images & image indexes were pushed to.")
+	fs.StringVar(&o.ImageMirrors, "image-mirrors", "", "Optional path to a YAML file listing image repositories which the release images & image indexes were mirrored to, in the same format as for 'gcb publish'.")
+	fs.StringVar(&o.PublishedHelmChartGitHubOwner, "published-helm-chart-github-owner", release.DefaultHelmChartGitHubOwner, "The name of the owner of the GitHub repo for Helm charts.")
+	fs.StringVar(&o.PublishedHelmChartGitHubRepo, "published-helm-chart-github-repo", release.DefaultHelmChartGitHubRepo, "The name of the GitHub repo for Helm charts.")
+	fs.StringVar(&o.PublishedHelmChartGitHubBranch, "published-helm-chart-github-branch", release.DefaultHelmChartGitHubBranch, "The name of the main branch in the GitHub repository for Helm charts.")
+	fs.StringVar(&o.PublishedHelmChartOCIRepository, "published-helm-chart-oci-repo", release.DefaultHelmChartOCIRepository, "The OCI repository Helm charts were pushed to.")
+	o.helmChartRepoOptions.addFlags(fs)
+	fs.StringVar(&o.PublishedGitHubOrg, "published-github-org", release.DefaultGitHubOrg, "The org of the repository where the release was published to.")
+	fs.StringVar(&o.PublishedGitHubRepo, "published-github-repo", release.DefaultGitHubRepo, "The repo name in the provided org where the release was published to.")
+	fs.StringSliceVar(&o.UnpublishActions, "unpublish-actions", []string{"*"}, fmt.Sprintf("Comma-separated list of publish actions to undo, or '*' to undo everything, including opt-in actions. Actions can be removed with a prefix of '-'. %s", publishActionOptions()))
+	markRequired("release-name")
+}
+
+func (o *unpublishOptions) print() {
+	log.Printf("Unpublish options:")
+	log.Printf("  Bucket: %q", o.Bucket)
+	log.Printf("  ReleaseName: %q", o.ReleaseName)
+	log.Printf("  NoMock: %t", o.NoMock)
+	log.Printf("  PublishedImageRepo: %q", o.PublishedImageRepository)
+	log.Printf("  ImageMirrors: %q", o.ImageMirrors)
+	log.Printf("  PublishedHelmChartGitHubRepo: %q", o.PublishedHelmChartGitHubRepo)
+	log.Printf("  PublishedHelmChartGitHubOwner: %q", o.PublishedHelmChartGitHubOwner)
+	log.Printf("  PublishedHelmChartGitHubBranch: %q", o.PublishedHelmChartGitHubBranch)
+	log.Printf("  PublishedHelmChartOCIRepo: %q", o.PublishedHelmChartOCIRepository)
+	o.helmChartRepoOptions.print()
+	log.Printf("  PublishedGitHubOrg: %q", o.PublishedGitHubOrg)
+	log.Printf("  PublishedGitHubRepo: %q", o.PublishedGitHubRepo)
+	log.Printf("  UnpublishActions: %q", strings.Join(o.UnpublishActions, ","))
+}
+
+// publishOptions converts the unpublish options into the equivalent options
+// for 'gcb publish', so that the names of everything which was published can
+// be computed by buildPublishPlan.
+func (o *unpublishOptions) publishOptions() *gcbPublishOptions {
+	p := NewGCBPublishOptions()
+	p.Bucket = o.Bucket
+	p.ReleaseName = o.ReleaseName
+	p.PublishedImageRepository = o.PublishedImageRepository
+	p.ImageMirrors = o.ImageMirrors
+	p.PublishedHelmChartGitHubOwner = o.PublishedHelmChartGitHubOwner
+	p.PublishedHelmChartGitHubRepo = o.PublishedHelmChartGitHubRepo
+	p.PublishedHelmChartGitHubBranch = o.PublishedHelmChartGitHubBranch
+	p.PublishedHelmChartOCIRepository = o.PublishedHelmChartOCIRepository
+	p.helmChartRepoOptions = o.helmChartRepoOptions
+	p.PublishedGitHubOrg = o.PublishedGitHubOrg
+	p.PublishedGitHubRepo = o.PublishedGitHubRepo
+	p.SkipSigning = true
+	p.PublishActions = unpublishActions(o.UnpublishActions)
+	return p
+}
+
+// unpublishActions returns the publish actions to undo, where '*' also
+// includes the opt-in actions, since they might have been taken when the
+// release was published.
+func unpublishActions(rawActions []string) []string {
+	var actions []string
+	for _, action := range rawActions {
+		actions = append(actions, action)
+		if strings.TrimSpace(action) == "*" {
+			actions = append(actions, optInPublishActions.List()...)
+		}
+	}
+	return actions
+}
+
+func unpublishCmd(rootOpts *rootOptions) *cobra.Command {
+	o := &unpublishOptions{}
+	cmd := &cobra.Command{
+		Use:          unpublishCommand,
+		Short:        unpublishDescription,
+		Long:         unpublishLongDescription,
+		Example:      unpublishExample,
+		SilenceUsage: true,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			o.print()
+			log.Printf("---")
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runUnpublish(rootOpts, o)
+		},
+	}
+	o.AddFlags(cmd.Flags(), mustMarkRequired(cmd.MarkFlagRequired))
+	return cmd
+}
+
+func runUnpublish(rootOpts *rootOptions, o *unpublishOptions) error {
+	ctx := context.Background()
+
+	store, err := release.OpenArtifactStore(ctx, o.Bucket)
+	if err != nil {
+		return err
+	}
+
+	staged, err := release.NewBucket(store, release.DefaultBucketPathPrefix, release.BuildTypeRelease).GetRelease(ctx, o.ReleaseName)
+	if err != nil {
+		return fmt.Errorf("failed to fetch release: %w", err)
+	}
+
+	rel, err := release.Unpack(ctx, staged)
+	if err != nil {
+		return fmt.Errorf("failed to unpack staged release: %w", err)
+	}
+
+	ledger, err := staged.Ledger(ctx)
+	if err != nil {
+		return err
+	}
+
+	plan, err := buildPublishPlan(o.publishOptions(), rel)
+	if err != nil {
+		return fmt.Errorf("failed to compute what was published: %w", err)
+	}
+
+	log.Printf("The following will be removed if present:")
+	if err := printUnpublishSummary(os.Stdout, plan); err != nil {
+		return err
+	}
+
+	if !o.NoMock {
+		log.Printf("--nomock flag set to false, exiting without removing anything")
+		return nil
+	}
+
+	log.Printf("!!! Removing release artifacts from public repositories !!!")
+
+	return unpublish(ctx, o, plan, ledger)
+}
+
+// unpublish removes everything in the plan, in the reverse of the order it
+// was published in, and removes the corresponding publish ledger entries so
+// that the release can be published again. Every enabled action is attempted
+// even if an earlier one fails. Nothing is removed if the GitHub release has
+// already been published.
+func unpublish(ctx context.Context, o *unpublishOptions, plan *publishPlan, ledger *release.Ledger) error {
+	var githubClient *github.Client
+	var ghRelease *github.RepositoryRelease
+	if plan.GitHubRelease != nil {
+		var err error
+		githubClient, err = newGitHubClient(ctx)
+		if err != nil {
+			return err
+		}
+
+		ghRelease, err = findGitHubRelease(ctx, o, githubClient, plan.GitHubRelease.Tag, ledger)
+		if err != nil {
+			return fmt.Errorf("failed to look up GitHub release: %w", err)
+		}
+
+		if ghRelease != nil && !ghRelease.GetDraft() {
+			return fmt.Errorf("GitHub release %s is no longer a draft, so the release has been published and nothing has been removed; it must be unpublished by hand", ghRelease.GetHTMLURL())
+		}
+	}
+
+	var errs []error
+
+	if plan.HelmChartOCI != nil {
+		if err := unpublishHelmChartOCI(ctx, registry.NewPublisher(), plan, ledger); err != nil {
+			errs = append(errs, fmt.Errorf("failed to remove Helm charts from OCI registry: %w", err))
+		}
+	}
+
+	// floating tags are moved back before the image indexes they point at are
+	// deleted
+	if len(plan.Images) > 0 || slices.Contains(plan.Actions, "pushfloatingimagetags") {
+		if err := withImageRegistries(o, func(registries []imageRegistry) error {
+			return restoreFloatingImageTags(ctx, registries, plan, ledger)
+		}); err != nil {
+			errs = append(errs, fmt.Errorf("failed to restore floating image tags: %w", err))
+		}
+	}
+
+	if len(plan.Images) > 0 {
+		if err := unpublishContainerImages(ctx, registry.NewPublisher(), plan, ledger); err != nil {
+			errs = append(errs, fmt.Errorf("failed to remove container images: %w", err))
+		}
+	}
+
+	if len(plan.Mirrors) > 0 {
+		if err := unpublishImageMirrors(ctx, o, plan, ledger); err != nil {
+			errs = append(errs, fmt.Errorf("failed to remove mirrored container images: %w", err))
+		}
+	}
+
+	if plan.GitHubRelease != nil {
+		if err := unpublishGitHubRelease(ctx, o, githubClient, ghRelease, plan, ledger); err != nil {
+			errs = append(errs, fmt.Errorf("failed to remove GitHub release: %w", err))
+		}
+	}
+
+	if plan.HelmChartPR != nil {
+		if err := unpublishHelmChartPR(ctx, o, plan, ledger); err != nil {
+			errs = append(errs, fmt.Errorf("failed to remove Helm chart PR: %w", err))
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	log.Printf("+++++++++ Unpublishing release completed successfully! +++++++++")
+	return nil
+}
+
+// unpublishContainerImages deletes image indexes before the images they
+// reference, along with any cosign signatures and attestations and any
+// attached artifacts such as SBOMs for either.
+func unpublishContainerImages(ctx context.Context, publisher *registry.Publisher, plan *publishPlan, ledger *release.Ledger) error {
+	var content []registryContent
+	for _, index := range plan.ImageIndexes {
+		content = append(content, registryContent{ref: index.Name})
+	}
+	for _, image := range plan.Images {
+		content = append(content, registryContent{ref: image.Target})
+	}
+
+	return unpublishRegistryContent(ctx, publisher, content, ledger)
+}
+
+// unpublishImageMirrors deletes the images and image indexes pushed to each
+// mirror, in the same way as unpublishContainerImages, using the credentials
+// for the mirror.
+func unpublishImageMirrors(ctx context.Context, o *unpublishOptions, plan *publishPlan, ledger *release.Ledger) error {
+	return withImageRegistries(o, func(registries []imageRegistry) error {
+		for _, mirror := range plan.Mirrors {
+			i := slices.IndexFunc(registries, func(r imageRegistry) bool { return r.repository == mirror.Repository })
+			if i < 0 {
+				return fmt.Errorf("no credentials for image mirror %q", mirror.Repository)
+			}
+
+			var content []registryContent
+			for _, index := range mirror.ImageIndexes {
+				content = append(content, registryContent{ref: index})
+			}
+			for _, image := range mirror.Images {
+				content = append(content, registryContent{ref: image})
+			}
+
+			if err := unpublishRegistryContent(ctx, registries[i].publisher, content, ledger); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// withImageRegistries calls f with the published image repository and each
+// of its mirrors, using the credentials for each.
+func withImageRegistries(o *unpublishOptions, f func(registries []imageRegistry) error) error {
+	dockerConfigDir, err := os.MkdirTemp("", "cmrel-image-mirrors-")
+	if err != nil {
+		return fmt.Errorf("failed to create directory for image mirror credentials: %w", err)
+	}
+	defer os.RemoveAll(dockerConfigDir)
+
+	registries, err := o.publishOptions().imageRegistries(dockerConfigDir)
+	if err != nil {
+		return err
+	}
+
+	return f(registries)
+}
+
+// unpublishHelmChartOCI deletes Helm charts pushed to an OCI registry, along
+// with their cosign signatures.
+func unpublishHelmChartOCI(ctx context.Context, publisher *registry.Publisher, plan *publishPlan, ledger *release.Ledger) error {
+	var content []registryContent
+	for _, chart := range plan.HelmChartOCI.Charts {
+		content = append(content, registryContent{ref: chart.Reference, digest: chart.Digest})
+	}
+
+	return unpublishRegistryContent(ctx, publisher, content, ledger)
+}
+
+// unpublishedContentKinds are the kinds of ledger entry which record content
+// pushed to a registry which unpublishRegistryContent can delete.
+var unpublishedContentKinds = []release.LedgerEntryKind{release.LedgerEntryImageIndex, release.LedgerEntryImage, release.LedgerEntryHelmChartOCI}
+
+// unpublishRegistryContent deletes each of the given tags from the registry,
+// along with any cosign signatures and attestations and any attached
+// artifacts, and removes them from the ledger. A tag is only deleted if it
+// still points at the content which was published, which is the digest of the
+// content if given or the digest recorded in the ledger otherwise; any other
+// tag is skipped with a warning and left in the ledger.
+func unpublishRegistryContent(ctx context.Context, publisher *registry.Publisher, content []registryContent, ledger *release.Ledger) error {
+	for _, c := range content {
+		expectedDigest := c.digest
+		if expectedDigest == "" {
+			for _, kind := range unpublishedContentKinds {
+				if entry, ok := ledger.Get(kind, c.ref); ok {
+					expectedDigest = entry.Digest
+					break
+				}
+			}
+		}
+
+		digest, err := publisher.RemoteDigest(ctx, c.ref)
+		if err != nil {
+			return err
+		}
+
+		if digest != "" && expectedDigest == "" {
+			log.Printf("WARNING: %q (%s) isn't recorded in the publish ledger as published by this release, skipping", c.ref, digest)
+			continue
+		}
+
+		if digest != "" && digest != expectedDigest {
+			log.Printf("WARNING: %q is %s in the registry but %s was published, skipping", c.ref, digest, expectedDigest)
+			continue
+		}
+
+		if digest == "" {
+			log.Printf("%q not found in registry, skipping", c.ref)
+		} else {
+			sigTag, err := registry.SignatureTag(c.ref, digest)
+			if err != nil {
+				return err
+			}
+
+			attTag, err := registry.AttestationTag(c.ref, digest)
+			if err != nil {
+				return err
+			}
+
+			for _, tag := range []string{sigTag, attTag} {
+				tagDigest, err := publisher.RemoteDigest(ctx, tag)
+				if err != nil {
+					return err
+				}
+				if tagDigest != "" {
+					log.Printf("Deleting %q", tag)
+					if err := publisher.Delete(ctx, tag); err != nil {
+						return err
+					}
+				}
+			}
+
+			if err := publisher.DeleteReferrers(ctx, c.ref, digest); err != nil {
+				return err
+			}
+
+			log.Printf("Deleting %q (%s)", c.ref, digest)
+			if err := publisher.Delete(ctx, c.ref); err != nil {
+				return err
+			}
+		}
+
+		for _, kind := range append([]release.LedgerEntryKind{release.LedgerEntrySignature, release.LedgerEntryAttestation}, unpublishedContentKinds...) {
+			if err := ledger.Remove(ctx, kind, c.ref); err != nil {
+				return err
+			}
+		}
+
+		for _, format := range sbom.Formats {
+			if err := ledger.Remove(ctx, release.LedgerEntrySBOM, sbomLedgerName(c.ref, format)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// unpublishGitHubRelease deletes the given draft GitHub release, which is nil
+// if it wasn't found.
+func unpublishGitHubRelease(ctx context.Context, o *unpublishOptions, githubClient *github.Client, ghRelease *github.RepositoryRelease, plan *publishPlan, ledger *release.Ledger) error {
+	if ghRelease == nil {
+		log.Printf("GitHub release %q not found, skipping", plan.GitHubRelease.Tag)
+	} else {
+		if !ghRelease.GetDraft() {
+			return fmt.Errorf("GitHub release %s is no longer a draft and must be deleted by hand", ghRelease.GetHTMLURL())
+		}
+
+		log.Printf("Deleting draft GitHub release %s", ghRelease.GetHTMLURL())
+		if _, err := githubClient.Repositories.DeleteRelease(ctx, o.PublishedGitHubOrg, o.PublishedGitHubRepo, ghRelease.GetID()); err != nil {
+			return err
+		}
+	}
+
+	for _, e := range ledger.Entries() {
+		if e.Kind == release.LedgerEntryGitHubAsset {
+			if err := ledger.Remove(ctx, e.Kind, e.Name); err != nil {
+				return err
+			}
+		}
+	}
+
+	return ledger.Remove(ctx, release.LedgerEntryGitHubRelease, plan.GitHubRelease.Tag)
+}
+
+// findGitHubRelease returns the GitHub release with the given tag, or nil if
+// there isn't one. Draft releases can't be looked up by tag, so the ID in the
+// publish ledger is used if there is one, and all releases are searched
+// otherwise.
+func findGitHubRelease(ctx context.Context, o *unpublishOptions, githubClient *github.Client, tag string, ledger *release.Ledger) (*github.RepositoryRelease, error) {
+	if entry, ok := ledger.Get(release.LedgerEntryGitHubRelease, tag); ok {
+		ghRelease, resp, err := githubClient.Repositories.GetRelease(ctx, o.PublishedGitHubOrg, o.PublishedGitHubRepo, entry.ID)
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			return nil, nil
+		}
+		return ghRelease, err
+	}
+
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		releases, resp, err := githubClient.Repositories.ListReleases(ctx, o.PublishedGitHubOrg, o.PublishedGitHubRepo, opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range releases {
+			if r.GetTagName() == tag {
+				return r, nil
+			}
+		}
+		if resp.NextPage == 0 {
+			return nil, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+func unpublishHelmChartPR(ctx context.Context, o *unpublishOptions, plan *publishPlan, ledger *release.Ledger) error {
+	helmRepo, err := newHelmChartRepositoryManager(ctx, o.publishOptions())
+	if err != nil {
+		return err
+	}
+
+	closed, err := helmRepo.Unpublish(ctx, plan.HelmChartPR.HeadBranch)
+	if err != nil {
+		return err
+	}
+
+	for _, prURL := range closed {
+		log.Printf("Closed Helm chart PR %s", prURL)
+	}
+
+	return ledger.Remove(ctx, release.LedgerEntryHelmChartPR, plan.HelmChartPR.HeadBranch)
+}
+
+// printUnpublishSummary writes a human readable summary of everything which
+// unpublish would attempt to remove to w.
+func printUnpublishSummary(w io.Writer, plan *publishPlan) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "Release %s (%s)\n", plan.ReleaseVersion, plan.ReleaseName)
+	fmt.Fprintf(tw, "Actions to undo: %v\n", plan.Actions)
+
+	if len(plan.ImageIndexes) > 0 || len(plan.Images) > 0 {
+		fmt.Fprintf(tw, "\nImage tags to be deleted, along with their signatures, attestations and SBOMs:\n")
+		for _, index := range plan.ImageIndexes {
+			fmt.Fprintf(tw, "  %s\n", index.Name)
+		}
+		for _, image := range plan.Images {
+			fmt.Fprintf(tw, "  %s\n", image.Target)
+		}
+	}
+
+	for _, mirror := range plan.Mirrors {
+		fmt.Fprintf(tw, "\nMirrored image tags to be deleted from %s, along with their signatures, attestations and SBOMs:\n", mirror.Repository)
+		for _, index := range mirror.ImageIndexes {
+			fmt.Fprintf(tw, "  %s\n", index)
+		}
+		for _, image := range mirror.Images {
+			fmt.Fprintf(tw, "  %s\n", image)
+		}
+	}
+
+	if len(plan.Images) > 0 || slices.Contains(plan.Actions, "pushfloatingimagetags") {
+		fmt.Fprintf(tw, "\nFloating image tags moved to the release to be moved back to the previous release, or deleted if there isn't one\n")
+	}
+
+	if plan.GitHubRelease != nil {
+		fmt.Fprintf(tw, "\nDraft GitHub release %s to be deleted from %s\n", plan.GitHubRelease.Tag, plan.GitHubRelease.Repository)
+	}
+
+	if plan.HelmChartPR != nil {
+		fmt.Fprintf(tw, "\nHelm chart PRs from branch %q to be closed, and the branch deleted, in %s\n", plan.HelmChartPR.HeadBranch, plan.HelmChartPR.Repository)
+	}
+
+	if plan.HelmChartOCI != nil {
+		fmt.Fprintf(tw, "\nHelm chart tags to be deleted from %s, along with their signatures:\n", plan.HelmChartOCI.Repository)
+		for _, chart := range plan.HelmChartOCI.Charts {
+			fmt.Fprintf(tw, "  %s\n", chart.Reference)
+		}
+	}
+
+	return tw.Flush()
+}
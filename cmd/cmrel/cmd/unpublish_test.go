@@ -0,0 +1,211 @@
+/*
+Copyright 2021 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+
+	"github.com/cert-manager/release/internal/testregistry"
+	"github.com/cert-manager/release/pkg/release"
+	"github.com/cert-manager/release/pkg/release/publish/registry"
+)
+
+func TestUnpublishContainerImages(t *testing.T) {
+	ctx := context.TODO()
+	host := testregistry.New(t)
+	dir := writeTestReleaseDir(t, "v1.15.0", "amd64")
+
+	o := NewGCBPublishOptions()
+	o.PublishedImageRepository = host + "/jetstack"
+	o.SkipSigning = true
+	o.PublishActions = []string{"pushcontainerimages"}
+
+	rel, ledger := loadTestRelease(t, dir)
+	o.ledger = ledger
+	if err := pushContainerImages(ctx, o, rel); err != nil {
+		t.Fatal(err)
+	}
+
+	// stand in for a cosign signature of the image index
+	indexName := buildImageIndexName(o.PublishedImageRepository, "controller", "v1.15.0")
+	publisher := registry.NewPublisher()
+	indexDigest, err := publisher.RemoteDigest(ctx, indexName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sigTag, err := registry.SignatureTag(indexName, indexDigest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := random.Image(16, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sigRef, err := name.ParseReference(sigTag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(sigRef, sig); err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := buildPublishPlan(o, rel)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	summary := &strings.Builder{}
+	if err := printUnpublishSummary(summary, plan); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(summary.String(), indexName) {
+		t.Errorf("expected summary to list %q, got:\n%s", indexName, summary)
+	}
+
+	if err := unpublishContainerImages(ctx, publisher, plan, ledger); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ref := range []string{indexName, sigTag, plan.Images[0].Target} {
+		digest, err := publisher.RemoteDigest(ctx, ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if digest != "" {
+			t.Errorf("expected %q to be deleted", ref)
+		}
+	}
+
+	if len(ledger.Entries()) != 0 {
+		t.Errorf("expected all ledger entries to be removed, got %#v", ledger.Entries())
+	}
+
+	// unpublishing is idempotent
+	if err := unpublishContainerImages(ctx, publisher, plan, ledger); err != nil {
+		t.Errorf("expected unpublishing a second time to succeed, got: %v", err)
+	}
+}
+
+func TestUnpublishRegistryContentSkipsContentWhichWasNotPublished(t *testing.T) {
+	ctx := context.TODO()
+	host := testregistry.New(t)
+	publisher := registry.NewPublisher()
+
+	pushRandomImage := func(ref string) string {
+		img, err := random.Image(16, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parsed, err := name.ParseReference(ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := remote.Write(parsed, img); err != nil {
+			t.Fatal(err)
+		}
+		digest, err := img.Digest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return digest.String()
+	}
+
+	published := host + "/jetstack/cert-manager-controller-amd64:v1.15.0"
+	replaced := host + "/jetstack/cert-manager-webhook-amd64:v1.15.0"
+	unrecorded := host + "/jetstack/cert-manager-cainjector-amd64:v1.15.0"
+	chart := host + "/charts/cert-manager:v1.15.0"
+
+	ledger := newTestLedger(t)
+	for _, ref := range []string{published, replaced} {
+		if err := ledger.Record(ctx, release.LedgerEntry{Kind: release.LedgerEntryImage, Name: ref, Digest: pushRandomImage(ref)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// replaced is pushed again after being published, and unrecorded and
+	// chart were never published by this release
+	pushRandomImage(replaced)
+	pushRandomImage(unrecorded)
+	pushRandomImage(chart)
+
+	content := []registryContent{
+		{ref: published},
+		{ref: replaced},
+		{ref: unrecorded},
+		{ref: chart, digest: "sha256:0000000000000000000000000000000000000000000000000000000000000000"},
+	}
+	if err := unpublishRegistryContent(ctx, publisher, content, ledger); err != nil {
+		t.Fatal(err)
+	}
+
+	for ref, expectDeleted := range map[string]bool{published: true, replaced: false, unrecorded: false, chart: false} {
+		digest, err := publisher.RemoteDigest(ctx, ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if deleted := digest == ""; deleted != expectDeleted {
+			t.Errorf("expected %q deleted: %t, got: %t", ref, expectDeleted, deleted)
+		}
+	}
+
+	if _, ok := ledger.Get(release.LedgerEntryImage, published); ok {
+		t.Errorf("expected %q to be removed from the ledger", published)
+	}
+	if _, ok := ledger.Get(release.LedgerEntryImage, replaced); !ok {
+		t.Errorf("expected %q to be left in the ledger", replaced)
+	}
+}
+
+func TestUnpublishActionsIncludeOptInActions(t *testing.T) {
+	tests := map[string]struct {
+		unpublishActions []string
+		expectedActions  []string
+	}{
+		"'*' undoes opt-in actions": {
+			unpublishActions: []string{"*"},
+			expectedActions:  sortedSlice(allPublishActionNames()),
+		},
+		"opt-in actions can be removed from '*'": {
+			unpublishActions: []string{"*", "-helmchartoci"},
+			expectedActions:  sortedSlice([]string{"githubrelease", "helmchartpr", "pushcontainerimages", "pushfloatingimagetags"}),
+		},
+		"explicitly listed actions": {
+			unpublishActions: []string{"pushcontainerimages"},
+			expectedActions:  []string{"pushcontainerimages"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			actions, err := canonicalizeAndVerifyPublishActions(unpublishActions(test.unpublishActions))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !slices.Equal(actions, test.expectedActions) {
+				t.Errorf("expected actions %v, got %v", test.expectedActions, actions)
+			}
+		})
+	}
+}
@@ -0,0 +1,600 @@
+/*
+Copyright 2021 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	flag "github.com/spf13/pflag"
+
+	"github.com/cert-manager/release/pkg/release"
+	"github.com/cert-manager/release/pkg/release/images"
+	"github.com/cert-manager/release/pkg/release/publish/registry"
+	"github.com/cert-manager/release/pkg/sign"
+	"github.com/cert-manager/release/pkg/sign/cosign"
+)
+
+const (
+	verifyCommand         = "verify"
+	verifyDescription     = "Verify that a published release matches what was staged and is correctly signed"
+	verifyLongDescription = `The verify command downloads the public artifacts of a release exactly as a
+user would, and checks them against the staged release they were published
+from:
+
+- every arch-specific image and multi-arch image index must have the digest
+  computed from the staged images, and a cosign signature made with the
+  signing key
+- SHA256SUMS must be signed with the PGP key for the signing key, and every
+  GitHub release asset must match both SHA256SUMS and the staged release
+- the Helm chart must match the staged chart, and its provenance file must be
+  signed with the PGP key for the signing key
+
+Every check is run, and all failures are reported together.
+
+The public key used to verify image signatures is fetched from KMS, and the
+PGP public key is derived from it in the same way as 'bootstrap-pgp', unless
+either is given explicitly.`
+)
+
+var verifyExample = fmt.Sprintf(`To verify the published v1.15.0 release:
+
+	%s %s --release-version v1.15.0`, rootCommand, verifyCommand)
+
+type verifyOptions struct {
+	// The name of the GCS bucket the release was staged to.
+	Bucket string
+
+	// ReleaseVersion is the version of the published release to verify
+	ReleaseVersion string
+
+	// ReleaseName is the name of the staged release which was published. It
+	// only needs to be set if more than one release was staged for the version.
+	ReleaseName string
+
+	// PublishedImageRepository is the image repository that images were
+	// pushed to.
+	PublishedImageRepository string
+
+	// PublishedGitHubOrg is the org of the repository where the release was
+	// published to.
+	PublishedGitHubOrg string
+
+	// PublishedGitHubRepo is the repo name in the provided org where the
+	// release was published to.
+	PublishedGitHubRepo string
+
+	// GitHubURL is the base URL which GitHub release assets are downloaded
+	// from.
+	GitHubURL string
+
+	// HelmChartRepoURL is the URL of the Helm chart repository which serves
+	// the published chart.
+	HelmChartRepoURL string
+
+	// SigningKMSKey is the full name of the GCP KMS key which the release was
+	// signed with, or the URI of another key; see sign.SigningKey.
+	SigningKMSKey string
+
+	// PGPProfile is the path to a YAML file of key profiles, which set the PGP
+	// identity of the signing key when deriving its PGP public key; see sign.KeyProfiles
+	PGPProfile string
+
+	// CosignPublicKey, if set, is the path to a PEM encoded public key used to
+	// verify image signatures instead of fetching it from KMS.
+	CosignPublicKey string
+
+	// PGPPublicKey, if set, is the path to an armored PGP public key used to
+	// verify PGP signatures instead of deriving it from the KMS key.
+	PGPPublicKey string
+}
+
+func (o *verifyOptions) AddFlags(fs *flag.FlagSet, markRequired func(string)) {
+	fs.StringVar(&o.Bucket, "bucket", release.DefaultBucketName, "The name of the GCS bucket the release was staged to, or a local directory prefixed with 'file://'.")
+	fs.StringVar(&o.ReleaseVersion, "release-version", "", "Version of the published release to verify.")
+	fs.StringVar(&o.ReleaseName, "release-name", "", "Name of the staged release which was published. Only required if more than one release was staged for the version.")
+	fs.StringVar(&o.PublishedImageRepository, "published-image-repo", release.DefaultImageRepository, "The docker image repository the release images & image indexes were pushed to.")
+	fs.StringVar(&o.PublishedGitHubOrg, "published-github-org", release.DefaultGitHubOrg, "The org of the repository where the release was published to.")
+	fs.StringVar(&o.PublishedGitHubRepo, "published-github-repo", release.DefaultGitHubRepo, "The repo name in the provided org where the release was published to.")
+	fs.StringVar(&o.GitHubURL, "github-url", "https://github.com", "The base URL which GitHub release assets are downloaded from.")
+	fs.StringVar(&o.HelmChartRepoURL, "helm-chart-repo-url", "https://charts.jetstack.io", "The URL of the Helm chart repository serving the published chart.")
+	fs.StringVar(&o.SigningKMSKey, "signing-kms-key", defaultKMSKey, "Key the release was signed with; "+signingKeyFormats+".")
+	fs.StringVar(&o.PGPProfile, "pgp-profile", "", pgpProfileUsage+" Only used if --pgp-public-key is not set.")
+	fs.StringVar(&o.CosignPublicKey, "cosign-public-key", "", "Path to a PEM encoded public key to verify image signatures with, instead of fetching it from KMS.")
+	fs.StringVar(&o.PGPPublicKey, "pgp-public-key", "", "Path to an armored PGP public key to verify PGP signatures with, instead of deriving it from the KMS key.")
+	markRequired("release-version")
+}
+
+func (o *verifyOptions) print() {
+	log.Printf("Verify options:")
+	log.Printf("  Bucket: %q", o.Bucket)
+	log.Printf("  ReleaseVersion: %q", o.ReleaseVersion)
+	log.Printf("  ReleaseName: %q", o.ReleaseName)
+	log.Printf("  PublishedImageRepo: %q", o.PublishedImageRepository)
+	log.Printf("  PublishedGitHubOrg: %q", o.PublishedGitHubOrg)
+	log.Printf("  PublishedGitHubRepo: %q", o.PublishedGitHubRepo)
+	log.Printf("  GitHubURL: %q", o.GitHubURL)
+	log.Printf("  HelmChartRepoURL: %q", o.HelmChartRepoURL)
+	log.Printf("  SigningKMSKey: %q", o.SigningKMSKey)
+	log.Printf("  PGPProfile: %q", o.PGPProfile)
+	log.Printf("  CosignPublicKey: %q", o.CosignPublicKey)
+	log.Printf("  PGPPublicKey: %q", o.PGPPublicKey)
+}
+
+func verifyCmd(rootOpts *rootOptions) *cobra.Command {
+	o := &verifyOptions{}
+	cmd := &cobra.Command{
+		Use:          verifyCommand,
+		Short:        verifyDescription,
+		Long:         verifyLongDescription,
+		Example:      verifyExample,
+		SilenceUsage: true,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			o.print()
+			log.Printf("---")
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runVerify(rootOpts, o)
+		},
+	}
+	o.AddFlags(cmd.Flags(), mustMarkRequired(cmd.MarkFlagRequired))
+	return cmd
+}
+
+func runVerify(rootOpts *rootOptions, o *verifyOptions) error {
+	ctx := context.Background()
+
+	store, err := release.OpenArtifactStore(ctx, o.Bucket)
+	if err != nil {
+		return err
+	}
+
+	staged, err := findPublishedStagedRelease(ctx, o, release.NewBucket(store, release.DefaultBucketPathPrefix, release.BuildTypeRelease))
+	if err != nil {
+		return err
+	}
+
+	rel, err := release.Unpack(ctx, staged)
+	if err != nil {
+		return fmt.Errorf("failed to unpack staged release: %w", err)
+	}
+
+	keys, err := o.verificationKeys(ctx)
+	if err != nil {
+		return err
+	}
+
+	return verifyRelease(ctx, o, registry.NewPublisher(), rel, keys)
+}
+
+// findPublishedStagedRelease returns the staged release which the release
+// being verified was published from.
+func findPublishedStagedRelease(ctx context.Context, o *verifyOptions, bucket *release.Bucket) (*release.Staged, error) {
+	if o.ReleaseName != "" {
+		staged, err := bucket.GetRelease(ctx, o.ReleaseName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch release: %w", err)
+		}
+
+		if staged.Metadata().ReleaseVersion != o.ReleaseVersion {
+			return nil, fmt.Errorf("staged release %q has version %q, not %q", o.ReleaseName, staged.Metadata().ReleaseVersion, o.ReleaseVersion)
+		}
+
+		return staged, nil
+	}
+
+	releases, err := bucket.ListReleases(ctx, o.ReleaseVersion, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list staged releases: %w", err)
+	}
+
+	switch len(releases) {
+	case 0:
+		return nil, fmt.Errorf("no staged release found for version %q", o.ReleaseVersion)
+
+	case 1:
+		return &releases[0], nil
+
+	default:
+		var names []string
+		for _, r := range releases {
+			names = append(names, r.Name())
+		}
+		return nil, fmt.Errorf("found %d staged releases for version %q, set --release-name to one of: %s", len(releases), o.ReleaseVersion, strings.Join(names, ", "))
+	}
+}
+
+// verificationKeys holds the public keys which published artifacts must be
+// signed with.
+type verificationKeys struct {
+	// cosign verifies image and image index signatures
+	cosign crypto.PublicKey
+
+	// pgp verifies SHA256SUMS and the Helm chart provenance
+	pgp sign.PGPArmoredBlock
+}
+
+// verificationKeys loads the public keys given explicitly, or else fetches
+// them using the KMS key. Deriving the PGP key needs permission to sign with
+// the KMS key, since the key's identity is self-signed.
+func (o *verifyOptions) verificationKeys(ctx context.Context) (*verificationKeys, error) {
+	keys := &verificationKeys{}
+
+	if o.CosignPublicKey != "" {
+		data, err := os.ReadFile(o.CosignPublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read cosign public key: %w", err)
+		}
+
+		keys.cosign, err = sign.ParsePublicKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse cosign public key %q: %w", o.CosignPublicKey, err)
+		}
+	}
+
+	if o.PGPPublicKey != "" {
+		data, err := os.ReadFile(o.PGPPublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read PGP public key: %w", err)
+		}
+
+		keys.pgp = sign.PGPArmoredBlock(data)
+	}
+
+	if keys.cosign != nil && keys.pgp != "" {
+		return keys, nil
+	}
+
+	parsedKeys, err := loadSigningKeys(o.SigningKMSKey, o.PGPProfile)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(parsedKeys) != 1 {
+		return nil, fmt.Errorf("expected a single key to verify the release with, but got %d", len(parsedKeys))
+	}
+
+	parsedKey := parsedKeys[0]
+
+	log.Printf("Fetching public key for %s", parsedKey)
+	signer, err := sign.NewSigner(ctx, parsedKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch public key: %w", err)
+	}
+
+	if keys.cosign == nil {
+		keys.cosign = signer.Public()
+	}
+
+	if keys.pgp == "" {
+		log.Printf("Deriving PGP public key for %s", parsedKey)
+		keys.pgp, err = sign.BootstrapPGP(signer)
+		if err != nil {
+			return nil, fmt.Errorf("failed to derive PGP public key: %w", err)
+		}
+	}
+
+	return keys, nil
+}
+
+// verifyRelease checks every published artifact of the release, returning
+// all of the failures found.
+func verifyRelease(ctx context.Context, o *verifyOptions, publisher *registry.Publisher, rel *release.Unpacked, keys *verificationKeys) error {
+	var errs []error
+
+	if err := verifyContainerImages(ctx, o, publisher, rel, keys); err != nil {
+		errs = append(errs, fmt.Errorf("container images failed verification: %w", err))
+	}
+
+	if err := verifyGitHubRelease(ctx, o, rel, keys); err != nil {
+		errs = append(errs, fmt.Errorf("GitHub release failed verification: %w", err))
+	}
+
+	if err := verifyHelmChart(ctx, o, rel, keys); err != nil {
+		errs = append(errs, fmt.Errorf("Helm chart failed verification: %w", err))
+	}
+
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	log.Printf("+++++++++ Release %s verified successfully! +++++++++", rel.ReleaseVersion)
+	return nil
+}
+
+// expectedRegistryContent returns every image and image index which
+// publishing the release pushes, with the digest computed from the staged
+// images.
+func expectedRegistryContent(repo string, rel *release.Unpacked) ([]registryContent, error) {
+	components := make([]string, 0, len(rel.ComponentImageBundles))
+	for name := range rel.ComponentImageBundles {
+		components = append(components, name)
+	}
+	sort.Strings(components)
+
+	var content []registryContent
+	for _, name := range components {
+		// work on copies so that PublishedTag is never set on the release
+		var published []*images.Tar
+		for _, t := range rel.ComponentImageBundles[name] {
+			digest, err := registry.ImageDigest(t)
+			if err != nil {
+				return nil, err
+			}
+
+			imageTag := buildImageTag(repo, name, t.Architecture(), rel.ReleaseVersion)
+			content = append(content, registryContent{ref: imageTag, digest: digest})
+
+			copied := *t
+			copied.PublishedTag = imageTag
+			copied.PublishedDigest = digest
+			published = append(published, &copied)
+		}
+
+		indexName := buildImageIndexName(repo, name, rel.ReleaseVersion)
+		idx, err := registry.CreateImageIndex(indexName, published)
+		if err != nil {
+			return nil, err
+		}
+
+		digest, err := idx.Digest()
+		if err != nil {
+			return nil, fmt.Errorf("failed to compute digest for image index %q: %w", indexName, err)
+		}
+
+		content = append(content, registryContent{ref: indexName, digest: digest.String()})
+	}
+
+	return content, nil
+}
+
+func verifyContainerImages(ctx context.Context, o *verifyOptions, publisher *registry.Publisher, rel *release.Unpacked, keys *verificationKeys) error {
+	content, err := expectedRegistryContent(o.PublishedImageRepository, rel)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, c := range content {
+		if err := verifyRegistryContent(ctx, publisher, c, keys.cosign); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		log.Printf("Verified %q (%s)", c.ref, c.digest)
+	}
+
+	return errors.Join(errs...)
+}
+
+// verifyRegistryContent checks that ref has the expected digest in the
+// registry, and that at least one of the cosign signatures for that digest
+// was made with the given key.
+func verifyRegistryContent(ctx context.Context, publisher *registry.Publisher, c registryContent, pub crypto.PublicKey) error {
+	remoteDigest, err := publisher.RemoteDigest(ctx, c.ref)
+	if err != nil {
+		return err
+	}
+
+	switch remoteDigest {
+	case c.digest:
+
+	case "":
+		return fmt.Errorf("%q not found in registry", c.ref)
+
+	default:
+		return fmt.Errorf("%q has digest %q in the registry but the staged release has digest %q", c.ref, remoteDigest, c.digest)
+	}
+
+	signatures, err := publisher.Signatures(ctx, c.ref, c.digest)
+	if err != nil {
+		return err
+	}
+
+	if len(signatures) == 0 {
+		return fmt.Errorf("%q is not signed", c.ref)
+	}
+
+	var sigErrs []error
+	for _, s := range signatures {
+		err := cosign.VerifySignature(pub, c.digest, s.Payload, s.Signature)
+		if err == nil {
+			return nil
+		}
+		sigErrs = append(sigErrs, err)
+	}
+
+	return fmt.Errorf("no valid signature found for %q: %w", c.ref, errors.Join(sigErrs...))
+}
+
+// githubAssetURL returns the URL which the named asset of the release is
+// downloaded from.
+func githubAssetURL(o *verifyOptions, tag, name string) string {
+	return fmt.Sprintf("%s/%s/%s/releases/download/%s/%s", strings.TrimSuffix(o.GitHubURL, "/"), o.PublishedGitHubOrg, o.PublishedGitHubRepo, tag, name)
+}
+
+// verifyGitHubRelease checks the signature of SHA256SUMS, that every asset
+// listed in it matches, and that every staged asset is listed with the
+// checksum of the staged file.
+func verifyGitHubRelease(ctx context.Context, o *verifyOptions, rel *release.Unpacked, keys *verificationKeys) error {
+	checksums, err := download(ctx, githubAssetURL(o, rel.ReleaseVersion, checksumsFileName))
+	if err != nil {
+		return err
+	}
+
+	signature, err := download(ctx, githubAssetURL(o, rel.ReleaseVersion, checksumsSignatureFileName))
+	if err != nil {
+		return err
+	}
+
+	if err := sign.VerifyDetachedSignature(keys.pgp, checksums, signature); err != nil {
+		return fmt.Errorf("%s failed verification: %w", checksumsSignatureFileName, err)
+	}
+
+	log.Printf("Verified signature of %s", checksumsFileName)
+
+	sums, err := parseChecksums(checksums)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, asset := range githubReleaseAssets(rel) {
+		staged, err := sha256SumFile(asset.Path)
+		if err != nil {
+			return fmt.Errorf("failed to compute sha256sum of staged asset %q: %w", asset.Name, err)
+		}
+
+		if sums[asset.Name] != staged {
+			errs = append(errs, fmt.Errorf("%s lists %q with sha256 %q but the staged release has %q", checksumsFileName, asset.Name, sums[asset.Name], staged))
+		}
+	}
+
+	names := make([]string, 0, len(sums))
+	for name := range sums {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		data, err := download(ctx, githubAssetURL(o, rel.ReleaseVersion, name))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		sum := sha256.Sum256(data)
+		if published := hex.EncodeToString(sum[:]); published != sums[name] {
+			errs = append(errs, fmt.Errorf("GitHub release asset %q has sha256 %q but %s lists %q", name, published, checksumsFileName, sums[name]))
+			continue
+		}
+
+		log.Printf("Verified GitHub release asset %q", name)
+	}
+
+	return errors.Join(errs...)
+}
+
+// parseChecksums parses a file in the format written by buildChecksums,
+// returning a map of file name to sha256 sum.
+func parseChecksums(data []byte) (map[string]string, error) {
+	sums := map[string]string{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		sum, name, ok := strings.Cut(scanner.Text(), "  ")
+		if !ok {
+			return nil, fmt.Errorf("invalid line in %s: %q", checksumsFileName, scanner.Text())
+		}
+		sums[name] = sum
+	}
+
+	return sums, scanner.Err()
+}
+
+// verifyHelmChart checks that each published chart matches the staged chart
+// and that its provenance file was signed with the PGP key.
+func verifyHelmChart(ctx context.Context, o *verifyOptions, rel *release.Unpacked, keys *verificationKeys) error {
+	// the provenance file names the chart it was created for, so the published
+	// chart is verified under its published file name
+	dir, err := os.MkdirTemp("", "cmrel-verify-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	var errs []error
+	for _, chart := range rel.Charts {
+		chartURL := fmt.Sprintf("%s/charts/%s", strings.TrimSuffix(o.HelmChartRepoURL, "/"), chart.PackageFileName())
+
+		published, err := download(ctx, chartURL)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		staged, err := os.ReadFile(chart.Path())
+		if err != nil {
+			return fmt.Errorf("failed to read staged chart: %w", err)
+		}
+
+		if !bytes.Equal(published, staged) {
+			errs = append(errs, fmt.Errorf("Helm chart %q does not match the staged chart", chartURL))
+			continue
+		}
+
+		prov, err := download(ctx, chartURL+".prov")
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		chartPath := filepath.Join(dir, chart.PackageFileName())
+		if err := os.WriteFile(chartPath, published, 0o644); err != nil {
+			return fmt.Errorf("failed to write downloaded chart: %w", err)
+		}
+
+		verification, err := sign.VerifyHelmChart(chartPath, prov, keys.pgp)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("provenance of Helm chart %q failed verification: %w", chartURL, err))
+			continue
+		}
+
+		log.Printf("Verified Helm chart %q, signed by key %s", chartURL, verification.KeyID)
+	}
+
+	return errors.Join(errs...)
+}
+
+// download fetches the content at the given URL.
+func download(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %q: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download %q: %s", url, resp.Status)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %q: %w", url, err)
+	}
+
+	return data, nil
+}
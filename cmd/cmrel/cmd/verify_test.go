@@ -0,0 +1,384 @@
+/*
+Copyright 2021 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	helmloader "helm.sh/helm/v4/pkg/chart/v2/loader"
+	helmsign "helm.sh/helm/v4/pkg/provenance"
+	"sigs.k8s.io/yaml"
+
+	"github.com/cert-manager/release/internal/testregistry"
+	"github.com/cert-manager/release/pkg/release"
+	"github.com/cert-manager/release/pkg/release/publish/registry"
+	"github.com/cert-manager/release/pkg/sign"
+	"github.com/cert-manager/release/pkg/sign/cosign"
+)
+
+// testSigningKeys are the private halves of the keys a test release is
+// published with.
+type testSigningKeys struct {
+	cosign *rsa.PrivateKey
+	pgp    *openpgp.Entity
+}
+
+func newTestSigningKeys(t *testing.T) *testSigningKeys {
+	t.Helper()
+	cosignKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pgpKey, err := openpgp.NewEntity("cert-manager Maintainers", "", "cert-manager-maintainers@googlegroups.com", &packet.Config{DefaultHash: crypto.SHA512, RSABits: 2048})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &testSigningKeys{cosign: cosignKey, pgp: pgpKey}
+}
+
+func (k *testSigningKeys) verificationKeys(t *testing.T) *verificationKeys {
+	t.Helper()
+	out := &bytes.Buffer{}
+	w, err := armor.Encode(out, openpgp.PublicKeyType, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := k.pgp.Serialize(w); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return &verificationKeys{cosign: &k.cosign.PublicKey, pgp: sign.PGPArmoredBlock(out.String())}
+}
+
+// signTestImage stores a cosign signature for the given digest in the
+// registry, in the same format as "cosign sign".
+func signTestImage(t *testing.T, ref, digest string, key *rsa.PrivateKey) {
+	t.Helper()
+	tag, err := name.NewTag(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload, err := json.Marshal(cosign.NewPayload(tag.Context().Name(), digest))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sum := sha512.Sum512(payload)
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA512, sum[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := mutate.Append(mutate.MediaType(empty.Image, types.OCIManifestSchema1), mutate.Addendum{
+		Layer:       static.NewLayer(payload, cosign.SimpleSigningMediaType),
+		Annotations: map[string]string{cosign.SignatureAnnotation: base64.StdEncoding.EncodeToString(signature)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sigTag, err := registry.SignatureTag(ref, digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sigRef, err := name.NewTag(sigTag)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := remote.Write(sigRef, img); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// publishTestRelease pushes and signs the release's images, and writes its
+// GitHub release assets and Helm chart to a directory laid out in the same
+// way as GitHub and the chart repository, which is served over HTTP.
+func publishTestRelease(t *testing.T, o *verifyOptions, rel *release.Unpacked, keys *testSigningKeys) string {
+	t.Helper()
+	ctx := context.TODO()
+
+	publisher := registry.NewPublisher()
+	for name, tars := range rel.ComponentImageBundles {
+		for _, tar := range tars {
+			if err := publisher.PushImage(ctx, tar, buildImageTag(o.PublishedImageRepository, name, tar.Architecture(), rel.ReleaseVersion)); err != nil {
+				t.Fatal(err)
+			}
+			signTestImage(t, tar.PublishedTag, tar.PublishedDigest, keys.cosign)
+		}
+
+		indexName := buildImageIndexName(o.PublishedImageRepository, name, rel.ReleaseVersion)
+		idx, err := registry.CreateImageIndex(indexName, tars)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := publisher.PushImageIndex(ctx, indexName, idx); err != nil {
+			t.Fatal(err)
+		}
+		digest, err := idx.Digest()
+		if err != nil {
+			t.Fatal(err)
+		}
+		signTestImage(t, indexName, digest.String(), keys.cosign)
+	}
+
+	root := t.TempDir()
+
+	assetDir := filepath.Join(root, o.PublishedGitHubOrg, o.PublishedGitHubRepo, "releases", "download", rel.ReleaseVersion)
+	if err := os.MkdirAll(assetDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	assets := githubReleaseAssets(rel)
+	for _, asset := range assets {
+		data, err := os.ReadFile(asset.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(assetDir, asset.Name), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	checksums, err := buildChecksums(assets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(assetDir, checksumsFileName), checksums, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	signature := &bytes.Buffer{}
+	if err := openpgp.ArmoredDetachSign(signature, keys.pgp, bytes.NewReader(checksums), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(assetDir, checksumsSignatureFileName), signature.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	chartDir := filepath.Join(root, "charts")
+	if err := os.MkdirAll(chartDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, chart := range rel.Charts {
+		data, err := os.ReadFile(chart.Path())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		loaded, err := helmloader.LoadFile(chart.Path())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		metadata, err := yaml.Marshal(loaded.Metadata)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		prov, err := (&helmsign.Signatory{Entity: keys.pgp}).ClearSign(data, chart.PackageFileName(), metadata)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(filepath.Join(chartDir, chart.PackageFileName()), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(chartDir, chart.PackageFileName()+".prov"), []byte(prov), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return root
+}
+
+func TestVerifyRelease(t *testing.T) {
+	keys := newTestSigningKeys(t)
+	otherKeys := newTestSigningKeys(t)
+
+	tests := map[string]struct {
+		// tamper modifies the published release, which is served from root
+		tamper func(t *testing.T, o *verifyOptions, rel *release.Unpacked, root string)
+
+		verifyWith *testSigningKeys
+		expectErr  string
+	}{
+		"release published correctly": {
+			verifyWith: keys,
+		},
+		"release signed with another key": {
+			verifyWith: otherKeys,
+			expectErr:  "no valid signature found",
+		},
+		"image not signed": {
+			tamper: func(t *testing.T, o *verifyOptions, rel *release.Unpacked, root string) {
+				tar := rel.ComponentImageBundles["controller"][0]
+				sigTag, err := registry.SignatureTag(tar.PublishedTag, tar.PublishedDigest)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := registry.NewPublisher().Delete(context.TODO(), sigTag); err != nil {
+					t.Fatal(err)
+				}
+			},
+			verifyWith: keys,
+			expectErr:  "is not signed",
+		},
+		"image index replaced": {
+			tamper: func(t *testing.T, o *verifyOptions, rel *release.Unpacked, root string) {
+				indexName := buildImageIndexName(o.PublishedImageRepository, "controller", rel.ReleaseVersion)
+				ref, err := name.NewTag(indexName)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := remote.WriteIndex(ref, empty.Index); err != nil {
+					t.Fatal(err)
+				}
+			},
+			verifyWith: keys,
+			expectErr:  "but the staged release has digest",
+		},
+		"GitHub release asset modified": {
+			tamper: func(t *testing.T, o *verifyOptions, rel *release.Unpacked, root string) {
+				path := filepath.Join(root, o.PublishedGitHubOrg, o.PublishedGitHubRepo, "releases", "download", rel.ReleaseVersion, "cert-manager.yaml")
+				if err := os.WriteFile(path, []byte("kind: Secret\n"), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			},
+			verifyWith: keys,
+			expectErr:  `GitHub release asset "cert-manager.yaml" has sha256`,
+		},
+		"Helm chart provenance missing": {
+			tamper: func(t *testing.T, o *verifyOptions, rel *release.Unpacked, root string) {
+				if err := os.Remove(filepath.Join(root, "charts", rel.Charts[0].PackageFileName()+".prov")); err != nil {
+					t.Fatal(err)
+				}
+			},
+			verifyWith: keys,
+			expectErr:  "404 Not Found",
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			host := testregistry.New(t)
+			rel, _ := loadTestRelease(t, writeTestReleaseDir(t, "v1.15.0", "amd64"))
+
+			o := &verifyOptions{
+				ReleaseVersion:           "v1.15.0",
+				PublishedImageRepository: host + "/jetstack",
+				PublishedGitHubOrg:       "cert-manager",
+				PublishedGitHubRepo:      "cert-manager",
+			}
+
+			root := publishTestRelease(t, o, rel, keys)
+			if test.tamper != nil {
+				test.tamper(t, o, rel, root)
+			}
+
+			server := httptest.NewServer(http.FileServer(http.Dir(root)))
+			t.Cleanup(server.Close)
+			o.GitHubURL = server.URL
+			o.HelmChartRepoURL = server.URL
+
+			err := verifyRelease(context.TODO(), o, registry.NewPublisher(), rel, test.verifyWith.verificationKeys(t))
+			if test.expectErr == "" {
+				if err != nil {
+					t.Errorf("expected release to pass verification, got: %v", err)
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), test.expectErr) {
+				t.Errorf("expected error containing %q, got: %v", test.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestParseChecksums(t *testing.T) {
+	tests := map[string]struct {
+		input     string
+		expected  map[string]string
+		expectErr bool
+	}{
+		"valid file": {
+			input:    "0123  cert-manager.yaml\n4567  cmctl-linux-amd64.tar.gz\n",
+			expected: map[string]string{"cert-manager.yaml": "0123", "cmctl-linux-amd64.tar.gz": "4567"},
+		},
+		"empty file": {
+			input:    "",
+			expected: map[string]string{},
+		},
+		"invalid line": {
+			input:     "0123 cert-manager.yaml\n",
+			expectErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			sums, err := parseChecksums([]byte(test.input))
+			if (err != nil) != test.expectErr {
+				t.Fatalf("expectErr=%v, err=%v", test.expectErr, err)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if len(sums) != len(test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, sums)
+			}
+			for name, sum := range test.expected {
+				if sums[name] != sum {
+					t.Errorf("expected %q to have sum %q, got %q", name, sum, sums[name])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2021 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package release
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"cloud.google.com/go/storage"
+)
+
+const (
+	// LocalArtifactStoreScheme is the prefix used to select a local
+	// filesystem ArtifactStore instead of a GCS bucket, e.g. when passed
+	// as the --bucket flag: "file:///tmp/cert-manager-release".
+	LocalArtifactStoreScheme = "file://"
+)
+
+// ErrArtifactNotFound is returned by an ArtifactStore when the named object
+// does not exist.
+var ErrArtifactNotFound = errors.New("artifact not found")
+
+// ArtifactStore is a flat store of named objects which holds staged releases.
+// Object names are '/' separated paths relative to the root of the store, in
+// the same way as object names in a GCS bucket.
+type ArtifactStore interface {
+	// List returns the names of all objects in the store which begin with
+	// the given prefix.
+	List(ctx context.Context, prefix string) ([]string, error)
+
+	// NewReader opens the named object for reading. The caller must close
+	// the returned reader.
+	NewReader(ctx context.Context, name string) (io.ReadCloser, error)
+
+	// NewWriter opens the named object for writing, replacing any existing
+	// object with the same name. The object is only guaranteed to be
+	// visible once the returned writer has been closed successfully. If
+	// writing fails, the caller must abort the writer instead of closing it
+	// so that the partially written object is discarded.
+	NewWriter(ctx context.Context, name string) (ArtifactWriter, error)
+
+	// Stat returns attributes of the named object, or ErrArtifactNotFound
+	// if it does not exist.
+	Stat(ctx context.Context, name string) (*ArtifactAttrs, error)
+}
+
+// ArtifactWriter writes a single object to an ArtifactStore.
+type ArtifactWriter interface {
+	io.Writer
+
+	// Close finishes writing the object, making it visible to readers.
+	Close() error
+
+	// Abort discards everything written so far, leaving any existing
+	// object with the same name in place. The writer can't be used after
+	// it has been aborted.
+	Abort()
+}
+
+// ArtifactAttrs holds attributes of a single object in an ArtifactStore.
+type ArtifactAttrs struct {
+	// Name is the full name of the object within the store.
+	Name string
+
+	// Size is the size of the object in bytes.
+	Size int64
+}
+
+// OpenArtifactStore returns an ArtifactStore for the given location. If the
+// location begins with LocalArtifactStoreScheme, the remainder is treated as
+// a path to a directory on the local filesystem. Otherwise, the location is
+// treated as the name of a GCS bucket.
+func OpenArtifactStore(ctx context.Context, location string) (ArtifactStore, error) {
+	if dir, ok := strings.CutPrefix(location, LocalArtifactStoreScheme); ok {
+		return NewLocalArtifactStore(dir)
+	}
+
+	gcs, err := storage.NewClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCS client: %w", err)
+	}
+
+	return NewGCSArtifactStore(gcs.Bucket(location)), nil
+}
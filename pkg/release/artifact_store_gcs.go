@@ -0,0 +1,94 @@
+/*
+Copyright 2021 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package release
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
+)
+
+type gcsArtifactStore struct {
+	bucket *storage.BucketHandle
+}
+
+// NewGCSArtifactStore returns an ArtifactStore backed by a GCS bucket.
+func NewGCSArtifactStore(bucket *storage.BucketHandle) ArtifactStore {
+	return &gcsArtifactStore{bucket: bucket}
+}
+
+func (g *gcsArtifactStore) List(ctx context.Context, prefix string) ([]string, error) {
+	var names []string
+	objs := g.bucket.Objects(ctx, &storage.Query{Prefix: prefix})
+	for {
+		objAttr, err := objs.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, objAttr.Name)
+	}
+	return names, nil
+}
+
+func (g *gcsArtifactStore) NewReader(ctx context.Context, name string) (io.ReadCloser, error) {
+	r, err := g.bucket.Object(name).NewReader(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return nil, ErrArtifactNotFound
+	}
+	return r, err
+}
+
+// NewWriter returns a writer whose context is cancelled when it's aborted,
+// which stops GCS from finalizing the partially written object.
+func (g *gcsArtifactStore) NewWriter(ctx context.Context, name string) (ArtifactWriter, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	return &gcsArtifactWriter{Writer: g.bucket.Object(name).NewWriter(ctx), cancel: cancel}, nil
+}
+
+func (g *gcsArtifactStore) Stat(ctx context.Context, name string) (*ArtifactAttrs, error) {
+	attrs, err := g.bucket.Object(name).Attrs(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return nil, ErrArtifactNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &ArtifactAttrs{Name: attrs.Name, Size: attrs.Size}, nil
+}
+
+type gcsArtifactWriter struct {
+	*storage.Writer
+	cancel context.CancelFunc
+}
+
+func (w *gcsArtifactWriter) Close() error {
+	defer w.cancel()
+	return w.Writer.Close()
+}
+
+func (w *gcsArtifactWriter) Abort() {
+	w.cancel()
+	// closing a writer whose context has been cancelled returns the
+	// cancellation error without finalizing the object
+	_ = w.Writer.Close()
+}
@@ -0,0 +1,162 @@
+/*
+Copyright 2021 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package release
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+type localArtifactStore struct {
+	root string
+}
+
+// NewLocalArtifactStore returns an ArtifactStore which stores objects as
+// files beneath the given directory on the local filesystem. The directory is
+// created if it does not already exist.
+func NewLocalArtifactStore(root string) (ArtifactStore, error) {
+	if root == "" {
+		return nil, fmt.Errorf("local artifact store requires a directory")
+	}
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create local artifact store directory %q: %w", root, err)
+	}
+	return &localArtifactStore{root: root}, nil
+}
+
+func (l *localArtifactStore) List(ctx context.Context, prefix string) ([]string, error) {
+	// only walk the deepest directory which could contain matching objects
+	walkRoot := l.root
+	if dir := path.Dir(prefix); strings.Contains(prefix, "/") && dir != "." {
+		walkRoot = filepath.Join(l.root, filepath.FromSlash(dir))
+	}
+
+	var names []string
+	err := filepath.WalkDir(walkRoot, func(p string, d fs.DirEntry, err error) error {
+		if os.IsNotExist(err) && p == walkRoot {
+			return fs.SkipAll
+		}
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(l.root, p)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		// skip partially written objects, see NewWriter
+		if strings.HasPrefix(path.Base(name), ".tmp-") {
+			return nil
+		}
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (l *localArtifactStore) NewReader(ctx context.Context, name string) (io.ReadCloser, error) {
+	p, err := l.pathFor(name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p)
+	if os.IsNotExist(err) {
+		return nil, ErrArtifactNotFound
+	}
+	return f, err
+}
+
+// NewWriter writes to a temporary file alongside the destination and renames
+// it into place on Close, so that readers never observe a partial object.
+// Abort removes the temporary file.
+func (l *localArtifactStore) NewWriter(ctx context.Context, name string) (ArtifactWriter, error) {
+	p, err := l.pathFor(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.CreateTemp(filepath.Dir(p), ".tmp-"+filepath.Base(p)+"-")
+	if err != nil {
+		return nil, err
+	}
+	return &localArtifactWriter{File: f, dest: p}, nil
+}
+
+func (l *localArtifactStore) Stat(ctx context.Context, name string) (*ArtifactAttrs, error) {
+	p, err := l.pathFor(name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(p)
+	if os.IsNotExist(err) {
+		return nil, ErrArtifactNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &ArtifactAttrs{Name: name, Size: info.Size()}, nil
+}
+
+// pathFor converts an object name into a path on disk, refusing any names
+// which would resolve to a location outside of the store's root.
+func (l *localArtifactStore) pathFor(name string) (string, error) {
+	cleaned := path.Clean("/" + name)
+	if cleaned == "/" || cleaned != "/"+name {
+		return "", fmt.Errorf("invalid object name %q", name)
+	}
+	return filepath.Join(l.root, filepath.FromSlash(name)), nil
+}
+
+type localArtifactWriter struct {
+	*os.File
+	dest string
+}
+
+func (w *localArtifactWriter) Close() error {
+	if err := w.File.Close(); err != nil {
+		os.Remove(w.File.Name())
+		return err
+	}
+	if err := os.Rename(w.File.Name(), w.dest); err != nil {
+		os.Remove(w.File.Name())
+		return err
+	}
+	return nil
+}
+
+func (w *localArtifactWriter) Abort() {
+	w.File.Close()
+	os.Remove(w.File.Name())
+}
@@ -0,0 +1,144 @@
+/*
+Copyright 2021 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package release
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeObject(t *testing.T, store ArtifactStore, name, content string) {
+	t.Helper()
+	w, err := store.NewWriter(context.TODO(), name)
+	if err != nil {
+		t.Fatalf("failed to open writer for %q: %v", name, err)
+	}
+	if _, err := io.WriteString(w, content); err != nil {
+		t.Fatalf("failed to write %q: %v", name, err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("failed to close writer for %q: %v", name, err)
+	}
+}
+
+func TestLocalArtifactStore(t *testing.T) {
+	ctx := context.TODO()
+
+	store, err := NewLocalArtifactStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeObject(t, store, "stage/gcb/release/v1.0.0-abc/metadata.json", "{}")
+	writeObject(t, store, "stage/gcb/release/v1.0.0-abc/cert-manager-manifests.tar.gz", "manifests")
+	writeObject(t, store, "stage/gcb/devel/abc/metadata.json", "{}")
+
+	names, err := store.List(ctx, "stage/gcb/release/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"stage/gcb/release/v1.0.0-abc/cert-manager-manifests.tar.gz",
+		"stage/gcb/release/v1.0.0-abc/metadata.json",
+	}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("wanted %v but got %v", expected, names)
+	}
+
+	r, err := store.NewReader(ctx, "stage/gcb/release/v1.0.0-abc/cert-manager-manifests.tar.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	content, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "manifests" {
+		t.Errorf("wanted content %q but got %q", "manifests", content)
+	}
+
+	attrs, err := store.Stat(ctx, "stage/gcb/release/v1.0.0-abc/cert-manager-manifests.tar.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attrs.Size != int64(len("manifests")) {
+		t.Errorf("wanted size %d but got %d", len("manifests"), attrs.Size)
+	}
+
+	if _, err := store.Stat(ctx, "does/not/exist"); !errors.Is(err, ErrArtifactNotFound) {
+		t.Errorf("wanted ErrArtifactNotFound but got %v", err)
+	}
+
+	if _, err := store.NewReader(ctx, "does/not/exist"); !errors.Is(err, ErrArtifactNotFound) {
+		t.Errorf("wanted ErrArtifactNotFound but got %v", err)
+	}
+
+	for _, name := range []string{"../escape", "a/../../escape", "/absolute", ""} {
+		if _, err := store.NewWriter(ctx, name); err == nil {
+			t.Errorf("expected an error when writing to invalid object name %q", name)
+		}
+	}
+}
+
+func TestLocalArtifactStoreAbort(t *testing.T) {
+	ctx := context.TODO()
+
+	dir := t.TempDir()
+	store, err := NewLocalArtifactStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	name := "stage/gcb/release/v1.0.0-abc/metadata.json"
+	writeObject(t, store, name, "{}")
+
+	w, err := store.NewWriter(ctx, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.WriteString(w, "{\"partial"); err != nil {
+		t.Fatal(err)
+	}
+	w.Abort()
+
+	r, err := store.NewReader(ctx, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	content, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "{}" {
+		t.Errorf("expected an aborted write to leave %q in place, got %q", "{}", content)
+	}
+
+	entries, err := os.ReadDir(filepath.Join(dir, "stage", "gcb", "release", "v1.0.0-abc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("expected an aborted write to leave no temporary files, got %d files", len(entries))
+	}
+}
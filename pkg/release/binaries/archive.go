@@ -17,7 +17,13 @@ limitations under the License.
 package binaries
 
 import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
 	"fmt"
+	"io"
+	"os"
+	"path"
 	"path/filepath"
 	"strings"
 )
@@ -80,3 +86,72 @@ func (i *Archive) Extension() string {
 func (i *Archive) ArtifactFilename() string {
 	return fmt.Sprintf("%s-%s-%s%s", i.Name(), i.OS(), i.Architecture(), i.Extension())
 }
+
+// Binary reads the binary stored in the archive.
+func (i *Archive) Binary() ([]byte, error) {
+	if i.ext == ".zip" {
+		return i.binaryFromZip()
+	}
+	return i.binaryFromTarGz()
+}
+
+// isBinary returns true if the given path within the archive is the binary
+func (i *Archive) isBinary(p string) bool {
+	base := path.Base(p)
+	return base == i.name || base == i.name+".exe"
+}
+
+func (i *Archive) binaryFromTarGz() ([]byte, error) {
+	f, err := os.Open(i.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	gzr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %q: %w", i.path, err)
+	}
+	defer gzr.Close()
+
+	tr := tar.NewReader(gzr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %q: %w", i.path, err)
+		}
+
+		if header.Typeflag == tar.TypeReg && i.isBinary(header.Name) {
+			return io.ReadAll(tr)
+		}
+	}
+
+	return nil, fmt.Errorf("could not find %q binary in %q", i.name, i.path)
+}
+
+func (i *Archive) binaryFromZip() ([]byte, error) {
+	zr, err := zip.OpenReader(i.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %q: %w", i.path, err)
+	}
+	defer zr.Close()
+
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() || !i.isBinary(f.Name) {
+			continue
+		}
+
+		r, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %q from %q: %w", f.Name, i.path, err)
+		}
+		defer r.Close()
+
+		return io.ReadAll(r)
+	}
+
+	return nil, fmt.Errorf("could not find %q binary in %q", i.name, i.path)
+}
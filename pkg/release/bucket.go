@@ -21,18 +21,15 @@ import (
 	"fmt"
 	"log"
 	"strings"
-
-	"cloud.google.com/go/storage"
-	"google.golang.org/api/iterator"
 )
 
 type Bucket struct {
-	bucket *storage.BucketHandle
+	store  ArtifactStore
 	prefix string
 }
 
-func NewBucket(bucket *storage.BucketHandle, prefix, releaseType string) *Bucket {
-	return &Bucket{bucket: bucket, prefix: fmt.Sprintf("%s/%s/", prefix, releaseType)}
+func NewBucket(store ArtifactStore, prefix, releaseType string) *Bucket {
+	return &Bucket{store: store, prefix: fmt.Sprintf("%s/%s/", prefix, releaseType)}
 }
 
 // GetRelease will fetch a single release from the bucket with the given name.
@@ -40,26 +37,16 @@ func NewBucket(bucket *storage.BucketHandle, prefix, releaseType string) *Bucket
 // the release is contained within.
 func (b *Bucket) GetRelease(ctx context.Context, name string) (*Staged, error) {
 	queryPath := b.prefix + name + "/"
-	stagedReleases := map[string][]*storage.ObjectHandle{}
-	objs := b.bucket.Objects(ctx, &storage.Query{Prefix: queryPath})
-	for {
-		objAttr, err := objs.Next()
-		if err == iterator.Done {
-			break
-		}
-		if err != nil {
-			return nil, err
-		}
-		obj := b.bucket.Object(objAttr.Name)
-		releaseName := NameForObjectPath(obj.ObjectName(), b.prefix)
-		stagedReleases[releaseName] = append(stagedReleases[releaseName], obj)
+	stagedReleases, err := b.listObjectsByRelease(ctx, queryPath)
+	if err != nil {
+		return nil, err
 	}
 	if len(stagedReleases) > 1 {
 		return nil, fmt.Errorf("internal error getting release: multiple releases found")
 	}
 	// iterate over the map. There is at most one element so return in the loop
 	for name, objs := range stagedReleases {
-		rel, err := NewStagedRelease(ctx, name, b.prefix, objs...)
+		rel, err := NewStagedRelease(ctx, b.store, name, b.prefix, objs...)
 		if err != nil {
 			return nil, fmt.Errorf("failed to load staged release: %w", err)
 		}
@@ -75,23 +62,13 @@ func (b *Bucket) GetRelease(ctx context.Context, name string) (*Staged, error) {
 // releases with the specified version built at the specified commit ref.
 // Specifying 'gitRef' without 'version' is not supported.
 func (b *Bucket) ListReleases(ctx context.Context, version, gitRef string) ([]Staged, error) {
-	stagedReleases := map[string][]*storage.ObjectHandle{}
-	objs := b.bucket.Objects(ctx, &storage.Query{Prefix: b.prefix + pathSuffixForVersion(version, gitRef)})
-	for {
-		objAttr, err := objs.Next()
-		if err == iterator.Done {
-			break
-		}
-		if err != nil {
-			return nil, err
-		}
-		obj := b.bucket.Object(objAttr.Name)
-		releaseName := NameForObjectPath(obj.ObjectName(), b.prefix)
-		stagedReleases[releaseName] = append(stagedReleases[releaseName], obj)
+	stagedReleases, err := b.listObjectsByRelease(ctx, b.prefix+pathSuffixForVersion(version, gitRef))
+	if err != nil {
+		return nil, err
 	}
 	var staged []Staged
 	for name, objs := range stagedReleases {
-		rel, err := NewStagedRelease(ctx, name, b.prefix, objs...)
+		rel, err := NewStagedRelease(ctx, b.store, name, b.prefix, objs...)
 		if err != nil {
 			log.Printf("failed to load staged release: %v", err)
 			continue
@@ -101,6 +78,21 @@ func (b *Bucket) ListReleases(ctx context.Context, version, gitRef string) ([]St
 	return staged, nil
 }
 
+// listObjectsByRelease lists all objects with the given prefix and groups
+// their names by the name of the release they belong to.
+func (b *Bucket) listObjectsByRelease(ctx context.Context, queryPath string) (map[string][]string, error) {
+	names, err := b.store.List(ctx, queryPath)
+	if err != nil {
+		return nil, err
+	}
+	stagedReleases := map[string][]string{}
+	for _, objName := range names {
+		releaseName := NameForObjectPath(objName, b.prefix)
+		stagedReleases[releaseName] = append(stagedReleases[releaseName], objName)
+	}
+	return stagedReleases, nil
+}
+
 // NameForObjectPath will return the name of the release that a given object
 // path is a member of by inspecting the path and trimming the prefix.
 func NameForObjectPath(path, prefix string) string {
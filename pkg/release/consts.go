@@ -66,6 +66,19 @@ const (
 	// repository for Helm charts.
 	DefaultHelmChartGitHubBranch = "main"
 
+	// DefaultHelmChartGitLabURL is the GitLab instance used for Helm charts
+	// when they're published to a GitLab project.
+	DefaultHelmChartGitLabURL = "https://gitlab.com"
+
+	// DefaultHelmChartGitBranch is the name of the main branch in a GitLab
+	// project or plain git repository for Helm charts.
+	DefaultHelmChartGitBranch = "main"
+
+	// DefaultHelmChartOCIRepository is the default OCI repository which Helm
+	// charts are pushed to. Each chart is pushed to a repository named after
+	// the chart within it.
+	DefaultHelmChartOCIRepository = "oci://quay.io/jetstack/charts"
+
 	// BuildTypeRelease denotes that a build is targeting an actual named
 	// release and is not just a development build that has been created using
 	// the release tool.
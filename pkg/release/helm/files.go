@@ -0,0 +1,114 @@
+/*
+Copyright 2021 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helm
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	helmrepo "helm.sh/helm/v4/pkg/repo/v1"
+
+	"github.com/cert-manager/release/pkg/release/manifests"
+)
+
+// repositoryFile is a file to be committed to a Helm chart repository
+type repositoryFile struct {
+	// path is the path of the file relative to the root of the repository
+	path string
+
+	content []byte
+
+	// binary is set if the content isn't text, and so must be encoded for
+	// APIs which take file contents as a string
+	binary bool
+}
+
+// chartFiles returns the given charts, along with their provenance files if
+// such signatures exist, as they're committed to the charts directory.
+func chartFiles(charts ...manifests.Chart) ([]repositoryFile, error) {
+	var files []repositoryFile
+	for _, chart := range charts {
+		chartFileName := chart.PackageFileName()
+
+		chartContent, err := os.ReadFile(chart.Path())
+		if err != nil {
+			return nil, err
+		}
+
+		files = append(files, repositoryFile{path: chartsDir + "/" + chartFileName, content: chartContent, binary: true})
+
+		if provPath := chart.ProvPath(); provPath != nil {
+			// provPath is the path to the prov file in the cert-manager-manifests bundle,
+			// and so we use provPath for reading the content of the file, but we want
+			// to use `chartFileName + ".prov"` for the committed file's name
+			provContent, err := os.ReadFile(*provPath)
+			if err != nil {
+				return nil, err
+			}
+
+			files = append(files, repositoryFile{path: chartsDir + "/" + chartFileName + ".prov", content: provContent})
+		}
+	}
+
+	return files, nil
+}
+
+// indexRepositoryFile adds entries for the given charts to the existing
+// repository index, so that the charts are installable once the change is
+// merged. If previous is set, it's the index committed by a previous run for
+// the same release, and the creation times in it are reused for unchanged
+// charts so that the index is only changed if the charts are.
+func indexRepositoryFile(existing, previous []byte, charts ...manifests.Chart) (repositoryFile, error) {
+	now := time.Now().UTC()
+
+	var versions []*helmrepo.ChartVersion
+	for _, chart := range charts {
+		version, err := newIndexChartVersion(chart, now)
+		if err != nil {
+			return repositoryFile{}, err
+		}
+
+		if created, ok := previousCreationTime(previous, version); ok {
+			version.Created = created
+		}
+
+		versions = append(versions, version)
+	}
+
+	index, err := mergeIndex(existing, versions...)
+	if err != nil {
+		return repositoryFile{}, err
+	}
+
+	return repositoryFile{path: indexFileName, content: index}, nil
+}
+
+// commitMessage returns the message for a commit adding the given chart files
+// and the updated index.
+func commitMessage(files []repositoryFile) string {
+	var fileNames []string
+	for _, file := range files {
+		if file.path != indexFileName {
+			fileNames = append(fileNames, path.Base(file.path))
+		}
+	}
+
+	return fmt.Sprintf(tplCommitMessage, strings.Join(fileNames, ", "), indexFileName)
+}
@@ -0,0 +1,307 @@
+/*
+Copyright 2021 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/storage/memory"
+
+	"github.com/cert-manager/release/pkg/release/manifests"
+)
+
+const (
+	// gitAuthorName and gitAuthorEmail are used for commits pushed to plain
+	// git remotes, where there's no API to fill in the authenticated user
+	gitAuthorName  = "cert-manager Maintainers"
+	gitAuthorEmail = "cert-manager-maintainers@googlegroups.com"
+)
+
+type gitRepositoryManager struct {
+	url    string
+	branch string
+	auth   transport.AuthMethod
+}
+
+// NewGitRepositoryManager returns a gitRepositoryManager which implements
+// RepositoryManager to push Helm charts to a branch in a plain git remote,
+// over SSH or HTTPS. There's no API to create a PR with, so the branch must be
+// merged by hand or by whatever review process the remote has. auth may be
+// nil if the remote doesn't need authentication.
+func NewGitRepositoryManager(url, branch string, auth transport.AuthMethod) RepositoryManager {
+	return &gitRepositoryManager{
+		url:    url,
+		branch: branch,
+		auth:   auth,
+	}
+}
+
+// Check is documented at RepositoryManager.Check
+// Lists the branches in the remote, which checks the credentials can be used
+// to read from it, and checks that the target branch exists. There's no way
+// to check for permission to push without pushing.
+func (o *gitRepositoryManager) Check(ctx context.Context) error {
+	refs, err := o.listBranches(ctx)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := refs[plumbing.NewBranchReferenceName(o.branch)]; !ok {
+		return fmt.Errorf("branch %q not found in %q", o.branch, o.url)
+	}
+
+	return nil
+}
+
+// Publish is documented at RepositoryManager.Publish
+// The charts, their provenance files and the updated index are added in a
+// single commit on top of the target branch and pushed to a branch named
+// after the release. If that branch already exists from a previous run with
+// the same files it's left alone, and otherwise it's force pushed. A
+// description of the pushed branch is returned in place of a PR URL.
+func (o *gitRepositoryManager) Publish(ctx context.Context, releaseName string, charts ...manifests.Chart) (string, error) {
+	log.Printf("Pushing Helm charts of %q to a new branch in %q", releaseName, o.destination())
+
+	branchRef := plumbing.NewBranchReferenceName(releaseName)
+	published := fmt.Sprintf("branch %q in %s", releaseName, o.url)
+
+	remoteRefs, err := o.listBranches(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	repo, err := git.CloneContext(ctx, memory.NewStorage(), memfs.New(), &git.CloneOptions{
+		URL:           o.url,
+		Auth:          o.auth,
+		ReferenceName: plumbing.NewBranchReferenceName(o.branch),
+		SingleBranch:  true,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to clone %q: %w", o.destination(), err)
+	}
+
+	head, err := repo.Head()
+	if err != nil {
+		return "", err
+	}
+
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return "", err
+	}
+
+	existingIndex, err := readWorktreeFile(worktree, indexFileName)
+	if err != nil {
+		return "", err
+	}
+
+	var existing *object.Commit
+	var previousIndex []byte
+	if existingHash, ok := remoteRefs[branchRef]; ok {
+		existing, err = o.fetchCommit(ctx, repo, branchRef, existingHash)
+		if err != nil {
+			return "", err
+		}
+
+		previousIndex, err = readCommitFile(existing, indexFileName)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	files, err := chartFiles(charts...)
+	if err != nil {
+		return "", err
+	}
+
+	index, err := indexRepositoryFile(existingIndex, previousIndex, charts...)
+	if err != nil {
+		return "", err
+	}
+
+	files = append(files, index)
+
+	for _, file := range files {
+		if err := writeWorktreeFile(worktree, file); err != nil {
+			return "", err
+		}
+	}
+
+	signature := &object.Signature{Name: gitAuthorName, Email: gitAuthorEmail, When: time.Now()}
+	commitHash, err := worktree.Commit(commitMessage(files), &git.CommitOptions{Author: signature, Committer: signature})
+	if err != nil {
+		return "", fmt.Errorf("failed to commit Helm charts: %w", err)
+	}
+
+	commit, err := repo.CommitObject(commitHash)
+	if err != nil {
+		return "", err
+	}
+
+	if existing != nil && existing.TreeHash == commit.TreeHash && len(existing.ParentHashes) == 1 && existing.ParentHashes[0] == head.Hash() {
+		log.Printf("Branch %q already exists with the same charts; reusing it", releaseName)
+		return published, nil
+	}
+
+	if err := repo.Storer.SetReference(plumbing.NewHashReference(branchRef, commitHash)); err != nil {
+		return "", err
+	}
+
+	if existing != nil {
+		log.Printf("Branch %q already exists with different files; resetting it to commit %s", releaseName, commitHash)
+	}
+
+	if err := repo.PushContext(ctx, &git.PushOptions{
+		Auth:     o.auth,
+		RefSpecs: []config.RefSpec{config.RefSpec(fmt.Sprintf("+%s:%s", branchRef, branchRef))},
+	}); err != nil {
+		return "", fmt.Errorf("failed to push branch %q to %q: %w", releaseName, o.url, err)
+	}
+
+	log.Printf("Pushed %s", published)
+	return published, nil
+}
+
+// Unpublish is documented at RepositoryManager.Unpublish
+// Deletes the branch created by Publish; there are no PRs to close.
+func (o *gitRepositoryManager) Unpublish(ctx context.Context, releaseName string) ([]string, error) {
+	branchRef := plumbing.NewBranchReferenceName(releaseName)
+
+	remoteRefs, err := o.listBranches(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := remoteRefs[branchRef]; !ok {
+		log.Printf("Branch %q does not exist", releaseName)
+		return nil, nil
+	}
+
+	log.Printf("Deleting branch %q from %s", releaseName, o.url)
+	remote := git.NewRemote(memory.NewStorage(), &config.RemoteConfig{Name: git.DefaultRemoteName, URLs: []string{o.url}})
+	if err := remote.PushContext(ctx, &git.PushOptions{
+		Auth:     o.auth,
+		RefSpecs: []config.RefSpec{config.RefSpec(":" + branchRef)},
+	}); err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return nil, fmt.Errorf("failed to delete branch %q: %w", releaseName, err)
+	}
+
+	return nil, nil
+}
+
+// listBranches returns the commit at the head of each branch in the remote
+func (o *gitRepositoryManager) listBranches(ctx context.Context) (map[plumbing.ReferenceName]plumbing.Hash, error) {
+	remote := git.NewRemote(memory.NewStorage(), &config.RemoteConfig{Name: git.DefaultRemoteName, URLs: []string{o.url}})
+	refs, err := remote.ListContext(ctx, &git.ListOptions{Auth: o.auth})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list branches in %q: %w", o.url, err)
+	}
+
+	branches := map[plumbing.ReferenceName]plumbing.Hash{}
+	for _, ref := range refs {
+		if ref.Name().IsBranch() && ref.Type() == plumbing.HashReference {
+			branches[ref.Name()] = ref.Hash()
+		}
+	}
+
+	return branches, nil
+}
+
+// fetchCommit fetches the given branch into repo and returns the commit at
+// its head
+func (o *gitRepositoryManager) fetchCommit(ctx context.Context, repo *git.Repository, branchRef plumbing.ReferenceName, hash plumbing.Hash) (*object.Commit, error) {
+	remoteRef := plumbing.NewRemoteReferenceName(git.DefaultRemoteName, branchRef.Short())
+	err := repo.FetchContext(ctx, &git.FetchOptions{
+		Auth:     o.auth,
+		RefSpecs: []config.RefSpec{config.RefSpec(fmt.Sprintf("+%s:%s", branchRef, remoteRef))},
+	})
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return nil, fmt.Errorf("failed to fetch branch %q from %q: %w", branchRef.Short(), o.url, err)
+	}
+
+	return repo.CommitObject(hash)
+}
+
+// readWorktreeFile returns the content of the file at path in the worktree,
+// or nil if it doesn't exist
+func readWorktreeFile(worktree *git.Worktree, path string) ([]byte, error) {
+	f, err := worktree.Filesystem.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	return io.ReadAll(f)
+}
+
+// readCommitFile returns the content of the file at path in the commit, or
+// nil if it doesn't exist
+func readCommitFile(commit *object.Commit, path string) ([]byte, error) {
+	f, err := commit.File(path)
+	if err != nil {
+		if errors.Is(err, object.ErrFileNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	content, err := f.Contents()
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(content), nil
+}
+
+// writeWorktreeFile writes the file to the worktree and stages it
+func writeWorktreeFile(worktree *git.Worktree, file repositoryFile) error {
+	f, err := worktree.Filesystem.Create(file.path)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(file.content); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	_, err = worktree.Add(file.path)
+	return err
+}
+
+func (o *gitRepositoryManager) destination() string {
+	return fmt.Sprintf("%s@%s", o.url, o.branch)
+}
@@ -0,0 +1,147 @@
+/*
+Copyright 2021 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helm
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestGitRemote creates a bare repository with a single commit on the given
+// branch, containing the given files, and returns its path
+func newTestGitRemote(t *testing.T, branch string, files map[string]string) string {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), "charts.git")
+	_, err := git.PlainInit(dir, true)
+	require.NoError(t, err)
+
+	repo, err := git.Init(memory.NewStorage(), memfs.New())
+	require.NoError(t, err)
+
+	worktree, err := repo.Worktree()
+	require.NoError(t, err)
+
+	for path, content := range files {
+		require.NoError(t, writeWorktreeFile(worktree, repositoryFile{path: path, content: []byte(content)}))
+	}
+
+	signature := &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()}
+	hash, err := worktree.Commit("Initial commit", &git.CommitOptions{Author: signature})
+	require.NoError(t, err)
+
+	branchRef := plumbing.NewBranchReferenceName(branch)
+	require.NoError(t, repo.Storer.SetReference(plumbing.NewHashReference(branchRef, hash)))
+
+	_, err = repo.CreateRemote(&config.RemoteConfig{Name: git.DefaultRemoteName, URLs: []string{dir}})
+	require.NoError(t, err)
+	require.NoError(t, repo.Push(&git.PushOptions{RefSpecs: []config.RefSpec{config.RefSpec(branchRef + ":" + branchRef)}}))
+
+	return dir
+}
+
+// branchCommit returns the commit at the head of the branch in the bare
+// repository at dir, or nil if the branch doesn't exist
+func branchCommit(t *testing.T, dir, branch string) *object.Commit {
+	t.Helper()
+	repo, err := git.PlainOpen(dir)
+	require.NoError(t, err)
+
+	ref, err := repo.Reference(plumbing.NewBranchReferenceName(branch), true)
+	if err == plumbing.ErrReferenceNotFound {
+		return nil
+	}
+	require.NoError(t, err)
+
+	commit, err := repo.CommitObject(ref.Hash())
+	require.NoError(t, err)
+	return commit
+}
+
+func TestGitRepositoryManager(t *testing.T) {
+	ctx := context.Background()
+
+	const (
+		branch      = "main"
+		releaseName = "v0.1.0-test.1-0123456789abcdef"
+	)
+
+	dir := newTestGitRemote(t, branch, map[string]string{"README.md": "charts"})
+	r := NewGitRepositoryManager(dir, branch, nil)
+	require.NoError(t, r.Check(ctx))
+
+	require.ErrorContains(t, NewGitRepositoryManager(dir, "missing", nil).Check(ctx), `branch "missing" not found`)
+
+	chart := copyTestChart(t, "-----BEGIN PGP SIGNED MESSAGE-----\n")
+	chartData, err := os.ReadFile(chart.Path())
+	require.NoError(t, err)
+
+	published, err := r.Publish(ctx, releaseName, *chart)
+	require.NoError(t, err)
+	require.Contains(t, published, releaseName)
+
+	base := branchCommit(t, dir, branch)
+	head := branchCommit(t, dir, releaseName)
+	require.NotNil(t, head)
+	require.Equal(t, []plumbing.Hash{base.Hash}, head.ParentHashes)
+
+	content, err := readCommitFile(head, "charts/cert-manager-v0.1.0-test.1.tgz")
+	require.NoError(t, err)
+	require.Equal(t, chartData, content)
+
+	index, err := readCommitFile(head, "index.yaml")
+	require.NoError(t, err)
+	require.Contains(t, string(index), "charts/cert-manager-v0.1.0-test.1.tgz")
+
+	// publishing again leaves the branch alone
+	_, err = r.Publish(ctx, releaseName, *chart)
+	require.NoError(t, err)
+	require.Equal(t, head.Hash, branchCommit(t, dir, releaseName).Hash)
+
+	// a branch with different files is reset to a single commit on top of the
+	// target branch, with the same index
+	other := copyTestChart(t, "-----BEGIN PGP SIGNED MESSAGE-----\nmodified\n")
+	_, err = r.Publish(ctx, releaseName, *other)
+	require.NoError(t, err)
+	modified := branchCommit(t, dir, releaseName)
+	require.NotEqual(t, head.Hash, modified.Hash)
+	require.Equal(t, []plumbing.Hash{base.Hash}, modified.ParentHashes)
+
+	_, err = r.Publish(ctx, releaseName, *chart)
+	require.NoError(t, err)
+	reset := branchCommit(t, dir, releaseName)
+	require.Equal(t, head.TreeHash, reset.TreeHash)
+
+	closed, err := r.Unpublish(ctx, releaseName)
+	require.NoError(t, err)
+	require.Empty(t, closed)
+	require.Nil(t, branchCommit(t, dir, releaseName))
+
+	// unpublishing again is not an error
+	_, err = r.Unpublish(ctx, releaseName)
+	require.NoError(t, err)
+}
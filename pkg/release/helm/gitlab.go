@@ -0,0 +1,272 @@
+/*
+Copyright 2021 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helm
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/cert-manager/release/pkg/release/manifests"
+)
+
+// Some template strings for GitLab merge requests.
+const (
+	tplMRTitle       = tplPRTitle
+	tplMRDescription = "This MR was generated by [cmrel](https://github.com/cert-manager/release) for the cert-manager staged release: `%s`."
+)
+
+type gitLabRepositoryManager struct {
+	client  GitLabClient
+	project string
+	branch  string
+}
+
+// NewGitLabRepositoryManager returns a gitLabRepositoryManager which
+// implements RepositoryManager to upload Helm charts to a branch in a GitLab
+// project and create a merge request.
+func NewGitLabRepositoryManager(client GitLabClient, project, branch string) RepositoryManager {
+	return &gitLabRepositoryManager{
+		client:  client,
+		project: project,
+		branch:  branch,
+	}
+}
+
+// Check is documented at RepositoryManager.Check
+// Checks that the authenticated user has at least developer access to the
+// project, which is needed to push branches and create merge requests, and
+// that the target branch exists.
+func (o *gitLabRepositoryManager) Check(ctx context.Context) error {
+	level, err := o.client.ProjectAccessLevel(ctx, o.project)
+	if err != nil {
+		if isGitLabStatus(err, http.StatusNotFound) {
+			return fmt.Errorf("project %q was not found or is not accessible with the configured token: %w", o.project, err)
+		}
+		return err
+	}
+
+	if level < GitLabDeveloperAccess {
+		return fmt.Errorf("expected at least developer access (%d) to project %q, got: %d", GitLabDeveloperAccess, o.project, level)
+	}
+
+	if _, err := o.client.GetBranch(ctx, o.project, o.branch); err != nil {
+		if isGitLabStatus(err, http.StatusNotFound) {
+			return fmt.Errorf("branch %q not found: %w", o.branch, err)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// Publish is documented at RepositoryManager.Publish
+// As with GitHub, the charts, their provenance files and the updated index are
+// added in a single commit on a branch named after the release, which is
+// reused if it already holds the same files and reset otherwise, and an open
+// merge request for the branch is reused.
+func (o *gitLabRepositoryManager) Publish(ctx context.Context, releaseName string, charts ...manifests.Chart) (string, error) {
+	log.Printf("Creating MR for merging Helm charts of %q into %q", releaseName, o.destination())
+
+	branchName := releaseName
+
+	base, err := o.client.GetBranch(ctx, o.project, o.branch)
+	if err != nil {
+		return "", fmt.Errorf("failed to get branch %q: %w", o.branch, err)
+	}
+
+	existing, err := o.client.GetBranch(ctx, o.project, branchName)
+	if err != nil {
+		if !isGitLabStatus(err, http.StatusNotFound) {
+			return "", fmt.Errorf("failed to get branch %q: %w", branchName, err)
+		}
+		existing = nil
+	}
+
+	files, err := chartFiles(charts...)
+	if err != nil {
+		return "", err
+	}
+
+	existingIndex, err := o.getFile(ctx, indexFileName, o.branch)
+	if err != nil {
+		return "", err
+	}
+
+	var previousIndex []byte
+	if existing != nil {
+		previousIndex, err = o.getFile(ctx, indexFileName, branchName)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	index, err := indexRepositoryFile(existingIndex, previousIndex, charts...)
+	if err != nil {
+		return "", err
+	}
+
+	files = append(files, index)
+
+	upToDate := false
+	if existing != nil {
+		upToDate, err = o.branchHasFiles(ctx, existing, base, files)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if upToDate {
+		log.Printf("Branch %q already exists with the same charts; reusing it", branchName)
+	} else {
+		commit := &GitLabNewCommit{
+			Branch:        branchName,
+			StartBranch:   o.branch,
+			CommitMessage: commitMessage(files),
+			// reset the branch if it exists from a previous run, so that it
+			// always holds a single commit on top of the target branch
+			Force: true,
+		}
+
+		for _, file := range files {
+			baseContent, err := o.getFile(ctx, file.path, o.branch)
+			if err != nil {
+				return "", err
+			}
+
+			action := "create"
+			if baseContent != nil {
+				action = "update"
+			}
+
+			commit.Actions = append(commit.Actions, &GitLabCommitAction{
+				Action:   action,
+				FilePath: file.path,
+				Content:  base64.StdEncoding.EncodeToString(file.content),
+				Encoding: "base64",
+			})
+		}
+
+		created, err := o.client.CreateCommit(ctx, o.project, commit)
+		if err != nil {
+			return "", fmt.Errorf("failed to commit Helm charts to branch %q: %w", branchName, err)
+		}
+		log.Printf("Committed Helm charts to branch %q at commit %s", branchName, created.ID)
+	}
+
+	mrs, err := o.client.ListMergeRequests(ctx, o.project, &GitLabMergeRequestListOptions{
+		SourceBranch: branchName,
+		TargetBranch: o.branch,
+		State:        "opened",
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to list MRs for branch %q: %w", branchName, err)
+	}
+
+	if len(mrs) > 0 {
+		log.Printf("Reusing existing MR: %s", mrs[0].WebURL)
+		return mrs[0].WebURL, nil
+	}
+
+	mr, err := o.client.CreateMergeRequest(ctx, o.project, &GitLabNewMergeRequest{
+		SourceBranch: branchName,
+		TargetBranch: o.branch,
+		Title:        fmt.Sprintf(tplMRTitle, releaseName),
+		Description:  fmt.Sprintf(tplMRDescription, releaseName),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	log.Printf("Created MR: %s", mr.WebURL)
+	return mr.WebURL, nil
+}
+
+// Unpublish is documented at RepositoryManager.Unpublish
+func (o *gitLabRepositoryManager) Unpublish(ctx context.Context, releaseName string) ([]string, error) {
+	branchName := releaseName
+
+	mrs, err := o.client.ListMergeRequests(ctx, o.project, &GitLabMergeRequestListOptions{
+		SourceBranch: branchName,
+		TargetBranch: o.branch,
+		State:        "opened",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list MRs for branch %q: %w", branchName, err)
+	}
+
+	var closed []string
+	for _, mr := range mrs {
+		log.Printf("Closing MR %s", mr.WebURL)
+		if _, err := o.client.CloseMergeRequest(ctx, o.project, mr.IID); err != nil {
+			return closed, fmt.Errorf("failed to close MR %s: %w", mr.WebURL, err)
+		}
+		closed = append(closed, mr.WebURL)
+	}
+
+	log.Printf("Deleting branch %q from %s", branchName, o.project)
+	if err := o.client.DeleteBranch(ctx, o.project, branchName); err != nil {
+		if !isGitLabStatus(err, http.StatusNotFound) {
+			return closed, fmt.Errorf("failed to delete branch %q: %w", branchName, err)
+		}
+		log.Printf("Branch %q does not exist", branchName)
+	}
+
+	return closed, nil
+}
+
+// getFile returns the content of the file at path in the given ref, or nil if
+// it doesn't exist
+func (o *gitLabRepositoryManager) getFile(ctx context.Context, path, ref string) ([]byte, error) {
+	content, err := o.client.GetRawFile(ctx, o.project, path, ref)
+	if err != nil {
+		if isGitLabStatus(err, http.StatusNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to fetch %q from %q: %w", path, ref, err)
+	}
+
+	return content, nil
+}
+
+// branchHasFiles returns true if the branch holds a single commit on top of
+// base, which contains exactly the given files
+func (o *gitLabRepositoryManager) branchHasFiles(ctx context.Context, branch, base *GitLabBranch, files []repositoryFile) (bool, error) {
+	if len(branch.Commit.ParentIDs) != 1 || branch.Commit.ParentIDs[0] != base.Commit.ID {
+		return false, nil
+	}
+
+	for _, file := range files {
+		content, err := o.getFile(ctx, file.path, branch.Name)
+		if err != nil {
+			return false, err
+		}
+
+		if content == nil || !bytes.Equal(content, file.content) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+func (o *gitLabRepositoryManager) destination() string {
+	return fmt.Sprintf("%s@%s", o.project, o.branch)
+}
@@ -0,0 +1,354 @@
+/*
+Copyright 2021 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// GitLab access levels; see https://docs.gitlab.com/ee/api/members.html#roles
+const (
+	GitLabDeveloperAccess  = 30
+	GitLabMaintainerAccess = 40
+)
+
+const (
+	// gitLabTimeout limits how long a single GitLab API request can take,
+	// including uploading the files of a commit
+	gitLabTimeout = time.Minute
+
+	// gitLabPerPage is the number of items requested in each page of a list
+	gitLabPerPage = 100
+)
+
+// GitLabClient provides the minimum necessary GitLab API client methods
+// required by the gitLabRepositoryManager. Projects are identified by their
+// full path, such as "jetstack/jetstack-charts".
+type GitLabClient interface {
+	// ProjectAccessLevel returns the access level of the authenticated user in
+	// the project, taking into account access granted through its group.
+	// GitLab API docs: https://docs.gitlab.com/ee/api/projects.html#get-single-project
+	ProjectAccessLevel(ctx context.Context, project string) (int, error)
+	// GetBranch returns the given branch, or a *GitLabErrorResponse with a
+	// 404 status code if it doesn't exist.
+	// GitLab API docs: https://docs.gitlab.com/ee/api/branches.html#get-single-repository-branch
+	GetBranch(ctx context.Context, project, branch string) (*GitLabBranch, error)
+	// GetRawFile returns the content of the file at path in the given ref, or
+	// a *GitLabErrorResponse with a 404 status code if it doesn't exist.
+	// GitLab API docs: https://docs.gitlab.com/ee/api/repository_files.html#get-raw-file-from-repository
+	GetRawFile(ctx context.Context, project, path, ref string) ([]byte, error)
+	// CreateCommit creates a commit with multiple file changes.
+	// GitLab API docs: https://docs.gitlab.com/ee/api/commits.html#create-a-commit-with-multiple-files-and-actions
+	CreateCommit(ctx context.Context, project string, commit *GitLabNewCommit) (*GitLabCommit, error)
+	// ListMergeRequests lists the merge requests in the project.
+	// GitLab API docs: https://docs.gitlab.com/ee/api/merge_requests.html#list-project-merge-requests
+	ListMergeRequests(ctx context.Context, project string, opts *GitLabMergeRequestListOptions) ([]*GitLabMergeRequest, error)
+	// CreateMergeRequest creates a merge request.
+	// GitLab API docs: https://docs.gitlab.com/ee/api/merge_requests.html#create-mr
+	CreateMergeRequest(ctx context.Context, project string, mr *GitLabNewMergeRequest) (*GitLabMergeRequest, error)
+	// CloseMergeRequest closes the merge request with the given project-level ID.
+	// GitLab API docs: https://docs.gitlab.com/ee/api/merge_requests.html#update-mr
+	CloseMergeRequest(ctx context.Context, project string, iid int) (*GitLabMergeRequest, error)
+	// DeleteBranch deletes the given branch.
+	// GitLab API docs: https://docs.gitlab.com/ee/api/branches.html#delete-repository-branch
+	DeleteBranch(ctx context.Context, project, branch string) error
+}
+
+// GitLabBranch is a branch in a GitLab project
+type GitLabBranch struct {
+	Name   string       `json:"name"`
+	Commit GitLabCommit `json:"commit"`
+}
+
+// GitLabCommit is a commit in a GitLab project
+type GitLabCommit struct {
+	ID        string   `json:"id"`
+	ParentIDs []string `json:"parent_ids"`
+}
+
+// GitLabNewCommit is a commit to be created on Branch, which is created from
+// StartBranch if it doesn't exist. If Force is set, Branch is reset to the new
+// commit on top of StartBranch even if it already exists.
+type GitLabNewCommit struct {
+	Branch        string                `json:"branch"`
+	StartBranch   string                `json:"start_branch,omitempty"`
+	CommitMessage string                `json:"commit_message"`
+	Force         bool                  `json:"force,omitempty"`
+	Actions       []*GitLabCommitAction `json:"actions"`
+}
+
+// GitLabCommitAction is a single file change in a GitLabNewCommit
+type GitLabCommitAction struct {
+	// Action is "create" for a new file or "update" for an existing one
+	Action   string `json:"action"`
+	FilePath string `json:"file_path"`
+	Content  string `json:"content"`
+	// Encoding is "base64" if Content is base64 encoded, or empty for text
+	Encoding string `json:"encoding,omitempty"`
+}
+
+// GitLabMergeRequestListOptions filters the merge requests listed by
+// ListMergeRequests.
+type GitLabMergeRequestListOptions struct {
+	SourceBranch string
+	TargetBranch string
+	// State is one of "opened", "closed", "locked" or "merged"
+	State string
+}
+
+// GitLabMergeRequest is a merge request in a GitLab project
+type GitLabMergeRequest struct {
+	IID          int    `json:"iid"`
+	WebURL       string `json:"web_url"`
+	State        string `json:"state"`
+	SourceBranch string `json:"source_branch"`
+	TargetBranch string `json:"target_branch"`
+}
+
+// GitLabNewMergeRequest is a merge request to be created
+type GitLabNewMergeRequest struct {
+	SourceBranch string `json:"source_branch"`
+	TargetBranch string `json:"target_branch"`
+	Title        string `json:"title"`
+	Description  string `json:"description"`
+}
+
+// GitLabErrorResponse is returned when the GitLab API responds with an error
+type GitLabErrorResponse struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *GitLabErrorResponse) Error() string {
+	return fmt.Sprintf("GitLab API responded with %d: %s", e.StatusCode, e.Message)
+}
+
+// isGitLabStatus returns true if err is a *GitLabErrorResponse with the given
+// status code
+func isGitLabStatus(err error, statusCode int) bool {
+	var gitLabErr *GitLabErrorResponse
+	return errors.As(err, &gitLabErr) && gitLabErr.StatusCode == statusCode
+}
+
+type gitLabHTTPClient struct {
+	client  *http.Client
+	baseURL *url.URL
+	token   string
+}
+
+// NewGitLabClient returns a GitLabClient for the GitLab instance at baseURL,
+// such as https://gitlab.com, which authenticates with the given personal,
+// project or group access token. The token must have the "api" scope.
+func NewGitLabClient(baseURL, token string) (GitLabClient, error) {
+	u, err := url.Parse(strings.TrimSuffix(baseURL, "/") + "/api/v4/")
+	if err != nil {
+		return nil, fmt.Errorf("invalid GitLab URL %q: %w", baseURL, err)
+	}
+
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return nil, fmt.Errorf("invalid GitLab URL %q: must be an http or https URL", baseURL)
+	}
+
+	return &gitLabHTTPClient{client: &http.Client{Timeout: gitLabTimeout}, baseURL: u, token: token}, nil
+}
+
+// do sends a request to the given API path, which must already be escaped,
+// encoding body as JSON if it's not nil. If out is not nil, the JSON response
+// is decoded into it.
+func (c *gitLabHTTPClient) do(ctx context.Context, method, path string, query url.Values, body any, out any) ([]byte, error) {
+	data, _, err := c.doWithHeader(ctx, method, path, query, body, out)
+	return data, err
+}
+
+// doWithHeader is like do, but also returns the headers of the response,
+// which hold the pagination of lists.
+func (c *gitLabHTTPClient) doWithHeader(ctx context.Context, method, path string, query url.Values, body any, out any) ([]byte, http.Header, error) {
+	u, err := c.baseURL.Parse(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	u.RawQuery = query.Encode()
+
+	var bodyReader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, nil, err
+		}
+		bodyReader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bodyReader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	req.Header.Set("PRIVATE-TOKEN", c.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		message := struct {
+			Message any `json:"message"`
+			Error   any `json:"error"`
+		}{}
+		if err := json.Unmarshal(data, &message); err != nil || (message.Message == nil && message.Error == nil) {
+			return nil, nil, &GitLabErrorResponse{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(data))}
+		}
+		if message.Message == nil {
+			message.Message = message.Error
+		}
+		return nil, nil, &GitLabErrorResponse{StatusCode: resp.StatusCode, Message: fmt.Sprint(message.Message)}
+	}
+
+	if out != nil {
+		if err := json.Unmarshal(data, out); err != nil {
+			return nil, nil, fmt.Errorf("failed to decode GitLab API response from %s %s: %w", method, u.Path, err)
+		}
+	}
+
+	return data, resp.Header, nil
+}
+
+func projectPath(project string, elems ...string) string {
+	parts := []string{"projects", url.PathEscape(project)}
+	for _, elem := range elems {
+		parts = append(parts, url.PathEscape(elem))
+	}
+	return strings.Join(parts, "/")
+}
+
+func (c *gitLabHTTPClient) ProjectAccessLevel(ctx context.Context, project string) (int, error) {
+	type access struct {
+		AccessLevel int `json:"access_level"`
+	}
+
+	var p struct {
+		Permissions struct {
+			ProjectAccess *access `json:"project_access"`
+			GroupAccess   *access `json:"group_access"`
+		} `json:"permissions"`
+	}
+
+	if _, err := c.do(ctx, http.MethodGet, projectPath(project), nil, nil, &p); err != nil {
+		return 0, err
+	}
+
+	level := 0
+	for _, a := range []*access{p.Permissions.ProjectAccess, p.Permissions.GroupAccess} {
+		if a != nil && a.AccessLevel > level {
+			level = a.AccessLevel
+		}
+	}
+
+	return level, nil
+}
+
+func (c *gitLabHTTPClient) GetBranch(ctx context.Context, project, branch string) (*GitLabBranch, error) {
+	b := &GitLabBranch{}
+	if _, err := c.do(ctx, http.MethodGet, projectPath(project, "repository", "branches", branch), nil, nil, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (c *gitLabHTTPClient) GetRawFile(ctx context.Context, project, path, ref string) ([]byte, error) {
+	return c.do(ctx, http.MethodGet, projectPath(project, "repository", "files", path, "raw"), url.Values{"ref": []string{ref}}, nil, nil)
+}
+
+func (c *gitLabHTTPClient) CreateCommit(ctx context.Context, project string, commit *GitLabNewCommit) (*GitLabCommit, error) {
+	created := &GitLabCommit{}
+	if _, err := c.do(ctx, http.MethodPost, projectPath(project, "repository", "commits"), nil, commit, created); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+func (c *gitLabHTTPClient) ListMergeRequests(ctx context.Context, project string, opts *GitLabMergeRequestListOptions) ([]*GitLabMergeRequest, error) {
+	query := url.Values{}
+	if opts.SourceBranch != "" {
+		query.Set("source_branch", opts.SourceBranch)
+	}
+	if opts.TargetBranch != "" {
+		query.Set("target_branch", opts.TargetBranch)
+	}
+	if opts.State != "" {
+		query.Set("state", opts.State)
+	}
+	query.Set("per_page", fmt.Sprint(gitLabPerPage))
+
+	// GitLab gives the next page of a list in the X-Next-Page header, which is
+	// empty on the last page
+	var mrs []*GitLabMergeRequest
+	for {
+		var page []*GitLabMergeRequest
+		_, header, err := c.doWithHeader(ctx, http.MethodGet, projectPath(project, "merge_requests"), query, nil, &page)
+		if err != nil {
+			return nil, err
+		}
+		mrs = append(mrs, page...)
+
+		next := header.Get("X-Next-Page")
+		if next == "" {
+			return mrs, nil
+		}
+		query.Set("page", next)
+	}
+}
+
+func (c *gitLabHTTPClient) CreateMergeRequest(ctx context.Context, project string, mr *GitLabNewMergeRequest) (*GitLabMergeRequest, error) {
+	created := &GitLabMergeRequest{}
+	if _, err := c.do(ctx, http.MethodPost, projectPath(project, "merge_requests"), nil, mr, created); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+func (c *gitLabHTTPClient) CloseMergeRequest(ctx context.Context, project string, iid int) (*GitLabMergeRequest, error) {
+	updated := &GitLabMergeRequest{}
+	body := map[string]string{"state_event": "close"}
+	if _, err := c.do(ctx, http.MethodPut, projectPath(project, "merge_requests", fmt.Sprint(iid)), nil, body, updated); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+func (c *gitLabHTTPClient) DeleteBranch(ctx context.Context, project, branch string) error {
+	_, err := c.do(ctx, http.MethodDelete, projectPath(project, "repository", "branches", branch), nil, nil, nil)
+	return err
+}
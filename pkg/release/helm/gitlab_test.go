@@ -0,0 +1,404 @@
+/*
+Copyright 2021 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helm
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeGitLab is an in-memory GitLab project implementing GitLabClient
+type fakeGitLab struct {
+	project     string
+	accessLevel int
+
+	commits  map[string]fakeGitLabCommit
+	branches map[string]string
+	mrs      []*GitLabMergeRequest
+}
+
+type fakeGitLabCommit struct {
+	parents []string
+	files   map[string][]byte
+}
+
+func newFakeGitLab(project, branch string, files map[string]string) *fakeGitLab {
+	f := &fakeGitLab{
+		project:     project,
+		accessLevel: GitLabDeveloperAccess,
+		commits:     map[string]fakeGitLabCommit{},
+		branches:    map[string]string{},
+	}
+
+	commit := fakeGitLabCommit{files: map[string][]byte{}}
+	for path, content := range files {
+		commit.files[path] = []byte(content)
+	}
+	f.branches[branch] = f.storeCommit(commit)
+	return f
+}
+
+func (f *fakeGitLab) storeCommit(commit fakeGitLabCommit) string {
+	var parts []string
+	for path, content := range commit.files {
+		parts = append(parts, path+"="+fakeHash("blob", string(content)))
+	}
+	sort.Strings(parts)
+
+	id := fakeHash("commit", append(parts, commit.parents...)...)
+	f.commits[id] = commit
+	return id
+}
+
+func (f *fakeGitLab) checkProject(project string) error {
+	if project != f.project {
+		return &GitLabErrorResponse{StatusCode: http.StatusNotFound, Message: "404 Project Not Found"}
+	}
+	return nil
+}
+
+func (f *fakeGitLab) ProjectAccessLevel(ctx context.Context, project string) (int, error) {
+	if err := f.checkProject(project); err != nil {
+		return 0, err
+	}
+	return f.accessLevel, nil
+}
+
+func (f *fakeGitLab) GetBranch(ctx context.Context, project, branch string) (*GitLabBranch, error) {
+	if err := f.checkProject(project); err != nil {
+		return nil, err
+	}
+	id, ok := f.branches[branch]
+	if !ok {
+		return nil, &GitLabErrorResponse{StatusCode: http.StatusNotFound, Message: "404 Branch Not Found"}
+	}
+	return &GitLabBranch{Name: branch, Commit: GitLabCommit{ID: id, ParentIDs: f.commits[id].parents}}, nil
+}
+
+func (f *fakeGitLab) GetRawFile(ctx context.Context, project, path, ref string) ([]byte, error) {
+	if err := f.checkProject(project); err != nil {
+		return nil, err
+	}
+	content, ok := f.commits[f.branches[ref]].files[path]
+	if !ok {
+		return nil, &GitLabErrorResponse{StatusCode: http.StatusNotFound, Message: "404 File Not Found"}
+	}
+	return content, nil
+}
+
+func (f *fakeGitLab) CreateCommit(ctx context.Context, project string, commit *GitLabNewCommit) (*GitLabCommit, error) {
+	if err := f.checkProject(project); err != nil {
+		return nil, err
+	}
+
+	parent, exists := f.branches[commit.Branch]
+	if exists && !commit.Force {
+		return nil, &GitLabErrorResponse{StatusCode: http.StatusBadRequest, Message: "A branch called '" + commit.Branch + "' already exists"}
+	}
+	if !exists || commit.Force {
+		parent = f.branches[commit.StartBranch]
+	}
+
+	files := map[string][]byte{}
+	for path, content := range f.commits[parent].files {
+		files[path] = content
+	}
+
+	for _, action := range commit.Actions {
+		_, fileExists := files[action.FilePath]
+		switch {
+		case action.Action == "create" && fileExists:
+			return nil, &GitLabErrorResponse{StatusCode: http.StatusBadRequest, Message: "A file with this name already exists"}
+		case action.Action == "update" && !fileExists:
+			return nil, &GitLabErrorResponse{StatusCode: http.StatusBadRequest, Message: "A file with this name doesn't exist"}
+		}
+
+		content := []byte(action.Content)
+		if action.Encoding == "base64" {
+			decoded, err := base64.StdEncoding.DecodeString(action.Content)
+			if err != nil {
+				return nil, err
+			}
+			content = decoded
+		}
+		files[action.FilePath] = content
+	}
+
+	id := f.storeCommit(fakeGitLabCommit{parents: []string{parent}, files: files})
+	f.branches[commit.Branch] = id
+	return &GitLabCommit{ID: id, ParentIDs: []string{parent}}, nil
+}
+
+func (f *fakeGitLab) ListMergeRequests(ctx context.Context, project string, opts *GitLabMergeRequestListOptions) ([]*GitLabMergeRequest, error) {
+	if err := f.checkProject(project); err != nil {
+		return nil, err
+	}
+	var mrs []*GitLabMergeRequest
+	for _, mr := range f.mrs {
+		if mr.SourceBranch == opts.SourceBranch && mr.TargetBranch == opts.TargetBranch && mr.State == opts.State {
+			mrs = append(mrs, mr)
+		}
+	}
+	return mrs, nil
+}
+
+func (f *fakeGitLab) CreateMergeRequest(ctx context.Context, project string, mr *GitLabNewMergeRequest) (*GitLabMergeRequest, error) {
+	if err := f.checkProject(project); err != nil {
+		return nil, err
+	}
+	if _, ok := f.branches[mr.SourceBranch]; !ok {
+		return nil, &GitLabErrorResponse{StatusCode: http.StatusNotFound, Message: "404 Source branch Not Found"}
+	}
+	iid := len(f.mrs) + 1
+	created := &GitLabMergeRequest{
+		IID:          iid,
+		WebURL:       fmt.Sprintf("https://gitlab.example.com/%s/-/merge_requests/%d", project, iid),
+		State:        "opened",
+		SourceBranch: mr.SourceBranch,
+		TargetBranch: mr.TargetBranch,
+	}
+	f.mrs = append(f.mrs, created)
+	return created, nil
+}
+
+func (f *fakeGitLab) CloseMergeRequest(ctx context.Context, project string, iid int) (*GitLabMergeRequest, error) {
+	if err := f.checkProject(project); err != nil {
+		return nil, err
+	}
+	if iid < 1 || iid > len(f.mrs) {
+		return nil, &GitLabErrorResponse{StatusCode: http.StatusNotFound, Message: "404 Not found"}
+	}
+	f.mrs[iid-1].State = "closed"
+	return f.mrs[iid-1], nil
+}
+
+func (f *fakeGitLab) DeleteBranch(ctx context.Context, project, branch string) error {
+	if err := f.checkProject(project); err != nil {
+		return err
+	}
+	if _, ok := f.branches[branch]; !ok {
+		return &GitLabErrorResponse{StatusCode: http.StatusNotFound, Message: "404 Branch Not Found"}
+	}
+	delete(f.branches, branch)
+	return nil
+}
+
+func TestGitLabRepositoryManager(t *testing.T) {
+	ctx := context.Background()
+
+	const (
+		project     = "jetstack/jetstack-charts"
+		branch      = "main"
+		releaseName = "v0.1.0-test.1-0123456789abcdef"
+	)
+
+	tests := map[string]struct {
+		accessLevel int
+		// setup is run after the chart has been published once, to simulate
+		// the state left behind by a previous failed or repeated run
+		setup func(f *fakeGitLab)
+
+		expectCheckErr      string
+		expectBranchChanged bool
+		expectMRNumber      int
+	}{
+		"publishing again reuses the branch and MR": {
+			setup:          func(f *fakeGitLab) {},
+			expectMRNumber: 1,
+		},
+		"a branch left without an MR gets an MR": {
+			setup: func(f *fakeGitLab) {
+				f.mrs = nil
+			},
+			expectMRNumber: 1,
+		},
+		"a branch with different files is reset": {
+			setup: func(f *fakeGitLab) {
+				head := f.commits[f.branches[releaseName]]
+				files := map[string][]byte{}
+				for path, content := range head.files {
+					files[path] = content
+				}
+				files["charts/cert-manager-v0.1.0-test.1.tgz"] = []byte("partial upload")
+				f.branches[releaseName] = f.storeCommit(fakeGitLabCommit{parents: []string{f.branches[releaseName]}, files: files})
+			},
+			expectBranchChanged: true,
+			expectMRNumber:      1,
+		},
+		"a closed MR is replaced": {
+			setup: func(f *fakeGitLab) {
+				f.mrs[0].State = "closed"
+			},
+			expectMRNumber: 2,
+		},
+		"insufficient access": {
+			accessLevel:    20,
+			expectCheckErr: "expected at least developer access (30)",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			f := newFakeGitLab(project, branch, map[string]string{
+				"README.md": "charts",
+			})
+			if test.accessLevel != 0 {
+				f.accessLevel = test.accessLevel
+			}
+
+			r := NewGitLabRepositoryManager(f, project, branch)
+			if test.expectCheckErr != "" {
+				require.ErrorContains(t, r.Check(ctx), test.expectCheckErr)
+				return
+			}
+			require.NoError(t, r.Check(ctx))
+
+			chart := copyTestChart(t, "-----BEGIN PGP SIGNED MESSAGE-----\n")
+			chartData, err := os.ReadFile(chart.Path())
+			require.NoError(t, err)
+
+			mrURL, err := r.Publish(ctx, releaseName, *chart)
+			require.NoError(t, err)
+			require.Equal(t, "https://gitlab.example.com/jetstack/jetstack-charts/-/merge_requests/1", mrURL)
+
+			head := f.commits[f.branches[releaseName]]
+			require.Equal(t, []string{f.branches[branch]}, head.parents)
+			require.Equal(t, chartData, head.files["charts/cert-manager-v0.1.0-test.1.tgz"])
+			require.Contains(t, string(head.files["index.yaml"]), "charts/cert-manager-v0.1.0-test.1.tgz")
+
+			test.setup(f)
+			before := f.branches[releaseName]
+
+			mrURL, err = r.Publish(ctx, releaseName, *chart)
+			require.NoError(t, err)
+			require.Equal(t, fmt.Sprintf("https://gitlab.example.com/jetstack/jetstack-charts/-/merge_requests/%d", test.expectMRNumber), mrURL)
+			require.Len(t, f.mrs, test.expectMRNumber)
+
+			if test.expectBranchChanged {
+				require.NotEqual(t, before, f.branches[releaseName], "expected branch to be reset")
+			} else {
+				require.Equal(t, before, f.branches[releaseName], "expected branch to be left unchanged")
+			}
+
+			head = f.commits[f.branches[releaseName]]
+			require.Equal(t, []string{f.branches[branch]}, head.parents)
+			require.Equal(t, chartData, head.files["charts/cert-manager-v0.1.0-test.1.tgz"])
+
+			closed, err := r.Unpublish(ctx, releaseName)
+			require.NoError(t, err)
+			require.Equal(t, []string{mrURL}, closed)
+			require.NotContains(t, f.branches, releaseName)
+
+			// unpublishing again is not an error
+			closed, err = r.Unpublish(ctx, releaseName)
+			require.NoError(t, err)
+			require.Empty(t, closed)
+		})
+	}
+}
+
+func TestGitLabClient(t *testing.T) {
+	ctx := context.Background()
+
+	var requests []string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.RequestURI())
+
+		if r.Header.Get("PRIVATE-TOKEN") != "token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"message":"401 Unauthorized"}`))
+			return
+		}
+
+		switch {
+		case r.Method == http.MethodGet && r.URL.EscapedPath() == "/api/v4/projects/jetstack%2Fcharts":
+			_, _ = w.Write([]byte(`{"permissions":{"project_access":null,"group_access":{"access_level":40}}}`))
+		case r.Method == http.MethodGet && r.URL.EscapedPath() == "/api/v4/projects/jetstack%2Fcharts/repository/files/charts%2Fcert-manager.tgz/raw":
+			_, _ = w.Write([]byte("chart for " + r.URL.Query().Get("ref")))
+		case r.Method == http.MethodPost && r.URL.EscapedPath() == "/api/v4/projects/jetstack%2Fcharts/repository/commits":
+			commit := &GitLabNewCommit{}
+			if err := json.NewDecoder(r.Body).Decode(commit); err != nil || !commit.Force || len(commit.Actions) != 1 {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(`{"message":"bad commit"}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"id":"abc","parent_ids":["def"]}`))
+		case r.Method == http.MethodGet && r.URL.EscapedPath() == "/api/v4/projects/jetstack%2Fcharts/merge_requests":
+			// two pages of merge requests
+			if r.URL.Query().Get("page") == "" {
+				w.Header().Set("X-Next-Page", "2")
+				_, _ = w.Write([]byte(`[{"iid":1,"source_branch":"v1.15.0"}]`))
+				return
+			}
+			w.Header().Set("X-Next-Page", "")
+			_, _ = w.Write([]byte(`[{"iid":2,"source_branch":"v1.15.0"}]`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"message":"404 Not Found"}`))
+		}
+	}))
+	defer s.Close()
+
+	client, err := NewGitLabClient(s.URL+"/", "token")
+	require.NoError(t, err)
+
+	level, err := client.ProjectAccessLevel(ctx, "jetstack/charts")
+	require.NoError(t, err)
+	require.Equal(t, GitLabMaintainerAccess, level)
+
+	content, err := client.GetRawFile(ctx, "jetstack/charts", "charts/cert-manager.tgz", "main")
+	require.NoError(t, err)
+	require.Equal(t, "chart for main", string(content))
+
+	commit, err := client.CreateCommit(ctx, "jetstack/charts", &GitLabNewCommit{
+		Branch:      "v1.15.0",
+		StartBranch: "main",
+		Force:       true,
+		Actions:     []*GitLabCommitAction{{Action: "create", FilePath: "index.yaml", Content: "apiVersion: v1\n"}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, &GitLabCommit{ID: "abc", ParentIDs: []string{"def"}}, commit)
+
+	mrs, err := client.ListMergeRequests(ctx, "jetstack/charts", &GitLabMergeRequestListOptions{SourceBranch: "v1.15.0"})
+	require.NoError(t, err)
+	require.Len(t, mrs, 2)
+	require.Equal(t, []int{1, 2}, []int{mrs[0].IID, mrs[1].IID})
+
+	_, err = client.GetBranch(ctx, "jetstack/charts", "missing")
+	require.True(t, isGitLabStatus(err, http.StatusNotFound), "expected a 404 error, got: %v", err)
+	require.ErrorContains(t, err, "404 Not Found")
+
+	unauthorized, err := NewGitLabClient(s.URL, "wrong")
+	require.NoError(t, err)
+	_, err = unauthorized.ProjectAccessLevel(ctx, "jetstack/charts")
+	require.True(t, isGitLabStatus(err, http.StatusUnauthorized), "expected a 401 error, got: %v", err)
+
+	require.True(t, strings.HasPrefix(requests[0], "GET /api/v4/projects/jetstack%2Fcharts"), "unexpected request %q", requests[0])
+
+	_, err = NewGitLabClient("gitlab.com", "token")
+	require.ErrorContains(t, err, "must be an http or https URL")
+}
@@ -23,7 +23,6 @@ import (
 	"fmt"
 	"log"
 	"net/http"
-	"os"
 	"strings"
 
 	"github.com/google/go-github/v35/github"
@@ -35,7 +34,7 @@ import (
 
 const (
 	// Some template strings for GitHub commit and PR messages.
-	tplCommitMessage = "Add %s"
+	tplCommitMessage = "Add %s\n\nThe added charts are also added to %s."
 	tplPRTitle       = "Helm charts for cert-manager staged release: %s"
 	tplPRDescription = "This PR was generated by [cmrel](https://github.com/cert-manager/release) for the cert-manager staged release: `%s`."
 )
@@ -46,16 +45,25 @@ func init() {
 	acceptableGitHubPermissions = sets.NewString("write", "admin")
 }
 
+// RepositoryManager publishes Helm charts to a chart repository; see
+// NewGitHubRepositoryManager, NewGitLabRepositoryManager and
+// NewGitRepositoryManager. Charts in OCI registries are pushed by an
+// OCIRepositoryManager instead.
 type RepositoryManager interface {
 	// Check is called immediately after instantiating the RepositoryManager to
-	// verify that the supplied GitHub configuration is valid and that the
-	// supplied GitHub client has permissions to perform branching, uploading
-	// and creating PRs.
+	// verify that the supplied configuration is valid and that the supplied
+	// credentials have permission to publish charts. For GitHub this means
+	// permissions to perform branching, uploading and creating PRs.
 	Check(ctx context.Context) error
-	// Publish creates a new GitHub branch, uploads all the chart files and then
+	// Publish publishes the given charts. For GitHub, it creates a new branch,
+	// uploads all the chart files and the updated repository index and then
 	// creates a PR to merge those into the main branch for the repository.
-	// The PR URl is returned on success.
+	// A URL for what was published, such as the PR URL, is returned on success.
 	Publish(ctx context.Context, releaseName string, charts ...manifests.Chart) (prURL string, err error)
+	// Unpublish closes any open PRs created by Publish for the given release
+	// and deletes the branch they were created from. The URLs of the closed
+	// PRs are returned. It is not an error if nothing was published.
+	Unpublish(ctx context.Context, releaseName string) (closedPRURLs []string, err error)
 }
 
 type gitHubRepositoryManager struct {
@@ -158,28 +166,95 @@ func (o *gitHubRepositoryManager) Check(ctx context.Context) (err error) {
 }
 
 // Publish is documented at RepositoryManager.Publish
+// All the charts, their provenance files and the updated repository index are
+// added in a single commit on a branch named after the release. If the branch
+// already exists from a previous run it's reused when it already holds the
+// same files, and otherwise reset to the new commit; an open PR for the
+// branch is reused in the same way. This means that Publish can safely be
+// retried if it fails part way through.
 func (o *gitHubRepositoryManager) Publish(ctx context.Context, releaseName string, charts ...manifests.Chart) (string, error) {
 	log.Printf("Creating PR for merging Helm charts of %q into %q", releaseName, o.destination())
 
-	// Create a new branch
-	newBranchName := releaseName
-	ref, err := o.createBranch(ctx, o.branch, newBranchName)
+	branchName := releaseName
+
+	baseRef, _, err := o.GitClient.GetRef(ctx, o.owner, o.repo, fmt.Sprintf("refs/heads/%s", o.branch))
+	if err != nil {
+		return "", fmt.Errorf("failed to get branch %q: %w", o.branch, err)
+	}
+
+	existingRef, err := o.getBranch(ctx, branchName)
+	if err != nil {
+		return "", err
+	}
+
+	entries, commitMessage, err := o.treeEntries(ctx, releaseName, existingRef != nil, charts...)
+	if err != nil {
+		return "", err
+	}
+
+	tree, _, err := o.GitClient.CreateTree(ctx, o.owner, o.repo, baseRef.GetObject().GetSHA(), entries)
 	if err != nil {
 		return "", err
 	}
 
-	// Upload charts
-	for _, chart := range charts {
-		if err := o.commitChartToBranch(ctx, ref, chart); err != nil {
+	if existingRef == nil {
+		commit, err := o.createCommit(ctx, baseRef.GetObject().GetSHA(), tree, commitMessage)
+		if err != nil {
+			return "", err
+		}
+
+		log.Printf("Creating branch %q at commit %s", branchName, commit.GetSHA())
+		if _, _, err := o.GitClient.CreateRef(ctx, o.owner, o.repo, &github.Reference{
+			Ref:    ptr.To(fmt.Sprintf("refs/heads/%s", branchName)),
+			Object: &github.GitObject{SHA: commit.SHA},
+		}); err != nil {
+			return "", fmt.Errorf("failed to create branch %q: %w", branchName, err)
+		}
+	} else {
+		existingCommit, _, err := o.RepositoriesClient.GetCommit(ctx, o.owner, o.repo, existingRef.GetObject().GetSHA())
+		if err != nil {
 			return "", err
 		}
+
+		// git trees are addressed by their content, so the branch already
+		// holds exactly the files being published if the tree SHAs match
+		if existingCommit.GetCommit().GetTree().GetSHA() == tree.GetSHA() {
+			log.Printf("Branch %q already exists with the same charts; reusing it", branchName)
+		} else {
+			commit, err := o.createCommit(ctx, baseRef.GetObject().GetSHA(), tree, commitMessage)
+			if err != nil {
+				return "", err
+			}
+
+			log.Printf("Branch %q already exists with different files; resetting it to commit %s", branchName, commit.GetSHA())
+			existingRef.Object.SHA = commit.SHA
+			if _, _, err := o.GitClient.UpdateRef(ctx, o.owner, o.repo, existingRef, true); err != nil {
+				return "", fmt.Errorf("failed to reset branch %q: %w", branchName, err)
+			}
+		}
 	}
+
+	prs, _, err := o.PullRequestClient.List(ctx, o.owner, o.repo, &github.PullRequestListOptions{
+		State: "open",
+		Head:  fmt.Sprintf("%s:%s", o.owner, branchName),
+		Base:  o.branch,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to list PRs for branch %q: %w", branchName, err)
+	}
+
+	if len(prs) > 0 {
+		prURL := prs[0].GetHTMLURL()
+		log.Printf("Reusing existing PR: %s", prURL)
+		return prURL, nil
+	}
+
 	// Create PR
 	npr := &github.NewPullRequest{
 		Title: ptr.To(fmt.Sprintf(tplPRTitle, releaseName)),
 		Body:  ptr.To(fmt.Sprintf(tplPRDescription, releaseName)),
 		Base:  ptr.To(o.branch),
-		Head:  ptr.To(newBranchName),
+		Head:  ptr.To(branchName),
 	}
 	pr, _, err := o.PullRequestClient.Create(ctx, o.owner, o.repo, npr)
 	if err != nil {
@@ -190,88 +265,148 @@ func (o *gitHubRepositoryManager) Publish(ctx context.Context, releaseName strin
 	return prURL, nil
 }
 
-// createBranch creates a new branch on the target repo
-// See https://stackoverflow.com/questions/9506181/github-api-create-branch
-func (o *gitHubRepositoryManager) createBranch(ctx context.Context, sourceName, branchName string) (*github.Reference, error) {
-	baseRef, _, err := o.GitClient.GetRef(ctx, o.owner, o.repo, fmt.Sprintf("refs/heads/%s", sourceName))
+// Unpublish is documented at RepositoryManager.Unpublish
+func (o *gitHubRepositoryManager) Unpublish(ctx context.Context, releaseName string) ([]string, error) {
+	branchName := releaseName
+
+	prs, _, err := o.PullRequestClient.List(ctx, o.owner, o.repo, &github.PullRequestListOptions{
+		State: "open",
+		Head:  fmt.Sprintf("%s:%s", o.owner, branchName),
+		Base:  o.branch,
+	})
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to list PRs for branch %q: %w", branchName, err)
 	}
 
-	newRef := &github.Reference{
-		Ref:    ptr.To(fmt.Sprintf("refs/heads/%s", branchName)),
-		Object: baseRef.GetObject(),
+	var closed []string
+	for _, pr := range prs {
+		log.Printf("Closing PR %s", pr.GetHTMLURL())
+		if _, _, err := o.PullRequestClient.Edit(ctx, o.owner, o.repo, pr.GetNumber(), &github.PullRequest{State: ptr.To("closed")}); err != nil {
+			return closed, fmt.Errorf("failed to close PR %s: %w", pr.GetHTMLURL(), err)
+		}
+		closed = append(closed, pr.GetHTMLURL())
+	}
+
+	log.Printf("Deleting branch %q from github.com/%s/%s", branchName, o.owner, o.repo)
+	if _, err := o.GitClient.DeleteRef(ctx, o.owner, o.repo, fmt.Sprintf("heads/%s", branchName)); err != nil {
+		var gitHubErr *github.ErrorResponse
+		// GitHub responds with 422 when deleting a ref which doesn't exist
+		if !errors.As(err, &gitHubErr) || (gitHubErr.Response.StatusCode != http.StatusNotFound && gitHubErr.Response.StatusCode != http.StatusUnprocessableEntity) {
+			return closed, fmt.Errorf("failed to delete branch %q: %w", branchName, err)
+		}
+		log.Printf("Branch %q does not exist", branchName)
 	}
 
-	ref, _, err := o.GitClient.CreateRef(ctx, o.owner, o.repo, newRef)
-	return ref, err
+	return closed, nil
 }
 
-// commitChartToBranch uploads a single Helm chart to the target branch, along with its signature if such a signature exists
-func (o *gitHubRepositoryManager) commitChartToBranch(ctx context.Context, ref *github.Reference, chart manifests.Chart) error {
-	entries := []*github.TreeEntry{}
+// getBranch returns the ref for the given branch, or nil if it doesn't exist
+func (o *gitHubRepositoryManager) getBranch(ctx context.Context, branchName string) (*github.Reference, error) {
+	ref, _, err := o.GitClient.GetRef(ctx, o.owner, o.repo, fmt.Sprintf("refs/heads/%s", branchName))
+	if err != nil {
+		var gitHubErr *github.ErrorResponse
+		if errors.As(err, &gitHubErr) && gitHubErr.Response.StatusCode == http.StatusNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get branch %q: %w", branchName, err)
+	}
 
-	chartFileName := chart.PackageFileName()
+	return ref, nil
+}
 
-	chartContent, err := os.ReadFile(chart.Path())
+// treeEntries returns the tree entries for the given charts, their provenance
+// files and the updated repository index, along with a message for the commit
+// adding them. If the branch for the release exists from a previous run, the
+// index on it is used to keep the creation times of unchanged charts.
+func (o *gitHubRepositoryManager) treeEntries(ctx context.Context, releaseName string, branchExists bool, charts ...manifests.Chart) ([]*github.TreeEntry, string, error) {
+	files, err := chartFiles(charts...)
 	if err != nil {
-		return err
+		return nil, "", err
 	}
 
-	// we can't just append a github.TreeEntry because the tgz file is binary data and can't be string encoded, which
-	// is required for the "TreeEntry.Content" field. Instead, we have to create a blob manually and use "TreeEntry.SHA"
-	// to refer to that blob
-	chartBlob, _, err := o.GitClient.CreateBlob(ctx, o.owner, o.repo, &github.Blob{
-		Content:  github.String(base64.StdEncoding.EncodeToString(chartContent)),
-		Encoding: github.String("base64"),
-	})
+	existing, err := o.getIndex(ctx, o.branch)
 	if err != nil {
-		return err
+		return nil, "", err
 	}
 
-	entries = append(entries, &github.TreeEntry{
-		Path: github.String("charts/" + chartFileName),
-		Type: github.String("blob"),
-		SHA:  chartBlob.SHA,
-		// 100644 = blob, see https://docs.github.com/en/rest/reference/git#create-a-tree--parameters
-		Mode: github.String("100644"),
-	})
-
-	commitMessage := fmt.Sprintf(tplCommitMessage, chartFileName)
-
-	provPath := chart.ProvPath()
-
-	if provPath != nil {
-		// provPath is the path to the prov file in the cert-manager-manifests bundle,
-		// and so we use provPath for reading the content of the file, but we want
-		// to use `chartFileName + ".prov"` for the committed file's name
-		provFileName := chartFileName + ".prov"
-		provContent, err := os.ReadFile(*provPath)
+	var previous []byte
+	if branchExists {
+		previous, err = o.getIndex(ctx, releaseName)
 		if err != nil {
-			return err
+			return nil, "", err
 		}
+	}
+
+	index, err := indexRepositoryFile(existing, previous, charts...)
+	if err != nil {
+		return nil, "", err
+	}
+
+	files = append(files, index)
 
-		// TreeEntries can create a blob entry if it contains textual data; a prov file does, so
-		// it's simpler to add than the tarred + gzipped chart
-		entries = append(entries, &github.TreeEntry{
-			Path:    github.String("charts/" + provFileName),
-			Type:    github.String("blob"),
-			Content: github.String(string(provContent)),
+	entries := []*github.TreeEntry{}
+	for _, file := range files {
+		entry := &github.TreeEntry{
+			Path: github.String(file.path),
+			Type: github.String("blob"),
 			// 100644 = blob, see https://docs.github.com/en/rest/reference/git#create-a-tree--parameters
 			Mode: github.String("100644"),
-		})
+		}
 
-		commitMessage = commitMessage + fmt.Sprintf(" and %s", provFileName)
+		if file.binary {
+			// we can't just set "TreeEntry.Content" because binary data such as a tgz file can't be string encoded.
+			// Instead, we have to create a blob manually and use "TreeEntry.SHA" to refer to that blob
+			blob, _, err := o.GitClient.CreateBlob(ctx, o.owner, o.repo, &github.Blob{
+				Content:  github.String(base64.StdEncoding.EncodeToString(file.content)),
+				Encoding: github.String("base64"),
+			})
+			if err != nil {
+				return nil, "", err
+			}
+
+			entry.SHA = blob.SHA
+		} else {
+			// TreeEntries can create a blob entry if it contains textual data, such as a prov file or the index
+			entry.Content = github.String(string(file.content))
+		}
+
+		entries = append(entries, entry)
 	}
 
-	tree, _, err := o.GitClient.CreateTree(ctx, o.owner, o.repo, *ref.Object.SHA, entries)
+	return entries, commitMessage(files), nil
+}
+
+// getIndex returns the contents of the Helm repository index on the given
+// branch, or nil if the branch doesn't have one yet. The index is fetched
+// as a git blob since the contents API doesn't return files larger than 1MB.
+func (o *gitHubRepositoryManager) getIndex(ctx context.Context, branchName string) ([]byte, error) {
+	_, dirContents, _, err := o.RepositoriesClient.GetContents(ctx, o.owner, o.repo, "", &github.RepositoryContentGetOptions{Ref: branchName})
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("failed to list files in branch %q: %w", branchName, err)
+	}
+
+	for _, content := range dirContents {
+		if content.GetName() != indexFileName || content.GetType() != "file" {
+			continue
+		}
+
+		data, _, err := o.GitClient.GetBlobRaw(ctx, o.owner, o.repo, content.GetSHA())
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch %s from branch %q: %w", indexFileName, branchName, err)
+		}
+
+		return data, nil
 	}
 
-	parent, _, err := o.RepositoriesClient.GetCommit(ctx, o.owner, o.repo, *ref.Object.SHA)
+	log.Printf("No %s found in branch %q", indexFileName, branchName)
+	return nil, nil
+}
+
+// createCommit creates a commit with the given tree on top of the given parent
+func (o *gitHubRepositoryManager) createCommit(ctx context.Context, parentSHA string, tree *github.Tree, commitMessage string) (*github.Commit, error) {
+	parent, _, err := o.RepositoriesClient.GetCommit(ctx, o.owner, o.repo, parentSHA)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// This needs to be set, according to:
@@ -287,16 +422,10 @@ func (o *gitHubRepositoryManager) commitChartToBranch(ctx context.Context, ref *
 	}
 	newCommit, _, err := o.GitClient.CreateCommit(ctx, o.owner, o.repo, commit)
 	if err != nil {
-		return err
-	}
-
-	ref.Object.SHA = newCommit.SHA
-	_, _, err = o.GitClient.UpdateRef(ctx, o.owner, o.repo, ref, false)
-	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
+	return newCommit, nil
 }
 
 func (o *gitHubRepositoryManager) destination() string {
@@ -18,14 +18,24 @@ package helm
 
 import (
 	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
+	"net/http"
 	"os"
+	"slices"
+	"sort"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/google/go-github/v35/github"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/oauth2"
+	helmrepo "helm.sh/helm/v4/pkg/repo/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/yaml"
 
 	"github.com/cert-manager/release/pkg/release/manifests"
 )
@@ -98,5 +108,468 @@ func TestIntegration(t *testing.T) {
 			config["HELM_GITHUB_REPO"],
 		)
 		require.Regexp(t, expectedURLPattern, prURL)
+
+		closed, err := r.Unpublish(ctx, fakeReleaseName)
+		require.NoError(t, err)
+		require.Equal(t, []string{prURL}, closed)
 	})
 }
+
+// fakeGitHub is an in-memory GitHub repository implementing the clients used
+// by gitHubRepositoryManager. Objects are addressed by a hash of their
+// content, as they are in git, so that identical trees have identical SHAs.
+type fakeGitHub struct {
+	owner string
+	repo  string
+
+	blobs   map[string][]byte
+	trees   map[string]map[string]string
+	commits map[string]fakeCommit
+	refs    map[string]string
+	prs     []*github.PullRequest
+}
+
+type fakeCommit struct {
+	tree    string
+	parents []string
+	message string
+}
+
+// newFakeGitHub returns a fake repository with a single commit on the given
+// branch, containing the given files
+func newFakeGitHub(t *testing.T, owner, repo, branch string, files map[string]string) *fakeGitHub {
+	t.Helper()
+	f := &fakeGitHub{
+		owner:   owner,
+		repo:    repo,
+		blobs:   map[string][]byte{},
+		trees:   map[string]map[string]string{},
+		commits: map[string]fakeCommit{},
+		refs:    map[string]string{},
+	}
+
+	tree := map[string]string{}
+	for path, content := range files {
+		tree[path] = f.storeBlob([]byte(content))
+	}
+
+	f.refs["refs/heads/"+branch] = f.storeCommit(fakeCommit{tree: f.storeTree(tree), message: "Initial commit"})
+	return f
+}
+
+func (f *fakeGitHub) client() *GitHubClient {
+	return &GitHubClient{
+		GitClient:          f,
+		PullRequestClient:  f,
+		RepositoriesClient: f,
+		UsersClient:        f,
+	}
+}
+
+func fakeHash(kind string, parts ...string) string {
+	h := sha1.Sum([]byte(kind + "\x00" + strings.Join(parts, "\x00")))
+	return hex.EncodeToString(h[:])
+}
+
+func (f *fakeGitHub) storeBlob(content []byte) string {
+	sha := fakeHash("blob", string(content))
+	f.blobs[sha] = content
+	return sha
+}
+
+func (f *fakeGitHub) storeTree(tree map[string]string) string {
+	var parts []string
+	for path, sha := range tree {
+		parts = append(parts, path+"="+sha)
+	}
+	sort.Strings(parts)
+
+	sha := fakeHash("tree", parts...)
+	f.trees[sha] = tree
+	return sha
+}
+
+func (f *fakeGitHub) storeCommit(commit fakeCommit) string {
+	sha := fakeHash("commit", append([]string{commit.tree, commit.message}, commit.parents...)...)
+	f.commits[sha] = commit
+	return sha
+}
+
+// branchCommit returns the commit at the head of the given branch
+func (f *fakeGitHub) branchCommit(t *testing.T, branch string) fakeCommit {
+	t.Helper()
+	sha, ok := f.refs["refs/heads/"+branch]
+	require.True(t, ok, "branch %q not found", branch)
+	return f.commits[sha]
+}
+
+// file returns the content of the file at the head of the given branch
+func (f *fakeGitHub) file(t *testing.T, branch, path string) []byte {
+	t.Helper()
+	sha, ok := f.trees[f.branchCommit(t, branch).tree][path]
+	require.True(t, ok, "file %q not found in branch %q", path, branch)
+	return f.blobs[sha]
+}
+
+func (f *fakeGitHub) checkRepo(owner, repo string) error {
+	if owner != f.owner || repo != f.repo {
+		return fakeErrorResponse(http.StatusNotFound)
+	}
+	return nil
+}
+
+func fakeErrorResponse(statusCode int) error {
+	return &github.ErrorResponse{
+		Response: &http.Response{StatusCode: statusCode, Request: &http.Request{Method: http.MethodGet}},
+		Message:  http.StatusText(statusCode),
+	}
+}
+
+func (f *fakeGitHub) GetRef(ctx context.Context, owner string, repo string, ref string) (*github.Reference, *github.Response, error) {
+	if err := f.checkRepo(owner, repo); err != nil {
+		return nil, nil, err
+	}
+	sha, ok := f.refs[ref]
+	if !ok {
+		return nil, nil, fakeErrorResponse(http.StatusNotFound)
+	}
+	return &github.Reference{Ref: ptr.To(ref), Object: &github.GitObject{SHA: ptr.To(sha)}}, nil, nil
+}
+
+func (f *fakeGitHub) CreateRef(ctx context.Context, owner string, repo string, ref *github.Reference) (*github.Reference, *github.Response, error) {
+	if err := f.checkRepo(owner, repo); err != nil {
+		return nil, nil, err
+	}
+	if _, exists := f.refs[ref.GetRef()]; exists {
+		return nil, nil, fakeErrorResponse(http.StatusUnprocessableEntity)
+	}
+	f.refs[ref.GetRef()] = ref.GetObject().GetSHA()
+	return ref, nil, nil
+}
+
+func (f *fakeGitHub) CreateTree(ctx context.Context, owner string, repo string, baseTree string, entries []*github.TreeEntry) (*github.Tree, *github.Response, error) {
+	if err := f.checkRepo(owner, repo); err != nil {
+		return nil, nil, err
+	}
+	if commit, ok := f.commits[baseTree]; ok {
+		baseTree = commit.tree
+	}
+	base, ok := f.trees[baseTree]
+	if !ok {
+		return nil, nil, fakeErrorResponse(http.StatusUnprocessableEntity)
+	}
+
+	tree := map[string]string{}
+	for path, sha := range base {
+		tree[path] = sha
+	}
+	for _, entry := range entries {
+		sha := entry.GetSHA()
+		if entry.Content != nil {
+			sha = f.storeBlob([]byte(entry.GetContent()))
+		}
+		if _, ok := f.blobs[sha]; !ok {
+			return nil, nil, fakeErrorResponse(http.StatusUnprocessableEntity)
+		}
+		tree[entry.GetPath()] = sha
+	}
+
+	return &github.Tree{SHA: ptr.To(f.storeTree(tree))}, nil, nil
+}
+
+func (f *fakeGitHub) CreateCommit(ctx context.Context, owner string, repo string, commit *github.Commit) (*github.Commit, *github.Response, error) {
+	if err := f.checkRepo(owner, repo); err != nil {
+		return nil, nil, err
+	}
+	c := fakeCommit{tree: commit.GetTree().GetSHA(), message: commit.GetMessage()}
+	for _, parent := range commit.Parents {
+		c.parents = append(c.parents, parent.GetSHA())
+	}
+	return &github.Commit{SHA: ptr.To(f.storeCommit(c))}, nil, nil
+}
+
+func (f *fakeGitHub) UpdateRef(ctx context.Context, owner string, repo string, ref *github.Reference, force bool) (*github.Reference, *github.Response, error) {
+	if err := f.checkRepo(owner, repo); err != nil {
+		return nil, nil, err
+	}
+	current, ok := f.refs[ref.GetRef()]
+	if !ok {
+		return nil, nil, fakeErrorResponse(http.StatusUnprocessableEntity)
+	}
+	newSHA := ref.GetObject().GetSHA()
+	if !force && !slices.Contains(f.commits[newSHA].parents, current) {
+		return nil, nil, fakeErrorResponse(http.StatusUnprocessableEntity)
+	}
+	f.refs[ref.GetRef()] = newSHA
+	return ref, nil, nil
+}
+
+func (f *fakeGitHub) CreateBlob(ctx context.Context, owner string, repo string, blob *github.Blob) (*github.Blob, *github.Response, error) {
+	if err := f.checkRepo(owner, repo); err != nil {
+		return nil, nil, err
+	}
+	content, err := base64.StdEncoding.DecodeString(blob.GetContent())
+	if err != nil {
+		return nil, nil, err
+	}
+	return &github.Blob{SHA: ptr.To(f.storeBlob(content))}, nil, nil
+}
+
+func (f *fakeGitHub) GetBlobRaw(ctx context.Context, owner, repo, sha string) ([]byte, *github.Response, error) {
+	if err := f.checkRepo(owner, repo); err != nil {
+		return nil, nil, err
+	}
+	content, ok := f.blobs[sha]
+	if !ok {
+		return nil, nil, fakeErrorResponse(http.StatusNotFound)
+	}
+	return content, nil, nil
+}
+
+func (f *fakeGitHub) DeleteRef(ctx context.Context, owner string, repo string, ref string) (*github.Response, error) {
+	if err := f.checkRepo(owner, repo); err != nil {
+		return nil, err
+	}
+	if _, ok := f.refs["refs/"+ref]; !ok {
+		return nil, fakeErrorResponse(http.StatusUnprocessableEntity)
+	}
+	delete(f.refs, "refs/"+ref)
+	return nil, nil
+}
+
+func (f *fakeGitHub) GetPermissionLevel(ctx context.Context, owner, repo, user string) (*github.RepositoryPermissionLevel, *github.Response, error) {
+	if err := f.checkRepo(owner, repo); err != nil {
+		return nil, nil, err
+	}
+	return &github.RepositoryPermissionLevel{Permission: ptr.To("write")}, nil, nil
+}
+
+func (f *fakeGitHub) GetCommit(ctx context.Context, owner, repo, sha string) (*github.RepositoryCommit, *github.Response, error) {
+	if err := f.checkRepo(owner, repo); err != nil {
+		return nil, nil, err
+	}
+	commit, ok := f.commits[sha]
+	if !ok {
+		return nil, nil, fakeErrorResponse(http.StatusNotFound)
+	}
+	return &github.RepositoryCommit{
+		SHA:    ptr.To(sha),
+		Commit: &github.Commit{Tree: &github.Tree{SHA: ptr.To(commit.tree)}, Message: ptr.To(commit.message)},
+	}, nil, nil
+}
+
+// GetContents only supports listing the root directory of a branch
+func (f *fakeGitHub) GetContents(ctx context.Context, owner, repo, path string, opts *github.RepositoryContentGetOptions) (*github.RepositoryContent, []*github.RepositoryContent, *github.Response, error) {
+	if err := f.checkRepo(owner, repo); err != nil {
+		return nil, nil, nil, err
+	}
+	if path != "" {
+		return nil, nil, nil, fmt.Errorf("fakeGitHub only supports listing the root directory, got %q", path)
+	}
+	sha, ok := f.refs["refs/heads/"+opts.Ref]
+	if !ok {
+		return nil, nil, nil, fakeErrorResponse(http.StatusNotFound)
+	}
+
+	var contents []*github.RepositoryContent
+	dirs := map[string]bool{}
+	for path, blobSHA := range f.trees[f.commits[sha].tree] {
+		if dir, _, isNested := strings.Cut(path, "/"); isNested {
+			if !dirs[dir] {
+				dirs[dir] = true
+				contents = append(contents, &github.RepositoryContent{Name: ptr.To(dir), Type: ptr.To("dir")})
+			}
+			continue
+		}
+		contents = append(contents, &github.RepositoryContent{Name: ptr.To(path), Type: ptr.To("file"), SHA: ptr.To(blobSHA)})
+	}
+	return nil, contents, nil, nil
+}
+
+func (f *fakeGitHub) Get(ctx context.Context, user string) (*github.User, *github.Response, error) {
+	return &github.User{Login: ptr.To("cmrel")}, &github.Response{Response: &http.Response{Header: http.Header{"X-Oauth-Scopes": []string{"repo"}}}}, nil
+}
+
+func (f *fakeGitHub) Create(ctx context.Context, owner string, repo string, pull *github.NewPullRequest) (*github.PullRequest, *github.Response, error) {
+	if err := f.checkRepo(owner, repo); err != nil {
+		return nil, nil, err
+	}
+	if _, ok := f.refs["refs/heads/"+pull.GetHead()]; !ok {
+		return nil, nil, fakeErrorResponse(http.StatusUnprocessableEntity)
+	}
+	number := len(f.prs) + 1
+	pr := &github.PullRequest{
+		Number:  ptr.To(number),
+		HTMLURL: ptr.To(fmt.Sprintf("https://github.com/%s/%s/pull/%d", owner, repo, number)),
+		State:   ptr.To("open"),
+		Title:   pull.Title,
+		Head:    &github.PullRequestBranch{Ref: pull.Head},
+		Base:    &github.PullRequestBranch{Ref: pull.Base},
+	}
+	f.prs = append(f.prs, pr)
+	return pr, nil, nil
+}
+
+func (f *fakeGitHub) List(ctx context.Context, owner string, repo string, opts *github.PullRequestListOptions) ([]*github.PullRequest, *github.Response, error) {
+	if err := f.checkRepo(owner, repo); err != nil {
+		return nil, nil, err
+	}
+	var prs []*github.PullRequest
+	for _, pr := range f.prs {
+		if pr.GetState() != opts.State || owner+":"+pr.GetHead().GetRef() != opts.Head || pr.GetBase().GetRef() != opts.Base {
+			continue
+		}
+		prs = append(prs, pr)
+	}
+	return prs, nil, nil
+}
+
+func (f *fakeGitHub) Edit(ctx context.Context, owner string, repo string, number int, pull *github.PullRequest) (*github.PullRequest, *github.Response, error) {
+	if err := f.checkRepo(owner, repo); err != nil {
+		return nil, nil, err
+	}
+	if number < 1 || number > len(f.prs) {
+		return nil, nil, fakeErrorResponse(http.StatusNotFound)
+	}
+	if pull.State != nil {
+		f.prs[number-1].State = pull.State
+	}
+	return f.prs[number-1], nil, nil
+}
+
+func TestGitHubRepositoryManagerPublish(t *testing.T) {
+	ctx := context.Background()
+
+	const (
+		owner       = "jetstack"
+		repo        = "jetstack-charts"
+		branch      = "main"
+		releaseName = "v0.1.0-test.1-0123456789abcdef"
+	)
+
+	existingIndex := `apiVersion: v1
+entries:
+  trust-manager:
+  - apiVersion: v2
+    created: "2020-01-01T00:00:00Z"
+    digest: "2222"
+    name: trust-manager
+    urls:
+    - charts/trust-manager-v0.1.0.tgz
+    version: v0.1.0
+generated: "2020-01-01T00:00:00Z"
+`
+
+	tests := map[string]struct {
+		// setup is run after the chart has been published once, to simulate
+		// the state left behind by a previous failed or repeated run
+		setup func(t *testing.T, f *fakeGitHub)
+
+		expectBranchChanged bool
+		expectPRNumber      int
+	}{
+		"publishing again reuses the branch and PR": {
+			setup:          func(t *testing.T, f *fakeGitHub) {},
+			expectPRNumber: 1,
+		},
+		"a branch left without a PR gets a PR": {
+			setup: func(t *testing.T, f *fakeGitHub) {
+				f.prs = nil
+			},
+			expectPRNumber: 1,
+		},
+		"a branch with different files is reset": {
+			setup: func(t *testing.T, f *fakeGitHub) {
+				head := f.branchCommit(t, releaseName)
+				tree := map[string]string{}
+				for path, sha := range f.trees[head.tree] {
+					tree[path] = sha
+				}
+				tree["charts/cert-manager-v0.1.0-test.1.tgz"] = f.storeBlob([]byte("partial upload"))
+				f.refs["refs/heads/"+releaseName] = f.storeCommit(fakeCommit{tree: f.storeTree(tree), parents: []string{f.refs["refs/heads/"+releaseName]}, message: "Partial upload"})
+			},
+			expectBranchChanged: true,
+			expectPRNumber:      1,
+		},
+		"a branch based on an old commit is reset": {
+			setup: func(t *testing.T, f *fakeGitHub) {
+				base := f.branchCommit(t, branch)
+				tree := map[string]string{}
+				for path, sha := range f.trees[base.tree] {
+					tree[path] = sha
+				}
+				tree["README.md"] = f.storeBlob([]byte("updated"))
+				f.refs["refs/heads/"+branch] = f.storeCommit(fakeCommit{tree: f.storeTree(tree), parents: []string{f.refs["refs/heads/"+branch]}, message: "Update README"})
+			},
+			expectBranchChanged: true,
+			expectPRNumber:      1,
+		},
+		"a closed PR is replaced": {
+			setup: func(t *testing.T, f *fakeGitHub) {
+				f.prs[0].State = ptr.To("closed")
+			},
+			expectPRNumber: 2,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			f := newFakeGitHub(t, owner, repo, branch, map[string]string{
+				"README.md":  "charts",
+				"index.yaml": existingIndex,
+			})
+			r := NewGitHubRepositoryManager(f.client(), owner, repo, branch)
+			require.NoError(t, r.Check(ctx))
+
+			chart := copyTestChart(t, "-----BEGIN PGP SIGNED MESSAGE-----\n")
+
+			prURL, err := r.Publish(ctx, releaseName, *chart)
+			require.NoError(t, err)
+			require.Equal(t, "https://github.com/jetstack/jetstack-charts/pull/1", prURL)
+
+			// the charts and index are added in a single commit on top of the base branch
+			head := f.branchCommit(t, releaseName)
+			require.Equal(t, []string{f.refs["refs/heads/"+branch]}, head.parents)
+
+			chartData, err := os.ReadFile(chart.Path())
+			require.NoError(t, err)
+			require.Equal(t, chartData, f.file(t, releaseName, "charts/cert-manager-v0.1.0-test.1.tgz"))
+			require.Equal(t, "-----BEGIN PGP SIGNED MESSAGE-----\n", string(f.file(t, releaseName, "charts/cert-manager-v0.1.0-test.1.tgz.prov")))
+
+			index := &helmrepo.IndexFile{}
+			require.NoError(t, yaml.Unmarshal(f.file(t, releaseName, "index.yaml"), index))
+			require.Len(t, index.Entries["trust-manager"], 1)
+			require.Len(t, index.Entries["cert-manager"], 1)
+			require.Equal(t, []string{"charts/cert-manager-v0.1.0-test.1.tgz"}, index.Entries["cert-manager"][0].URLs)
+
+			test.setup(t, f)
+			before := f.refs["refs/heads/"+releaseName]
+
+			prURL, err = r.Publish(ctx, releaseName, *chart)
+			require.NoError(t, err)
+			require.Equal(t, fmt.Sprintf("https://github.com/jetstack/jetstack-charts/pull/%d", test.expectPRNumber), prURL)
+			require.Len(t, f.prs, test.expectPRNumber)
+
+			after := f.refs["refs/heads/"+releaseName]
+			if test.expectBranchChanged {
+				require.NotEqual(t, before, after, "expected branch to be reset")
+			} else {
+				require.Equal(t, before, after, "expected branch to be left unchanged")
+			}
+
+			// either way, the branch holds a single commit on top of the base branch
+			require.Equal(t, []string{f.refs["refs/heads/"+branch]}, f.branchCommit(t, releaseName).parents)
+			require.Equal(t, chartData, f.file(t, releaseName, "charts/cert-manager-v0.1.0-test.1.tgz"))
+
+			// the creation time of the chart is kept when the branch is reused or reset
+			reindexed := &helmrepo.IndexFile{}
+			require.NoError(t, yaml.Unmarshal(f.file(t, releaseName, "index.yaml"), reindexed))
+			require.True(t, index.Entries["cert-manager"][0].Created.Equal(reindexed.Entries["cert-manager"][0].Created))
+
+			closed, err := r.Unpublish(ctx, releaseName)
+			require.NoError(t, err)
+			require.Equal(t, []string{prURL}, closed)
+			require.NotContains(t, f.refs, "refs/heads/"+releaseName)
+		})
+	}
+}
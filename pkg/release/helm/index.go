@@ -0,0 +1,159 @@
+/*
+Copyright 2021 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helm
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	helmchart "helm.sh/helm/v4/pkg/chart/v2"
+	helmloader "helm.sh/helm/v4/pkg/chart/v2/loader"
+	helmrepo "helm.sh/helm/v4/pkg/repo/v1"
+	"sigs.k8s.io/yaml"
+
+	"github.com/cert-manager/release/pkg/release/manifests"
+)
+
+const (
+	// indexFileName is the path of the Helm repository index in the charts
+	// repository
+	indexFileName = "index.yaml"
+
+	// chartsDir is the directory in the charts repository which charts are
+	// committed to; index entries refer to charts relative to the index
+	chartsDir = "charts"
+)
+
+// newIndexChartVersion returns the index entry for the given chart, which is
+// committed to the charts directory of the repository.
+func newIndexChartVersion(chart manifests.Chart, created time.Time) (*helmrepo.ChartVersion, error) {
+	loaded, err := helmloader.LoadFile(chart.Path())
+	if err != nil {
+		return nil, fmt.Errorf("failed to load chart %q: %w", chart.Path(), err)
+	}
+
+	chartData, err := os.ReadFile(chart.Path())
+	if err != nil {
+		return nil, err
+	}
+
+	digest := sha256.Sum256(chartData)
+
+	return &helmrepo.ChartVersion{
+		Metadata: loaded.Metadata,
+		URLs:     []string{chartsDir + "/" + chart.PackageFileName()},
+		Created:  created,
+		Digest:   hex.EncodeToString(digest[:]),
+	}, nil
+}
+
+// parseIndex parses a Helm repository index. Unlike helmrepo.LoadIndexFile,
+// entries which helm considers invalid are kept, so that rewriting an index
+// doesn't drop them; only empty entries are removed.
+func parseIndex(data []byte) (*helmrepo.IndexFile, error) {
+	index := &helmrepo.IndexFile{}
+	if err := yaml.Unmarshal(data, index); err != nil {
+		return nil, err
+	}
+
+	if index.Entries == nil {
+		index.Entries = map[string]helmrepo.ChartVersions{}
+	}
+
+	for name, entries := range index.Entries {
+		entries = slices.DeleteFunc(entries, func(entry *helmrepo.ChartVersion) bool { return entry == nil })
+		for _, entry := range entries {
+			if entry.Metadata == nil {
+				entry.Metadata = &helmchart.Metadata{}
+			}
+		}
+		index.Entries[name] = entries
+	}
+
+	return index, nil
+}
+
+// mergeIndex parses the existing index, which may be empty if the repository
+// has no index yet, and adds the given chart versions to it. Existing entries
+// for the same chart name and version are replaced. The updated index is
+// returned in YAML form, with the versions of each chart sorted newest first
+// as 'helm repo index' does. The index's generation time is set to the latest
+// creation time of the added versions rather than the current time, so that
+// merging the same versions into the same index always gives the same result.
+func mergeIndex(existing []byte, versions ...*helmrepo.ChartVersion) ([]byte, error) {
+	index := &helmrepo.IndexFile{
+		APIVersion: helmrepo.APIVersionV1,
+		Entries:    map[string]helmrepo.ChartVersions{},
+	}
+
+	if len(existing) > 0 {
+		var err error
+		index, err = parseIndex(existing)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse existing %s: %w", indexFileName, err)
+		}
+
+		if index.APIVersion == "" {
+			return nil, fmt.Errorf("existing %s has no apiVersion", indexFileName)
+		}
+	}
+
+	// Merge keeps the entries it's called on, so the existing entries are
+	// merged into the added versions to replace any with the same version
+	added := &helmrepo.IndexFile{Entries: map[string]helmrepo.ChartVersions{}}
+	for _, version := range versions {
+		added.Entries[version.Name] = append(added.Entries[version.Name], version)
+	}
+
+	added.Merge(index)
+	index.Entries = added.Entries
+	index.SortEntries()
+
+	for _, version := range versions {
+		if version.Created.After(index.Generated) {
+			index.Generated = version.Created
+		}
+	}
+
+	return yaml.Marshal(index)
+}
+
+// previousCreationTime returns the creation time of the given chart version in
+// an index written by a previous run, if that index has an entry for the same
+// version of the chart with the same digest.
+func previousCreationTime(previous []byte, version *helmrepo.ChartVersion) (time.Time, bool) {
+	if len(previous) == 0 {
+		return time.Time{}, false
+	}
+
+	index, err := parseIndex(previous)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	for _, entry := range index.Entries[version.Name] {
+		if entry.Metadata != nil && entry.Version == version.Version && entry.Digest == version.Digest {
+			return entry.Created, true
+		}
+	}
+
+	return time.Time{}, false
+}
@@ -0,0 +1,180 @@
+/*
+Copyright 2021 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	helmchart "helm.sh/helm/v4/pkg/chart/v2"
+	helmrepo "helm.sh/helm/v4/pkg/repo/v1"
+	"sigs.k8s.io/yaml"
+)
+
+func TestMergeIndex(t *testing.T) {
+	generated := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	chart := copyTestChart(t, "")
+	newVersion, err := newIndexChartVersion(*chart, generated)
+	require.NoError(t, err)
+
+	require.Equal(t, []string{"charts/cert-manager-v0.1.0-test.1.tgz"}, newVersion.URLs)
+	require.Equal(t, chart.AppVersion(), newVersion.AppVersion)
+	require.Len(t, newVersion.Digest, 64)
+
+	const existingIndex = `apiVersion: v1
+entries:
+  cert-manager:
+  - apiVersion: v1
+    appVersion: v0.0.1
+    created: "2020-01-01T00:00:00Z"
+    digest: 0000
+    name: cert-manager
+    urls:
+    - https://charts.jetstack.io/charts/cert-manager-v0.0.1.tgz
+    version: v0.0.1
+  - apiVersion: v1
+    appVersion: v0.1.0-test.1
+    created: "2020-01-01T00:00:00Z"
+    digest: 1111
+    name: cert-manager
+    urls:
+    - charts/cert-manager-v0.1.0-test.1.tgz
+    version: v0.1.0-test.1
+  - apiVersion: v1
+    name: cert-manager
+    urls:
+    - charts/cert-manager-latest.tgz
+    version: latest
+  trust-manager:
+  - apiVersion: v2
+    appVersion: v0.1.0
+    created: "2020-01-01T00:00:00Z"
+    digest: 2222
+    name: trust-manager
+    urls:
+    - https://charts.jetstack.io/charts/trust-manager-v0.1.0.tgz
+    version: v0.1.0
+generated: "2020-01-01T00:00:00Z"
+`
+
+	const existingIndexWithMetadata = `annotations:
+  charts.example.com/owner: jetstack
+apiVersion: v1
+entries: {}
+generated: "2020-01-01T00:00:00Z"
+publicKeys:
+- example-key
+serverInfo:
+  contextPath: /charts
+`
+
+	tests := map[string]struct {
+		existing string
+		versions []*helmrepo.ChartVersion
+
+		expectedVersions    map[string][]string
+		expectedAnnotations map[string]string
+		expectedServerInfo  map[string]interface{}
+		expectedPublicKeys  []string
+		expectedErr         string
+	}{
+		"new index": {
+			versions: []*helmrepo.ChartVersion{newVersion},
+			expectedVersions: map[string][]string{
+				"cert-manager": {"v0.1.0-test.1"},
+			},
+		},
+		"existing entries are kept and the new version replaces the old": {
+			existing: existingIndex,
+			versions: []*helmrepo.ChartVersion{newVersion},
+			expectedVersions: map[string][]string{
+				"cert-manager":  {"v0.1.0-test.1", "v0.0.1", "latest"},
+				"trust-manager": {"v0.1.0"},
+			},
+		},
+		"versions are sorted newest first": {
+			existing: existingIndex,
+			versions: []*helmrepo.ChartVersion{
+				newVersion,
+				{Metadata: &helmchart.Metadata{APIVersion: "v1", Name: "cert-manager", Version: "v1.0.0"}, URLs: []string{"charts/cert-manager-v1.0.0.tgz"}, Created: generated.Add(-time.Hour)},
+			},
+			expectedVersions: map[string][]string{
+				"cert-manager":  {"v1.0.0", "v0.1.0-test.1", "v0.0.1", "latest"},
+				"trust-manager": {"v0.1.0"},
+			},
+		},
+		"index fields which aren't entries are kept": {
+			existing: existingIndexWithMetadata,
+			versions: []*helmrepo.ChartVersion{newVersion},
+			expectedVersions: map[string][]string{
+				"cert-manager": {"v0.1.0-test.1"},
+			},
+			expectedAnnotations: map[string]string{"charts.example.com/owner": "jetstack"},
+			expectedServerInfo:  map[string]interface{}{"contextPath": "/charts"},
+			expectedPublicKeys:  []string{"example-key"},
+		},
+		"invalid existing index": {
+			existing:    "entries: [",
+			versions:    []*helmrepo.ChartVersion{newVersion},
+			expectedErr: "failed to parse existing index.yaml",
+		},
+		"existing index without an apiVersion": {
+			existing:    "entries: {}\n",
+			versions:    []*helmrepo.ChartVersion{newVersion},
+			expectedErr: "existing index.yaml has no apiVersion",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			merged, err := mergeIndex([]byte(test.existing), test.versions...)
+			if test.expectedErr != "" {
+				require.ErrorContains(t, err, test.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+
+			index := &helmrepo.IndexFile{}
+			require.NoError(t, yaml.Unmarshal(merged, index))
+
+			require.Equal(t, "v1", index.APIVersion)
+			require.True(t, index.Generated.Equal(generated), "expected generated time %v, got %v", generated, index.Generated)
+
+			versions := map[string][]string{}
+			for name, entries := range index.Entries {
+				for _, entry := range entries {
+					versions[name] = append(versions[name], entry.Version)
+				}
+			}
+			require.Equal(t, test.expectedVersions, versions)
+			require.Equal(t, test.expectedAnnotations, index.Annotations)
+			require.Equal(t, test.expectedServerInfo, index.ServerInfo)
+			require.Equal(t, test.expectedPublicKeys, index.PublicKeys)
+
+			for _, entry := range index.Entries["cert-manager"] {
+				if entry.Version != newVersion.Version {
+					continue
+				}
+				require.Equal(t, newVersion.Digest, entry.Digest)
+				require.Equal(t, newVersion.URLs, entry.URLs)
+				require.True(t, entry.Created.Equal(generated), "expected created time %v, got %v", generated, entry.Created)
+			}
+		})
+	}
+}
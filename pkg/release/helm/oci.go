@@ -0,0 +1,285 @@
+/*
+Copyright 2021 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	helmloader "helm.sh/helm/v4/pkg/chart/v2/loader"
+
+	"github.com/cert-manager/release/pkg/release/manifests"
+)
+
+// Media types of the parts of a Helm chart stored in an OCI registry, as
+// defined by Helm; see https://helm.sh/docs/topics/registries/#helm-chart-manifest
+const (
+	ChartConfigMediaType     types.MediaType = "application/vnd.cncf.helm.config.v1+json"
+	ChartContentMediaType    types.MediaType = "application/vnd.cncf.helm.chart.content.v1.tar+gzip"
+	ChartProvenanceMediaType types.MediaType = "application/vnd.cncf.helm.chart.provenance.v1.prov"
+)
+
+// OCIRepositoryManager pushes Helm charts to an OCI registry, in the same
+// format as 'helm push'. Each chart is pushed to
+// <repository>/<chart name>:<chart version>, along with its provenance file
+// if it has one. It isn't a RepositoryManager, since charts are tagged by
+// their version rather than the release name and so are removed by deleting
+// their tags from the registry rather than by release name.
+type OCIRepositoryManager struct {
+	repository name.Repository
+
+	keychain  authn.Keychain
+	transport http.RoundTripper
+}
+
+// NewOCIRepositoryManager returns an OCIRepositoryManager for the given
+// repository, which may have an "oci://" prefix, e.g.
+// "oci://quay.io/jetstack/charts". Credentials are read from the default
+// keychain (i.e. the Docker config file).
+func NewOCIRepositoryManager(repository string) (*OCIRepositoryManager, error) {
+	repo, err := name.NewRepository(strings.TrimPrefix(repository, "oci://"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid OCI repository for Helm charts %q: %w", repository, err)
+	}
+
+	return &OCIRepositoryManager{
+		repository: repo,
+		keychain:   authn.DefaultKeychain,
+		transport:  remote.DefaultTransport,
+	}, nil
+}
+
+// WithTransport makes requests to the registry using the given transport,
+// for example to rate limit them.
+func (o *OCIRepositoryManager) WithTransport(transport http.RoundTripper) *OCIRepositoryManager {
+	o.transport = transport
+	return o
+}
+
+// OCIChart is a Helm chart in the format it is pushed to an OCI registry
+type OCIChart struct {
+	// Reference is the tag which the chart is pushed under, without an
+	// "oci://" prefix; this is the form used by cosign
+	Reference string
+
+	// Digest is the digest of the chart's manifest
+	Digest string
+
+	tag      name.Tag
+	manifest []byte
+	blobs    []v1.Layer
+}
+
+// URL returns the reference to the chart in the form used by helm, e.g.
+// "oci://quay.io/jetstack/charts/cert-manager:v1.15.0"
+func (c *OCIChart) URL() string {
+	return "oci://" + c.Reference
+}
+
+// Check authenticates against the registry with permission to push to the
+// repository, which fails if the registry can't be reached or rejects the
+// credentials.
+func (o *OCIRepositoryManager) Check(ctx context.Context) error {
+	auth, err := o.keychain.Resolve(o.repository)
+	if err != nil {
+		return fmt.Errorf("failed to find credentials for %q: %w", o.repository, err)
+	}
+
+	scopes := []string{o.repository.Scope(transport.PushScope)}
+	if _, err := transport.NewWithContext(ctx, o.repository.Registry, auth, o.transport, scopes); err != nil {
+		return fmt.Errorf("failed to authenticate to %q: %w", o.repository, err)
+	}
+
+	return nil
+}
+
+// Publish builds and pushes the given charts, returning the URLs of the
+// pushed charts separated by commas.
+func (o *OCIRepositoryManager) Publish(ctx context.Context, releaseName string, charts ...manifests.Chart) (string, error) {
+	log.Printf("Pushing Helm charts of %q to %q", releaseName, o.repository)
+
+	var urls []string
+	for _, chart := range charts {
+		ociChart, err := o.Build(chart)
+		if err != nil {
+			return "", err
+		}
+
+		if err := o.Push(ctx, ociChart); err != nil {
+			return "", err
+		}
+
+		urls = append(urls, ociChart.URL())
+	}
+
+	return strings.Join(urls, ","), nil
+}
+
+// Build creates the OCI manifest for the given chart without pushing
+// anything, so that its digest is known ahead of time. The chart's metadata is
+// stored in the manifest's config, as it is by 'helm push', and its provenance
+// file as a layer after the chart itself.
+func (o *OCIRepositoryManager) Build(chart manifests.Chart) (*OCIChart, error) {
+	loaded, err := helmloader.LoadFile(chart.Path())
+	if err != nil {
+		return nil, fmt.Errorf("failed to load chart %q: %w", chart.Path(), err)
+	}
+
+	configData, err := json.Marshal(loaded.Metadata)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal chart metadata for %q: %w", chart.Path(), err)
+	}
+
+	chartData, err := os.ReadFile(chart.Path())
+	if err != nil {
+		return nil, err
+	}
+
+	config := static.NewLayer(configData, ChartConfigMediaType)
+	layers := []v1.Layer{static.NewLayer(chartData, ChartContentMediaType)}
+
+	if provPath := chart.ProvPath(); provPath != nil {
+		provData, err := os.ReadFile(*provPath)
+		if err != nil {
+			return nil, err
+		}
+
+		layers = append(layers, static.NewLayer(provData, ChartProvenanceMediaType))
+	}
+
+	configDescriptor, err := descriptor(config, ChartConfigMediaType)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := v1.Manifest{
+		SchemaVersion: 2,
+		MediaType:     types.OCIManifestSchema1,
+		Config:        *configDescriptor,
+		// no creation time is set, so that the digest only depends on the chart
+		Annotations: map[string]string{
+			"org.opencontainers.image.title":   loaded.Metadata.Name,
+			"org.opencontainers.image.version": loaded.Metadata.Version,
+		},
+	}
+
+	if loaded.Metadata.Description != "" {
+		manifest.Annotations["org.opencontainers.image.description"] = loaded.Metadata.Description
+	}
+
+	for _, layer := range layers {
+		mediaType, err := layer.MediaType()
+		if err != nil {
+			return nil, err
+		}
+
+		layerDescriptor, err := descriptor(layer, mediaType)
+		if err != nil {
+			return nil, err
+		}
+
+		manifest.Layers = append(manifest.Layers, *layerDescriptor)
+	}
+
+	rawManifest, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal manifest for chart %q: %w", chart.Path(), err)
+	}
+
+	digest, _, err := v1.SHA256(bytes.NewReader(rawManifest))
+	if err != nil {
+		return nil, err
+	}
+
+	// OCI tags can't contain '+', so helm replaces it with '_' in chart versions
+	tag := o.repository.Repo(o.repository.RepositoryStr(), loaded.Metadata.Name).Tag(strings.ReplaceAll(loaded.Metadata.Version, "+", "_"))
+
+	return &OCIChart{
+		Reference: tag.String(),
+		Digest:    digest.String(),
+		tag:       tag,
+		manifest:  rawManifest,
+		blobs:     append([]v1.Layer{config}, layers...),
+	}, nil
+}
+
+// Push pushes a chart created by Build to the registry
+func (o *OCIRepositoryManager) Push(ctx context.Context, chart *OCIChart) error {
+	options := o.remoteOptions(ctx)
+
+	for _, blob := range chart.blobs {
+		if err := remote.WriteLayer(chart.tag.Context(), blob, options...); err != nil {
+			return fmt.Errorf("failed to push chart %q: %w", chart.Reference, err)
+		}
+	}
+
+	log.Printf("Pushing Helm chart %q (%s)", chart.Reference, chart.Digest)
+	if err := remote.Put(chart.tag, &rawManifest{manifest: chart.manifest}, options...); err != nil {
+		return fmt.Errorf("failed to push manifest for chart %q: %w", chart.Reference, err)
+	}
+
+	return nil
+}
+
+func (o *OCIRepositoryManager) remoteOptions(ctx context.Context) []remote.Option {
+	return []remote.Option{
+		remote.WithContext(ctx),
+		remote.WithAuthFromKeychain(o.keychain),
+		remote.WithTransport(o.transport),
+	}
+}
+
+// descriptor returns the OCI descriptor for the given blob
+func descriptor(blob v1.Layer, mediaType types.MediaType) (*v1.Descriptor, error) {
+	digest, err := blob.Digest()
+	if err != nil {
+		return nil, err
+	}
+
+	size, err := blob.Size()
+	if err != nil {
+		return nil, err
+	}
+
+	return &v1.Descriptor{MediaType: mediaType, Size: size, Digest: digest}, nil
+}
+
+// rawManifest is a manifest which is pushed exactly as given; see remote.Put
+type rawManifest struct {
+	manifest []byte
+}
+
+func (m *rawManifest) RawManifest() ([]byte, error) {
+	return m.manifest, nil
+}
+
+func (m *rawManifest) MediaType() (types.MediaType, error) {
+	return types.OCIManifestSchema1, nil
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2021 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cert-manager/release/internal/testregistry"
+	"github.com/cert-manager/release/pkg/release/manifests"
+)
+
+// copyTestChart copies the test chart to a temporary directory, along with a
+// provenance file if prov is set, and returns it as a manifests.Chart.
+func copyTestChart(t *testing.T, prov string) *manifests.Chart {
+	t.Helper()
+	data, err := os.ReadFile("testdata/cert-manager-v0.1.0-test.1.tgz")
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "cert-manager-v0.1.0-test.1.tgz")
+	require.NoError(t, os.WriteFile(path, data, 0o644))
+
+	if prov != "" {
+		require.NoError(t, os.WriteFile(path+".prov", []byte(prov), 0o644))
+	}
+
+	chart, err := manifests.NewChart(path)
+	require.NoError(t, err)
+	return chart
+}
+
+func TestOCIRepositoryManager(t *testing.T) {
+	ctx := context.Background()
+
+	tests := map[string]struct {
+		prov               string
+		expectedMediaTypes []types.MediaType
+	}{
+		"chart with provenance": {
+			prov:               "-----BEGIN PGP SIGNED MESSAGE-----\n",
+			expectedMediaTypes: []types.MediaType{ChartContentMediaType, ChartProvenanceMediaType},
+		},
+		"chart without provenance": {
+			expectedMediaTypes: []types.MediaType{ChartContentMediaType},
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			host := testregistry.New(t)
+
+			m, err := NewOCIRepositoryManager("oci://" + host + "/jetstack/charts")
+			require.NoError(t, err)
+			require.NoError(t, m.Check(ctx))
+
+			chart := copyTestChart(t, test.prov)
+
+			ociChart, err := m.Build(*chart)
+			require.NoError(t, err)
+			require.Equal(t, host+"/jetstack/charts/cert-manager:v0.1.0-test.1", ociChart.Reference)
+			require.Equal(t, "oci://"+ociChart.Reference, ociChart.URL())
+
+			published, err := m.Publish(ctx, "v0.1.0-test.1-abcdef", *chart)
+			require.NoError(t, err)
+			require.Equal(t, ociChart.URL(), published)
+
+			ref, err := name.NewTag(ociChart.Reference)
+			require.NoError(t, err)
+
+			desc, err := remote.Get(ref)
+			require.NoError(t, err)
+			require.Equal(t, ociChart.Digest, desc.Digest.String(), "digest of pushed chart should be known before pushing")
+
+			manifest, err := v1.ParseManifest(bytes.NewReader(desc.Manifest))
+			require.NoError(t, err)
+			require.Equal(t, ChartConfigMediaType, manifest.Config.MediaType)
+
+			var mediaTypes []types.MediaType
+			for _, layer := range manifest.Layers {
+				mediaTypes = append(mediaTypes, layer.MediaType)
+			}
+			require.Equal(t, test.expectedMediaTypes, mediaTypes)
+
+			// helm reads the chart metadata from the config blob
+			config, err := remote.Layer(ref.Context().Digest(manifest.Config.Digest.String()))
+			require.NoError(t, err)
+			rc, err := config.Uncompressed()
+			require.NoError(t, err)
+			defer rc.Close()
+
+			var metadata struct {
+				Name    string `json:"name"`
+				Version string `json:"version"`
+			}
+			require.NoError(t, json.NewDecoder(rc).Decode(&metadata))
+			require.Equal(t, "cert-manager", metadata.Name)
+			require.Equal(t, "v0.1.0-test.1", metadata.Version)
+		})
+	}
+}
@@ -22,6 +22,9 @@ import (
 	"os"
 	"strings"
 
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+
 	"github.com/cert-manager/release/pkg/release/tar"
 )
 
@@ -45,6 +48,10 @@ type Tar struct {
 	// be different to the raw image name which was part of the release. This should
 	// be set after an image has been re-tagged and pushed.
 	PublishedTag string
+
+	// PublishedDigest is the digest of the image manifest which was pushed
+	// under PublishedTag. This should be set after an image has been pushed.
+	PublishedDigest string
 }
 
 func NewTar(path, osStr, arch string) (*Tar, error) {
@@ -113,3 +120,12 @@ func (i *Tar) ImageTag() string {
 	}
 	return s[1]
 }
+
+// Image returns the image stored in the tar file, read lazily from disk.
+func (i *Tar) Image() (v1.Image, error) {
+	img, err := tarball.ImageFromPath(i.path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image from %q: %w", i.path, err)
+	}
+	return img, nil
+}
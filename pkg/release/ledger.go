@@ -0,0 +1,183 @@
+/*
+Copyright 2021 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package release
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	// LedgerFileName is the name of the file, stored alongside metadata.json,
+	// which records each step of publishing a release as it completes.
+	LedgerFileName = "publish-ledger.json"
+)
+
+// LedgerEntryKind is the kind of published content a LedgerEntry refers to.
+type LedgerEntryKind string
+
+const (
+	LedgerEntryImage            LedgerEntryKind = "image"
+	LedgerEntryImageIndex       LedgerEntryKind = "imageindex"
+	LedgerEntryFloatingImageTag LedgerEntryKind = "floatingimagetag"
+	LedgerEntrySignature        LedgerEntryKind = "signature"
+	LedgerEntryAttestation      LedgerEntryKind = "attestation"
+	LedgerEntrySBOM             LedgerEntryKind = "sbom"
+	LedgerEntryGitHubRelease    LedgerEntryKind = "githubrelease"
+	LedgerEntryGitHubAsset      LedgerEntryKind = "githubasset"
+	LedgerEntryHelmChartPR      LedgerEntryKind = "helmchartpr"
+	LedgerEntryHelmChartOCI     LedgerEntryKind = "helmchartoci"
+)
+
+// LedgerEntry records a single completed publishing step.
+type LedgerEntry struct {
+	Kind LedgerEntryKind `json:"kind"`
+
+	// Name identifies the published content within its kind, e.g. an image
+	// tag or the name of a GitHub release asset.
+	Name string `json:"name"`
+
+	// Digest is the digest of the content which was published, e.g.
+	// "sha256:<hex>". It is used to verify that content is unchanged before
+	// skipping a step on a re-run.
+	Digest string `json:"digest,omitempty"`
+
+	// ID is the identifier of the published content in a remote system, if
+	// it has one, e.g. the ID of a GitHub release.
+	ID int64 `json:"id,omitempty"`
+
+	// URL is a link to the published content, if it has one.
+	URL string `json:"url,omitempty"`
+
+	// Keys are the signing keys which have signed or attested the content,
+	// for signature and attestation entries. While keys are being rotated,
+	// content is signed with more than one key.
+	Keys []string `json:"keys,omitempty"`
+
+	CompletedAt time.Time `json:"completedAt"`
+}
+
+// Ledger is a persisted record of the steps taken while publishing a release,
+// which allows a failed publish to be resumed without repeating completed
+// steps. Every change is written back to the ArtifactStore immediately.
+type Ledger struct {
+	store      ArtifactStore
+	objectName string
+
+	entries []LedgerEntry
+}
+
+// LoadLedger reads the ledger stored in the given object, returning an empty
+// ledger if the object does not yet exist.
+func LoadLedger(ctx context.Context, store ArtifactStore, objectName string) (*Ledger, error) {
+	l := &Ledger{store: store, objectName: objectName}
+
+	r, err := store.NewReader(ctx, objectName)
+	if errors.Is(err, ErrArtifactNotFound) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read publish ledger %q: %w", objectName, err)
+	}
+	defer r.Close()
+
+	if err := json.NewDecoder(r).Decode(&l.entries); err != nil {
+		return nil, fmt.Errorf("failed to decode publish ledger %q: %w", objectName, err)
+	}
+
+	return l, nil
+}
+
+// ObjectName returns the name of the object the ledger is persisted to.
+func (l *Ledger) ObjectName() string {
+	return l.objectName
+}
+
+// Entries returns every entry in the ledger, in the order they were recorded.
+func (l *Ledger) Entries() []LedgerEntry {
+	return append([]LedgerEntry(nil), l.entries...)
+}
+
+// Get returns the entry with the given kind and name, if one has been recorded.
+func (l *Ledger) Get(kind LedgerEntryKind, name string) (LedgerEntry, bool) {
+	for _, e := range l.entries {
+		if e.Kind == kind && e.Name == name {
+			return e, true
+		}
+	}
+	return LedgerEntry{}, false
+}
+
+// Record adds the given entry to the ledger, replacing any existing entry
+// with the same kind and name, and persists the ledger.
+func (l *Ledger) Record(ctx context.Context, entry LedgerEntry) error {
+	if entry.CompletedAt.IsZero() {
+		entry.CompletedAt = time.Now().UTC()
+	}
+
+	replaced := false
+	for i, e := range l.entries {
+		if e.Kind == entry.Kind && e.Name == entry.Name {
+			l.entries[i] = entry
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		l.entries = append(l.entries, entry)
+	}
+
+	return l.save(ctx)
+}
+
+// Remove deletes the entry with the given kind and name from the ledger, if
+// there is one, and persists the ledger.
+func (l *Ledger) Remove(ctx context.Context, kind LedgerEntryKind, name string) error {
+	for i, e := range l.entries {
+		if e.Kind == kind && e.Name == name {
+			l.entries = append(l.entries[:i], l.entries[i+1:]...)
+			return l.save(ctx)
+		}
+	}
+	return nil
+}
+
+func (l *Ledger) save(ctx context.Context) error {
+	data, err := json.MarshalIndent(l.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	w, err := l.store.NewWriter(ctx, l.objectName)
+	if err != nil {
+		return fmt.Errorf("failed to write publish ledger %q: %w", l.objectName, err)
+	}
+
+	if _, err := w.Write(data); err != nil {
+		w.Abort()
+		return fmt.Errorf("failed to write publish ledger %q: %w", l.objectName, err)
+	}
+
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to write publish ledger %q: %w", l.objectName, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,85 @@
+/*
+Copyright 2021 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package release
+
+import (
+	"context"
+	"testing"
+)
+
+func TestLedgerPersistsEntries(t *testing.T) {
+	ctx := context.TODO()
+
+	store, err := NewLocalArtifactStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	name := stageTestRelease(t, store, "v1.15.0", "amd64")
+	staged, err := NewBucket(store, DefaultBucketPathPrefix, BuildTypeRelease).GetRelease(ctx, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ledger, err := staged.Ledger(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ledger.Entries()) != 0 {
+		t.Fatalf("expected a new ledger to be empty, got %v", ledger.Entries())
+	}
+
+	if err := ledger.Record(ctx, LedgerEntry{Kind: LedgerEntryImage, Name: "example.com/a:v1", Digest: "sha256:aaaa"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ledger.Record(ctx, LedgerEntry{Kind: LedgerEntryGitHubRelease, Name: "v1.15.0", ID: 42}); err != nil {
+		t.Fatal(err)
+	}
+	// recording the same kind and name again replaces the earlier entry
+	if err := ledger.Record(ctx, LedgerEntry{Kind: LedgerEntryImage, Name: "example.com/a:v1", Digest: "sha256:bbbb"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the ledger is stored alongside metadata.json and must not stop the
+	// release from being loaded again
+	staged, err = NewBucket(store, DefaultBucketPathPrefix, BuildTypeRelease).GetRelease(ctx, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := staged.Ledger(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(reloaded.Entries()) != 2 {
+		t.Fatalf("expected 2 entries after reloading, got %v", reloaded.Entries())
+	}
+
+	image, ok := reloaded.Get(LedgerEntryImage, "example.com/a:v1")
+	if !ok || image.Digest != "sha256:bbbb" || image.CompletedAt.IsZero() {
+		t.Errorf("unexpected image entry: %#v", image)
+	}
+
+	if ghRelease, ok := reloaded.Get(LedgerEntryGitHubRelease, "v1.15.0"); !ok || ghRelease.ID != 42 {
+		t.Errorf("unexpected GitHub release entry: %#v", ghRelease)
+	}
+
+	if _, ok := reloaded.Get(LedgerEntryImageIndex, "example.com/a:v1"); ok {
+		t.Errorf("expected entries to be keyed by kind as well as name")
+	}
+}
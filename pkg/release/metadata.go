@@ -16,6 +16,8 @@ limitations under the License.
 
 package release
 
+import "time"
+
 // Metadata about a staged release.
 type Metadata struct {
 	// ReleaseVersion, if set, is an explicit version used to build the release
@@ -34,6 +36,34 @@ type Metadata struct {
 	// how they were produced. This is used as part of the migration from Bazel to
 	// Make. An empty BuildSource is assumed to mean Bazel produced the files.
 	BuildSource string `json:"buildSource,omitempty"`
+
+	// BuildInputs, if set, records the inputs which were used to build the
+	// release. It's used to generate provenance for published artifacts.
+	BuildInputs *BuildInputs `json:"buildInputs,omitempty"`
+
+	// SigningKeys are the keys which signed the Helm chart while the release
+	// was staged. Staged releases which weren't signed, or which were staged
+	// before signing keys were recorded, have no keys.
+	SigningKeys []string `json:"signingKeys,omitempty"`
+}
+
+// BuildInputs describes how a staged release was built.
+type BuildInputs struct {
+	// BuildID is the ID of the Google Cloud Build which built the release.
+	BuildID string `json:"buildID,omitempty"`
+
+	// BuilderImage is the container image in which the release was built.
+	BuilderImage string `json:"builderImage,omitempty"`
+
+	// Substitutions are the Cloud Build substitutions which were passed to
+	// the build.
+	Substitutions map[string]string `json:"substitutions,omitempty"`
+
+	// TargetPlatforms is the list of "os/arch" platforms which were built.
+	TargetPlatforms []string `json:"targetPlatforms,omitempty"`
+
+	StartedOn  time.Time `json:"startedOn"`
+	FinishedOn time.Time `json:"finishedOn"`
 }
 
 type ArtifactMetadata struct {
@@ -0,0 +1,97 @@
+/*
+Copyright 2021 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notes
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/google/go-github/v35/github"
+)
+
+// GitHubClient provides the minimum necessary GitHub API client methods
+// required to generate release notes.
+type GitHubClient struct {
+	RepositoriesClient
+	PullRequestClient
+}
+
+// NewGitHubClient returns a GitHubClient which uses the given GitHub API
+// client.
+func NewGitHubClient(client *github.Client) *GitHubClient {
+	return &GitHubClient{
+		RepositoriesClient: &repositoriesClient{client: client},
+		PullRequestClient:  client.PullRequests,
+	}
+}
+
+type RepositoriesClient interface {
+	// ListTags lists a page of the tags in a repository.
+	// GitHub API docs: https://docs.github.com/en/free-pro-team@latest/rest/reference/repos/#list-repository-tags
+	ListTags(ctx context.Context, owner string, repo string, opts *github.ListOptions) ([]*github.RepositoryTag, *github.Response, error)
+	// CompareCommits lists a page of the commits which are reachable from
+	// head but not from base.
+	// GitHub API docs: https://docs.github.com/en/free-pro-team@latest/rest/reference/repos/#compare-two-commits
+	CompareCommits(ctx context.Context, owner, repo, base, head string, opts *github.ListOptions) (*github.CommitsComparison, *github.Response, error)
+}
+
+type PullRequestClient interface {
+	// List lists a page of the pull requests in a repository.
+	// GitHub API docs: https://docs.github.com/en/free-pro-team@latest/rest/reference/pulls/#list-pull-requests
+	List(ctx context.Context, owner string, repo string, opts *github.PullRequestListOptions) ([]*github.PullRequest, *github.Response, error)
+}
+
+// repositoriesClient implements RepositoriesClient. The go-github
+// CompareCommits method doesn't support pagination, and so only ever returns
+// the first 250 commits of a comparison.
+type repositoriesClient struct {
+	client *github.Client
+}
+
+func (c *repositoriesClient) ListTags(ctx context.Context, owner string, repo string, opts *github.ListOptions) ([]*github.RepositoryTag, *github.Response, error) {
+	return c.client.Repositories.ListTags(ctx, owner, repo, opts)
+}
+
+func (c *repositoriesClient) CompareCommits(ctx context.Context, owner, repo, base, head string, opts *github.ListOptions) (*github.CommitsComparison, *github.Response, error) {
+	u := fmt.Sprintf("repos/%v/%v/compare/%v...%v", owner, repo, url.QueryEscape(base), url.QueryEscape(head))
+	if opts != nil {
+		query := url.Values{}
+		if opts.Page != 0 {
+			query.Set("page", fmt.Sprint(opts.Page))
+		}
+		if opts.PerPage != 0 {
+			query.Set("per_page", fmt.Sprint(opts.PerPage))
+		}
+		if len(query) > 0 {
+			u += "?" + query.Encode()
+		}
+	}
+
+	req, err := c.client.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	comp := new(github.CommitsComparison)
+	resp, err := c.client.Do(ctx, req, comp)
+	if err != nil {
+		return nil, resp, err
+	}
+
+	return comp, resp, nil
+}
@@ -0,0 +1,402 @@
+/*
+Copyright 2021 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notes
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/blang/semver"
+	"github.com/google/go-github/v35/github"
+)
+
+const (
+	// releaseNoneLabel marks a PR as having no user facing changes.
+	releaseNoneLabel = "release-note-none"
+
+	// uncategorized is the category of changes without a known kind label.
+	uncategorized = "Uncategorized"
+
+	perPage = 100
+)
+
+// categories maps each kind label to the category its changes are listed
+// under. Categories are listed in this order, and a change with more than one
+// kind label is listed under the first.
+var categories = []struct {
+	label string
+	title string
+}{
+	{label: "kind/api-change", title: "API Change"},
+	{label: "kind/deprecation", title: "Deprecation"},
+	{label: "kind/feature", title: "Feature"},
+	{label: "kind/bug", title: "Bug or Regression"},
+	{label: "kind/documentation", title: "Documentation"},
+	{label: "kind/cleanup", title: "Other (Cleanup or Flake)"},
+	{label: "kind/flake", title: "Other (Cleanup or Flake)"},
+}
+
+// releaseNoteBlock matches the fenced release-note block of a PR description.
+var releaseNoteBlock = regexp.MustCompile("(?s)```release-note[ \t]*\r?\n(.*?)```")
+
+// Notes are the release notes of a single release.
+type Notes struct {
+	// Version is the version being released.
+	Version string
+
+	// PreviousVersion is the tag which Changes are listed since.
+	PreviousVersion string
+
+	// Changes are the release notes of the PRs merged since PreviousVersion,
+	// ordered by PR number.
+	Changes []Change
+
+	// Images are the multi-arch images published with the release.
+	Images []Image
+
+	// Verification describes how the release can be verified.
+	Verification Verification
+}
+
+// Change is the release note of a single merged PR.
+type Change struct {
+	Category string
+	Note     string
+	Number   int
+	URL      string
+	Author   string
+}
+
+// Image is a published image and its digest.
+type Image struct {
+	Name   string
+	Digest string
+}
+
+// Verification lists the files and keys used to verify a release.
+type Verification struct {
+	// CosignKeys are the keys images are signed with, in the format accepted
+	// by "cosign verify --key". If empty, the release isn't signed.
+	CosignKeys []string
+
+	// ChecksumsFile is the release asset listing the checksum of every other
+	// asset.
+	ChecksumsFile string
+
+	// ChecksumsSignatureFile is the release asset holding a PGP signature of
+	// ChecksumsFile.
+	ChecksumsSignatureFile string
+
+	// KeyringFile is the release asset holding the PGP public keys which
+	// ChecksumsFile was signed with.
+	KeyringFile string
+}
+
+// Generate lists the changes in version, which was built from ref in
+// owner/repo, since the previous release. For a GA release the previous
+// release is the highest GA version below it, and for a pre-release it's the
+// highest version of any kind below it.
+func Generate(ctx context.Context, client *GitHubClient, owner, repo, version, ref string) (*Notes, error) {
+	previous, err := previousVersion(ctx, client, owner, repo, version)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Listing PRs merged into %s/%s between %s and %s", owner, repo, previous, ref)
+	commits, err := compareCommits(ctx, client, owner, repo, previous, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	prs, err := listMergedPullRequests(ctx, client, owner, repo, commits)
+	if err != nil {
+		return nil, err
+	}
+
+	notes := &Notes{Version: version, PreviousVersion: previous}
+	for _, pr := range prs {
+		change, ok := changeFor(pr)
+		if !ok {
+			continue
+		}
+		notes.Changes = append(notes.Changes, change)
+	}
+	sort.Slice(notes.Changes, func(i, j int) bool {
+		return notes.Changes[i].Number < notes.Changes[j].Number
+	})
+
+	log.Printf("Found %d release notes in %d PRs merged since %s", len(notes.Changes), len(prs), previous)
+	return notes, nil
+}
+
+// previousVersion returns the tag of the release which version follows.
+func previousVersion(ctx context.Context, client *GitHubClient, owner, repo, version string) (string, error) {
+	current, err := parseVersion(version)
+	if err != nil {
+		return "", fmt.Errorf("invalid release version %q: %w", version, err)
+	}
+
+	var previous string
+	var previousSemver semver.Version
+	opts := &github.ListOptions{PerPage: perPage}
+	for {
+		tags, resp, err := client.ListTags(ctx, owner, repo, opts)
+		if err != nil {
+			return "", fmt.Errorf("failed to list tags in %s/%s: %w", owner, repo, err)
+		}
+
+		for _, tag := range tags {
+			v, err := parseVersion(tag.GetName())
+			if err != nil {
+				continue
+			}
+			if !v.LT(current) || (len(current.Pre) == 0 && len(v.Pre) > 0) {
+				continue
+			}
+			if previous == "" || v.GT(previousSemver) {
+				previous, previousSemver = tag.GetName(), v
+			}
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	if previous == "" {
+		return "", fmt.Errorf("couldn't find a release before %q in %s/%s", version, owner, repo)
+	}
+
+	return previous, nil
+}
+
+func parseVersion(version string) (semver.Version, error) {
+	v, ok := strings.CutPrefix(version, "v")
+	if !ok {
+		return semver.Version{}, fmt.Errorf("version number must have a leading 'v' character")
+	}
+	return semver.Parse(v)
+}
+
+// compareCommits returns every commit reachable from head but not from base.
+func compareCommits(ctx context.Context, client *GitHubClient, owner, repo, base, head string) ([]*github.RepositoryCommit, error) {
+	var commits []*github.RepositoryCommit
+	opts := &github.ListOptions{PerPage: perPage}
+	for {
+		comparison, resp, err := client.CompareCommits(ctx, owner, repo, base, head, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compare %s...%s in %s/%s: %w", base, head, owner, repo, err)
+		}
+
+		commits = append(commits, comparison.Commits...)
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	return commits, nil
+}
+
+// listMergedPullRequests returns the merged PRs whose merge commit is one of
+// the given commits, keyed by PR number. Rather than looking up the PRs of
+// each commit, closed PRs are listed most recently updated first until they
+// were last updated before the oldest of the commits was committed, since a
+// PR is updated when it's merged.
+func listMergedPullRequests(ctx context.Context, client *GitHubClient, owner, repo string, commits []*github.RepositoryCommit) (map[int]*github.PullRequest, error) {
+	merged := map[int]*github.PullRequest{}
+	if len(commits) == 0 {
+		return merged, nil
+	}
+
+	shas := map[string]bool{}
+	var since time.Time
+	for _, commit := range commits {
+		shas[commit.GetSHA()] = true
+
+		committed := commit.GetCommit().GetCommitter().GetDate()
+		if since.IsZero() || committed.Before(since) {
+			since = committed
+		}
+	}
+
+	opts := &github.PullRequestListOptions{
+		State:       "closed",
+		Sort:        "updated",
+		Direction:   "desc",
+		ListOptions: github.ListOptions{PerPage: perPage},
+	}
+	for {
+		prs, resp, err := client.List(ctx, owner, repo, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list PRs in %s/%s: %w", owner, repo, err)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("unexpected response code %d when listing PRs in %s/%s", resp.StatusCode, owner, repo)
+		}
+
+		for _, pr := range prs {
+			if pr.GetUpdatedAt().Before(since) {
+				return merged, nil
+			}
+
+			if pr.MergedAt != nil && shas[pr.GetMergeCommitSHA()] {
+				merged[pr.GetNumber()] = pr
+			}
+		}
+
+		if resp.NextPage == 0 {
+			return merged, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// changeFor returns the release note of the given PR, or false if it has no
+// release note.
+func changeFor(pr *github.PullRequest) (Change, bool) {
+	labels := map[string]bool{}
+	for _, label := range pr.Labels {
+		labels[label.GetName()] = true
+	}
+
+	if labels[releaseNoneLabel] {
+		return Change{}, false
+	}
+
+	match := releaseNoteBlock.FindStringSubmatch(pr.GetBody())
+	if match == nil {
+		return Change{}, false
+	}
+
+	note := strings.TrimSpace(strings.ReplaceAll(match[1], "\r\n", "\n"))
+	if note == "" || strings.EqualFold(note, "none") || strings.EqualFold(note, "n/a") {
+		return Change{}, false
+	}
+
+	category := uncategorized
+	for _, c := range categories {
+		if labels[c.label] {
+			category = c.title
+			break
+		}
+	}
+
+	return Change{
+		Category: category,
+		Note:     note,
+		Number:   pr.GetNumber(),
+		URL:      pr.GetHTMLURL(),
+		Author:   pr.GetUser().GetLogin(),
+	}, true
+}
+
+// Markdown renders the release notes in the format used for the body of a
+// GitHub release.
+func (n *Notes) Markdown() string {
+	out := &strings.Builder{}
+
+	fmt.Fprintf(out, "## Changes since %s\n", n.PreviousVersion)
+	if len(n.Changes) == 0 {
+		fmt.Fprintf(out, "\nNo user facing changes.\n")
+	}
+
+	var titles []string
+	for _, c := range categories {
+		titles = append(titles, c.title)
+	}
+	titles = append(titles, uncategorized)
+
+	written := map[string]bool{}
+	for _, title := range titles {
+		if written[title] {
+			continue
+		}
+		written[title] = true
+
+		var changes []Change
+		for _, change := range n.Changes {
+			if change.Category == title {
+				changes = append(changes, change)
+			}
+		}
+		if len(changes) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(out, "\n### %s\n\n", title)
+		for _, change := range changes {
+			// indent continuation lines so that multi-line notes stay in
+			// the same list item
+			note := strings.ReplaceAll(change.Note, "\n", "\n  ")
+			fmt.Fprintf(out, "- %s ([#%d](%s), [@%s](https://github.com/%s))\n", note, change.Number, change.URL, change.Author, change.Author)
+		}
+	}
+
+	if len(n.Images) > 0 {
+		fmt.Fprintf(out, "\n## Images\n\n")
+		fmt.Fprintf(out, "| Image | Digest |\n")
+		fmt.Fprintf(out, "| ----- | ------ |\n")
+		for _, image := range n.Images {
+			fmt.Fprintf(out, "| `%s` | `%s` |\n", image.Name, image.Digest)
+		}
+	}
+
+	n.writeVerification(out)
+
+	return out.String()
+}
+
+func (n *Notes) writeVerification(out *strings.Builder) {
+	v := n.Verification
+	if v.ChecksumsFile == "" && (len(v.CosignKeys) == 0 || len(n.Images) == 0) {
+		return
+	}
+
+	fmt.Fprintf(out, "\n## Verification\n")
+
+	if len(v.CosignKeys) > 0 && len(n.Images) > 0 {
+		fmt.Fprintf(out, "\nImages are signed with cosign, and can be verified by digest with:\n\n```console\n")
+		for _, key := range v.CosignKeys {
+			fmt.Fprintf(out, "$ cosign verify --key %s %s@%s\n", key, n.Images[0].Name, n.Images[0].Digest)
+		}
+		fmt.Fprintf(out, "```\n")
+	}
+
+	if v.ChecksumsFile == "" {
+		return
+	}
+
+	fmt.Fprintf(out, "\nThe checksum of every release asset is listed in `%s`", v.ChecksumsFile)
+	if v.ChecksumsSignatureFile == "" {
+		fmt.Fprintf(out, ". Download it next to the assets and check them with:\n\n```console\n")
+	} else {
+		fmt.Fprintf(out, ", which is signed by the keys in `%s`. Download them next to the assets and check them with:\n\n```console\n", v.KeyringFile)
+		fmt.Fprintf(out, "$ gpg --import %s\n", v.KeyringFile)
+		fmt.Fprintf(out, "$ gpg --verify %s %s\n", v.ChecksumsSignatureFile, v.ChecksumsFile)
+	}
+	fmt.Fprintf(out, "$ sha256sum --check --ignore-missing %s\n```\n", v.ChecksumsFile)
+}
@@ -0,0 +1,291 @@
+/*
+Copyright 2021 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notes
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v35/github"
+)
+
+// fakeGitHub is an in-memory GitHub repository which serves every list in
+// pages of a single item, to exercise pagination.
+type fakeGitHub struct {
+	tags []string
+
+	// commits are the commits listed by any comparison
+	commits []*github.RepositoryCommit
+
+	// pulls are the closed PRs, most recently updated first
+	pulls []*github.PullRequest
+
+	compared []string
+
+	// listedPulls is the number of PRs which have been listed
+	listedPulls int
+}
+
+func (f *fakeGitHub) client() *GitHubClient {
+	return &GitHubClient{RepositoriesClient: f, PullRequestClient: f}
+}
+
+func page(opts *github.ListOptions, total int) (int, *github.Response) {
+	i := opts.Page
+	if i == 0 {
+		i = 1
+	}
+	resp := &github.Response{Response: &http.Response{StatusCode: http.StatusOK}}
+	if i < total {
+		resp.NextPage = i + 1
+	}
+	return i - 1, resp
+}
+
+func (f *fakeGitHub) ListTags(ctx context.Context, owner string, repo string, opts *github.ListOptions) ([]*github.RepositoryTag, *github.Response, error) {
+	i, resp := page(opts, len(f.tags))
+	if i >= len(f.tags) {
+		return nil, resp, nil
+	}
+	return []*github.RepositoryTag{{Name: github.String(f.tags[i])}}, resp, nil
+}
+
+func (f *fakeGitHub) CompareCommits(ctx context.Context, owner, repo, base, head string, opts *github.ListOptions) (*github.CommitsComparison, *github.Response, error) {
+	f.compared = append(f.compared, base+"..."+head)
+	i, resp := page(opts, len(f.commits))
+	comparison := &github.CommitsComparison{TotalCommits: github.Int(len(f.commits))}
+	if i < len(f.commits) {
+		comparison.Commits = f.commits[i : i+1]
+	}
+	return comparison, resp, nil
+}
+
+func (f *fakeGitHub) List(ctx context.Context, owner string, repo string, opts *github.PullRequestListOptions) ([]*github.PullRequest, *github.Response, error) {
+	if opts.State != "closed" || opts.Sort != "updated" || opts.Direction != "desc" {
+		return nil, nil, fmt.Errorf("unexpected list options %#v", opts)
+	}
+	i, resp := page(&opts.ListOptions, len(f.pulls))
+	if i >= len(f.pulls) {
+		return nil, resp, nil
+	}
+	f.listedPulls++
+	return f.pulls[i : i+1], resp, nil
+}
+
+// since is the time of the oldest commit in the fake comparison
+var since = time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+func commit(sha string, hours int) *github.RepositoryCommit {
+	committed := since.Add(time.Duration(hours) * time.Hour)
+	return &github.RepositoryCommit{
+		SHA:    github.String(sha),
+		Commit: &github.Commit{Committer: &github.CommitAuthor{Date: &committed}},
+	}
+}
+
+// pr returns a PR which was merged with the given merge commit, and last
+// updated the given number of hours after the oldest commit.
+func pr(number int, mergeCommit string, hours int, body string, labels ...string) *github.PullRequest {
+	updated := since.Add(time.Duration(hours) * time.Hour)
+	p := &github.PullRequest{
+		Number:         github.Int(number),
+		Body:           github.String(body),
+		HTMLURL:        github.String(fmt.Sprintf("https://github.com/cert-manager/cert-manager/pull/%d", number)),
+		User:           &github.User{Login: github.String("author")},
+		MergedAt:       &updated,
+		UpdatedAt:      &updated,
+		MergeCommitSHA: github.String(mergeCommit),
+	}
+	for _, label := range labels {
+		p.Labels = append(p.Labels, &github.Label{Name: github.String(label)})
+	}
+	return p
+}
+
+func note(text string) string {
+	return "Some description\n\n```release-note\n" + text + "\n```\n"
+}
+
+func TestGenerate(t *testing.T) {
+	// GitHub gives closed PRs a merge commit to test merging them
+	unmerged := pr(5, "b", 8, note("Not merged"), "kind/feature")
+	unmerged.MergedAt = nil
+
+	commits := []*github.RepositoryCommit{commit("a", 0), commit("b", 1), commit("c", 2), commit("d", 3), commit("e", 4), commit("f", 5), commit("g", 6)}
+	pulls := []*github.PullRequest{
+		pr(7, "g", 10, note("No kind label")),
+		unmerged,
+		// merged into another branch after the oldest commit
+		pr(8, "z", 7, note("Merged elsewhere"), "kind/feature"),
+		pr(6, "f", 5, "No release note block", "kind/feature"),
+		pr(4, "e", 4, note("Labelled as none"), "release-note-none"),
+		pr(2, "d", 3, note("NONE"), "kind/bug"),
+		pr(1, "c", 2, note("Fixed a bug\r\nacross two lines"), "kind/bug", "kind/cleanup"),
+		pr(3, "a", 0, note("Added a feature"), "kind/feature"),
+		// PRs updated before the oldest commit aren't listed
+		pr(9, "a", -1, note("Merged before the previous release"), "kind/feature"),
+		pr(10, "y", -2, note("Merged before the previous release"), "kind/feature"),
+	}
+
+	expectedChanges := []Change{
+		{Category: "Bug or Regression", Note: "Fixed a bug\nacross two lines", Number: 1, URL: "https://github.com/cert-manager/cert-manager/pull/1", Author: "author"},
+		{Category: "Feature", Note: "Added a feature", Number: 3, URL: "https://github.com/cert-manager/cert-manager/pull/3", Author: "author"},
+		{Category: "Uncategorized", Note: "No kind label", Number: 7, URL: "https://github.com/cert-manager/cert-manager/pull/7", Author: "author"},
+	}
+
+	tests := map[string]struct {
+		tags             []string
+		version          string
+		expectedPrevious string
+		expectErr        string
+	}{
+		"GA release is compared to the previous GA release": {
+			tags:             []string{"v1.14.4", "v1.15.0-beta.0", "v1.16.0", "v1.14.5", "v1.15.0-rc.1", "not-a-version", "cmd/ctl/v1.15.0"},
+			version:          "v1.15.0",
+			expectedPrevious: "v1.14.5",
+		},
+		"pre-release is compared to the previous pre-release": {
+			tags:             []string{"v1.14.5", "v1.15.0-beta.0", "v1.15.0-alpha.1"},
+			version:          "v1.15.0-beta.1",
+			expectedPrevious: "v1.15.0-beta.0",
+		},
+		"first pre-release is compared to the previous GA release": {
+			tags:             []string{"v1.14.5", "v1.14.4"},
+			version:          "v1.15.0-alpha.0",
+			expectedPrevious: "v1.14.5",
+		},
+		"no previous release": {
+			tags:      []string{"v1.15.0", "v1.15.0-rc.1"},
+			version:   "v1.15.0",
+			expectErr: `couldn't find a release before "v1.15.0"`,
+		},
+		"invalid version": {
+			tags:      []string{"v1.14.5"},
+			version:   "1.15.0",
+			expectErr: "version number must have a leading 'v' character",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			fake := &fakeGitHub{tags: test.tags, commits: commits, pulls: pulls}
+
+			notes, err := Generate(context.TODO(), fake.client(), "cert-manager", "cert-manager", test.version, "abcdef")
+			if test.expectErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.expectErr) {
+					t.Errorf("expected error containing %q, got: %v", test.expectErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if notes.PreviousVersion != test.expectedPrevious {
+				t.Errorf("expected previous version %q, got %q", test.expectedPrevious, notes.PreviousVersion)
+			}
+
+			if expected := []string{test.expectedPrevious + "...abcdef"}; !reflect.DeepEqual(fake.compared[:1], expected) {
+				t.Errorf("expected comparison %v, got %v", expected, fake.compared)
+			}
+
+			if !reflect.DeepEqual(notes.Changes, expectedChanges) {
+				t.Errorf("unexpected changes:\n%#v", notes.Changes)
+			}
+
+			// listing stops at the first PR updated before the oldest commit
+			if expected := len(pulls) - 1; fake.listedPulls != expected {
+				t.Errorf("expected %d PRs to be listed, got %d", expected, fake.listedPulls)
+			}
+		})
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	changes := []Change{
+		{Category: "Bug or Regression", Note: "Fixed a bug\nacross two lines", Number: 1, URL: "https://github.com/cert-manager/cert-manager/pull/1", Author: "alice"},
+		{Category: "Uncategorized", Note: "No kind label", Number: 2, URL: "https://github.com/cert-manager/cert-manager/pull/2", Author: "bob"},
+		{Category: "Feature", Note: "Added a feature", Number: 3, URL: "https://github.com/cert-manager/cert-manager/pull/3", Author: "alice"},
+	}
+	images := []Image{
+		{Name: "quay.io/jetstack/cert-manager-controller:v1.15.0", Digest: "sha256:1111"},
+		{Name: "quay.io/jetstack/cert-manager-webhook:v1.15.0", Digest: "sha256:2222"},
+	}
+	verification := Verification{
+		CosignKeys:             []string{"gcpkms://key1", "gcpkms://key2"},
+		ChecksumsFile:          "SHA256SUMS",
+		ChecksumsSignatureFile: "SHA256SUMS.asc",
+		KeyringFile:            "cert-manager-keyring.asc",
+	}
+
+	tests := map[string]struct {
+		notes    Notes
+		expected string
+	}{
+		"all sections": {
+			notes: Notes{Version: "v1.15.0", PreviousVersion: "v1.14.5", Changes: changes, Images: images, Verification: verification},
+			expected: "## Changes since v1.14.5\n" +
+				"\n### Feature\n\n" +
+				"- Added a feature ([#3](https://github.com/cert-manager/cert-manager/pull/3), [@alice](https://github.com/alice))\n" +
+				"\n### Bug or Regression\n\n" +
+				"- Fixed a bug\n  across two lines ([#1](https://github.com/cert-manager/cert-manager/pull/1), [@alice](https://github.com/alice))\n" +
+				"\n### Uncategorized\n\n" +
+				"- No kind label ([#2](https://github.com/cert-manager/cert-manager/pull/2), [@bob](https://github.com/bob))\n" +
+				"\n## Images\n\n" +
+				"| Image | Digest |\n" +
+				"| ----- | ------ |\n" +
+				"| `quay.io/jetstack/cert-manager-controller:v1.15.0` | `sha256:1111` |\n" +
+				"| `quay.io/jetstack/cert-manager-webhook:v1.15.0` | `sha256:2222` |\n" +
+				"\n## Verification\n" +
+				"\nImages are signed with cosign, and can be verified by digest with:\n\n```console\n" +
+				"$ cosign verify --key gcpkms://key1 quay.io/jetstack/cert-manager-controller:v1.15.0@sha256:1111\n" +
+				"$ cosign verify --key gcpkms://key2 quay.io/jetstack/cert-manager-controller:v1.15.0@sha256:1111\n" +
+				"```\n" +
+				"\nThe checksum of every release asset is listed in `SHA256SUMS`, which is signed by the keys in `cert-manager-keyring.asc`. Download them next to the assets and check them with:\n\n```console\n" +
+				"$ gpg --import cert-manager-keyring.asc\n" +
+				"$ gpg --verify SHA256SUMS.asc SHA256SUMS\n" +
+				"$ sha256sum --check --ignore-missing SHA256SUMS\n" +
+				"```\n",
+		},
+		"unsigned release without changes": {
+			notes: Notes{Version: "v1.15.1", PreviousVersion: "v1.15.0", Images: images[:1], Verification: Verification{ChecksumsFile: "SHA256SUMS"}},
+			expected: "## Changes since v1.15.0\n" +
+				"\nNo user facing changes.\n" +
+				"\n## Images\n\n" +
+				"| Image | Digest |\n" +
+				"| ----- | ------ |\n" +
+				"| `quay.io/jetstack/cert-manager-controller:v1.15.0` | `sha256:1111` |\n" +
+				"\n## Verification\n" +
+				"\nThe checksum of every release asset is listed in `SHA256SUMS`. Download it next to the assets and check them with:\n\n```console\n" +
+				"$ sha256sum --check --ignore-missing SHA256SUMS\n" +
+				"```\n",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := test.notes.Markdown(); got != test.expected {
+				t.Errorf("unexpected release notes, expected:\n%s\ngot:\n%s", test.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,204 @@
+/*
+Copyright 2021 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package provenance generates SLSA provenance for published release artifacts,
+// in the form of in-toto statements.
+package provenance
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cert-manager/release/pkg/release"
+)
+
+const (
+	// StatementType is the type of every in-toto statement we produce
+	StatementType = "https://in-toto.io/Statement/v1"
+
+	// PredicateType is the type of the predicate of every statement we produce
+	PredicateType = "https://slsa.dev/provenance/v1"
+
+	// PayloadType is the DSSE payload type used when signing a statement
+	PayloadType = "application/vnd.in-toto+json"
+
+	// BuildType identifies how a release was built, and so how to interpret
+	// the parameters in a BuildDefinition
+	BuildType = "https://github.com/cert-manager/release/gcb-stage@v1"
+
+	// BuilderID identifies the platform which built a release
+	BuilderID = "https://cloudbuild.googleapis.com/GoogleHostedWorker"
+)
+
+// Statement is an in-toto statement asserting that the Predicate applies to each Subject.
+type Statement struct {
+	Type          string    `json:"_type"`
+	Subject       []Subject `json:"subject"`
+	PredicateType string    `json:"predicateType"`
+	Predicate     Predicate `json:"predicate"`
+}
+
+// Subject is a single artifact which a Statement refers to.
+type Subject struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+// Predicate is a SLSA v1 provenance predicate.
+type Predicate struct {
+	BuildDefinition BuildDefinition `json:"buildDefinition"`
+	RunDetails      RunDetails      `json:"runDetails"`
+}
+
+type BuildDefinition struct {
+	BuildType            string               `json:"buildType"`
+	ExternalParameters   ExternalParameters   `json:"externalParameters"`
+	InternalParameters   *InternalParameters  `json:"internalParameters,omitempty"`
+	ResolvedDependencies []ResourceDescriptor `json:"resolvedDependencies,omitempty"`
+}
+
+// ExternalParameters are the parameters of a build which are under the
+// control of whoever requested the release.
+type ExternalParameters struct {
+	Repository      string   `json:"repository,omitempty"`
+	GitCommitRef    string   `json:"gitCommitRef"`
+	ReleaseVersion  string   `json:"releaseVersion"`
+	TargetPlatforms []string `json:"targetPlatforms,omitempty"`
+}
+
+// InternalParameters are the parameters of a build which were set by the
+// build platform.
+type InternalParameters struct {
+	Substitutions map[string]string `json:"substitutions,omitempty"`
+}
+
+type ResourceDescriptor struct {
+	URI    string            `json:"uri"`
+	Digest map[string]string `json:"digest,omitempty"`
+}
+
+type RunDetails struct {
+	Builder  Builder        `json:"builder"`
+	Metadata *BuildMetadata `json:"metadata,omitempty"`
+}
+
+type Builder struct {
+	ID string `json:"id"`
+}
+
+type BuildMetadata struct {
+	InvocationID string     `json:"invocationId,omitempty"`
+	StartedOn    *time.Time `json:"startedOn,omitempty"`
+	FinishedOn   *time.Time `json:"finishedOn,omitempty"`
+}
+
+// repositorySubstitution is the Cloud Build substitution which holds the URL
+// of the cert-manager repository which was built
+const repositorySubstitution = "_CM_REPO"
+
+// NewPredicate returns the provenance of the given release. If the release
+// didn't record its build inputs, only the git commit and version are known.
+func NewPredicate(rel *release.Unpacked) Predicate {
+	p := Predicate{
+		BuildDefinition: BuildDefinition{
+			BuildType: BuildType,
+			ExternalParameters: ExternalParameters{
+				GitCommitRef:   rel.GitCommitRef,
+				ReleaseVersion: rel.ReleaseVersion,
+			},
+		},
+		RunDetails: RunDetails{
+			Builder: Builder{ID: BuilderID},
+		},
+	}
+
+	inputs := rel.BuildInputs
+	if inputs == nil {
+		p.BuildDefinition.ResolvedDependencies = []ResourceDescriptor{gitDependency("", rel.GitCommitRef)}
+		return p
+	}
+
+	repository := inputs.Substitutions[repositorySubstitution]
+
+	p.BuildDefinition.ExternalParameters.Repository = repository
+	p.BuildDefinition.ExternalParameters.TargetPlatforms = inputs.TargetPlatforms
+
+	if len(inputs.Substitutions) > 0 {
+		p.BuildDefinition.InternalParameters = &InternalParameters{Substitutions: inputs.Substitutions}
+	}
+
+	p.BuildDefinition.ResolvedDependencies = []ResourceDescriptor{gitDependency(repository, rel.GitCommitRef)}
+	if inputs.BuilderImage != "" {
+		p.BuildDefinition.ResolvedDependencies = append(p.BuildDefinition.ResolvedDependencies, imageDependency(inputs.BuilderImage))
+	}
+
+	metadata := &BuildMetadata{InvocationID: inputs.BuildID}
+	if !inputs.StartedOn.IsZero() {
+		metadata.StartedOn = &inputs.StartedOn
+	}
+	if !inputs.FinishedOn.IsZero() {
+		metadata.FinishedOn = &inputs.FinishedOn
+	}
+	p.RunDetails.Metadata = metadata
+
+	return p
+}
+
+// NewStatement returns a statement asserting the provenance of the given
+// release applies to each subject.
+func NewStatement(rel *release.Unpacked, subjects ...Subject) *Statement {
+	return &Statement{
+		Type:          StatementType,
+		Subject:       subjects,
+		PredicateType: PredicateType,
+		Predicate:     NewPredicate(rel),
+	}
+}
+
+// NewSubject returns a subject with the given name and digest, where the
+// digest is of the form "<algorithm>:<hex>".
+func NewSubject(name, digest string) (Subject, error) {
+	algorithm, hex, ok := strings.Cut(digest, ":")
+	if !ok || algorithm == "" || hex == "" {
+		return Subject{}, fmt.Errorf("invalid digest %q for %q", digest, name)
+	}
+
+	return Subject{Name: name, Digest: map[string]string{algorithm: hex}}, nil
+}
+
+func gitDependency(repository, gitCommitRef string) ResourceDescriptor {
+	if repository == "" {
+		repository = fmt.Sprintf("https://github.com/%s/%s.git", release.DefaultGitHubOrg, release.DefaultGitHubRepo)
+	}
+
+	return ResourceDescriptor{
+		URI:    fmt.Sprintf("git+%s@%s", repository, gitCommitRef),
+		Digest: map[string]string{"gitCommit": gitCommitRef},
+	}
+}
+
+func imageDependency(image string) ResourceDescriptor {
+	d := ResourceDescriptor{URI: "docker://" + image}
+
+	if _, digest, ok := strings.Cut(image, "@"); ok {
+		if algorithm, hex, ok := strings.Cut(digest, ":"); ok {
+			d.Digest = map[string]string{algorithm: hex}
+		}
+	}
+
+	return d
+}
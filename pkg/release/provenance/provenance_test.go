@@ -0,0 +1,107 @@
+/*
+Copyright 2021 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package provenance
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cert-manager/release/pkg/release"
+)
+
+func TestNewPredicate(t *testing.T) {
+	started := time.Date(2021, 9, 29, 14, 0, 0, 0, time.UTC)
+	finished := started.Add(time.Hour)
+
+	tests := map[string]struct {
+		inputs *release.BuildInputs
+
+		expExternal     ExternalParameters
+		expDependencies []ResourceDescriptor
+		expMetadata     *BuildMetadata
+	}{
+		"release without recorded build inputs only has the git commit": {
+			expExternal: ExternalParameters{GitCommitRef: "abcdef", ReleaseVersion: "v1.15.0"},
+			expDependencies: []ResourceDescriptor{
+				{URI: "git+https://github.com/cert-manager/cert-manager.git@abcdef", Digest: map[string]string{"gitCommit": "abcdef"}},
+			},
+		},
+		"release with recorded build inputs": {
+			inputs: &release.BuildInputs{
+				BuildID:         "1234",
+				BuilderImage:    "gcr.io/cloud-builders/bazel@sha256:0123",
+				Substitutions:   map[string]string{"_CM_REPO": "https://github.com/example/cert-manager.git"},
+				TargetPlatforms: []string{"linux/amd64"},
+				StartedOn:       started,
+				FinishedOn:      finished,
+			},
+			expExternal: ExternalParameters{
+				Repository:      "https://github.com/example/cert-manager.git",
+				GitCommitRef:    "abcdef",
+				ReleaseVersion:  "v1.15.0",
+				TargetPlatforms: []string{"linux/amd64"},
+			},
+			expDependencies: []ResourceDescriptor{
+				{URI: "git+https://github.com/example/cert-manager.git@abcdef", Digest: map[string]string{"gitCommit": "abcdef"}},
+				{URI: "docker://gcr.io/cloud-builders/bazel@sha256:0123", Digest: map[string]string{"sha256": "0123"}},
+			},
+			expMetadata: &BuildMetadata{InvocationID: "1234", StartedOn: &started, FinishedOn: &finished},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			p := NewPredicate(&release.Unpacked{
+				ReleaseVersion: "v1.15.0",
+				GitCommitRef:   "abcdef",
+				BuildInputs:    test.inputs,
+			})
+
+			if p.BuildDefinition.BuildType != BuildType || p.RunDetails.Builder.ID != BuilderID {
+				t.Errorf("unexpected build type or builder: %#v", p)
+			}
+
+			if !reflect.DeepEqual(p.BuildDefinition.ExternalParameters, test.expExternal) {
+				t.Errorf("expected external parameters %#v, got %#v", test.expExternal, p.BuildDefinition.ExternalParameters)
+			}
+
+			if !reflect.DeepEqual(p.BuildDefinition.ResolvedDependencies, test.expDependencies) {
+				t.Errorf("expected resolved dependencies %#v, got %#v", test.expDependencies, p.BuildDefinition.ResolvedDependencies)
+			}
+
+			if !reflect.DeepEqual(p.RunDetails.Metadata, test.expMetadata) {
+				t.Errorf("expected metadata %#v, got %#v", test.expMetadata, p.RunDetails.Metadata)
+			}
+		})
+	}
+}
+
+func TestNewSubject(t *testing.T) {
+	s, err := NewSubject("cert-manager.yaml", "sha256:0123")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.Name != "cert-manager.yaml" || s.Digest["sha256"] != "0123" {
+		t.Errorf("unexpected subject %#v", s)
+	}
+
+	if _, err := NewSubject("cert-manager.yaml", "0123"); err == nil {
+		t.Errorf("expected an error for a digest without an algorithm")
+	}
+}
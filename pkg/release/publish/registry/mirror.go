@@ -0,0 +1,220 @@
+/*
+Copyright 2021 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package registry
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/docker/cli/cli/config"
+	"github.com/docker/cli/cli/config/types"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/google"
+	"sigs.k8s.io/yaml"
+
+	"github.com/cert-manager/release/pkg/sign"
+)
+
+// Mirror is an image repository which release images and image indexes are
+// pushed to in addition to the published image repository, with its own
+// credentials and signing keys.
+type Mirror struct {
+	// Repository is the image repository to push to, e.g. ghcr.io/cert-manager
+	Repository string `json:"repository"`
+
+	// DockerConfigEnv is the name of an environment variable holding the
+	// contents of a Docker config.json file with credentials for the mirror.
+	DockerConfigEnv string `json:"dockerConfigEnv,omitempty"`
+
+	// GoogleAuth, if true, authenticates to the mirror using Google
+	// application default credentials, e.g. for Artifact Registry.
+	GoogleAuth bool `json:"googleAuth,omitempty"`
+
+	// SigningKeys are the keys to sign content in the mirror with, in any
+	// format accepted by sign.ParseSigningKeys. Defaults to the keys used to
+	// sign content in the published image repository.
+	SigningKeys string `json:"signingKeys,omitempty"`
+
+	// SkipSigning, if true, skips signing content in the mirror.
+	SkipSigning bool `json:"skipSigning,omitempty"`
+}
+
+// Mirrors lists the mirrors of the published image repository. Mirrors are
+// read from a YAML file such as:
+//
+//	mirrors:
+//	- repository: ghcr.io/cert-manager
+//	  dockerConfigEnv: GHCR_DOCKER_CONFIG
+//	- repository: europe-docker.pkg.dev/<PROJECT>/cert-manager
+//	  googleAuth: true
+//	  signingKeys: projects/<PROJECT>/locations/<LOCATION>/keyRings/<KEYRING>/cryptoKeys/<KEY>/cryptoKeyVersions/<VERSION>
+//	- repository: registry.example.com/cert-manager
+//	  skipSigning: true
+//
+// Mirrors without credentials use the Docker config file, like the published
+// image repository.
+type Mirrors struct {
+	Mirrors []Mirror `json:"mirrors"`
+}
+
+// LoadMirrors reads mirrors from the YAML file at path. An empty path or an
+// empty file gives no mirrors.
+func LoadMirrors(path string) (*Mirrors, error) {
+	if path == "" {
+		return &Mirrors{}, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image mirrors: %w", err)
+	}
+
+	mirrors, err := ParseMirrors(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid image mirrors in %q: %w", path, err)
+	}
+
+	return mirrors, nil
+}
+
+// ParseMirrors parses and validates YAML mirrors; see Mirrors for the format
+func ParseMirrors(data []byte) (*Mirrors, error) {
+	mirrors := &Mirrors{}
+	if err := yaml.UnmarshalStrict(data, mirrors); err != nil {
+		return nil, err
+	}
+
+	seen := map[string]struct{}{}
+	for i, m := range mirrors.Mirrors {
+		repo, err := name.NewRepository(m.Repository)
+		if err != nil {
+			return nil, fmt.Errorf("mirror %d: invalid repository %q: %w", i, m.Repository, err)
+		}
+
+		if _, ok := seen[repo.Name()]; ok {
+			return nil, fmt.Errorf("mirror %d: repository %q is listed more than once", i, m.Repository)
+		}
+		seen[repo.Name()] = struct{}{}
+
+		if m.DockerConfigEnv != "" && m.GoogleAuth {
+			return nil, fmt.Errorf("mirror %d: only one of dockerConfigEnv and googleAuth can be set", i)
+		}
+
+		if m.SigningKeys != "" {
+			if m.SkipSigning {
+				return nil, fmt.Errorf("mirror %d: signingKeys can't be set with skipSigning", i)
+			}
+
+			if _, err := sign.ParseSigningKeys(m.SigningKeys); err != nil {
+				return nil, fmt.Errorf("mirror %d: %w", i, err)
+			}
+		}
+	}
+
+	return mirrors, nil
+}
+
+// Repositories returns the repository of each mirror.
+func (m *Mirrors) Repositories() []string {
+	repos := make([]string, len(m.Mirrors))
+	for i, mirror := range m.Mirrors {
+		repos[i] = mirror.Repository
+	}
+	return repos
+}
+
+// WriteDockerConfig writes the Docker config file held in the mirror's
+// DockerConfigEnv environment variable to dir, so that it can be used by
+// DockerConfigKeychain and by tools such as cosign through the DOCKER_CONFIG
+// environment variable.
+func (m Mirror) WriteDockerConfig(dir string) error {
+	data := os.Getenv(m.DockerConfigEnv)
+	if data == "" {
+		return fmt.Errorf("%s environment variable not set - it must hold the Docker config file for mirror %q", m.DockerConfigEnv, m.Repository)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, config.ConfigFileName), []byte(data), 0o600); err != nil {
+		return fmt.Errorf("failed to write Docker config file for mirror %q: %w", m.Repository, err)
+	}
+
+	return nil
+}
+
+// Keychain returns the keychain used to authenticate to the mirror, given
+// the directory its Docker config file was written to by WriteDockerConfig.
+func (m Mirror) Keychain(dockerConfigDir string) authn.Keychain {
+	switch {
+	case m.DockerConfigEnv != "":
+		return DockerConfigKeychain(dockerConfigDir)
+	case m.GoogleAuth:
+		return google.Keychain
+	default:
+		return authn.DefaultKeychain
+	}
+}
+
+// DockerConfigKeychain returns a keychain which reads credentials from the
+// Docker config file in dir, rather than the default location.
+func DockerConfigKeychain(dir string) authn.Keychain {
+	return &dockerConfigKeychain{dir: dir}
+}
+
+type dockerConfigKeychain struct {
+	dir string
+}
+
+func (k *dockerConfigKeychain) Resolve(target authn.Resource) (authn.Authenticator, error) {
+	cf, err := config.Load(k.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	// credentials may be stored for the repository or for the whole registry,
+	// and Docker Hub credentials are stored under a legacy key; see
+	// authn.DefaultKeychain
+	var cfg, empty types.AuthConfig
+	for _, key := range []string{target.String(), target.RegistryStr()} {
+		if key == name.DefaultRegistry {
+			key = authn.DefaultAuthKey
+		}
+
+		cfg, err = cf.GetAuthConfig(key)
+		if err != nil {
+			return nil, err
+		}
+
+		// GetAuthConfig always sets ServerAddress, which isn't used
+		cfg.ServerAddress = ""
+		if cfg != empty {
+			break
+		}
+	}
+
+	if cfg == empty {
+		return authn.Anonymous, nil
+	}
+
+	return authn.FromConfig(authn.AuthConfig{
+		Username:      cfg.Username,
+		Password:      cfg.Password,
+		Auth:          cfg.Auth,
+		IdentityToken: cfg.IdentityToken,
+		RegistryToken: cfg.RegistryToken,
+	}), nil
+}
@@ -0,0 +1,143 @@
+/*
+Copyright 2021 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package registry
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+func TestParseMirrors(t *testing.T) {
+	const kmsKey = "projects/p/locations/l/keyRings/r/cryptoKeys/k/cryptoKeyVersions/1"
+
+	tests := map[string]struct {
+		yaml      string
+		expected  []string
+		expectErr string
+	}{
+		"no mirrors": {
+			yaml: "",
+		},
+		"mirrors": {
+			yaml:     "mirrors:\n- repository: ghcr.io/cert-manager\n  dockerConfigEnv: GHCR_DOCKER_CONFIG\n- repository: europe-docker.pkg.dev/p/cert-manager\n  googleAuth: true\n  signingKeys: " + kmsKey + "\n- repository: registry.example.com/cert-manager\n  skipSigning: true\n",
+			expected: []string{"ghcr.io/cert-manager", "europe-docker.pkg.dev/p/cert-manager", "registry.example.com/cert-manager"},
+		},
+		"unknown field": {
+			yaml:      "mirrors:\n- repository: ghcr.io/cert-manager\n  password: hunter2\n",
+			expectErr: "unknown field",
+		},
+		"invalid repository": {
+			yaml:      "mirrors:\n- repository: ghcr.io/Cert-Manager\n",
+			expectErr: "invalid repository",
+		},
+		"repository listed twice": {
+			yaml:      "mirrors:\n- repository: ghcr.io/cert-manager\n- repository: ghcr.io/cert-manager\n",
+			expectErr: "more than once",
+		},
+		"more than one kind of credentials": {
+			yaml:      "mirrors:\n- repository: ghcr.io/cert-manager\n  dockerConfigEnv: GHCR_DOCKER_CONFIG\n  googleAuth: true\n",
+			expectErr: "only one of dockerConfigEnv and googleAuth",
+		},
+		"signing keys with skipSigning": {
+			yaml:      "mirrors:\n- repository: ghcr.io/cert-manager\n  signingKeys: " + kmsKey + "\n  skipSigning: true\n",
+			expectErr: "can't be set with skipSigning",
+		},
+		"invalid signing keys": {
+			yaml:      "mirrors:\n- repository: ghcr.io/cert-manager\n  signingKeys: not-a-key\n",
+			expectErr: "mirror 0",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			mirrors, err := ParseMirrors([]byte(test.yaml))
+			if test.expectErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.expectErr) {
+					t.Errorf("expected error containing %q, got: %v", test.expectErr, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := mirrors.Repositories(); strings.Join(got, ",") != strings.Join(test.expected, ",") {
+				t.Errorf("expected repositories %v, got %v", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestDockerConfigKeychain(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("TEST_DOCKER_CONFIG", `{"auths":{"ghcr.io":{"auth":"dXNlcjpwYXNz"}}}`)
+
+	m := Mirror{Repository: "ghcr.io/cert-manager", DockerConfigEnv: "TEST_DOCKER_CONFIG"}
+	if err := m.WriteDockerConfig(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "config.json")); err != nil {
+		t.Fatal(err)
+	}
+
+	keychain := m.Keychain(dir)
+
+	repo, err := name.NewRepository("ghcr.io/cert-manager/cert-manager-controller")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	auth, err := keychain.Resolve(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := auth.Authorization()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Username != "user" || cfg.Password != "pass" {
+		t.Errorf("expected credentials from the Docker config file, got %#v", cfg)
+	}
+
+	other, err := name.NewRepository("quay.io/jetstack/cert-manager-controller")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	auth, err = keychain.Resolve(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if auth != authn.Anonymous {
+		t.Errorf("expected anonymous access to a registry without credentials, got %#v", auth)
+	}
+
+	missing := Mirror{Repository: "ghcr.io/cert-manager", DockerConfigEnv: "TEST_MISSING_DOCKER_CONFIG"}
+	if err := missing.WriteDockerConfig(t.TempDir()); err == nil || !strings.Contains(err.Error(), "TEST_MISSING_DOCKER_CONFIG") {
+		t.Errorf("expected an error for a missing Docker config, got: %v", err)
+	}
+}
@@ -0,0 +1,232 @@
+/*
+Copyright 2021 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package registry
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"golang.org/x/time/rate"
+)
+
+// RateLimiter limits the rate of requests to each registry host, and holds
+// back requests to a host for as long as it asks to with a Retry-After header.
+// A nil RateLimiter doesn't limit anything.
+type RateLimiter struct {
+	defaultLimit float64
+	hostLimits   map[string]float64
+
+	mu    sync.Mutex
+	hosts map[string]*hostLimiter
+}
+
+type hostLimiter struct {
+	limiter *rate.Limiter
+
+	// notBefore is when the host last asked for requests to resume
+	notBefore time.Time
+}
+
+// NewRateLimiter returns a RateLimiter which allows requestsPerSecond
+// requests to each registry host, except for those hosts with a limit of
+// their own in hostLimits. A limit of zero or less means no limit.
+func NewRateLimiter(requestsPerSecond float64, hostLimits map[string]float64) *RateLimiter {
+	return &RateLimiter{
+		defaultLimit: requestsPerSecond,
+		hostLimits:   hostLimits,
+		hosts:        map[string]*hostLimiter{},
+	}
+}
+
+func (l *RateLimiter) host(host string) *hostLimiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if h, ok := l.hosts[host]; ok {
+		return h
+	}
+
+	limit, ok := l.hostLimits[host]
+	if !ok {
+		limit = l.defaultLimit
+	}
+
+	h := &hostLimiter{limiter: rate.NewLimiter(rate.Inf, 0)}
+	if limit > 0 {
+		h.limiter = rate.NewLimiter(rate.Limit(limit), int(math.Ceil(limit)))
+	}
+
+	l.hosts[host] = h
+	return h
+}
+
+// Wait blocks until a request can be made to the given registry host.
+func (l *RateLimiter) Wait(ctx context.Context, host string) error {
+	if l == nil {
+		return nil
+	}
+
+	h := l.host(host)
+
+	l.mu.Lock()
+	delay := time.Until(h.notBefore)
+	l.mu.Unlock()
+
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	return h.limiter.Wait(ctx)
+}
+
+// WaitForReference blocks until a request can be made to the registry
+// holding the given image reference. It's used for tools such as cosign
+// which make their own requests, each run of which counts as one request.
+func (l *RateLimiter) WaitForReference(ctx context.Context, ref string) error {
+	if l == nil {
+		return nil
+	}
+
+	parsed, err := name.ParseReference(ref)
+	if err != nil {
+		return err
+	}
+
+	return l.Wait(ctx, parsed.Context().RegistryStr())
+}
+
+// retryAfter records that the given host asked for requests to stop until
+// the given time.
+func (l *RateLimiter) retryAfter(host string, notBefore time.Time) {
+	h := l.host(host)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if notBefore.After(h.notBefore) {
+		h.notBefore = notBefore
+	}
+}
+
+// Transport returns a transport which waits for the rate limit of each
+// request's host before making the request with base, for use with
+// remote.WithTransport.
+func (l *RateLimiter) Transport(base http.RoundTripper) http.RoundTripper {
+	if l == nil {
+		return base
+	}
+
+	return &rateLimitedTransport{limiter: l, base: base}
+}
+
+type rateLimitedTransport struct {
+	limiter *RateLimiter
+	base    http.RoundTripper
+}
+
+func (t *rateLimitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.limiter.Wait(req.Context(), req.URL.Host); err != nil {
+		return nil, err
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		if notBefore, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+			t.limiter.retryAfter(req.URL.Host, notBefore)
+		}
+	}
+
+	return resp, nil
+}
+
+// parseRetryAfter parses a Retry-After header, which holds either a number
+// of seconds or an HTTP date, returning when requests can resume.
+func parseRetryAfter(value string, now time.Time) (time.Time, bool) {
+	if value == "" {
+		return time.Time{}, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return time.Time{}, false
+		}
+		return now.Add(time.Duration(seconds) * time.Second), true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		return date, true
+	}
+
+	return time.Time{}, false
+}
+
+// IsRetryable returns true for errors which might be transient, such as rate
+// limiting, server errors or dropped connections, and false for errors which
+// retrying won't fix, such as authentication failures. Errors which didn't
+// come from a registry or the network, for example from running cosign, are
+// assumed to be permanent.
+func IsRetryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var terr *transport.Error
+	if !errors.As(err, &terr) {
+		return isTransientNetworkError(err)
+	}
+
+	switch terr.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return false
+	case http.StatusTooManyRequests:
+		return true
+	}
+
+	return terr.Temporary() || terr.StatusCode >= http.StatusInternalServerError
+}
+
+// isTransientNetworkError returns true if err is a network error which
+// retrying might fix, such as a timeout or a connection which was reset.
+func isTransientNetworkError(err error) bool {
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+
+	var nerr net.Error
+	return errors.As(err, &nerr) && nerr.Timeout()
+}
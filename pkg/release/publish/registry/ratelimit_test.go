@@ -0,0 +1,226 @@
+/*
+Copyright 2021 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+)
+
+func TestIsRetryable(t *testing.T) {
+	tests := map[string]struct {
+		err       error
+		retryable bool
+	}{
+		"unauthorized": {
+			err:       &transport.Error{StatusCode: http.StatusUnauthorized},
+			retryable: false,
+		},
+		"forbidden": {
+			err:       &transport.Error{StatusCode: http.StatusForbidden},
+			retryable: false,
+		},
+		"wrapped forbidden": {
+			err:       fmt.Errorf("failed to push image: %w", &transport.Error{StatusCode: http.StatusForbidden}),
+			retryable: false,
+		},
+		"bad request": {
+			err:       &transport.Error{StatusCode: http.StatusBadRequest},
+			retryable: false,
+		},
+		"too many requests": {
+			err:       &transport.Error{StatusCode: http.StatusTooManyRequests},
+			retryable: true,
+		},
+		"wrapped too many requests": {
+			err:       fmt.Errorf("failed to push image: %w", &transport.Error{StatusCode: http.StatusTooManyRequests}),
+			retryable: true,
+		},
+		"service unavailable": {
+			err:       &transport.Error{StatusCode: http.StatusServiceUnavailable},
+			retryable: true,
+		},
+		"internal server error": {
+			err:       &transport.Error{StatusCode: http.StatusInternalServerError},
+			retryable: true,
+		},
+		"context canceled": {
+			err:       fmt.Errorf("failed to push image: %w", context.Canceled),
+			retryable: false,
+		},
+		"error not from a registry": {
+			err:       errors.New("cosign exited with status 1"),
+			retryable: false,
+		},
+		"connection reset": {
+			err:       fmt.Errorf("failed to push image: %w", &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}),
+			retryable: true,
+		},
+		"network timeout": {
+			err:       fmt.Errorf("failed to push image: %w", &net.DNSError{Err: "i/o timeout", IsTimeout: true}),
+			retryable: true,
+		},
+		"unexpected EOF": {
+			err:       fmt.Errorf("failed to push image: %w", io.ErrUnexpectedEOF),
+			retryable: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if retryable := IsRetryable(test.err); retryable != test.retryable {
+				t.Errorf("expected IsRetryable to return %t, got %t", test.retryable, retryable)
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := map[string]struct {
+		value     string
+		notBefore time.Time
+		ok        bool
+	}{
+		"empty": {
+			value: "",
+		},
+		"seconds": {
+			value:     "30",
+			notBefore: now.Add(30 * time.Second),
+			ok:        true,
+		},
+		"negative seconds": {
+			value: "-1",
+		},
+		"HTTP date": {
+			value:     "Tue, 02 Jan 2024 03:05:05 GMT",
+			notBefore: now.Add(time.Minute),
+			ok:        true,
+		},
+		"invalid": {
+			value: "soon",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			notBefore, ok := parseRetryAfter(test.value, now)
+			if ok != test.ok {
+				t.Fatalf("expected ok to be %t, got %t", test.ok, ok)
+			}
+
+			if !notBefore.Equal(test.notBefore) {
+				t.Errorf("expected %v, got %v", test.notBefore, notBefore)
+			}
+		})
+	}
+}
+
+func TestRateLimiterLimitsEachHost(t *testing.T) {
+	l := NewRateLimiter(0, map[string]float64{"slow.example.com": 1})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	// hosts without a limit of their own use the default of no limit
+	for range 100 {
+		if err := l.Wait(ctx, "fast.example.com"); err != nil {
+			t.Fatalf("expected requests to an unlimited host not to wait: %v", err)
+		}
+	}
+
+	if err := l.Wait(ctx, "slow.example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := l.Wait(ctx, "slow.example.com"); err == nil {
+		t.Errorf("expected a second request within a second to a host limited to 1 request per second to wait")
+	}
+
+	var nilLimiter *RateLimiter
+	if err := nilLimiter.WaitForReference(ctx, "slow.example.com/cert-manager-controller:v1.15.0"); err != nil {
+		t.Errorf("expected a nil rate limiter not to limit anything: %v", err)
+	}
+}
+
+func TestRateLimiterHonorsRetryAfter(t *testing.T) {
+	var requests atomic.Int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer s.Close()
+
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l := NewRateLimiter(0, nil)
+	client := &http.Client{Transport: l.Transport(http.DefaultTransport)}
+
+	resp, err := client.Get(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("expected the first request to be rate limited, got status %d", resp.StatusCode)
+	}
+
+	// requests to the host are held back until the Retry-After has passed
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	if err := l.Wait(ctx, u.Host); err == nil {
+		t.Errorf("expected a request straight after a Retry-After of 1s to wait")
+	}
+
+	start := time.Now()
+	resp, err = client.Get(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected the retried request to succeed, got status %d", resp.StatusCode)
+	}
+
+	if waited := time.Since(start); waited < 500*time.Millisecond {
+		t.Errorf("expected the retried request to wait for the Retry-After, but it was made after %s", waited)
+	}
+}
@@ -18,63 +18,559 @@ package registry
 
 import (
 	"context"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"net/http"
 
-	"github.com/cert-manager/release/pkg/release/docker"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/cert-manager/release/pkg/release"
 	"github.com/cert-manager/release/pkg/release/images"
+	"github.com/cert-manager/release/pkg/sign/cosign"
 )
 
-func CreateManifestList(ctx context.Context, name string, tars []*images.Tar) error {
-	imageNames := make([]string, len(tars))
+// IndexEntry describes a single image within a multi-arch image index, along
+// with the platform it is declared for.
+type IndexEntry struct {
+	Image     string
+	OS        string
+	Arch      string
+	Variant   string
+	OSVersion string
+}
+
+// IndexEntries returns the entries which CreateImageIndex will add to an
+// image index built from the given tars, in order. Each tar must have its
+// PublishedTag set.
+//
+// The platform of each entry is read from the image's config blob, and must
+// match the platform the image was staged for and be one of
+// release.ServerPlatforms.
+func IndexEntries(tars []*images.Tar) ([]IndexEntry, error) {
+	entries := make([]IndexEntry, len(tars))
 	for i, t := range tars {
 		if t.PublishedTag == "" {
-			return fmt.Errorf("image %q has no PublishedTag", t.RawImageName())
+			return nil, fmt.Errorf("image %q has no PublishedTag", t.RawImageName())
+		}
+
+		img, err := t.Image()
+		if err != nil {
+			return nil, err
+		}
+
+		platform, err := imagePlatform(t, img)
+		if err != nil {
+			return nil, err
+		}
+
+		entries[i] = IndexEntry{
+			Image:     t.PublishedTag,
+			OS:        platform.OS,
+			Arch:      platform.Architecture,
+			Variant:   platform.Variant,
+			OSVersion: platform.OSVersion,
+		}
+	}
+
+	return entries, nil
+}
+
+// CreateImageIndex builds a multi-arch OCI image index from the given tars,
+// with platforms as described by IndexEntries. Each tar must have been pushed
+// already, so that PublishedTag and PublishedDigest are set.
+// Nothing is sent to a registry; see Publisher.PushImageIndex.
+func CreateImageIndex(name string, tars []*images.Tar) (v1.ImageIndex, error) {
+	entries, err := IndexEntries(tars)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Creating image index %q", name)
+
+	addenda := make([]mutate.IndexAddendum, len(tars))
+	for i, t := range tars {
+		img, err := ociImage(t)
+		if err != nil {
+			return nil, err
+		}
+
+		digest, err := img.Digest()
+		if err != nil {
+			return nil, fmt.Errorf("failed to compute digest for image %q: %w", t.PublishedTag, err)
+		}
+
+		// the image is re-read from disk here; refuse to reference anything
+		// other than exactly what was pushed
+		if digest.String() != t.PublishedDigest {
+			return nil, fmt.Errorf("image %q has digest %q but %q was pushed", t.PublishedTag, digest, t.PublishedDigest)
+		}
+
+		e := entries[i]
+		log.Printf("Adding image %q with os=%q, arch=%q, variant=%q, os.version=%q", e.Image, e.OS, e.Arch, e.Variant, e.OSVersion)
+		addenda[i] = mutate.IndexAddendum{
+			Add: img,
+			Descriptor: v1.Descriptor{
+				Platform: &v1.Platform{
+					OS:           e.OS,
+					Architecture: e.Arch,
+					Variant:      e.Variant,
+					OSVersion:    e.OSVersion,
+				},
+			},
 		}
+	}
+
+	idx := mutate.AppendManifests(mutate.IndexMediaType(empty.Index, types.OCIImageIndex), addenda...)
+
+	log.Printf("Created image index %q", name)
+	return idx, nil
+}
+
+// Publisher pushes images and image indexes directly to a registry over the
+// distribution API. It does not require a Docker daemon.
+type Publisher struct {
+	options []remote.Option
+}
+
+// NewPublisher returns a Publisher which authenticates using the default
+// keychain (i.e. the Docker config file). Any options given are applied after
+// the defaults, and so can be used to override them.
+func NewPublisher(options ...remote.Option) *Publisher {
+	return &Publisher{
+		options: append([]remote.Option{remote.WithAuthFromKeychain(authn.DefaultKeychain)}, options...),
+	}
+}
+
+// ImageDigest returns the digest which the image stored in the given tar will
+// have once pushed by PushImage.
+func ImageDigest(t *images.Tar) (string, error) {
+	img, err := ociImage(t)
+	if err != nil {
+		return "", err
+	}
+
+	digest, err := img.Digest()
+	if err != nil {
+		return "", fmt.Errorf("failed to compute digest for image %q: %w", t.RawImageName(), err)
+	}
+
+	return digest.String(), nil
+}
+
+// PushImage pushes the image stored in the given tar under the given tag, as
+// an OCI image. On success, the tar's PublishedTag and PublishedDigest are set.
+func (p *Publisher) PushImage(ctx context.Context, t *images.Tar, tag string) error {
+	ref, err := nameTag(tag)
+	if err != nil {
+		return err
+	}
+
+	img, err := ociImage(t)
+	if err != nil {
+		return err
+	}
+
+	digest, err := img.Digest()
+	if err != nil {
+		return fmt.Errorf("failed to compute digest for image %q: %w", t.RawImageName(), err)
+	}
+
+	log.Printf("Pushing image %q as %q", t.RawImageName(), tag)
+	if err := remote.Write(ref, img, p.remoteOptions(ctx)...); err != nil {
+		return fmt.Errorf("failed to push image %q: %w", tag, err)
+	}
+
+	t.PublishedTag = tag
+	t.PublishedDigest = digest.String()
+
+	return nil
+}
+
+// PushImageIndex pushes an image index built by CreateImageIndex under the
+// given name. All images it references must already have been pushed to the
+// same repository.
+func (p *Publisher) PushImageIndex(ctx context.Context, name string, idx v1.ImageIndex) error {
+	ref, err := nameTag(name)
+	if err != nil {
+		return err
+	}
+
+	if err := remote.WriteIndex(ref, idx, p.remoteOptions(ctx)...); err != nil {
+		return fmt.Errorf("failed to push image index %q: %w", name, err)
+	}
+
+	return nil
+}
+
+// RemoteDigest returns the digest of the manifest currently tagged with the
+// given name in the registry, or an empty string if the tag does not exist.
+func (p *Publisher) RemoteDigest(ctx context.Context, name string) (string, error) {
+	ref, err := nameTag(name)
+	if err != nil {
+		return "", err
+	}
+
+	desc, err := remote.Head(ref, p.remoteOptions(ctx)...)
+	var terr *transport.Error
+	if errors.As(err, &terr) && terr.StatusCode == http.StatusNotFound {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to query registry for %q: %w", name, err)
+	}
+
+	return desc.Digest.String(), nil
+}
+
+// Delete deletes the given tag from the registry.
+func (p *Publisher) Delete(ctx context.Context, name string) error {
+	ref, err := nameTag(name)
+	if err != nil {
+		return err
+	}
+
+	if err := remote.Delete(ref, p.remoteOptions(ctx)...); err != nil {
+		return fmt.Errorf("failed to delete %q: %w", name, err)
+	}
+
+	return nil
+}
+
+// Tags lists the tags in the repository of the given tag.
+func (p *Publisher) Tags(ctx context.Context, name string) ([]string, error) {
+	ref, err := nameTag(name)
+	if err != nil {
+		return nil, err
+	}
+
+	tags, err := remote.List(ref.Context(), p.remoteOptions(ctx)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags in %q: %w", ref.Context(), err)
+	}
+
+	return tags, nil
+}
+
+// Tag tags the manifest with the given digest in the repository of name with
+// tag, which is moved if it already exists.
+func (p *Publisher) Tag(ctx context.Context, name, digest, tag string) error {
+	ref, err := nameTag(name)
+	if err != nil {
+		return err
+	}
+
+	desc, err := remote.Get(ref.Context().Digest(digest), p.remoteOptions(ctx)...)
+	if err != nil {
+		return fmt.Errorf("failed to fetch %s@%s: %w", ref.Context(), digest, err)
+	}
+
+	if err := remote.Tag(ref.Context().Tag(tag), desc, p.remoteOptions(ctx)...); err != nil {
+		return fmt.Errorf("failed to tag %s@%s as %q: %w", ref.Context(), digest, tag, err)
+	}
+
+	return nil
+}
+
+// AttachArtifact pushes the given data as an OCI artifact whose subject is the
+// image or image index with the given digest in the same repository as the
+// given tag, so that it can be discovered through the referrers API. The
+// digest of the pushed artifact is returned.
+func (p *Publisher) AttachArtifact(ctx context.Context, name, subjectDigest, artifactType string, data []byte) (string, error) {
+	ref, err := nameTag(name)
+	if err != nil {
+		return "", err
+	}
+
+	subject, err := remote.Head(ref.Context().Digest(subjectDigest), p.remoteOptions(ctx)...)
+	if err != nil {
+		return "", fmt.Errorf("failed to query registry for %q: %w", name, err)
+	}
+
+	img := mutate.ConfigMediaType(mutate.MediaType(empty.Image, types.OCIManifestSchema1), types.MediaType(artifactType))
+	img, err = mutate.Append(img, mutate.Addendum{Layer: static.NewLayer(data, types.MediaType(artifactType))})
+	if err != nil {
+		return "", fmt.Errorf("failed to build artifact for %q: %w", name, err)
+	}
+
+	artifact, ok := mutate.Subject(img, *subject).(v1.Image)
+	if !ok {
+		return "", fmt.Errorf("failed to set subject of artifact for %q", name)
+	}
+
+	digest, err := artifact.Digest()
+	if err != nil {
+		return "", fmt.Errorf("failed to compute digest of artifact for %q: %w", name, err)
+	}
+
+	if err := remote.Write(ref.Context().Digest(digest.String()), artifact, p.remoteOptions(ctx)...); err != nil {
+		return "", fmt.Errorf("failed to push artifact for %q: %w", name, err)
+	}
+
+	return digest.String(), nil
+}
+
+// Referrers returns the digests of every artifact whose subject is the given
+// digest, in the same repository as the given tag.
+func (p *Publisher) Referrers(ctx context.Context, name, digest string) ([]string, error) {
+	ref, err := nameTag(name)
+	if err != nil {
+		return nil, err
+	}
+
+	idx, err := remote.Referrers(ref.Context().Digest(digest), p.remoteOptions(ctx)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list referrers of %q: %w", name, err)
+	}
+
+	manifest, err := idx.IndexManifest()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list referrers of %q: %w", name, err)
+	}
+
+	var digests []string
+	for _, desc := range manifest.Manifests {
+		digests = append(digests, desc.Digest.String())
+	}
 
-		imageNames[i] = t.PublishedTag
+	return digests, nil
+}
+
+// DeleteReferrers deletes every artifact whose subject is the given digest,
+// along with the fallback tag used to track them in registries which don't
+// support the referrers API.
+func (p *Publisher) DeleteReferrers(ctx context.Context, name, digest string) error {
+	referrers, err := p.Referrers(ctx, name, digest)
+	if err != nil {
+		return err
 	}
 
-	log.Printf("Creating manifest list %q", name)
-	if err := docker.CreateManifestList(ctx, name, imageNames); err != nil {
-		log.Printf("Failed to create manifest list with name %q - ensure no existing manifest list exists with the same name, and ensure all member images are pushed to the remote registry.", name)
+	ref, err := nameTag(name)
+	if err != nil {
 		return err
 	}
 
-	for _, t := range tars {
-		a := manifestListAnnotationsForOSArch(t.OS(), t.Architecture())
-		log.Printf("Annotating image %q with os=%q, arch=%q, variant=%q", t.PublishedTag, a.os, a.arch, a.variant)
-		if err := docker.AnnotateManifestList(ctx, name, t.PublishedTag, a.os, a.arch, a.variant); err != nil {
-			log.Printf("Failed to annotate manifest list with os/arch information.")
-			return err
+	for _, referrer := range referrers {
+		log.Printf("Deleting artifact %s attached to %q", referrer, name)
+		if err := remote.Delete(ref.Context().Digest(referrer), p.remoteOptions(ctx)...); err != nil {
+			return fmt.Errorf("failed to delete artifact %s attached to %q: %w", referrer, name, err)
 		}
 	}
 
-	log.Printf("Created manifest list %q", name)
+	fallbackTag, err := cosignTag(name, digest, "")
+	if err != nil {
+		return err
+	}
+
+	fallbackDigest, err := p.RemoteDigest(ctx, fallbackTag)
+	if err != nil {
+		return err
+	}
+
+	if fallbackDigest != "" {
+		return p.Delete(ctx, fallbackTag)
+	}
+
 	return nil
 }
 
-type manifestAnnotation struct {
-	os, arch, variant string
+// Signature is a payload signed by cosign, along with its signature.
+type Signature struct {
+	Payload   []byte
+	Signature []byte
 }
 
-func manifestListAnnotationsForOSArch(os, arch string) manifestAnnotation {
-	if arch == "arm" {
-		return manifestAnnotation{
-			os:      os,
-			arch:    arch,
-			variant: "v7",
+// Signatures returns every signature stored by cosign for the given digest, in
+// the same repository as the given tag. Nothing is returned if there are no
+// signatures.
+func (p *Publisher) Signatures(ctx context.Context, name, digest string) ([]Signature, error) {
+	sigTag, err := SignatureTag(name, digest)
+	if err != nil {
+		return nil, err
+	}
+
+	ref, err := nameTag(sigTag)
+	if err != nil {
+		return nil, err
+	}
+
+	img, err := remote.Image(ref, p.remoteOptions(ctx)...)
+	var terr *transport.Error
+	if errors.As(err, &terr) && terr.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch signatures %q: %w", sigTag, err)
+	}
+
+	manifest, err := img.Manifest()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signatures %q: %w", sigTag, err)
+	}
+
+	var signatures []Signature
+	for _, desc := range manifest.Layers {
+		if desc.MediaType != cosign.SimpleSigningMediaType {
+			continue
+		}
+
+		signature, err := base64.StdEncoding.DecodeString(desc.Annotations[cosign.SignatureAnnotation])
+		if err != nil {
+			return nil, fmt.Errorf("invalid signature in %q: %w", sigTag, err)
+		}
+
+		layer, err := img.LayerByDigest(desc.Digest)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read signed payload in %q: %w", sigTag, err)
+		}
+
+		payload, err := readLayer(layer)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read signed payload in %q: %w", sigTag, err)
 		}
+
+		signatures = append(signatures, Signature{Payload: payload, Signature: signature})
+	}
+
+	return signatures, nil
+}
+
+// SignatureTag returns the tag which cosign stores the signature for the
+// given digest under, in the same repository as the given tag.
+func SignatureTag(name, digest string) (string, error) {
+	return cosignTag(name, digest, "sig")
+}
+
+// AttestationTag returns the tag which cosign stores attestations for the
+// given digest under, in the same repository as the given tag.
+func AttestationTag(name, digest string) (string, error) {
+	return cosignTag(name, digest, "att")
+}
+
+// cosignTag returns the tag "<algorithm>-<hex>.<suffix>" for the given digest.
+// Without a suffix this is also the fallback tag for the referrers API.
+func cosignTag(name, digest, suffix string) (string, error) {
+	ref, err := nameTag(name)
+	if err != nil {
+		return "", err
+	}
+
+	h, err := v1.NewHash(digest)
+	if err != nil {
+		return "", fmt.Errorf("invalid digest %q: %w", digest, err)
+	}
+
+	tag := fmt.Sprintf("%s-%s", h.Algorithm, h.Hex)
+	if suffix != "" {
+		tag += "." + suffix
+	}
+
+	return ref.Context().Tag(tag).String(), nil
+}
+
+// readLayer returns the content of the given layer exactly as it was stored,
+// which for signed payloads is not compressed.
+func readLayer(layer v1.Layer) ([]byte, error) {
+	rc, err := layer.Compressed()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	return io.ReadAll(rc)
+}
+
+func (p *Publisher) remoteOptions(ctx context.Context) []remote.Option {
+	return append([]remote.Option{remote.WithContext(ctx)}, p.options...)
+}
+
+func nameTag(s string) (name.Tag, error) {
+	ref, err := name.NewTag(s)
+	if err != nil {
+		return name.Tag{}, fmt.Errorf("invalid image tag %q: %w", s, err)
 	}
-	if arch == "arm64" {
-		return manifestAnnotation{
-			os:      os,
-			arch:    arch,
-			variant: "v8",
+	return ref, nil
+}
+
+// imagePlatform reads the platform from the given image's config blob and
+// checks it against the platform the image was staged for.
+func imagePlatform(t *images.Tar, img v1.Image) (*v1.Platform, error) {
+	cfg, err := img.ConfigFile()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config for image %q: %w", t.RawImageName(), err)
+	}
+
+	platform := cfg.Platform()
+	if platform == nil {
+		return nil, fmt.Errorf("image %q does not declare a platform in its config", t.RawImageName())
+	}
+
+	if platform.OS != t.OS() || platform.Architecture != t.Architecture() {
+		return nil, fmt.Errorf("image %q declares platform %s/%s in its config but was staged for %s/%s", t.RawImageName(), platform.OS, platform.Architecture, t.OS(), t.Architecture())
+	}
+
+	if !sets.NewString(release.ServerPlatforms[platform.OS]...).Has(platform.Architecture) {
+		return nil, fmt.Errorf("image %q has platform %s/%s which is not a server platform", t.RawImageName(), platform.OS, platform.Architecture)
+	}
+
+	return platform, nil
+}
+
+// ociLayerMediaTypes maps Docker layer media types to their OCI equivalents.
+var ociLayerMediaTypes = map[types.MediaType]types.MediaType{
+	types.DockerLayer:             types.OCILayer,
+	types.DockerUncompressedLayer: types.OCIUncompressedLayer,
+	types.DockerForeignLayer:      types.OCIRestrictedLayer,
+}
+
+// ociImage reads the image stored in the given tar and converts it to use
+// OCI media types. 'docker save' always produces Docker media types, which
+// shouldn't be mixed with an OCI image index.
+func ociImage(t *images.Tar) (v1.Image, error) {
+	img, err := t.Image()
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := img.ConfigFile()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config for image %q: %w", t.RawImageName(), err)
+	}
+
+	layers, err := img.Layers()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read layers for image %q: %w", t.RawImageName(), err)
+	}
+
+	addenda := make([]mutate.Addendum, len(layers))
+	for i, layer := range layers {
+		mediaType, err := layer.MediaType()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read layer media type for image %q: %w", t.RawImageName(), err)
+		}
+		if ociType, ok := ociLayerMediaTypes[mediaType]; ok {
+			mediaType = ociType
 		}
+		addenda[i] = mutate.Addendum{Layer: layer, MediaType: mediaType}
 	}
-	return manifestAnnotation{
-		os:   os,
-		arch: arch,
+
+	base := mutate.ConfigMediaType(mutate.MediaType(empty.Image, types.OCIManifestSchema1), types.OCIConfigJSON)
+	converted, err := mutate.Append(base, addenda...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert image %q to OCI: %w", t.RawImageName(), err)
 	}
+
+	// restore the original config, including history and rootfs
+	return mutate.ConfigFile(converted, cfg)
 }
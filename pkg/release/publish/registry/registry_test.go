@@ -0,0 +1,352 @@
+/*
+Copyright 2021 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package registry
+
+import (
+	"context"
+	"encoding/base64"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+
+	"github.com/cert-manager/release/internal/testregistry"
+	"github.com/cert-manager/release/pkg/release/images"
+	"github.com/cert-manager/release/pkg/sign/cosign"
+)
+
+// writeTestImageTar writes a random image to a tar file in the same format
+// as 'docker save', and returns it as an images.Tar staged for the given
+// platform. The image config declares configPlatform.
+func writeTestImageTar(t *testing.T, rawImageName, osName, arch string, configPlatform v1.Platform) *images.Tar {
+	t.Helper()
+	img, err := random.Image(256, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := img.ConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg = cfg.DeepCopy()
+	cfg.OS = configPlatform.OS
+	cfg.Architecture = configPlatform.Architecture
+	cfg.Variant = configPlatform.Variant
+	cfg.OSVersion = configPlatform.OSVersion
+	img, err = mutate.ConfigFile(img, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tag, err := name.NewTag(rawImageName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "image.tar")
+	if err := tarball.WriteToFile(path, tag, img); err != nil {
+		t.Fatal(err)
+	}
+
+	tar, err := images.NewTar(path, osName, arch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tar
+}
+
+func TestPublisherPushesImagesAndImageIndex(t *testing.T) {
+	ctx := context.TODO()
+	host := testregistry.New(t)
+
+	tars := []*images.Tar{
+		writeTestImageTar(t, "quay.io/jetstack/cert-manager-controller-amd64:v1.15.0", "linux", "amd64", v1.Platform{OS: "linux", Architecture: "amd64"}),
+		writeTestImageTar(t, "quay.io/jetstack/cert-manager-controller-arm:v1.15.0", "linux", "arm", v1.Platform{OS: "linux", Architecture: "arm", Variant: "v7"}),
+	}
+
+	publisher := NewPublisher()
+	for _, tar := range tars {
+		tag := host + "/jetstack/cert-manager-controller-" + tar.Architecture() + ":v1.15.0"
+		if err := publisher.PushImage(ctx, tar, tag); err != nil {
+			t.Fatal(err)
+		}
+		if tar.PublishedTag != tag || tar.PublishedDigest == "" {
+			t.Errorf("expected PublishedTag and PublishedDigest to be set, got %q and %q", tar.PublishedTag, tar.PublishedDigest)
+		}
+	}
+
+	indexName := host + "/jetstack/cert-manager-controller:v1.15.0"
+	idx, err := CreateImageIndex(indexName, tars)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := publisher.PushImageIndex(ctx, indexName, idx); err != nil {
+		t.Fatal(err)
+	}
+
+	ref, err := name.ParseReference(indexName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pushed, err := remote.Index(ref, remote.WithContext(ctx))
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := pushed.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if manifest.MediaType != types.OCIImageIndex {
+		t.Errorf("expected pushed index to have media type %q, got %q", types.OCIImageIndex, manifest.MediaType)
+	}
+	if len(manifest.Manifests) != 2 {
+		t.Fatalf("expected 2 manifests in pushed image index, got %d", len(manifest.Manifests))
+	}
+	for i, desc := range manifest.Manifests {
+		if desc.MediaType != types.OCIManifestSchema1 {
+			t.Errorf("manifest %d: expected media type %q, got %q", i, types.OCIManifestSchema1, desc.MediaType)
+		}
+		if desc.Digest.String() != tars[i].PublishedDigest {
+			t.Errorf("manifest %d: expected digest %q, got %q", i, tars[i].PublishedDigest, desc.Digest)
+		}
+		if desc.Platform == nil || desc.Platform.Architecture != tars[i].Architecture() {
+			t.Errorf("manifest %d: expected architecture %q, got %#v", i, tars[i].Architecture(), desc.Platform)
+		}
+	}
+	if v := manifest.Manifests[1].Platform.Variant; v != "v7" {
+		t.Errorf("expected arm image to have variant v7 from its config, got %q", v)
+	}
+}
+
+func TestCreateImageIndexRequiresPushedImages(t *testing.T) {
+	tar := writeTestImageTar(t, "quay.io/jetstack/cert-manager-controller-amd64:v1.15.0", "linux", "amd64", v1.Platform{OS: "linux", Architecture: "amd64"})
+
+	if _, err := CreateImageIndex("example.com/cert-manager-controller:v1.15.0", []*images.Tar{tar}); err == nil {
+		t.Errorf("expected an error when building an image index from an image which hasn't been pushed")
+	}
+}
+
+func TestIndexEntriesValidatesPlatform(t *testing.T) {
+	tests := map[string]struct {
+		arch           string
+		configPlatform v1.Platform
+		expErr         bool
+	}{
+		"matching platform is accepted": {
+			arch:           "s390x",
+			configPlatform: v1.Platform{OS: "linux", Architecture: "s390x"},
+		},
+		"os.version is passed through": {
+			arch:           "amd64",
+			configPlatform: v1.Platform{OS: "linux", Architecture: "amd64", OSVersion: "6.1"},
+		},
+		"config architecture differing from staged architecture is rejected": {
+			arch:           "arm64",
+			configPlatform: v1.Platform{OS: "linux", Architecture: "amd64"},
+			expErr:         true,
+		},
+		"missing platform is rejected": {
+			arch:   "amd64",
+			expErr: true,
+		},
+		"non-server platform is rejected": {
+			arch:           "riscv64",
+			configPlatform: v1.Platform{OS: "linux", Architecture: "riscv64"},
+			expErr:         true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			tar := writeTestImageTar(t, "quay.io/jetstack/cert-manager-controller-"+test.arch+":v1.15.0", "linux", test.arch, test.configPlatform)
+			tar.PublishedTag = "example.com/cert-manager-controller-" + test.arch + ":v1.15.0"
+
+			entries, err := IndexEntries([]*images.Tar{tar})
+			if test.expErr {
+				if err == nil {
+					t.Errorf("expected an error but got entries %#v", entries)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			e := entries[0]
+			if e.OS != test.configPlatform.OS || e.Arch != test.configPlatform.Architecture || e.OSVersion != test.configPlatform.OSVersion {
+				t.Errorf("expected entry to match config platform %#v, got %#v", test.configPlatform, e)
+			}
+		})
+	}
+}
+
+func TestPublisherTagsImages(t *testing.T) {
+	ctx := context.TODO()
+	host := testregistry.New(t)
+
+	publisher := NewPublisher()
+
+	var digests []string
+	for _, version := range []string{"v1.15.0", "v1.15.1"} {
+		tar := writeTestImageTar(t, "quay.io/jetstack/cert-manager-controller-amd64:"+version, "linux", "amd64", v1.Platform{OS: "linux", Architecture: "amd64"})
+		if err := publisher.PushImage(ctx, tar, host+"/jetstack/cert-manager-controller-amd64:"+version); err != nil {
+			t.Fatal(err)
+		}
+		digests = append(digests, tar.PublishedDigest)
+	}
+
+	name := host + "/jetstack/cert-manager-controller-amd64:v1.15.0"
+	for _, digest := range digests {
+		if err := publisher.Tag(ctx, name, digest, "v1.15"); err != nil {
+			t.Fatal(err)
+		}
+
+		// the tag is moved to the latest digest
+		remoteDigest, err := publisher.RemoteDigest(ctx, host+"/jetstack/cert-manager-controller-amd64:v1.15")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if remoteDigest != digest {
+			t.Errorf("expected v1.15 to be tagged with %q, got %q", digest, remoteDigest)
+		}
+	}
+
+	tags, err := publisher.Tags(ctx, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"v1.15", "v1.15.0", "v1.15.1"}; !slices.Equal(tags, expected) {
+		t.Errorf("expected tags %v, got %v", expected, tags)
+	}
+
+	if err := publisher.Tag(ctx, name, "sha256:"+strings.Repeat("0", 64), "v1.15"); err == nil {
+		t.Errorf("expected tagging a missing manifest to fail")
+	}
+}
+
+func TestPublisherAttachesArtifacts(t *testing.T) {
+	ctx := context.TODO()
+	host := testregistry.New(t)
+
+	tar := writeTestImageTar(t, "quay.io/jetstack/cert-manager-controller-amd64:v1.15.0", "linux", "amd64", v1.Platform{OS: "linux", Architecture: "amd64"})
+	tag := host + "/jetstack/cert-manager-controller-amd64:v1.15.0"
+
+	publisher := NewPublisher()
+	if err := publisher.PushImage(ctx, tar, tag); err != nil {
+		t.Fatal(err)
+	}
+
+	var attached []string
+	for _, artifactType := range []string{"application/spdx+json", "application/vnd.cyclonedx+json"} {
+		digest, err := publisher.AttachArtifact(ctx, tag, tar.PublishedDigest, artifactType, []byte(`{}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		attached = append(attached, digest)
+	}
+
+	referrers, err := publisher.Referrers(ctx, tag, tar.PublishedDigest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(referrers) != 2 {
+		t.Fatalf("expected 2 referrers, got %v", referrers)
+	}
+	for _, digest := range attached {
+		found := false
+		for _, r := range referrers {
+			found = found || r == digest
+		}
+		if !found {
+			t.Errorf("expected attached artifact %s to be listed as a referrer, got %v", digest, referrers)
+		}
+	}
+
+	if err := publisher.DeleteReferrers(ctx, tag, tar.PublishedDigest); err != nil {
+		t.Fatal(err)
+	}
+
+	referrers, err = publisher.Referrers(ctx, tag, tar.PublishedDigest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(referrers) != 0 {
+		t.Errorf("expected no referrers after deleting them, got %v", referrers)
+	}
+}
+
+func TestPublisherReadsSignatures(t *testing.T) {
+	ctx := context.TODO()
+	host := testregistry.New(t)
+
+	tar := writeTestImageTar(t, "quay.io/jetstack/cert-manager-controller-amd64:v1.15.0", "linux", "amd64", v1.Platform{OS: "linux", Architecture: "amd64"})
+	tag := host + "/jetstack/cert-manager-controller-amd64:v1.15.0"
+
+	publisher := NewPublisher()
+	if err := publisher.PushImage(ctx, tar, tag); err != nil {
+		t.Fatal(err)
+	}
+
+	signatures, err := publisher.Signatures(ctx, tag, tar.PublishedDigest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(signatures) != 0 {
+		t.Errorf("expected no signatures before signing, got %#v", signatures)
+	}
+
+	payload := []byte(`{"critical":{}}`)
+	img, err := mutate.Append(mutate.MediaType(empty.Image, types.OCIManifestSchema1), mutate.Addendum{
+		Layer:       static.NewLayer(payload, cosign.SimpleSigningMediaType),
+		Annotations: map[string]string{cosign.SignatureAnnotation: base64.StdEncoding.EncodeToString([]byte("signature"))},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sigTag, err := SignatureTag(tag, tar.PublishedDigest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sigRef, err := name.NewTag(sigTag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(sigRef, img); err != nil {
+		t.Fatal(err)
+	}
+
+	signatures, err = publisher.Signatures(ctx, tag, tar.PublishedDigest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(signatures) != 1 || string(signatures[0].Payload) != string(payload) || string(signatures[0].Signature) != "signature" {
+		t.Errorf("unexpected signatures: %#v", signatures)
+	}
+}
@@ -0,0 +1,255 @@
+/*
+Copyright 2021 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sbom
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Format is a serialization format for an SBOM
+type Format string
+
+const (
+	FormatSPDX      Format = "spdx"
+	FormatCycloneDX Format = "cyclonedx"
+)
+
+// Formats lists every supported format
+var Formats = []Format{FormatSPDX, FormatCycloneDX}
+
+const (
+	// toolName is recorded as the creator of every SBOM
+	toolName = "cmrel"
+
+	// namespacePrefix is used to build unique, but reproducible, SPDX document namespaces
+	namespacePrefix = "https://cert-manager.io/spdx/"
+)
+
+// FileExtension returns the file extension conventionally used for the format
+func (f Format) FileExtension() string {
+	switch f {
+	case FormatSPDX:
+		return ".spdx.json"
+	case FormatCycloneDX:
+		return ".cdx.json"
+	default:
+		return ".json"
+	}
+}
+
+// MediaType returns the media type of the format, used when attaching SBOMs to images
+func (f Format) MediaType() string {
+	switch f {
+	case FormatSPDX:
+		return "application/spdx+json"
+	case FormatCycloneDX:
+		return "application/vnd.cyclonedx+json"
+	default:
+		return "application/json"
+	}
+}
+
+// Encode serializes the document in the given format
+func (d *Document) Encode(f Format) ([]byte, error) {
+	switch f {
+	case FormatSPDX:
+		return d.SPDX()
+	case FormatCycloneDX:
+		return d.CycloneDX()
+	default:
+		return nil, fmt.Errorf("unknown SBOM format %q", f)
+	}
+}
+
+type spdxDocument struct {
+	SPDXVersion       string             `json:"spdxVersion"`
+	DataLicense       string             `json:"dataLicense"`
+	SPDXID            string             `json:"SPDXID"`
+	Name              string             `json:"name"`
+	DocumentNamespace string             `json:"documentNamespace"`
+	CreationInfo      spdxCreationInfo   `json:"creationInfo"`
+	Packages          []spdxPackage      `json:"packages"`
+	Relationships     []spdxRelationship `json:"relationships"`
+}
+
+type spdxCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+type spdxPackage struct {
+	Name             string            `json:"name"`
+	SPDXID           string            `json:"SPDXID"`
+	VersionInfo      string            `json:"versionInfo,omitempty"`
+	DownloadLocation string            `json:"downloadLocation"`
+	FilesAnalyzed    bool              `json:"filesAnalyzed"`
+	Checksums        []spdxChecksum    `json:"checksums,omitempty"`
+	ExternalRefs     []spdxExternalRef `json:"externalRefs,omitempty"`
+}
+
+type spdxChecksum struct {
+	Algorithm     string `json:"algorithm"`
+	ChecksumValue string `json:"checksumValue"`
+}
+
+type spdxExternalRef struct {
+	ReferenceCategory string `json:"referenceCategory"`
+	ReferenceType     string `json:"referenceType"`
+	ReferenceLocator  string `json:"referenceLocator"`
+}
+
+type spdxRelationship struct {
+	SPDXElementID      string `json:"spdxElementId"`
+	RelationshipType   string `json:"relationshipType"`
+	RelatedSPDXElement string `json:"relatedSpdxElement"`
+}
+
+// SPDX serializes the document as SPDX 2.3 JSON
+func (d *Document) SPDX() ([]byte, error) {
+	const rootID = "SPDXRef-Subject"
+
+	root := spdxPackage{
+		Name:             d.Subject.Name,
+		SPDXID:           rootID,
+		VersionInfo:      d.Subject.Version,
+		DownloadLocation: "NOASSERTION",
+	}
+	if algorithm, hex, ok := strings.Cut(d.Subject.Digest, ":"); ok {
+		root.Checksums = []spdxChecksum{{Algorithm: strings.ToUpper(algorithm), ChecksumValue: hex}}
+	}
+
+	doc := spdxDocument{
+		SPDXVersion:       "SPDX-2.3",
+		DataLicense:       "CC0-1.0",
+		SPDXID:            "SPDXRef-DOCUMENT",
+		Name:              d.Subject.Name,
+		DocumentNamespace: namespacePrefix + d.uniqueID(),
+		CreationInfo: spdxCreationInfo{
+			Created:  d.Created.Format(time.RFC3339),
+			Creators: []string{"Tool: " + toolName},
+		},
+		Packages: []spdxPackage{root},
+		Relationships: []spdxRelationship{
+			{SPDXElementID: "SPDXRef-DOCUMENT", RelationshipType: "DESCRIBES", RelatedSPDXElement: rootID},
+		},
+	}
+
+	for i, p := range d.Packages {
+		id := fmt.Sprintf("SPDXRef-Package-%d", i)
+		doc.Packages = append(doc.Packages, spdxPackage{
+			Name:             p.Name,
+			SPDXID:           id,
+			VersionInfo:      p.Version,
+			DownloadLocation: "NOASSERTION",
+			ExternalRefs: []spdxExternalRef{
+				{ReferenceCategory: "PACKAGE-MANAGER", ReferenceType: "purl", ReferenceLocator: p.PURL},
+			},
+		})
+		doc.Relationships = append(doc.Relationships, spdxRelationship{SPDXElementID: rootID, RelationshipType: "CONTAINS", RelatedSPDXElement: id})
+	}
+
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+type cdxDocument struct {
+	BOMFormat    string         `json:"bomFormat"`
+	SpecVersion  string         `json:"specVersion"`
+	SerialNumber string         `json:"serialNumber"`
+	Version      int            `json:"version"`
+	Metadata     cdxMetadata    `json:"metadata"`
+	Components   []cdxComponent `json:"components"`
+}
+
+type cdxMetadata struct {
+	Timestamp string       `json:"timestamp"`
+	Tools     []cdxTool    `json:"tools"`
+	Component cdxComponent `json:"component"`
+}
+
+type cdxTool struct {
+	Name string `json:"name"`
+}
+
+type cdxComponent struct {
+	BOMRef  string    `json:"bom-ref,omitempty"`
+	Type    string    `json:"type"`
+	Name    string    `json:"name"`
+	Version string    `json:"version,omitempty"`
+	PURL    string    `json:"purl,omitempty"`
+	Hashes  []cdxHash `json:"hashes,omitempty"`
+}
+
+type cdxHash struct {
+	Alg     string `json:"alg"`
+	Content string `json:"content"`
+}
+
+// CycloneDX serializes the document as CycloneDX 1.5 JSON
+func (d *Document) CycloneDX() ([]byte, error) {
+	root := cdxComponent{
+		Type:    "application",
+		Name:    d.Subject.Name,
+		Version: d.Subject.Version,
+	}
+	if d.Kind == KindImage {
+		root.Type = "container"
+	}
+	if algorithm, hex, ok := strings.Cut(d.Subject.Digest, ":"); ok && algorithm == "sha256" {
+		root.Hashes = []cdxHash{{Alg: "SHA-256", Content: hex}}
+	}
+
+	doc := cdxDocument{
+		BOMFormat:    "CycloneDX",
+		SpecVersion:  "1.5",
+		SerialNumber: "urn:uuid:" + d.uniqueID(),
+		Version:      1,
+		Metadata: cdxMetadata{
+			Timestamp: d.Created.Format(time.RFC3339),
+			Tools:     []cdxTool{{Name: toolName}},
+			Component: root,
+		},
+		Components: []cdxComponent{},
+	}
+
+	for _, p := range d.Packages {
+		doc.Components = append(doc.Components, cdxComponent{
+			BOMRef:  p.PURL,
+			Type:    "library",
+			Name:    p.Name,
+			Version: p.Version,
+			PURL:    p.PURL,
+		})
+	}
+
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+// uniqueID returns a UUID derived from the subject, so that the same artifact
+// always gets the same document namespace and serial number.
+func (d *Document) uniqueID() string {
+	sum := sha256.Sum256([]byte(d.Subject.Name + "@" + d.Subject.Digest))
+
+	// set the version (5, name-based) and variant bits of the UUID
+	sum[6] = (sum[6] & 0x0f) | 0x50
+	sum[8] = (sum[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
@@ -0,0 +1,274 @@
+/*
+Copyright 2021 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package sbom generates software bills of materials for release artifacts,
+// in both SPDX and CycloneDX JSON formats.
+package sbom
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"debug/buildinfo"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+)
+
+// Kind is the kind of artifact an SBOM describes
+type Kind string
+
+const (
+	KindImage  Kind = "image"
+	KindBinary Kind = "binary"
+)
+
+// Subject identifies the artifact an SBOM describes.
+type Subject struct {
+	// Name is the name of the artifact, e.g. an image repository or binary name
+	Name string
+
+	// Version is the version of cert-manager the artifact is part of
+	Version string
+
+	// Digest is the digest of the artifact, of the form "sha256:<hex>"
+	Digest string
+}
+
+// Package is a single piece of software found in an artifact.
+type Package struct {
+	Name    string
+	Version string
+
+	// PURL is the package URL of the package, see https://github.com/package-url/purl-spec
+	PURL string
+}
+
+// Document is a software bill of materials for a single artifact, which can be
+// serialized as either SPDX or CycloneDX.
+type Document struct {
+	Kind    Kind
+	Subject Subject
+
+	// Created is the time the SBOM is recorded as having been created.
+	// Documents are only reproducible if this is fixed.
+	Created time.Time
+
+	// Packages are sorted by PURL
+	Packages []Package
+}
+
+// FromImage creates an SBOM for the given container image by walking the
+// files in its layers, finding OS packages installed by dpkg or apk and
+// reading the build info from any Go binaries.
+func FromImage(img v1.Image, subject Subject, created time.Time) (*Document, error) {
+	rc := mutate.Extract(img)
+	defer rc.Close()
+
+	var packages []Package
+
+	tr := tar.NewReader(rc)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read layers of %q: %w", subject.Name, err)
+		}
+
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		name := strings.TrimPrefix(path.Clean("/"+header.Name), "/")
+
+		switch {
+		case name == "var/lib/dpkg/status" || isDistrolessStatusFile(name):
+			found, err := parseDpkgStatus(tr)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read dpkg database %q in %q: %w", name, subject.Name, err)
+			}
+			packages = append(packages, found...)
+
+		case name == "lib/apk/db/installed":
+			found, err := parseApkInstalled(tr)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read apk database %q in %q: %w", name, subject.Name, err)
+			}
+			packages = append(packages, found...)
+
+		case header.Mode&0o111 != 0:
+			data, err := io.ReadAll(tr)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %q in %q: %w", name, subject.Name, err)
+			}
+
+			// files which aren't Go binaries are ignored
+			if found, err := goPackages(data); err == nil {
+				packages = append(packages, found...)
+			}
+		}
+	}
+
+	return newDocument(KindImage, subject, created, packages), nil
+}
+
+// FromGoBinary creates an SBOM for the given Go binary from its embedded
+// build info.
+func FromGoBinary(binary []byte, subject Subject, created time.Time) (*Document, error) {
+	packages, err := goPackages(binary)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Go build info from %q: %w", subject.Name, err)
+	}
+
+	return newDocument(KindBinary, subject, created, packages), nil
+}
+
+func newDocument(kind Kind, subject Subject, created time.Time, packages []Package) *Document {
+	seen := map[string]bool{}
+	var unique []Package
+	for _, p := range packages {
+		if seen[p.PURL] {
+			continue
+		}
+		seen[p.PURL] = true
+		unique = append(unique, p)
+	}
+
+	sort.Slice(unique, func(i, j int) bool {
+		return unique[i].PURL < unique[j].PURL
+	})
+
+	return &Document{
+		Kind:     kind,
+		Subject:  subject,
+		Created:  created.UTC(),
+		Packages: unique,
+	}
+}
+
+// isDistrolessStatusFile returns true for the per-package dpkg status files
+// used by distroless images, ignoring the md5sums files alongside them.
+func isDistrolessStatusFile(name string) bool {
+	return path.Dir(name) == "var/lib/dpkg/status.d" && !strings.Contains(path.Base(name), ".")
+}
+
+func goPackages(binary []byte) ([]Package, error) {
+	info, err := buildinfo.Read(bytes.NewReader(binary))
+	if err != nil {
+		return nil, err
+	}
+
+	packages := []Package{
+		goPackage("stdlib", strings.TrimPrefix(info.GoVersion, "go")),
+		goPackage(info.Main.Path, info.Main.Version),
+	}
+
+	for _, dep := range info.Deps {
+		if dep.Replace != nil {
+			dep = dep.Replace
+		}
+		packages = append(packages, goPackage(dep.Path, dep.Version))
+	}
+
+	return packages, nil
+}
+
+func goPackage(name, version string) Package {
+	return Package{
+		Name:    name,
+		Version: version,
+		PURL:    fmt.Sprintf("pkg:golang/%s@%s", name, url.PathEscape(version)),
+	}
+}
+
+// parseDpkgStatus parses the paragraphs of a dpkg status database
+func parseDpkgStatus(r io.Reader) ([]Package, error) {
+	var packages []Package
+	err := parseParagraphs(r, ": ", func(fields map[string]string) {
+		if fields["Package"] == "" {
+			return
+		}
+		// the main status database lists packages which have been removed
+		if status, ok := fields["Status"]; ok && !strings.HasSuffix(status, " installed") {
+			return
+		}
+		packages = append(packages, Package{
+			Name:    fields["Package"],
+			Version: fields["Version"],
+			PURL:    fmt.Sprintf("pkg:deb/debian/%s@%s?arch=%s", fields["Package"], url.PathEscape(fields["Version"]), fields["Architecture"]),
+		})
+	})
+	return packages, err
+}
+
+// parseApkInstalled parses the paragraphs of an apk installed database
+func parseApkInstalled(r io.Reader) ([]Package, error) {
+	var packages []Package
+	err := parseParagraphs(r, ":", func(fields map[string]string) {
+		if fields["P"] == "" {
+			return
+		}
+		packages = append(packages, Package{
+			Name:    fields["P"],
+			Version: fields["V"],
+			PURL:    fmt.Sprintf("pkg:apk/alpine/%s@%s?arch=%s", fields["P"], url.PathEscape(fields["V"]), fields["A"]),
+		})
+	})
+	return packages, err
+}
+
+// parseParagraphs calls fn with the fields of each blank-line separated
+// paragraph of "<key><sep><value>" lines. Continuation lines are ignored.
+func parseParagraphs(r io.Reader, sep string, fn func(map[string]string)) error {
+	fields := map[string]string{}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if strings.TrimSpace(line) == "" {
+			if len(fields) > 0 {
+				fn(fields)
+				fields = map[string]string{}
+			}
+			continue
+		}
+
+		if strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
+			continue
+		}
+
+		if key, value, ok := strings.Cut(line, sep); ok {
+			fields[key] = strings.TrimSpace(value)
+		}
+	}
+
+	if len(fields) > 0 {
+		fn(fields)
+	}
+
+	return scanner.Err()
+}
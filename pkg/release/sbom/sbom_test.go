@@ -0,0 +1,186 @@
+/*
+Copyright 2021 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sbom
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+)
+
+// testBinary returns the currently running test binary, which is a Go binary
+// with embedded build info
+func testBinary(t *testing.T) []byte {
+	t.Helper()
+	path, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func testImage(t *testing.T, files map[string][]byte) v1.Image {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	for name, content := range files {
+		mode := int64(0o644)
+		if strings.HasPrefix(name, "usr/bin/") {
+			mode = 0o755
+		}
+		if err := tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: mode, Size: int64(len(content))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	layer, err := tarball.LayerFromOpener(func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(buf.Bytes())), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := mutate.AppendLayers(empty.Image, layer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+func hasPURL(doc *Document, prefix string) bool {
+	for _, p := range doc.Packages {
+		if strings.HasPrefix(p.PURL, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestFromImage(t *testing.T) {
+	img := testImage(t, map[string][]byte{
+		"var/lib/dpkg/status.d/tzdata":         []byte("Package: tzdata\nVersion: 2021a-1\nArchitecture: all\nDescription: time zone\n and daylight-saving time data\n"),
+		"var/lib/dpkg/status.d/tzdata.md5sums": []byte("0123  usr/share/zoneinfo/UTC\n"),
+		"lib/apk/db/installed":                 []byte("P:musl\nV:1.2.2-r3\nA:x86_64\n\nP:busybox\nV:1.33.1-r3\nA:x86_64\n"),
+		"usr/bin/controller":                   testBinary(t),
+		"etc/passwd":                           []byte("root:x:0:0:root:/root:/sbin/nologin\n"),
+	})
+
+	subject := Subject{Name: "quay.io/jetstack/cert-manager-controller-amd64", Version: "v1.15.0", Digest: "sha256:0123"}
+	doc, err := FromImage(img, subject, time.Unix(0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, prefix := range []string{
+		"pkg:deb/debian/tzdata@2021a-1?arch=all",
+		"pkg:apk/alpine/musl@1.2.2-r3?arch=x86_64",
+		"pkg:apk/alpine/busybox@1.33.1-r3?arch=x86_64",
+		"pkg:golang/stdlib@",
+	} {
+		if !hasPURL(doc, prefix) {
+			t.Errorf("expected SBOM to contain a package with PURL %q, got %#v", prefix, doc.Packages)
+		}
+	}
+
+	if len(doc.Packages) < 4 {
+		t.Errorf("expected at least 4 packages, got %d", len(doc.Packages))
+	}
+}
+
+func TestFromGoBinary(t *testing.T) {
+	doc, err := FromGoBinary(testBinary(t), Subject{Name: "cmctl", Version: "v1.15.0", Digest: "sha256:0123"}, time.Unix(0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !hasPURL(doc, "pkg:golang/stdlib@") {
+		t.Errorf("expected SBOM to contain the Go standard library, got %#v", doc.Packages)
+	}
+
+	if _, err := FromGoBinary([]byte("not a binary"), Subject{Name: "cmctl"}, time.Unix(0, 0)); err == nil {
+		t.Errorf("expected an error reading build info from a file which isn't a Go binary")
+	}
+}
+
+func TestEncode(t *testing.T) {
+	doc := &Document{
+		Kind:    KindImage,
+		Subject: Subject{Name: "quay.io/jetstack/cert-manager-controller-amd64", Version: "v1.15.0", Digest: "sha256:0123"},
+		Created: time.Unix(0, 0).UTC(),
+		Packages: []Package{
+			{Name: "tzdata", Version: "2021a-1", PURL: "pkg:deb/debian/tzdata@2021a-1?arch=all"},
+		},
+	}
+
+	for _, format := range Formats {
+		t.Run(string(format), func(t *testing.T) {
+			first, err := doc.Encode(format)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			second, err := doc.Encode(format)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(first, second) {
+				t.Errorf("expected encoding to be reproducible")
+			}
+
+			var decoded map[string]any
+			if err := json.Unmarshal(first, &decoded); err != nil {
+				t.Fatal(err)
+			}
+
+			if !strings.Contains(string(first), "pkg:deb/debian/tzdata@2021a-1?arch=all") {
+				t.Errorf("expected encoded SBOM to contain package PURL, got:\n%s", first)
+			}
+
+			switch format {
+			case FormatSPDX:
+				if decoded["spdxVersion"] != "SPDX-2.3" {
+					t.Errorf("unexpected spdxVersion %v", decoded["spdxVersion"])
+				}
+			case FormatCycloneDX:
+				if decoded["bomFormat"] != "CycloneDX" {
+					t.Errorf("unexpected bomFormat %v", decoded["bomFormat"])
+				}
+			}
+		})
+	}
+}
@@ -20,18 +20,18 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"path/filepath"
 	"strings"
-
-	"cloud.google.com/go/storage"
 )
 
-// Staged is a release build staged in a GCS bucket.
+// Staged is a release build staged in an ArtifactStore, usually a GCS bucket.
 // It provides convenience methods to interact with release build and inspect
 // metadata.
 type Staged struct {
 	name      string
 	prefix    string
+	store     ArtifactStore
 	meta      Metadata
 	artifacts []StagedArtifact
 }
@@ -39,17 +39,31 @@ type Staged struct {
 // StagedArtifact represents a single artifact within a release, with some
 // associated metadata read from the release metadata.json file.
 type StagedArtifact struct {
-	Metadata     ArtifactMetadata
-	ObjectHandle *storage.ObjectHandle
+	Metadata ArtifactMetadata
+
+	// ObjectName is the full name of the artifact within Store.
+	ObjectName string
+
+	// Store is the ArtifactStore the artifact is held in.
+	Store ArtifactStore
+}
+
+// NewReader opens the staged artifact for reading. The caller must close the
+// returned reader.
+func (a *StagedArtifact) NewReader(ctx context.Context) (io.ReadCloser, error) {
+	return a.Store.NewReader(ctx, a.ObjectName)
 }
 
-func NewStagedRelease(ctx context.Context, name, prefix string, objects ...*storage.ObjectHandle) (*Staged, error) {
-	meta, err := loadReleaseMetadataFile(ctx, objects...)
+// NewStagedRelease loads the metadata for the release with the given name
+// from the named objects in store and cross-references it with the objects
+// present.
+func NewStagedRelease(ctx context.Context, store ArtifactStore, name, prefix string, objects ...string) (*Staged, error) {
+	meta, err := loadReleaseMetadataFile(ctx, store, objects...)
 	if err != nil {
 		return nil, err
 	}
 
-	artifacts, err := crossReferenceArtifactMetadata(*meta, name, prefix, objects...)
+	artifacts, err := crossReferenceArtifactMetadata(*meta, store, name, prefix, objects...)
 	if err != nil {
 		return nil, err
 	}
@@ -57,11 +71,36 @@ func NewStagedRelease(ctx context.Context, name, prefix string, objects ...*stor
 	return &Staged{
 		name:      name,
 		prefix:    prefix,
+		store:     store,
 		meta:      *meta,
 		artifacts: artifacts,
 	}, nil
 }
 
+// LoadStagedDirectory loads a staged release from a directory on the local
+// filesystem which contains a metadata.json file alongside the artifacts it
+// references, in the same layout as a single release directory in a bucket.
+// The name of the release is the name of the directory.
+func LoadStagedDirectory(ctx context.Context, dir string) (*Staged, error) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	store, err := NewLocalArtifactStore(filepath.Dir(absDir))
+	if err != nil {
+		return nil, err
+	}
+
+	name := filepath.Base(absDir)
+	objs, err := store.List(ctx, name+"/")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list artifacts in %q: %w", dir, err)
+	}
+
+	return NewStagedRelease(ctx, store, name, "", objs...)
+}
+
 // Name will return the name of the release in the GCS bucket
 func (s Staged) Name() string {
 	return s.name
@@ -72,6 +111,13 @@ func (s Staged) Metadata() Metadata {
 	return s.meta
 }
 
+// Ledger loads the publish ledger for the release, which is stored alongside
+// its metadata.json file. An empty ledger is returned if the release has
+// never been published.
+func (s Staged) Ledger(ctx context.Context) (*Ledger, error) {
+	return LoadLedger(ctx, s.store, s.prefix+s.name+"/"+LedgerFileName)
+}
+
 // ArtifactsOfKind returns a list of staged artifacts of the type denoted by
 // `kind`. A kind may be 'server', 'manifests', 'test' etc.
 func (s Staged) ArtifactsOfKind(kind string) []StagedArtifact {
@@ -85,20 +131,20 @@ func (s Staged) ArtifactsOfKind(kind string) []StagedArtifact {
 	return objs
 }
 
-func loadReleaseMetadataFile(ctx context.Context, objs ...*storage.ObjectHandle) (*Metadata, error) {
-	var metadataObj *storage.ObjectHandle
+func loadReleaseMetadataFile(ctx context.Context, store ArtifactStore, objs ...string) (*Metadata, error) {
+	metadataObj := ""
 	for _, f := range objs {
-		if filepath.Base(f.ObjectName()) == MetadataFileName {
+		if filepath.Base(f) == MetadataFileName {
 			metadataObj = f
 			break
 		}
 	}
 
-	if metadataObj == nil {
+	if metadataObj == "" {
 		return nil, fmt.Errorf("release metadata not found")
 	}
 
-	r, err := metadataObj.NewReader(ctx)
+	r, err := store.NewReader(ctx, metadataObj)
 	if err != nil {
 		return nil, err
 	}
@@ -112,27 +158,27 @@ func loadReleaseMetadataFile(ctx context.Context, objs ...*storage.ObjectHandle)
 	return &m, nil
 }
 
-func crossReferenceArtifactMetadata(meta Metadata, name, prefix string, objs ...*storage.ObjectHandle) ([]StagedArtifact, error) {
+func crossReferenceArtifactMetadata(meta Metadata, store ArtifactStore, name, prefix string, objs ...string) ([]StagedArtifact, error) {
 	var artifacts []StagedArtifact
-	objectMap := mapifyObjectHandles(objs...)
+	objectSet := mapifyObjectNames(objs...)
 	objPrefix := prefix + name + "/"
 	for _, a := range meta.Artifacts {
-		obj, ok := objectMap[objPrefix+a.Name]
-		if !ok {
-			return nil, fmt.Errorf("artifact %q named in manifest file but not present in list of GCS objects (path tested: %s)", a.Name, objPrefix+a.Name)
+		if _, ok := objectSet[objPrefix+a.Name]; !ok {
+			return nil, fmt.Errorf("artifact %q named in manifest file but not present in list of stored objects (path tested: %s)", a.Name, objPrefix+a.Name)
 		}
 		artifacts = append(artifacts, StagedArtifact{
-			Metadata:     a,
-			ObjectHandle: obj,
+			Metadata:   a,
+			ObjectName: objPrefix + a.Name,
+			Store:      store,
 		})
 	}
 	return artifacts, nil
 }
 
-func mapifyObjectHandles(objs ...*storage.ObjectHandle) map[string]*storage.ObjectHandle {
-	m := make(map[string]*storage.ObjectHandle, len(objs))
+func mapifyObjectNames(objs ...string) map[string]struct{} {
+	m := make(map[string]struct{}, len(objs))
 	for _, obj := range objs {
-		m[obj.ObjectName()] = obj
+		m[obj] = struct{}{}
 	}
 	return m
 }
@@ -45,6 +45,9 @@ type Unpacked struct {
 	YAMLs                 []manifests.YAML
 	CtlBinaryBundles      []binaries.Archive // Only in v1.14.X and below.
 	ComponentImageBundles map[string][]*images.Tar
+
+	// BuildInputs is nil if the staged release didn't record its build inputs
+	BuildInputs *BuildInputs
 }
 
 // Unpack takes a staged release, inspects its metadata, fetches referenced
@@ -114,6 +117,7 @@ func Unpack(ctx context.Context, s *Staged) (*Unpacked, error) {
 		Charts:                charts,
 		CtlBinaryBundles:      ctlBinaryBundles,
 		ComponentImageBundles: bundles,
+		BuildInputs:           s.Metadata().BuildInputs,
 	}, nil
 }
 
@@ -294,7 +298,7 @@ func downloadStagedArtifact(ctx context.Context, a *StagedArtifact) (*os.File, e
 		return nil, err
 	}
 
-	r, err := a.ObjectHandle.NewReader(ctx)
+	r, err := a.NewReader(ctx)
 	if err != nil {
 		f.Close()
 		return nil, err
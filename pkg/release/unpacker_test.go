@@ -0,0 +1,215 @@
+/*
+Copyright 2021 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package release
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"testing"
+)
+
+type tarEntry struct {
+	name    string
+	content []byte
+}
+
+// buildTar creates a tar archive holding the given entries, adding directory
+// headers for every parent directory so that the archive can be extracted by
+// tar.UntarGz.
+func buildTar(t *testing.T, entries ...tarEntry) []byte {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	seenDirs := map[string]bool{}
+	for _, e := range entries {
+		var dirs []string
+		for dir := path.Dir(e.name); dir != "." && !seenDirs[dir]; dir = path.Dir(dir) {
+			seenDirs[dir] = true
+			dirs = append([]string{dir}, dirs...)
+		}
+		for _, dir := range dirs {
+			if err := tw.WriteHeader(&tar.Header{Name: dir + "/", Typeflag: tar.TypeDir, Mode: 0o755}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := tw.WriteHeader(&tar.Header{Name: e.name, Typeflag: tar.TypeReg, Mode: 0o644, Size: int64(len(e.content))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(e.content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func gzipBytes(t *testing.T, data []byte) []byte {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	gw := gzip.NewWriter(buf)
+	if _, err := gw.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// stageTestRelease writes a minimal but complete staged release to the given
+// store, and returns the name of the release.
+func stageTestRelease(t *testing.T, store ArtifactStore, version string, arches ...string) string {
+	t.Helper()
+	gitRef := "0123456789abcdef0123456789abcdef01234567"
+	outputDir := BucketPathForRelease(DefaultBucketPathPrefix, BuildTypeRelease, version, gitRef)
+
+	artifacts := map[string][]byte{}
+	var metas []ArtifactMetadata
+
+	chart := gzipBytes(t, buildTar(t, tarEntry{
+		name:    "cert-manager/Chart.yaml",
+		content: fmt.Appendf(nil, "name: cert-manager\nversion: %s\nappVersion: %s\n", version, version),
+	}))
+	artifacts["cert-manager-manifests.tar.gz"] = gzipBytes(t, buildTar(t,
+		tarEntry{name: "deploy/chart/cert-manager-" + version + ".tgz", content: chart},
+		tarEntry{name: "deploy/manifests/cert-manager.yaml", content: []byte("kind: List\n")},
+		tarEntry{name: "deploy/manifests/cert-manager.crds.yaml", content: []byte("kind: List\n")},
+	))
+	metas = append(metas, ArtifactMetadata{Name: "cert-manager-manifests.tar.gz"})
+
+	for _, arch := range arches {
+		var images []tarEntry
+		for _, component := range []string{"controller", "webhook"} {
+			imageManifest := fmt.Appendf(nil, `[{"RepoTags":["%s/cert-manager-%s-%s:%s"]}]`, DefaultImageRepository, component, arch, version)
+			images = append(images, tarEntry{
+				name:    "server/images/" + component + ".tar",
+				content: buildTar(t, tarEntry{name: "manifest.json", content: imageManifest}),
+			})
+		}
+		name := fmt.Sprintf("cert-manager-server-linux-%s.tar.gz", arch)
+		artifacts[name] = gzipBytes(t, buildTar(t, images...))
+		metas = append(metas, ArtifactMetadata{Name: name, OS: "linux", Architecture: arch})
+	}
+
+	for i, m := range metas {
+		sum := sha256.Sum256(artifacts[m.Name])
+		metas[i].SHA256 = hex.EncodeToString(sum[:])
+		writeObject(t, store, outputDir+"/"+m.Name, string(artifacts[m.Name]))
+	}
+
+	meta, err := json.Marshal(Metadata{
+		ReleaseVersion: version,
+		GitCommitRef:   gitRef,
+		Artifacts:      metas,
+		BuildSource:    BuildSourceMake,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeObject(t, store, outputDir+"/"+MetadataFileName, string(meta))
+
+	return NameForObjectPath(outputDir+"/", DefaultBucketPathPrefix+"/"+BuildTypeRelease+"/")
+}
+
+func TestUnpackFromLocalStore(t *testing.T) {
+	ctx := context.TODO()
+
+	store, err := NewLocalArtifactStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	name := stageTestRelease(t, store, "v1.15.0", "amd64", "arm64")
+
+	bucket := NewBucket(store, DefaultBucketPathPrefix, BuildTypeRelease)
+
+	listed, err := bucket.ListReleases(ctx, "v1.15.0", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listed) != 1 || listed[0].Name() != name {
+		t.Fatalf("expected to list exactly one release named %q but got %v", name, listed)
+	}
+
+	staged, err := bucket.GetRelease(ctx, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rel, err := Unpack(ctx, staged)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rel.ReleaseVersion != "v1.15.0" {
+		t.Errorf("wanted release version %q but got %q", "v1.15.0", rel.ReleaseVersion)
+	}
+
+	if len(rel.Charts) != 1 || rel.Charts[0].Version() != "v1.15.0" {
+		t.Errorf("expected a single chart with version v1.15.0, got %v", rel.Charts)
+	}
+
+	if len(rel.YAMLs) != 2 {
+		t.Errorf("expected 2 YAML manifests but got %d", len(rel.YAMLs))
+	}
+
+	var components []string
+	for component, tars := range rel.ComponentImageBundles {
+		components = append(components, component)
+		if len(tars) != 2 {
+			t.Errorf("expected 2 image tars for component %q but got %d", component, len(tars))
+		}
+	}
+	sort.Strings(components)
+	if fmt.Sprint(components) != "[controller webhook]" {
+		t.Errorf("unexpected components: %v", components)
+	}
+}
+
+func TestUnpackRejectsChecksumMismatch(t *testing.T) {
+	ctx := context.TODO()
+
+	store, err := NewLocalArtifactStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	name := stageTestRelease(t, store, "v1.15.0", "amd64")
+
+	// overwrite an artifact after its checksum has been recorded
+	outputDir := DefaultBucketPathPrefix + "/" + BuildTypeRelease + "/" + name
+	writeObject(t, store, outputDir+"/cert-manager-server-linux-amd64.tar.gz", "tampered")
+
+	staged, err := NewBucket(store, DefaultBucketPathPrefix, BuildTypeRelease).GetRelease(ctx, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Unpack(ctx, staged); err == nil {
+		t.Errorf("expected Unpack to fail with a mismatching checksum")
+	}
+}
@@ -51,14 +51,16 @@ func ValidateUnpackedRelease(opts Options, rel *release.Unpacked) ([]string, err
 		}
 	}
 
-	if release.CmctlIsShipped(opts.ReleaseVersion) && len(rel.CtlBinaryBundles) == 0 {
+	// CmctlIsShipped panics on versions which aren't semver, so only check
+	// for ctl binaries if we have a version we can reason about
+	if validateSemver(opts.ReleaseVersion) == nil && release.CmctlIsShipped(opts.ReleaseVersion) && len(rel.CtlBinaryBundles) == 0 {
 		violations = append(violations, fmt.Sprintf("No ctl binaries found in release - this is probably an error!"))
 	}
 	return violations, nil
 }
 
 func validateSemver(v string) error {
-	if v[0] != 'v' {
+	if len(v) == 0 || v[0] != 'v' {
 		return fmt.Errorf("version number must have a leading 'v' character")
 	}
 	// trim v prefix as the semver library only offers ParseTolerant
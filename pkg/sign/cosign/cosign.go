@@ -18,23 +18,126 @@ package cosign
 
 import (
 	"context"
+	"crypto"
+	"encoding/json"
+	"fmt"
 
 	"github.com/cert-manager/release/pkg/shell"
 	"github.com/cert-manager/release/pkg/sign"
 )
 
-// Sign calls out to cosign to sign a given container using the provided GCP key.
-func Sign(ctx context.Context, cosignPath string, containers []string, key sign.GCPKMSKey) error {
+const (
+	// SimpleSigningMediaType is the media type of each layer in a cosign
+	// signature image; the layer holds the payload which was signed.
+	SimpleSigningMediaType = "application/vnd.dev.cosign.simplesigning.v1+json"
+
+	// SignatureAnnotation is the annotation on each layer of a cosign signature
+	// image which holds the base64 encoded signature of the layer's payload.
+	SignatureAnnotation = "dev.cosignproject.cosign/signature"
+
+	// signatureType is the type of every payload signed by "cosign sign"
+	signatureType = "cosign container image signature"
+)
+
+// Payload is the "simple signing" payload which cosign signs for an image, see
+// https://github.com/containers/image/blob/main/docs/containers-signature.5.md
+type Payload struct {
+	Critical PayloadCritical `json:"critical"`
+	Optional map[string]any  `json:"optional"`
+}
+
+type PayloadCritical struct {
+	Identity PayloadIdentity `json:"identity"`
+	Image    PayloadImage    `json:"image"`
+	Type     string          `json:"type"`
+}
+
+type PayloadIdentity struct {
+	DockerReference string `json:"docker-reference"`
+}
+
+type PayloadImage struct {
+	DockerManifestDigest string `json:"docker-manifest-digest"`
+}
+
+// NewPayload returns the payload which cosign signs for the image with the given
+// digest in the given repository.
+func NewPayload(repository, digest string) *Payload {
+	return &Payload{
+		Critical: PayloadCritical{
+			Identity: PayloadIdentity{DockerReference: repository},
+			Image:    PayloadImage{DockerManifestDigest: digest},
+			Type:     signatureType,
+		},
+	}
+}
+
+// Sign calls out to cosign to sign a given container using the provided key, which
+// must be a GCP KMS key. env holds extra environment variables for cosign, such as
+// DOCKER_CONFIG to use registry credentials other than the default.
+func Sign(ctx context.Context, cosignPath string, env []string, containers []string, key sign.SigningKey) error {
+	cosignKey, err := key.CosignFormat()
+	if err != nil {
+		return err
+	}
+
 	args := append([]string{
 		"sign",
 		"--key",
-		key.CosignFormat(),
+		cosignKey,
 	}, containers...)
 
-	return shell.Command(ctx, "", cosignPath, args...)
+	return shell.CommandWithEnv(ctx, "", env, cosignPath, args...)
+}
+
+// Attest calls out to cosign to attach a signed in-toto attestation holding the given
+// predicate to a given container using the provided key, which must be a GCP KMS key.
+// env holds extra environment variables for cosign, as for Sign.
+func Attest(ctx context.Context, cosignPath string, env []string, container string, predicatePath string, predicateType string, key sign.SigningKey) error {
+	cosignKey, err := key.CosignFormat()
+	if err != nil {
+		return err
+	}
+
+	args := []string{
+		"attest",
+		"--key",
+		cosignKey,
+		"--predicate",
+		predicatePath,
+		"--type",
+		predicateType,
+		container,
+	}
+
+	return shell.CommandWithEnv(ctx, "", env, cosignPath, args...)
 }
 
 // Version calls "cosign version", both for informational purposes and as a check that the binary exists
 func Version(ctx context.Context, cosignPath string) error {
 	return shell.Command(ctx, "", cosignPath, []string{"version"}...)
 }
+
+// VerifySignature checks that the given payload, read from a cosign signature image,
+// is a signature for the image with the given digest, and that signature is a valid
+// signature of the payload made by pub. This doesn't need the cosign binary.
+func VerifySignature(pub crypto.PublicKey, digest string, payload, signature []byte) error {
+	if err := sign.VerifySignature(pub, payload, signature); err != nil {
+		return err
+	}
+
+	var p Payload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return fmt.Errorf("failed to parse signed payload: %w", err)
+	}
+
+	if p.Critical.Type != signatureType {
+		return fmt.Errorf("signed payload has unexpected type %q", p.Critical.Type)
+	}
+
+	if p.Critical.Image.DockerManifestDigest != digest {
+		return fmt.Errorf("signature is for digest %q, not %q", p.Critical.Image.DockerManifestDigest, digest)
+	}
+
+	return nil
+}
@@ -0,0 +1,133 @@
+/*
+Copyright 2021 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cosign
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/json"
+	"testing"
+)
+
+const testDigest = "sha256:4f53cda18c2baa0c0354bb5f9a3ecbe5ed12ab4d8e11ba873c2f11161202b945"
+
+func TestVerifySignature(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signRSA := func(payload []byte) []byte {
+		digest := sha512.Sum512(payload)
+		sig, err := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA512, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sig
+	}
+
+	signRSAPSS := func(payload []byte) []byte {
+		digest := sha256.Sum256(payload)
+		sig, err := rsa.SignPSS(rand.Reader, rsaKey, crypto.SHA256, digest[:], nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sig
+	}
+
+	signECDSA := func(payload []byte) []byte {
+		digest := sha256.Sum256(payload)
+		sig, err := ecdsa.SignASN1(rand.Reader, ecdsaKey, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sig
+	}
+
+	payload, err := json.Marshal(NewPayload("quay.io/jetstack/cert-manager-controller", testDigest))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	otherPayload, err := json.Marshal(NewPayload("quay.io/jetstack/cert-manager-controller", "sha256:0000"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		pub       crypto.PublicKey
+		payload   []byte
+		signature []byte
+		expectErr bool
+	}{
+		"valid RSA signature": {
+			pub:       &rsaKey.PublicKey,
+			payload:   payload,
+			signature: signRSA(payload),
+			expectErr: false,
+		},
+		"valid RSA-PSS signature": {
+			pub:       &rsaKey.PublicKey,
+			payload:   payload,
+			signature: signRSAPSS(payload),
+			expectErr: false,
+		},
+		"valid ECDSA signature": {
+			pub:       &ecdsaKey.PublicKey,
+			payload:   payload,
+			signature: signECDSA(payload),
+			expectErr: false,
+		},
+		"signature from a different key": {
+			pub:       &ecdsaKey.PublicKey,
+			payload:   payload,
+			signature: signRSA(payload),
+			expectErr: true,
+		},
+		"signature over a different payload": {
+			pub:       &rsaKey.PublicKey,
+			payload:   payload,
+			signature: signRSA(otherPayload),
+			expectErr: true,
+		},
+		"valid signature for a different digest": {
+			pub:       &rsaKey.PublicKey,
+			payload:   otherPayload,
+			signature: signRSA(otherPayload),
+			expectErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := VerifySignature(test.pub, testDigest, test.payload, test.signature)
+			if (err != nil) != test.expectErr {
+				t.Errorf("expectErr=%v, err=%v", test.expectErr, err)
+			}
+		})
+	}
+}
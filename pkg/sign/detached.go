@@ -0,0 +1,79 @@
+/*
+Copyright 2021 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sign
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+)
+
+// DetachedSignature creates an ASCII-armored, detached PGP signature over the given data
+// using the given signers. The signature can be verified with the public key produced by
+// BootstrapPGP, e.g. using "gpg --verify". If there's more than one signer, the armored
+// block holds a signature from each, any of which is enough for verification.
+func DetachedSignature(signers []Signer, data []byte) ([]byte, error) {
+	keys, err := pgpKeys(signers)
+	if err != nil {
+		return nil, err
+	}
+
+	return detachedSignature(keys, data)
+}
+
+func detachedSignature(keys []pgpKey, data []byte) ([]byte, error) {
+	out := &bytes.Buffer{}
+
+	armoredWriter, err := armor.Encode(out, openpgp.SignatureType, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not create writer for detached signature: %w", err)
+	}
+
+	for _, key := range keys {
+		if err := openpgp.DetachSign(armoredWriter, key.entity, bytes.NewReader(data), key.cfg); err != nil {
+			return nil, fmt.Errorf("failed to create detached signature: %w", err)
+		}
+	}
+
+	if err := armoredWriter.Close(); err != nil {
+		return nil, fmt.Errorf("failed to create detached signature: %w", err)
+	}
+
+	// Always add a blank line to the end of the raw output
+	fmt.Fprintf(out, "\n")
+
+	return out.Bytes(), nil
+}
+
+// VerifyDetachedSignature checks that the ASCII-armored, detached PGP signature was made
+// over the given data by the given armored public key, such as that produced by
+// BootstrapPGP.
+func VerifyDetachedSignature(publicKey PGPArmoredBlock, data []byte, signature []byte) error {
+	keyring, err := openpgp.ReadArmoredKeyRing(strings.NewReader(string(publicKey)))
+	if err != nil {
+		return fmt.Errorf("failed to read PGP public key: %w", err)
+	}
+
+	if _, err := openpgp.CheckArmoredDetachedSignature(keyring, bytes.NewReader(data), bytes.NewReader(signature), nil); err != nil {
+		return fmt.Errorf("invalid signature: %w", err)
+	}
+
+	return nil
+}
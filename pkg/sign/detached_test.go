@@ -0,0 +1,130 @@
+/*
+Copyright 2021 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sign
+
+import (
+	"bytes"
+	"crypto"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+)
+
+func TestDetachedSignature(t *testing.T) {
+	cfg := &packet.Config{DefaultHash: crypto.SHA512, RSABits: 2048}
+
+	entity, err := openpgp.NewEntity(defaultPGPIdentity.Name, "", defaultPGPIdentity.Email, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := []byte("0123456789abcdef  cert-manager.yaml\n")
+
+	signature, err := detachedSignature([]pgpKey{{entity: entity, cfg: cfg}}, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.HasPrefix(signature, []byte("-----BEGIN PGP SIGNATURE-----")) {
+		t.Errorf("expected an armored signature, got:\n%s", signature)
+	}
+
+	keyring := openpgp.EntityList{entity}
+
+	if _, err := openpgp.CheckArmoredDetachedSignature(keyring, bytes.NewReader(data), bytes.NewReader(signature), nil); err != nil {
+		t.Errorf("expected signature to verify: %v", err)
+	}
+
+	if _, err := openpgp.CheckArmoredDetachedSignature(keyring, bytes.NewReader(append(data, 'x')), bytes.NewReader(signature), nil); err == nil {
+		t.Errorf("expected signature over modified data to fail verification")
+	}
+}
+
+func TestVerifyDetachedSignature(t *testing.T) {
+	cfg := &packet.Config{DefaultHash: crypto.SHA512, RSABits: 2048}
+
+	entity, err := openpgp.NewEntity(defaultPGPIdentity.Name, "", defaultPGPIdentity.Email, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := openpgp.NewEntity(defaultPGPIdentity.Name, "", defaultPGPIdentity.Email, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := []byte("0123456789abcdef  cert-manager.yaml\n")
+
+	signature, err := detachedSignature([]pgpKey{{entity: entity, cfg: cfg}}, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		publicKey PGPArmoredBlock
+		data      []byte
+		expectErr bool
+	}{
+		"valid signature": {
+			publicKey: armoredPublicKey(t, entity),
+			data:      data,
+			expectErr: false,
+		},
+		"modified data": {
+			publicKey: armoredPublicKey(t, entity),
+			data:      append(data, 'x'),
+			expectErr: true,
+		},
+		"different key": {
+			publicKey: armoredPublicKey(t, other),
+			data:      data,
+			expectErr: true,
+		},
+		"invalid key": {
+			publicKey: "not a key",
+			data:      data,
+			expectErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := VerifyDetachedSignature(test.publicKey, test.data, signature)
+			if (err != nil) != test.expectErr {
+				t.Errorf("expectErr=%v, err=%v", test.expectErr, err)
+			}
+		})
+	}
+}
+
+func armoredPublicKey(t *testing.T, entity *openpgp.Entity) PGPArmoredBlock {
+	t.Helper()
+	out := &bytes.Buffer{}
+	w, err := armor.Encode(out, openpgp.PublicKeyType, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := entity.Serialize(w); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return PGPArmoredBlock(out.String())
+}
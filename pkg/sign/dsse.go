@@ -0,0 +1,89 @@
+/*
+Copyright 2021 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sign
+
+import (
+	"crypto"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+)
+
+// DSSEEnvelope is a signed payload in the Dead Simple Signing Envelope format, see
+// https://github.com/secure-systems-lab/dsse/blob/master/envelope.md
+type DSSEEnvelope struct {
+	PayloadType string          `json:"payloadType"`
+	Payload     string          `json:"payload"`
+	Signatures  []DSSESignature `json:"signatures"`
+}
+
+// DSSESignature is a single signature over a DSSEEnvelope's payload
+type DSSESignature struct {
+	KeyID string `json:"keyid,omitempty"`
+	Sig   string `json:"sig"`
+}
+
+// DSSE signs the given payload using the given signers, returning an envelope holding
+// both the payload and a signature from each signer.
+func DSSE(signers []Signer, payloadType string, payload []byte) (*DSSEEnvelope, error) {
+	var envelope *DSSEEnvelope
+
+	for _, signer := range signers {
+		signed, err := dsseSign(signer, signer.HashAlgo(), signer.String(), payloadType, payload)
+		if err != nil {
+			return nil, err
+		}
+
+		if envelope == nil {
+			envelope = signed
+			continue
+		}
+
+		envelope.Signatures = append(envelope.Signatures, signed.Signatures...)
+	}
+
+	if envelope == nil {
+		return nil, fmt.Errorf("no signing keys were given")
+	}
+
+	return envelope, nil
+}
+
+func dsseSign(signer crypto.Signer, hash crypto.Hash, keyID string, payloadType string, payload []byte) (*DSSEEnvelope, error) {
+	hasher := hash.New()
+	hasher.Write(dssePAE(payloadType, payload))
+
+	sig, err := signer.Sign(rand.Reader, hasher.Sum(nil), hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign DSSE payload: %w", err)
+	}
+
+	return &DSSEEnvelope{
+		PayloadType: payloadType,
+		Payload:     base64.StdEncoding.EncodeToString(payload),
+		Signatures: []DSSESignature{{
+			KeyID: keyID,
+			Sig:   base64.StdEncoding.EncodeToString(sig),
+		}},
+	}, nil
+}
+
+// dssePAE returns the pre-authentication encoding of the payload, which is what is
+// actually signed
+func dssePAE(payloadType string, payload []byte) []byte {
+	return fmt.Appendf(nil, "DSSEv1 %d %s %d %s", len(payloadType), payloadType, len(payload), payload)
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2021 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sign
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha512"
+	"encoding/base64"
+	"testing"
+)
+
+func TestDSSEPAE(t *testing.T) {
+	// example taken from the DSSE protocol specification
+	got := string(dssePAE("http://example.com/HelloWorld", []byte("hello world")))
+	expected := "DSSEv1 29 http://example.com/HelloWorld 11 hello world"
+
+	if got != expected {
+		t.Errorf("expected PAE %q, got %q", expected, got)
+	}
+}
+
+func TestDSSESign(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload := []byte(`{"_type":"https://in-toto.io/Statement/v1"}`)
+
+	envelope, err := dsseSign(key, crypto.SHA512, "test-key", "application/vnd.in-toto+json", payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(envelope.Payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(decoded) != string(payload) {
+		t.Errorf("expected envelope to hold payload %q, got %q", payload, decoded)
+	}
+
+	if len(envelope.Signatures) != 1 || envelope.Signatures[0].KeyID != "test-key" {
+		t.Fatalf("expected a single signature from test-key, got %#v", envelope.Signatures)
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(envelope.Signatures[0].Sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	digest := sha512.Sum512(dssePAE(envelope.PayloadType, payload))
+	if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA512, digest[:], sig); err != nil {
+		t.Errorf("expected signature to verify: %v", err)
+	}
+}
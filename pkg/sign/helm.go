@@ -17,25 +17,44 @@ limitations under the License.
 package sign
 
 import (
-	"context"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
 	"strings"
+	"time"
 
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/clearsign"
 	helmloader "helm.sh/helm/v4/pkg/chart/v2/loader"
 	helmsign "helm.sh/helm/v4/pkg/provenance"
 	"sigs.k8s.io/yaml"
 )
 
 // HelmChart signs a given packaged helm chart (usually a .tgz file) using the given
-// KMS key, returning the human-readable signature bytes.
-func HelmChart(ctx context.Context, key GCPKMSKey, chartPath string) ([]byte, error) {
-	signatory, err := signatoryFromKMS(ctx, key)
+// signers, returning the human-readable signature bytes. If there's more than one signer,
+// the provenance file holds a signature from each, so the chart can be verified by helm
+// with any one of their public keys.
+func HelmChart(signers []Signer, chartPath string) ([]byte, error) {
+	keys, err := pgpKeys(signers)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create KMS signer: %w", err)
+		return nil, err
 	}
 
+	return signHelmChart(keys, chartPath)
+}
+
+// signHelmChart creates a provenance file in the same format as helm. helm's own
+// Signatory.ClearSign isn't used since it always signs SHA512 digests, which KMS
+// keys can only do if they were created for SHA512.
+func signHelmChart(keys []pgpKey, chartPath string) ([]byte, error) {
 	archiveData, err := os.ReadFile(chartPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read chart %q: %w", chartPath, err)
@@ -51,35 +70,224 @@ func HelmChart(ctx context.Context, key GCPKMSKey, chartPath string) ([]byte, er
 		return nil, fmt.Errorf("failed to marshal chart metadata for %q: %w", chartPath, err)
 	}
 
-	signature, err := signatory.ClearSign(archiveData, filepath.Base(chartPath), metadataBytes)
+	message, err := helmMessageBlock(archiveData, filepath.Base(chartPath), metadataBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create provenance for %q: %w", chartPath, err)
+	}
+
+	signed, err := clearSign(keys, message)
 	if err != nil {
 		return nil, fmt.Errorf("failed to sign %q: %w", chartPath, err)
 	}
 
-	// signatory.ClearSign doesn't necessarily return an error on failure; it can silently
-	// return an empty signature and no error, so we check signature after checking the
-	// error to confirm that a signature was actually made.
+	return signed, nil
+}
+
+// clearSign clearsigns the message with each of the given keys. openpgp can clearsign
+// with multiple keys, but only using a single hash for all of them, which KMS keys with
+// different algorithms can't do. Instead the message is clearsigned by each key in turn,
+// and the signatures are combined into a single signature block.
+func clearSign(keys []pgpKey, message []byte) ([]byte, error) {
+	var text []byte
+	var hashes []string
+	signatures := &bytes.Buffer{}
+
+	for _, key := range keys {
+		out := &bytes.Buffer{}
+
+		w, err := clearsign.Encode(out, key.entity.PrivateKey, key.cfg)
+		if err != nil {
+			return nil, err
+		}
 
-	// This can happen when an incorrect KMS key type is used, which helm can't handle
-	if len(strings.TrimSpace(signature)) == 0 {
-		return nil, fmt.Errorf("got empty signature from helm signing process; this can indicate a KMS key of an incorrect type")
+		if _, err := w.Write(message); err != nil {
+			// Close isn't called, since that's what makes the signature and there's
+			// no point signing an incomplete message
+			return nil, err
+		}
+
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+
+		if len(keys) == 1 {
+			return out.Bytes(), nil
+		}
+
+		block, _ := clearsign.Decode(out.Bytes())
+		if block == nil {
+			return nil, fmt.Errorf("could not decode clearsigned message")
+		}
+
+		if _, err := io.Copy(signatures, block.ArmoredSignature.Body); err != nil {
+			return nil, fmt.Errorf("could not read signature: %w", err)
+		}
+
+		for _, hash := range block.Headers.Values("Hash") {
+			if !slices.Contains(hashes, hash) {
+				hashes = append(hashes, hash)
+			}
+		}
+
+		if text == nil {
+			// the dash-escaped message is the same for every key, and sits between the
+			// blank line after the headers and the start of the signature block
+			raw := out.Bytes()
+			start := bytes.Index(raw, []byte("\n\n"))
+			end := bytes.Index(raw, []byte("-----BEGIN PGP SIGNATURE-----"))
+			if start == -1 || end < start {
+				return nil, fmt.Errorf("could not find message in clearsigned output")
+			}
+			text = raw[start+2 : end]
+		}
+	}
+
+	out := &bytes.Buffer{}
+	out.WriteString("-----BEGIN PGP SIGNED MESSAGE-----\n")
+	if len(hashes) > 0 {
+		fmt.Fprintf(out, "Hash: %s\n", strings.Join(hashes, ","))
 	}
+	out.WriteString("\n")
+	out.Write(text)
 
-	return []byte(signature), nil
+	// clearsign.Encode doesn't write an armor checksum, so neither is this
+	armoredWriter, err := armor.EncodeWithChecksumOption(out, "PGP SIGNATURE", nil, false)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := armoredWriter.Write(signatures.Bytes()); err != nil {
+		return nil, err
+	}
+
+	if err := armoredWriter.Close(); err != nil {
+		return nil, err
+	}
+
+	return out.Bytes(), nil
 }
 
-// signatoryFromKMS creates a Helm Signatory backed by a KMS key. The Signatory can then
-// be used to sign helm charts, but won't also be usable for validating signatures.
-func signatoryFromKMS(ctx context.Context, key GCPKMSKey) (*helmsign.Signatory, error) {
-	entity, _, err := deriveEntity(ctx, key)
+// helmMessageBlock creates the signed part of a provenance file, which is the chart
+// metadata followed by the checksum of the chart archive. This matches the message
+// block created by helm.
+func helmMessageBlock(archiveData []byte, fileName string, metadataBytes []byte) ([]byte, error) {
+	checksum := sha256.Sum256(archiveData)
+
+	sums, err := yaml.Marshal(&helmsign.SumCollection{
+		Files: map[string]string{
+			fileName: "sha256:" + hex.EncodeToString(checksum[:]),
+		},
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	return &helmsign.Signatory{
-		Entity: entity,
-		// KeyRing is used for verification, which we don't support or care about
-		// in cmrel since we expect end-users to use the helm CLI for verification
-		KeyRing: nil,
-	}, nil
+	// YAML documents usually start with "---", which isn't allowed in a clearsigned
+	// message, so helm separates the documents with the YAML document end marker instead
+	message := bytes.NewBuffer(metadataBytes)
+	message.WriteString("\n...\n")
+	message.Write(sums)
+
+	return message.Bytes(), nil
+}
+
+// HelmChartVerification describes a Helm chart provenance file which was successfully
+// verified by VerifyHelmChart.
+type HelmChartVerification struct {
+	// FileName is the name of the chart archive in the provenance file
+	FileName string
+
+	// SHA256 is the checksum of the chart archive, of the form "sha256:<hex>"
+	SHA256 string
+
+	// KeyID is the ID of the PGP key which made the signature, in hex
+	KeyID string
+
+	// KeyCreationTime is the creation time of the PGP key which made the signature.
+	// For keys derived from KMS this is always the same; see keyCreationTimeUnix.
+	KeyCreationTime time.Time
+
+	// SignatureCreationTime is the time the signature was made
+	SignatureCreationTime time.Time
+
+	// Identities are the user IDs of the PGP key which made the signature
+	Identities []string
+}
+
+// VerifyHelmChart checks that prov is a clearsigned Helm provenance file made by the given
+// armored public key for the packaged chart at chartPath, as created by HelmChart. Both the
+// SHA256 of the archive and the chart metadata block must match the chart.
+func VerifyHelmChart(chartPath string, prov []byte, armoredPublicKey PGPArmoredBlock) (*HelmChartVerification, error) {
+	keyring, err := openpgp.ReadArmoredKeyRing(strings.NewReader(string(armoredPublicKey)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PGP public key: %w", err)
+	}
+
+	block, _ := clearsign.Decode(prov)
+	if block == nil {
+		return nil, fmt.Errorf("no clearsigned message found in provenance file")
+	}
+
+	signature, signer, err := openpgp.VerifyDetachedSignature(keyring, bytes.NewReader(block.Bytes), block.ArmoredSignature.Body, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature: %w", err)
+	}
+
+	var signedMetadata map[string]any
+	sums := &helmsign.SumCollection{}
+	if err := helmsign.ParseMessageBlock(block.Plaintext, &signedMetadata, sums); err != nil {
+		return nil, fmt.Errorf("failed to parse provenance file: %w", err)
+	}
+
+	archiveData, err := os.ReadFile(chartPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read chart %q: %w", chartPath, err)
+	}
+
+	fileName := filepath.Base(chartPath)
+	checksum := sha256.Sum256(archiveData)
+	sum := "sha256:" + hex.EncodeToString(checksum[:])
+
+	signedSum, ok := sums.Files[fileName]
+	if !ok {
+		return nil, fmt.Errorf("provenance file has no checksum for %q", fileName)
+	}
+
+	if signedSum != sum {
+		return nil, fmt.Errorf("provenance file has checksum %q for %q but the chart has checksum %q", signedSum, fileName, sum)
+	}
+
+	chart, err := helmloader.LoadFile(chartPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load chart %q: %w", chartPath, err)
+	}
+
+	metadataBytes, err := yaml.Marshal(chart.Metadata)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal chart metadata for %q: %w", chartPath, err)
+	}
+
+	var chartMetadata map[string]any
+	if err := yaml.Unmarshal(metadataBytes, &chartMetadata); err != nil {
+		return nil, fmt.Errorf("failed to parse chart metadata for %q: %w", chartPath, err)
+	}
+
+	if !reflect.DeepEqual(signedMetadata, chartMetadata) {
+		return nil, fmt.Errorf("chart metadata in provenance file does not match the metadata of %q", chartPath)
+	}
+
+	verification := &HelmChartVerification{
+		FileName:              fileName,
+		SHA256:                sum,
+		KeyID:                 signer.PrimaryKey.KeyIdString(),
+		KeyCreationTime:       signer.PrimaryKey.CreationTime,
+		SignatureCreationTime: signature.CreationTime,
+	}
+
+	for id := range signer.Identities {
+		verification.Identities = append(verification.Identities, id)
+	}
+	sort.Strings(verification.Identities)
+
+	return verification, nil
 }
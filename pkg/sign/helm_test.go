@@ -0,0 +1,174 @@
+/*
+Copyright 2021 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sign
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	helmsign "helm.sh/helm/v4/pkg/provenance"
+)
+
+// writeTestChart writes a packaged chart with the given Chart.yaml to dir,
+// returning its path.
+func writeTestChart(t *testing.T, dir, fileName, chartYAML string) string {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	gw := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gw)
+	if err := tw.WriteHeader(&tar.Header{Name: "cert-manager/Chart.yaml", Typeflag: tar.TypeReg, Mode: 0o644, Size: int64(len(chartYAML))}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tw.Write([]byte(chartYAML)); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, fileName)
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestVerifyHelmChart(t *testing.T) {
+	cfg := &packet.Config{DefaultHash: crypto.SHA512, RSABits: 2048}
+
+	entity, err := openpgp.NewEntity(defaultPGPIdentity.Name, "", defaultPGPIdentity.Email, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := openpgp.NewEntity(defaultPGPIdentity.Name, "", defaultPGPIdentity.Email, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const chartYAML = "apiVersion: v1\nname: cert-manager\nversion: v1.15.0\nappVersion: v1.15.0\n"
+
+	dir := t.TempDir()
+	chartPath := writeTestChart(t, dir, "cert-manager-v1.15.0.tgz", chartYAML)
+	signatory := &helmsign.Signatory{Entity: entity, KeyRing: openpgp.EntityList{entity}}
+
+	prov, err := signHelmChart([]pgpKey{{entity: entity, cfg: cfg}}, chartPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chartData, err := os.ReadFile(chartPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// provenance files must also be verifiable by helm itself
+	if _, err := signatory.Verify(chartData, prov, filepath.Base(chartPath)); err != nil {
+		t.Errorf("expected provenance file to be verified by helm: %v", err)
+	}
+
+	wrongMetadataProv, err := signatory.ClearSign(chartData, filepath.Base(chartPath), []byte("name: cert-manager\nversion: v1.16.0\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		chartPath string
+		prov      []byte
+		publicKey PGPArmoredBlock
+		expectErr string
+	}{
+		"valid provenance": {
+			chartPath: chartPath,
+			prov:      prov,
+			publicKey: armoredPublicKey(t, entity),
+		},
+		"signed by a different key": {
+			chartPath: chartPath,
+			prov:      prov,
+			publicKey: armoredPublicKey(t, other),
+			expectErr: "invalid signature",
+		},
+		"modified chart": {
+			chartPath: writeTestChart(t, t.TempDir(), "cert-manager-v1.15.0.tgz", chartYAML+"description: modified\n"),
+			prov:      prov,
+			publicKey: armoredPublicKey(t, entity),
+			expectErr: "but the chart has checksum",
+		},
+		"chart with a different file name": {
+			chartPath: writeTestChart(t, dir, "cert-manager.tgz", chartYAML),
+			prov:      prov,
+			publicKey: armoredPublicKey(t, entity),
+			expectErr: "no checksum for",
+		},
+		"metadata doesn't match chart": {
+			chartPath: chartPath,
+			prov:      []byte(wrongMetadataProv),
+			publicKey: armoredPublicKey(t, entity),
+			expectErr: "chart metadata in provenance file does not match",
+		},
+		"not clearsigned": {
+			chartPath: chartPath,
+			prov:      []byte("files:\n  cert-manager-v1.15.0.tgz: sha256:0000\n"),
+			publicKey: armoredPublicKey(t, entity),
+			expectErr: "no clearsigned message",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			verification, err := VerifyHelmChart(test.chartPath, test.prov, test.publicKey)
+			if test.expectErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.expectErr) {
+					t.Errorf("expected error containing %q, got: %v", test.expectErr, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if verification.KeyID != entity.PrimaryKey.KeyIdString() {
+				t.Errorf("expected key ID %q, got %q", entity.PrimaryKey.KeyIdString(), verification.KeyID)
+			}
+
+			if !verification.KeyCreationTime.Equal(entity.PrimaryKey.CreationTime) {
+				t.Errorf("expected key creation time %v, got %v", entity.PrimaryKey.CreationTime, verification.KeyCreationTime)
+			}
+
+			if verification.FileName != "cert-manager-v1.15.0.tgz" || !strings.HasPrefix(verification.SHA256, "sha256:") {
+				t.Errorf("unexpected verified file: %#v", verification)
+			}
+
+			if len(verification.Identities) != 1 {
+				t.Errorf("expected the signing key to have 1 identity, got %v", verification.Identities)
+			}
+		})
+	}
+}
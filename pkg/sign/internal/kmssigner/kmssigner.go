@@ -18,12 +18,14 @@
 package kmssigner
 
 // This has been modified to suit cert-manager's use case; we add support for SHA512
-// digests, which are forced by helm. This is copied across to the cert-manager/release
+// digests, which are forced by helm, and for RSA-PSS and ECDSA keys. This is copied across to the cert-manager/release
 // project unless/until the changes are merged upstream in:
 // https://github.com/heptiolabs/google-kms-pgp/
 
 import (
 	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/base64"
@@ -39,22 +41,56 @@ import (
 type Signer interface {
 	crypto.Signer
 
-	RSAPublicKey() *rsa.PublicKey
-
 	CreationTime() time.Time
 
 	HashAlgo() crypto.Hash
+
+	// PSS returns true for RSA keys which create RSASSA-PSS signatures rather
+	// than PKCS #1 v1.5 signatures.
+	PSS() bool
+}
+
+// algorithm describes a KMS asymmetric signing algorithm
+type algorithm struct {
+	hash crypto.Hash
+
+	// pss is true for RSA-PSS algorithms
+	pss bool
+
+	// curve is set for ECDSA algorithms
+	curve elliptic.Curve
+}
+
+// algorithms holds every KMS signing algorithm we support, keyed by name
+var algorithms = map[string]algorithm{
+	"RSA_SIGN_PKCS1_2048_SHA256": {hash: crypto.SHA256},
+	"RSA_SIGN_PKCS1_3072_SHA256": {hash: crypto.SHA256},
+	"RSA_SIGN_PKCS1_4096_SHA256": {hash: crypto.SHA256},
+	"RSA_SIGN_PKCS1_4096_SHA512": {hash: crypto.SHA512},
+
+	"RSA_SIGN_PSS_2048_SHA256": {hash: crypto.SHA256, pss: true},
+	"RSA_SIGN_PSS_3072_SHA256": {hash: crypto.SHA256, pss: true},
+	"RSA_SIGN_PSS_4096_SHA256": {hash: crypto.SHA256, pss: true},
+	"RSA_SIGN_PSS_4096_SHA512": {hash: crypto.SHA512, pss: true},
+
+	"EC_SIGN_P256_SHA256": {hash: crypto.SHA256, curve: elliptic.P256()},
+	"EC_SIGN_P384_SHA384": {hash: crypto.SHA384, curve: elliptic.P384()},
 }
 
 // NewWithExplicitMetadata returns a crypto.Signer backed by the named Google Cloud KMS key,
 // but doesn't need the "cloudkms.cryptoKeyVersions.get" permission which would otherwise be required
-// for fetching the hash algorithm and creation time.
-func NewWithExplicitMetadata(api *cloudkms.Service, name string, hashAlgo crypto.Hash, creationTime time.Time) (Signer, error) {
+// for fetching the creation time. The algorithm of the key is returned along with its public key.
+func NewWithExplicitMetadata(api *cloudkms.Service, name string, creationTime time.Time) (Signer, error) {
 	res, err := api.Projects.Locations.KeyRings.CryptoKeys.CryptoKeyVersions.GetPublicKey(name).Do()
 	if err != nil {
 		return nil, fmt.Errorf("could not get public key from Google Cloud KMS API: %w", err)
 	}
 
+	algo, ok := algorithms[res.Algorithm]
+	if !ok {
+		return nil, fmt.Errorf("unsupported key algorithm %q", res.Algorithm)
+	}
+
 	block, _ := pem.Decode([]byte(res.Pem))
 	if block == nil || block.Type != "PUBLIC KEY" {
 		return nil, fmt.Errorf("could not decode public key PEM")
@@ -65,18 +101,28 @@ func NewWithExplicitMetadata(api *cloudkms.Service, name string, hashAlgo crypto
 		return nil, fmt.Errorf("could not parse public key: %w", err)
 	}
 
-	pubkeyRSA, ok := pubkey.(*rsa.PublicKey)
-	if !ok {
-		return nil, fmt.Errorf("public key was not an RSA key as expected; got type %T", pubkey)
+	switch pubkey := pubkey.(type) {
+	case *rsa.PublicKey:
+		if algo.curve != nil {
+			return nil, fmt.Errorf("public key was an RSA key but key algorithm is %q", res.Algorithm)
+		}
+
+	case *ecdsa.PublicKey:
+		if algo.curve != pubkey.Curve {
+			return nil, fmt.Errorf("public key was an ECDSA key on curve %s but key algorithm is %q", pubkey.Curve.Params().Name, res.Algorithm)
+		}
+
+	default:
+		return nil, fmt.Errorf("public key was not an RSA or ECDSA key as expected; got type %T", pubkey)
 	}
 
 	return &kmsSigner{
 		api:  api,
 		name: name,
 
-		pubkey:        *pubkeyRSA,
-		creationTime:  creationTime,
-		pgpDigestAlgo: hashAlgo,
+		pubkey:       pubkey,
+		creationTime: creationTime,
+		algo:         algo,
 	}, nil
 }
 
@@ -87,19 +133,7 @@ func New(api *cloudkms.Service, name string) (Signer, error) {
 		return nil, fmt.Errorf("could not get key version from Google Cloud KMS API: %w", err)
 	}
 
-	var hashAlgo crypto.Hash
-
-	switch metadata.Algorithm {
-	case "RSA_SIGN_PKCS1_2048_SHA256":
-		hashAlgo = crypto.SHA256
-	case "RSA_SIGN_PKCS1_3072_SHA256":
-		hashAlgo = crypto.SHA256
-	case "RSA_SIGN_PKCS1_4096_SHA256":
-		hashAlgo = crypto.SHA256
-	case "RSA_SIGN_PKCS1_4096_SHA512":
-		hashAlgo = crypto.SHA512
-
-	default:
+	if _, ok := algorithms[metadata.Algorithm]; !ok {
 		return nil, fmt.Errorf("unsupported key algorithm %q", metadata.Algorithm)
 	}
 
@@ -108,48 +142,52 @@ func New(api *cloudkms.Service, name string) (Signer, error) {
 		return nil, fmt.Errorf("could not parse key creation timestamp: %w", err)
 	}
 
-	return NewWithExplicitMetadata(api, name, hashAlgo, creationTime)
+	return NewWithExplicitMetadata(api, name, creationTime)
 }
 
 type kmsSigner struct {
 	api          *cloudkms.Service
 	name         string
-	pubkey       rsa.PublicKey
+	pubkey       crypto.PublicKey
 	creationTime time.Time
 
-	pgpDigestAlgo crypto.Hash
+	algo algorithm
 }
 
+// Public returns the public key, which is either an *rsa.PublicKey or an *ecdsa.PublicKey
 func (k *kmsSigner) Public() crypto.PublicKey {
 	return k.pubkey
 }
 
-func (k *kmsSigner) RSAPublicKey() *rsa.PublicKey {
-	return &k.pubkey
-}
-
 func (k *kmsSigner) CreationTime() time.Time {
 	return k.creationTime
 }
 
 func (k *kmsSigner) HashAlgo() crypto.Hash {
-	return k.pgpDigestAlgo
+	return k.algo.hash
+}
+
+func (k *kmsSigner) PSS() bool {
+	return k.algo.pss
 }
 
 // KMSDigest returns a Digest corresponding to the given digest algorithm, or an error
 // if the digest is the incorrect size
 func (k *kmsSigner) KMSDigest(digest []byte) (*cloudkms.Digest, error) {
-	if len(digest) != k.pgpDigestAlgo.Size() {
-		return nil, fmt.Errorf("expected digest to have length %d but got %d", k.pgpDigestAlgo.Size(), len(digest))
+	if len(digest) != k.algo.hash.Size() {
+		return nil, fmt.Errorf("expected digest to have length %d but got %d", k.algo.hash.Size(), len(digest))
 	}
 
 	encodedDigest := base64.StdEncoding.EncodeToString(digest)
 
 	kmsDigest := new(cloudkms.Digest)
-	switch k.pgpDigestAlgo {
+	switch k.algo.hash {
 	case crypto.SHA256:
 		kmsDigest.Sha256 = encodedDigest
 
+	case crypto.SHA384:
+		kmsDigest.Sha384 = encodedDigest
+
 	case crypto.SHA512:
 		kmsDigest.Sha512 = encodedDigest
 
@@ -160,6 +198,9 @@ func (k *kmsSigner) KMSDigest(digest []byte) (*cloudkms.Digest, error) {
 	return kmsDigest, nil
 }
 
+// Sign signs the given digest using the KMS key; opts is ignored, since the padding and
+// hash are fixed by the key's algorithm. ECDSA signatures are ASN.1 encoded, as
+// required by crypto.Signer.
 func (k *kmsSigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
 	kmsDigest, err := k.KMSDigest(digest)
 	if err != nil {
@@ -20,7 +20,6 @@ import (
 	"archive/tar"
 	"bytes"
 	"compress/gzip"
-	"context"
 	"fmt"
 	"io"
 	"log"
@@ -35,9 +34,10 @@ const manifestLocation = "deploy/chart/cert-manager.tgz"
 // CertManagerManifests takes a path to a cert-manager-manifests.tar.gz file, loads it into
 // memory and signs anything inside the archive which is signable; currently,
 // the helm chart located at "deploy/chart/cert-manager.tgz" is signed, and a
-// signature "deploy/chart/cert-manager.tgz.prov" will be added.
+// signature "deploy/chart/cert-manager.tgz.prov" will be added, holding a signature
+// from each of the given signers.
 // The cert-manifests.tar.gz file is changed in-place.
-func CertManagerManifests(ctx context.Context, key GCPKMSKey, path string, releaseVersion string) error {
+func CertManagerManifests(signers []Signer, path string, releaseVersion string) error {
 	// 1. Create temp dir for chart archive to be extracted to
 	// (Helm signing requires a filename, not a reader, so we have to write to disk here)
 	tmpDest, err := os.MkdirTemp("", "cmrel-extracted-manifests-")
@@ -75,7 +75,7 @@ func CertManagerManifests(ctx context.Context, key GCPKMSKey, path string, relea
 	}
 
 	// 3. Sign chart
-	signatureBytes, err := HelmChart(ctx, key, chartPath)
+	signatureBytes, err := HelmChart(signers, chartPath)
 	if err != nil {
 		return fmt.Errorf("failed to sign helm chart at %q: %w", chartPath, err)
 	}
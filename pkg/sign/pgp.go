@@ -0,0 +1,310 @@
+/*
+Copyright 2021 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sign
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/cloudkms/v1"
+	"google.golang.org/api/option"
+
+	"github.com/cert-manager/release/pkg/sign/internal/kmssigner"
+)
+
+const (
+	// PGP key ids are complicated, see https://datatracker.ietf.org/doc/html/rfc4880#section-12
+	// Key IDs include hashed data taken from the "public key packet" (go doc "golang.org/x/crypto/openpgp/packet" PublicKey)
+	// The public key packet, crucially, includes its CreationTime. That means that for a key with a stable
+	// static ID to be created, the creation time must be static.
+	// We could use the time that the KMS key was created, but that requires addtional permissions (i.e., the permission to "get"
+	// the key using the GCP API), so instead we hardcode the creation time for all keys, unless
+	// a different time is set in the key's profile; see KeyProfiles.
+	// The time below is "2021-09-29T14:09:53Z", which just happens to be the time this change was started.
+	keyCreationTimeUnix = 1632924593
+)
+
+// see comment for keyCreationTimeUnix
+var staticKeyCreationTime = time.Unix(keyCreationTimeUnix, 0)
+
+// PGPArmoredBlock is an ASCII-armored PGP key block
+type PGPArmoredBlock string
+
+// BootstrapPGP creates a PGP public key for the given signer, with the signer's identity
+// (the cert-manager identity unless its key has a profile) and self-signed using the signer. The signer can then be used for code signing,
+// and the public key distributed for verification purposes.
+// Signers read from PGP private keys already have an identity, which is kept as-is.
+func BootstrapPGP(signer Signer) (PGPArmoredBlock, error) {
+	return Keyring([]Signer{signer})
+}
+
+// Keyring creates a PGP public keyring holding the key for each of the given signers, as
+// created by BootstrapPGP. While keys are being rotated, the keyring holds both the old and
+// the new key so that signatures made by either can be verified.
+func Keyring(signers []Signer) (PGPArmoredBlock, error) {
+	// Largely taken from:
+	// https://github.com/heptiolabs/google-kms-pgp/blob/89c17dd5877a5c0f98f1906444b831dd2352b365/main.go#L131-L209
+	keys, err := pgpKeys(signers)
+	if err != nil {
+		return "", err
+	}
+
+	out := &bytes.Buffer{}
+
+	armoredWriter, err := armor.Encode(out, "PGP PUBLIC KEY BLOCK", nil)
+	if err != nil {
+		return "", fmt.Errorf("could not create writer for public key: %w", err)
+	}
+
+	for _, key := range keys {
+		if err := key.entity.Serialize(armoredWriter); err != nil {
+			return "", fmt.Errorf("could not serialize public key: %w", err)
+		}
+	}
+
+	if err := armoredWriter.Close(); err != nil {
+		return "", fmt.Errorf("error closing public key writer: %w", err)
+	}
+
+	// Always add a blank line to the end of the raw output
+	fmt.Fprintf(out, "\n")
+
+	return PGPArmoredBlock(out.String()), nil
+}
+
+// addIdentity adds the given identity to the entity, self-signed using the entity's
+// private key.
+func addIdentity(entity *openpgp.Entity, packetCfg *packet.Config, identity PGPIdentity) error {
+	uid := packet.NewUserId(identity.Name, identity.Comment, identity.Email)
+	if uid == nil {
+		return fmt.Errorf("could not generate PGP user ID metadata; this indicates there were invalid characters in name, comment or email")
+	}
+
+	isPrimary := true
+	selfSignature := &packet.Signature{
+		// SigTypePositiveCert means "we're absolutely sure this identity is correct"
+		// Since we're the ones creating the identity, we're sure it's correct
+		SigType: packet.SigTypePositiveCert,
+		// CreationTime is informational; unless the identity has an expiry time,
+		// no lifetime is set and the key never expires.
+		CreationTime: entity.PrimaryKey.CreationTime,
+		PubKeyAlgo:   entity.PrimaryKey.PubKeyAlgo,
+		Hash:         packetCfg.Hash(),
+		IsPrimaryId:  &isPrimary,
+		// FlagsValid must be set if any flags are set
+		// FlagSign is true because the key is intended as a signing key
+		// No other flags are relevant
+		FlagsValid:  true,
+		FlagSign:    true,
+		IssuerKeyId: &entity.PrimaryKey.KeyId,
+	}
+
+	if !identity.ExpiryTime.IsZero() {
+		if !identity.ExpiryTime.After(entity.PrimaryKey.CreationTime) {
+			return fmt.Errorf("PGP key expiry time %s must be after its creation time %s", identity.ExpiryTime, entity.PrimaryKey.CreationTime)
+		}
+
+		// key lifetimes are given in seconds from the creation of the key
+		lifetime := uint32(identity.ExpiryTime.Sub(entity.PrimaryKey.CreationTime) / time.Second)
+		selfSignature.KeyLifetimeSecs = &lifetime
+	}
+
+	if err := selfSignature.SignUserId(uid.Id, entity.PrimaryKey, entity.PrivateKey, packetCfg); err != nil {
+		return fmt.Errorf("could not self-sign PGP public key: %w", err)
+	}
+
+	entity.Identities[uid.Id] = &openpgp.Identity{
+		Name:          uid.Id,
+		UserId:        uid,
+		SelfSignature: selfSignature,
+		// Signatures is what's serialized, so it must also hold the self-signature
+		Signatures: []*packet.Signature{selfSignature},
+	}
+
+	return nil
+}
+
+// pgpKey is the PGP entity for a signer, along with the packet config which it must
+// be used with
+type pgpKey struct {
+	entity *openpgp.Entity
+	cfg    *packet.Config
+}
+
+// pgpKeys creates a pgpKey for each of the given signers
+func pgpKeys(signers []Signer) ([]pgpKey, error) {
+	if len(signers) == 0 {
+		return nil, fmt.Errorf("no signing keys were given")
+	}
+
+	keys := make([]pgpKey, 0, len(signers))
+
+	for _, signer := range signers {
+		entity, cfg, err := pgpEntity(signer)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get an entity from key %q: %w", signer, err)
+		}
+
+		keys = append(keys, pgpKey{entity: entity, cfg: cfg})
+	}
+
+	return keys, nil
+}
+
+// pgpEntity creates a PGP entity from the given signer; the entity wraps the signer and a
+// self-signed identity and can be used for signing charts and other artifacts. The openpgp packet
+// config is also returned.
+func pgpEntity(signer Signer) (*openpgp.Entity, *packet.Config, error) {
+	// Largely taken from:
+	// https://github.com/heptiolabs/google-kms-pgp/blob/89c17dd5877a5c0f98f1906444b831dd2352b365/main.go#L320-L356
+
+	// The signer can only sign digests made with its own hash, which is SHA512 for our
+	// RSA keys
+	cfg := &packet.Config{
+		DefaultHash: signer.HashAlgo(),
+	}
+
+	if local, ok := signer.(*localSigner); ok && local.entity != nil {
+		return local.entity, cfg, nil
+	}
+
+	var primaryKey *packet.PublicKey
+
+	switch pub := signer.Public().(type) {
+	case *rsa.PublicKey:
+		// OpenPGP RSA signatures always use PKCS #1 v1.5 padding
+		if pss, ok := signer.(interface{ PSS() bool }); ok && pss.PSS() {
+			return nil, nil, fmt.Errorf("%q is an RSA-PSS key, which can't create PGP signatures", signer)
+		}
+
+		primaryKey = packet.NewRSAPublicKey(signer.CreationTime(), pub)
+
+	case *ecdsa.PublicKey:
+		var err error
+		primaryKey, err = newECDSAPublicKey(signer.CreationTime(), pub)
+		if err != nil {
+			return nil, nil, err
+		}
+
+	default:
+		return nil, nil, fmt.Errorf("PGP signing requires an RSA or ECDSA key but %q has a key of type %T", signer, pub)
+	}
+
+	entity := &openpgp.Entity{
+		PrimaryKey: primaryKey,
+		Identities: make(map[string]*openpgp.Identity),
+	}
+
+	// packet.NewSignerPrivateKey only accepts concrete private key types, so the private key
+	// is built directly; RSA and ECDSA signatures are made through the crypto.Signer interface.
+	// Copying the primary key also ensures the private key has the same key ID, without which
+	// "signatures end up with a key ID that doesn't match the primary key"
+	entity.PrivateKey = &packet.PrivateKey{
+		PublicKey:  *entity.PrimaryKey,
+		PrivateKey: signer,
+	}
+
+	// An identity is required for the key to be usable for signing by openpgp
+	if err := addIdentity(entity, cfg, signer.PGPIdentity()); err != nil {
+		return nil, nil, err
+	}
+
+	return entity, cfg, nil
+}
+
+// ecdsaCurveOIDs holds the DER-encoded OIDs which identify the NIST curves in OpenPGP
+// public keys, see https://datatracker.ietf.org/doc/html/rfc6637#section-11
+var ecdsaCurveOIDs = map[elliptic.Curve][]byte{
+	elliptic.P256(): {0x2a, 0x86, 0x48, 0xce, 0x3d, 0x03, 0x01, 0x07},
+	elliptic.P384(): {0x2b, 0x81, 0x04, 0x00, 0x22},
+}
+
+// newECDSAPublicKey creates a PGP public key for the given ECDSA key. The openpgp package
+// can only create ECDSA keys from its own key types, so the public key packet is built
+// by hand and then parsed; see https://datatracker.ietf.org/doc/html/rfc6637#section-9
+func newECDSAPublicKey(creationTime time.Time, pub *ecdsa.PublicKey) (*packet.PublicKey, error) {
+	oid, ok := ecdsaCurveOIDs[pub.Curve]
+	if !ok {
+		return nil, fmt.Errorf("unsupported ECDSA curve %s for PGP signing", pub.Curve.Params().Name)
+	}
+
+	ecdhKey, err := pub.ECDH()
+	if err != nil {
+		return nil, fmt.Errorf("invalid ECDSA public key: %w", err)
+	}
+
+	// the point is uncompressed, so it starts with 0x04 which has 3 significant bits
+	point := ecdhKey.Bytes()
+	pointBits := (len(point)-1)*8 + 3
+
+	body := &bytes.Buffer{}
+	body.WriteByte(4) // version
+	_ = binary.Write(body, binary.BigEndian, uint32(creationTime.Unix()))
+	body.WriteByte(byte(packet.PubKeyAlgoECDSA))
+	body.WriteByte(byte(len(oid)))
+	body.Write(oid)
+	_ = binary.Write(body, binary.BigEndian, uint16(pointBits))
+	body.Write(point)
+
+	// new format packet header for a public key packet with a one-byte length, which is
+	// always enough for the curves we support
+	serialized := append([]byte{0xc0 | 6, byte(body.Len())}, body.Bytes()...)
+
+	p, err := packet.Read(bytes.NewReader(serialized))
+	if err != nil {
+		return nil, fmt.Errorf("could not create PGP public key: %w", err)
+	}
+
+	publicKey, ok := p.(*packet.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("could not create PGP public key: got packet of type %T", p)
+	}
+
+	return publicKey, nil
+}
+
+// newKMSSigner creates a signer backed by the given KMS key, which signs digests
+// created with the hash of the key's algorithm. The creation time is that of the
+// PGP key derived from the signer.
+func newKMSSigner(ctx context.Context, key GCPKMSKey, creationTime time.Time) (kmssigner.Signer, error) {
+	oauthClient, err := google.DefaultClient(ctx, cloudkms.CloudPlatformScope)
+	if err != nil {
+		return nil, fmt.Errorf("could not create GCP OAuth2 client: %w", err)
+	}
+
+	svc, err := cloudkms.NewService(ctx, option.WithHTTPClient(oauthClient))
+	if err != nil {
+		return nil, fmt.Errorf("could not create GCP KMS client: %w", err)
+	}
+
+	signer, err := kmssigner.NewWithExplicitMetadata(svc, key.GCPFormat(), creationTime)
+	if err != nil {
+		return nil, fmt.Errorf("could not create KMS signer: %w", err)
+	}
+
+	return signer, nil
+}
@@ -0,0 +1,182 @@
+/*
+Copyright 2021 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sign
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"sigs.k8s.io/yaml"
+)
+
+// defaultPGPIdentity is the identity of PGP keys derived from signing keys which have no
+// profile, and which cert-manager's own keys have always used.
+var defaultPGPIdentity = PGPIdentity{
+	Name:         "cert-manager Maintainers",
+	Email:        "cert-manager-maintainers@googlegroups.com",
+	CreationTime: staticKeyCreationTime,
+}
+
+// PGPIdentity is the identity of the PGP key derived from a signing key, which is what
+// the public key, Helm chart provenance files and other PGP signatures claim to be from.
+type PGPIdentity struct {
+	// Name is the name in the PGP user ID, which must be set
+	Name string `json:"name"`
+
+	// Email is the email address in the PGP user ID
+	Email string `json:"email,omitempty"`
+
+	// Comment is the comment in the PGP user ID
+	Comment string `json:"comment,omitempty"`
+
+	// CreationTime is the creation time of the PGP key. It's part of the key ID, so it
+	// must never change once the public key has been distributed. Defaults to the
+	// creation time of cert-manager's keys; see keyCreationTimeUnix.
+	CreationTime time.Time `json:"creationTime"`
+
+	// ExpiryTime, if set, is when the PGP key expires. Signatures made by an expired
+	// key fail verification.
+	ExpiryTime time.Time `json:"expiryTime"`
+}
+
+// KeyProfile sets the PGP identity for a signing key
+type KeyProfile struct {
+	// Key is the signing key the profile applies to, in any format accepted by
+	// ParseSigningKey. A profile without a key applies to every key which doesn't
+	// have a profile of its own.
+	Key string `json:"key,omitempty"`
+
+	PGPIdentity
+}
+
+// KeyProfiles holds the PGP identities of signing keys, allowing projects other than
+// cert-manager to sign as themselves. Profiles are read from a YAML file such as:
+//
+//	profiles:
+//	- key: projects/<PROJECT>/locations/<LOCATION>/keyRings/<KEYRING>/cryptoKeys/<KEY>/cryptoKeyVersions/<VERSION>
+//	  name: Example Maintainers
+//	  email: maintainers@example.com
+//	  comment: release signing key
+//	  creationTime: "2024-01-01T00:00:00Z"
+//	  expiryTime: "2027-01-01T00:00:00Z"
+//
+// Keys without a profile use the identity cert-manager has always used.
+type KeyProfiles struct {
+	Profiles []KeyProfile `json:"profiles"`
+}
+
+// LoadKeyProfiles reads key profiles from the YAML file at path. An empty path or an
+// empty file gives no profiles, so every key uses the default identity.
+func LoadKeyProfiles(path string) (*KeyProfiles, error) {
+	if path == "" {
+		return &KeyProfiles{}, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key profiles: %w", err)
+	}
+
+	profiles, err := ParseKeyProfiles(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key profiles in %q: %w", path, err)
+	}
+
+	return profiles, nil
+}
+
+// ParseKeyProfiles parses and validates YAML key profiles; see KeyProfiles for the format
+func ParseKeyProfiles(data []byte) (*KeyProfiles, error) {
+	profiles := &KeyProfiles{}
+	if err := yaml.UnmarshalStrict(data, profiles); err != nil {
+		return nil, err
+	}
+
+	seen := map[string]struct{}{}
+	for i, profile := range profiles.Profiles {
+		if profile.Key != "" {
+			key, err := ParseSigningKey(profile.Key)
+			if err != nil {
+				return nil, fmt.Errorf("profile %d: %w", i, err)
+			}
+
+			// keys are stored in a canonical format so they can be looked up
+			profiles.Profiles[i].Key = key.String()
+		}
+
+		if _, ok := seen[profiles.Profiles[i].Key]; ok {
+			return nil, fmt.Errorf("profile %d: more than one profile for key %q", i, profile.Key)
+		}
+		seen[profiles.Profiles[i].Key] = struct{}{}
+
+		if profile.Name == "" {
+			return nil, fmt.Errorf("profile %d: name must be set", i)
+		}
+
+		creationTime := profile.CreationTime
+		if creationTime.IsZero() {
+			creationTime = defaultPGPIdentity.CreationTime
+		}
+
+		if !profile.ExpiryTime.IsZero() && !profile.ExpiryTime.After(creationTime) {
+			return nil, fmt.Errorf("profile %d: expiryTime %s must be after creationTime %s", i, profile.ExpiryTime.Format(time.RFC3339), creationTime.Format(time.RFC3339))
+		}
+	}
+
+	return profiles, nil
+}
+
+// Apply returns the given keys with the PGP identity from their profile set; see
+// SigningKey.PGPIdentity
+func (p *KeyProfiles) Apply(keys []SigningKey) []SigningKey {
+	applied := make([]SigningKey, 0, len(keys))
+
+	for _, key := range keys {
+		if profile, ok := p.profileFor(key); ok {
+			identity := profile.PGPIdentity
+			if identity.CreationTime.IsZero() {
+				identity.CreationTime = defaultPGPIdentity.CreationTime
+			}
+			key.pgpIdentity = &identity
+		}
+
+		applied = append(applied, key)
+	}
+
+	return applied
+}
+
+func (p *KeyProfiles) profileFor(key SigningKey) (KeyProfile, bool) {
+	var fallback *KeyProfile
+
+	for i, profile := range p.Profiles {
+		if profile.Key == key.String() {
+			return profile, true
+		}
+
+		if profile.Key == "" {
+			fallback = &p.Profiles[i]
+		}
+	}
+
+	if fallback != nil {
+		return *fallback, true
+	}
+
+	return KeyProfile{}, false
+}
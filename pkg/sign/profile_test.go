@@ -0,0 +1,213 @@
+/*
+Copyright 2021 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sign
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseKeyProfiles(t *testing.T) {
+	tests := map[string]struct {
+		input            string
+		expectedProfiles []KeyProfile
+		expectErr        string
+	}{
+		"empty file": {
+			input: "",
+		},
+		"canonicalizes keys": {
+			input: "profiles:\n- key: gcpkms://" + testKMSKey + "\n  name: Example Maintainers\n  email: maintainers@example.com\n  creationTime: \"2024-01-01T00:00:00Z\"\n",
+			expectedProfiles: []KeyProfile{
+				{
+					Key: testKMSKey,
+					PGPIdentity: PGPIdentity{
+						Name:         "Example Maintainers",
+						Email:        "maintainers@example.com",
+						CreationTime: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+					},
+				},
+			},
+		},
+		"profile without a key": {
+			input: "profiles:\n- name: Example Maintainers\n  comment: release signing key\n",
+			expectedProfiles: []KeyProfile{
+				{PGPIdentity: PGPIdentity{Name: "Example Maintainers", Comment: "release signing key"}},
+			},
+		},
+		"unknown field": {
+			input:     "profiles:\n- name: Example Maintainers\n  mail: maintainers@example.com\n",
+			expectErr: "unknown field",
+		},
+		"missing name": {
+			input:     "profiles:\n- key: ephemeral://test\n  email: maintainers@example.com\n",
+			expectErr: "name must be set",
+		},
+		"invalid key": {
+			input:     "profiles:\n- key: s3://bucket/key\n  name: Example Maintainers\n",
+			expectErr: "unsupported signing key scheme",
+		},
+		"duplicate key": {
+			input:     "profiles:\n- key: ephemeral://test\n  name: First\n- key: ephemeral://test\n  name: Second\n",
+			expectErr: "more than one profile",
+		},
+		"more than one profile without a key": {
+			input:     "profiles:\n- name: First\n- name: Second\n",
+			expectErr: "more than one profile",
+		},
+		"expiry before default creation time": {
+			input:     "profiles:\n- name: Example Maintainers\n  expiryTime: \"2020-01-01T00:00:00Z\"\n",
+			expectErr: "must be after creationTime",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			profiles, err := ParseKeyProfiles([]byte(test.input))
+			if test.expectErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.expectErr) {
+					t.Errorf("expected error containing %q, got: %v", test.expectErr, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !slices.EqualFunc(profiles.Profiles, test.expectedProfiles, func(a, b KeyProfile) bool {
+				return a.Key == b.Key && a.Name == b.Name && a.Email == b.Email && a.Comment == b.Comment &&
+					a.CreationTime.Equal(b.CreationTime) && a.ExpiryTime.Equal(b.ExpiryTime)
+			}) {
+				t.Errorf("expected profiles %#v, got %#v", test.expectedProfiles, profiles.Profiles)
+			}
+		})
+	}
+}
+
+func TestKeyProfilesApply(t *testing.T) {
+	profiles, err := ParseKeyProfiles([]byte("profiles:\n- key: ephemeral://custom\n  name: Custom\n- name: Fallback\n  creationTime: \"2024-01-01T00:00:00Z\"\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := ParseSigningKeys("ephemeral://custom,ephemeral://other")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	applied := profiles.Apply(keys)
+
+	tests := map[string]struct {
+		key                  SigningKey
+		expectedName         string
+		expectedCreationTime time.Time
+	}{
+		"key with its own profile": {
+			key:                  applied[0],
+			expectedName:         "Custom",
+			expectedCreationTime: staticKeyCreationTime,
+		},
+		"key using the fallback profile": {
+			key:                  applied[1],
+			expectedName:         "Fallback",
+			expectedCreationTime: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		},
+		"key without profiles": {
+			key:                  keys[0],
+			expectedName:         defaultPGPIdentity.Name,
+			expectedCreationTime: staticKeyCreationTime,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			identity := test.key.PGPIdentity()
+
+			if identity.Name != test.expectedName {
+				t.Errorf("expected identity name %q, got %q", test.expectedName, identity.Name)
+			}
+
+			if !identity.CreationTime.Equal(test.expectedCreationTime) {
+				t.Errorf("expected creation time %v, got %v", test.expectedCreationTime, identity.CreationTime)
+			}
+		})
+	}
+}
+
+func TestSigningWithKeyProfile(t *testing.T) {
+	profiles, err := ParseKeyProfiles([]byte(`profiles:
+- key: ephemeral://profile
+  name: Example Maintainers
+  email: maintainers@example.com
+  comment: release signing key
+  creationTime: "2024-01-01T00:00:00Z"
+  expiryTime: "2099-01-01T00:00:00Z"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := ParseSigningKey("ephemeral://profile")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signers, err := NewSigners(context.Background(), profiles.Apply([]SigningKey{key}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	publicKey, err := BootstrapPGP(signers[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chartPath := writeTestChart(t, t.TempDir(), "cert-manager-v1.15.0.tgz", "apiVersion: v1\nname: cert-manager\nversion: v1.15.0\n")
+
+	prov, err := HelmChart(signers, chartPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verification, err := VerifyHelmChart(chartPath, prov, publicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedIdentities := []string{"Example Maintainers (release signing key) <maintainers@example.com>"}
+	if !slices.Equal(verification.Identities, expectedIdentities) {
+		t.Errorf("expected identities %v, got %v", expectedIdentities, verification.Identities)
+	}
+
+	if expected := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC); !verification.KeyCreationTime.Equal(expected) {
+		t.Errorf("expected key creation time %v, got %v", expected, verification.KeyCreationTime)
+	}
+
+	entity, _, err := pgpEntity(signers[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lifetime := entity.PrimaryIdentity().SelfSignature.KeyLifetimeSecs
+	if lifetime == nil || time.Unix(1704067200+int64(*lifetime), 0).UTC().Year() != 2099 {
+		t.Errorf("expected the key to expire in 2099, got lifetime %v", lifetime)
+	}
+}
@@ -0,0 +1,466 @@
+/*
+Copyright 2021 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sign
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	helmsign "helm.sh/helm/v4/pkg/provenance"
+)
+
+const testKMSKey = "projects/cert-manager-release/locations/europe-west1/keyRings/cert-manager-release/cryptoKeys/cert-manager-release-signing-key/cryptoKeyVersions/1"
+
+func TestParseSigningKey(t *testing.T) {
+	tests := map[string]struct {
+		input             string
+		expectedString    string
+		expectedCosignKey string
+		shouldError       bool
+	}{
+		"parses GCP formatted key without a scheme": {
+			input:             testKMSKey,
+			expectedString:    testKMSKey,
+			expectedCosignKey: "gcpkms://projects/cert-manager-release/locations/europe-west1/keyRings/cert-manager-release/cryptoKeys/cert-manager-release-signing-key/versions/1",
+		},
+		"parses GCP formatted key with a scheme": {
+			input:             "gcpkms://" + testKMSKey,
+			expectedString:    testKMSKey,
+			expectedCosignKey: "gcpkms://projects/cert-manager-release/locations/europe-west1/keyRings/cert-manager-release/cryptoKeys/cert-manager-release-signing-key/versions/1",
+		},
+		"parses file key": {
+			input:          "file:///etc/cmrel/key.pem",
+			expectedString: "file:///etc/cmrel/key.pem",
+		},
+		"parses ephemeral key": {
+			input:          "ephemeral://test",
+			expectedString: "ephemeral://test",
+		},
+		"doesn't parse invalid KMS key": {
+			input:       "gcpkms://projects/cert-manager-release",
+			shouldError: true,
+		},
+		"doesn't parse file key without a path": {
+			input:       "file://",
+			shouldError: true,
+		},
+		"doesn't parse unknown scheme": {
+			input:       "awskms://alias/cert-manager",
+			shouldError: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			key, err := ParseSigningKey(test.input)
+
+			if (err != nil) != test.shouldError {
+				t.Errorf("shouldError=%v, err=%v", test.shouldError, err)
+				return
+			}
+
+			if test.shouldError {
+				return
+			}
+
+			if key.String() != test.expectedString {
+				t.Errorf("wanted key %q but got %q", test.expectedString, key.String())
+			}
+
+			cosignKey, err := key.CosignFormat()
+			if test.expectedCosignKey == "" {
+				if err == nil {
+					t.Errorf("expected an error getting cosign formatted key for %q but got %q", key, cosignKey)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if cosignKey != test.expectedCosignKey {
+				t.Errorf("wanted cosign formatted key %q but got %q", test.expectedCosignKey, cosignKey)
+			}
+		})
+	}
+}
+
+func TestParseSigningKeys(t *testing.T) {
+	tests := map[string]struct {
+		input        string
+		expectedKeys []string
+		shouldError  bool
+	}{
+		"parses a single key": {
+			input:        testKMSKey,
+			expectedKeys: []string{testKMSKey},
+		},
+		"parses multiple keys in order": {
+			input:        "ephemeral://new, " + testKMSKey,
+			expectedKeys: []string{"ephemeral://new", testKMSKey},
+		},
+		"doesn't parse duplicate keys": {
+			input:       testKMSKey + ",gcpkms://" + testKMSKey,
+			shouldError: true,
+		},
+		"doesn't parse an empty key": {
+			input:       "ephemeral://new,",
+			shouldError: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			keys, err := ParseSigningKeys(test.input)
+
+			if (err != nil) != test.shouldError {
+				t.Errorf("shouldError=%v, err=%v", test.shouldError, err)
+				return
+			}
+
+			if test.shouldError {
+				return
+			}
+
+			var got []string
+			for _, key := range keys {
+				got = append(got, key.String())
+			}
+
+			if !slices.Equal(got, test.expectedKeys) {
+				t.Errorf("wanted keys %v but got %v", test.expectedKeys, got)
+			}
+		})
+	}
+}
+
+// writePEMKey writes the given private key to dir in PKCS8 format, returning a file:// URI for it
+func writePEMKey(t *testing.T, dir string, privateKey crypto.Signer) string {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return "file://" + path
+}
+
+// writePGPKey writes the given entity to dir as an armored PGP private key, returning a
+// file:// URI for it
+func writePGPKey(t *testing.T, dir string, entity *openpgp.Entity) string {
+	t.Helper()
+	out := &bytes.Buffer{}
+	w, err := armor.Encode(out, openpgp.PrivateKeyType, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := entity.SerializePrivate(w, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "key.asc")
+	if err := os.WriteFile(path, out.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return "file://" + path
+}
+
+func TestSigners(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p256Key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p384Key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entity, err := openpgp.NewEntity("fork maintainers", "", "maintainers@example.com", &packet.Config{DefaultHash: crypto.SHA512, RSABits: 2048})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		key              string
+		expectedIdentity string
+	}{
+		"ephemeral key": {
+			key:              "ephemeral://test",
+			expectedIdentity: "cert-manager Maintainers <cert-manager-maintainers@googlegroups.com>",
+		},
+		"RSA PEM file": {
+			key:              writePEMKey(t, t.TempDir(), rsaKey),
+			expectedIdentity: "cert-manager Maintainers <cert-manager-maintainers@googlegroups.com>",
+		},
+		"ECDSA P256 PEM file": {
+			key:              writePEMKey(t, t.TempDir(), p256Key),
+			expectedIdentity: "cert-manager Maintainers <cert-manager-maintainers@googlegroups.com>",
+		},
+		"ECDSA P384 PEM file": {
+			key:              writePEMKey(t, t.TempDir(), p384Key),
+			expectedIdentity: "cert-manager Maintainers <cert-manager-maintainers@googlegroups.com>",
+		},
+		"PGP private key file": {
+			key:              writePGPKey(t, t.TempDir(), entity),
+			expectedIdentity: "fork maintainers <maintainers@example.com>",
+		},
+	}
+
+	const chartYAML = "apiVersion: v1\nname: cert-manager\nversion: v1.15.0\n"
+	chartPath := writeTestChart(t, t.TempDir(), "cert-manager-v1.15.0.tgz", chartYAML)
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			key, err := ParseSigningKey(test.key)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			signer, err := NewSigner(context.Background(), key)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			envelope, err := DSSE([]Signer{signer}, "application/vnd.in-toto+json", []byte("{}"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			sig, err := base64.StdEncoding.DecodeString(envelope.Signatures[0].Sig)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := VerifySignature(signer.Public(), dssePAE(envelope.PayloadType, []byte("{}")), sig); err != nil {
+				t.Errorf("expected DSSE signature to verify: %v", err)
+			}
+
+			publicKey, err := BootstrapPGP(signer)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			prov, err := HelmChart([]Signer{signer}, chartPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			verification, err := VerifyHelmChart(chartPath, prov, publicKey)
+			if err != nil {
+				t.Fatalf("expected helm chart signature to verify: %v", err)
+			}
+
+			if len(verification.Identities) != 1 || verification.Identities[0] != test.expectedIdentity {
+				t.Errorf("expected signing key to have identity %q, got %v", test.expectedIdentity, verification.Identities)
+			}
+
+			data := []byte("checksums")
+			signature, err := DetachedSignature([]Signer{signer}, data)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := VerifyDetachedSignature(publicKey, data, signature); err != nil {
+				t.Errorf("expected detached signature to verify: %v", err)
+			}
+		})
+	}
+}
+
+func TestSigningWithMultipleKeys(t *testing.T) {
+	p256Key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the keys use different hashes, as an old RSA key and a new ECDSA key would
+	keys, err := ParseSigningKeys("ephemeral://old," + writePEMKey(t, t.TempDir(), p256Key))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signers, err := NewSigners(context.Background(), keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var publicKeys []PGPArmoredBlock
+	for _, signer := range signers {
+		publicKey, err := BootstrapPGP(signer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		publicKeys = append(publicKeys, publicKey)
+	}
+
+	keyring, err := Keyring(signers)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entities, err := openpgp.ReadArmoredKeyRing(strings.NewReader(string(keyring)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entities) != 2 {
+		t.Fatalf("expected keyring to hold 2 keys, got %d", len(entities))
+	}
+
+	const chartYAML = "apiVersion: v1\nname: cert-manager\nversion: v1.15.0\n"
+	chartPath := writeTestChart(t, t.TempDir(), "cert-manager-v1.15.0.tgz", chartYAML)
+
+	prov, err := HelmChart(signers, chartPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chartData, err := os.ReadFile(chartPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := []byte("checksums")
+	signature, err := DetachedSignature(signers, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// users who only have one of the keys must be able to verify everything
+	for i, publicKey := range publicKeys {
+		verification, err := VerifyHelmChart(chartPath, prov, publicKey)
+		if err != nil {
+			t.Fatalf("expected helm chart signature to verify with key %d: %v", i, err)
+		}
+
+		if verification.KeyID != entities[i].PrimaryKey.KeyIdString() {
+			t.Errorf("expected helm chart to be verified with key %s, got %s", entities[i].PrimaryKey.KeyIdString(), verification.KeyID)
+		}
+
+		signatory := &helmsign.Signatory{KeyRing: openpgp.EntityList{entities[i]}}
+		if _, err := signatory.Verify(chartData, prov, filepath.Base(chartPath)); err != nil {
+			t.Errorf("expected provenance file to be verified by helm with key %d: %v", i, err)
+		}
+
+		if err := VerifyDetachedSignature(publicKey, data, signature); err != nil {
+			t.Errorf("expected detached signature to verify with key %d: %v", i, err)
+		}
+	}
+
+	envelope, err := DSSE(signers, "application/vnd.in-toto+json", []byte("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(envelope.Signatures) != 2 {
+		t.Fatalf("expected a DSSE signature from each key, got %#v", envelope.Signatures)
+	}
+
+	for i, dsseSig := range envelope.Signatures {
+		if dsseSig.KeyID != signers[i].String() {
+			t.Errorf("expected signature %d to have key ID %q, got %q", i, signers[i], dsseSig.KeyID)
+		}
+
+		sig, err := base64.StdEncoding.DecodeString(dsseSig.Sig)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := VerifySignature(signers[i].Public(), dssePAE(envelope.PayloadType, []byte("{}")), sig); err != nil {
+			t.Errorf("expected DSSE signature %d to verify: %v", i, err)
+		}
+	}
+}
+
+func TestEphemeralSignersAreReused(t *testing.T) {
+	key, err := ParseSigningKey("ephemeral://reused")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := NewSigner(context.Background(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := NewSigner(context.Background(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !first.Public().(*rsa.PublicKey).Equal(second.Public()) {
+		t.Errorf("expected ephemeral keys with the same name to be the same key")
+	}
+}
+
+// pssSigner is a Signer for an RSA key which only makes RSA-PSS signatures, like an
+// RSA_SIGN_PSS_* KMS key
+type pssSigner struct {
+	*localSigner
+}
+
+func (s *pssSigner) PSS() bool {
+	return true
+}
+
+func TestPGPEntityRejectsPSSKeys(t *testing.T) {
+	key, err := ParseSigningKey("ephemeral://pss")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer, err := ephemeralSigner(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := pgpEntity(&pssSigner{signer}); err == nil {
+		t.Errorf("expected an error creating a PGP entity for an RSA-PSS key")
+	}
+}
@@ -0,0 +1,97 @@
+/*
+Copyright 2021 The cert-manager Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sign
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+)
+
+// ParsePublicKey parses a PEM-encoded PKIX public key, which is the format
+// used by both cosign and the KMS API.
+func ParsePublicKey(data []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return nil, fmt.Errorf("could not decode public key PEM")
+	}
+
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse public key: %w", err)
+	}
+
+	return pub, nil
+}
+
+// VerifySignature checks that sig is a signature over data made by the private
+// half of pub. The digest algorithm is the one KMS uses for keys of that type.
+// An RSA public key doesn't say which padding or digest its KMS algorithm uses, so
+// both PKCS #1 v1.5 and PSS signatures are accepted over either SHA256 or SHA512
+// digests; our RSA keys are usually RSA_SIGN_PKCS1_4096_SHA512 since Helm requires
+// SHA512 digests.
+func VerifySignature(pub crypto.PublicKey, data, sig []byte) error {
+	switch pub := pub.(type) {
+	case *rsa.PublicKey:
+		for _, hash := range []crypto.Hash{crypto.SHA512, crypto.SHA256} {
+			digest, err := hashData(hash, data)
+			if err != nil {
+				return err
+			}
+
+			if rsa.VerifyPKCS1v15(pub, hash, digest, sig) == nil || rsa.VerifyPSS(pub, hash, digest, sig, nil) == nil {
+				return nil
+			}
+		}
+
+		return fmt.Errorf("invalid signature")
+
+	case *ecdsa.PublicKey:
+		hash := crypto.SHA256
+		if pub.Curve == elliptic.P384() {
+			hash = crypto.SHA384
+		}
+
+		digest, err := hashData(hash, data)
+		if err != nil {
+			return err
+		}
+
+		if !ecdsa.VerifyASN1(pub, digest, sig) {
+			return fmt.Errorf("invalid signature")
+		}
+
+		return nil
+
+	default:
+		return fmt.Errorf("unsupported public key type %T", pub)
+	}
+}
+
+func hashData(hash crypto.Hash, data []byte) ([]byte, error) {
+	if !hash.Available() {
+		return nil, fmt.Errorf("hash function %s is not available", hash)
+	}
+
+	h := hash.New()
+	h.Write(data)
+	return h.Sum(nil), nil
+}